@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/thekubefleet/kubefleet/internal/grpcclient"
+	"github.com/thekubefleet/kubefleet/internal/k8s"
+	"github.com/thekubefleet/kubefleet/internal/podlogs"
+	agentpb "github.com/thekubefleet/kubefleet/proto"
+)
+
+const (
+	// minReportInterval is the shortest interval the server may set
+	minReportInterval = 5 * time.Second
+	// defaultFetchTailLines is used when a fetch logs command has no tail
+	defaultFetchTailLines = 100
+)
+
+// commandHandler runs commands the server sends over the stream. Interval
+// changes and collection requests are handed to the main loop. intervals
+// holds at most the latest change, so a busy main loop doesn't hold up
+// commands.
+type commandHandler struct {
+	k8sClient *k8s.Client
+	reporter  *grpcclient.DeltaReporter
+	intervals chan time.Duration
+	collect   chan<- struct{}
+}
+
+// setInterval queues an interval change, replacing one the main loop hasn't
+// picked up yet
+func (h *commandHandler) setInterval(interval time.Duration) {
+	for {
+		select {
+		case h.intervals <- interval:
+			return
+		default:
+		}
+		select {
+		case <-h.intervals:
+		default:
+		}
+	}
+}
+
+func (h *commandHandler) HandleCommand(ctx context.Context, cmd *agentpb.Command) *agentpb.CommandResult {
+	switch action := cmd.Action.(type) {
+	case *agentpb.Command_SetReportInterval:
+		interval := time.Duration(action.SetReportInterval.IntervalSeconds) * time.Second
+		if interval < minReportInterval {
+			return &agentpb.CommandResult{Message: fmt.Sprintf("interval must be at least %s", minReportInterval)}
+		}
+		h.setInterval(interval)
+		return &agentpb.CommandResult{Success: true, Message: fmt.Sprintf("report interval set to %s", interval)}
+
+	case *agentpb.Command_Resync:
+		h.reporter.RequestBaseline()
+		// A collection is already due if one is queued
+		select {
+		case h.collect <- struct{}{}:
+		default:
+		}
+		return &agentpb.CommandResult{Success: true, Message: "baseline requested"}
+
+	case *agentpb.Command_FetchLogs:
+		logs, err := h.fetchLogs(ctx, action.FetchLogs)
+		if err != nil {
+			return &agentpb.CommandResult{Message: err.Error()}
+		}
+		return &agentpb.CommandResult{Success: true, Logs: logs}
+
+	default:
+		return &agentpb.CommandResult{Message: "unsupported command"}
+	}
+}
+
+// TailLogs relays a pod's logs from this cluster to the server
+func (h *commandHandler) TailLogs(ctx context.Context, req *agentpb.LogRequest, send func(*agentpb.LogStream) error) error {
+	return podlogs.Tail(ctx, h.k8sClient, req, send)
+}
+
+// fetchLogs reads the tail of one container's logs, or of every container
+// in the pod when none is named
+func (h *commandHandler) fetchLogs(ctx context.Context, req *agentpb.LogRequest) ([]*agentpb.PodLog, error) {
+	tailLines := int64(req.TailLines)
+	if tailLines <= 0 {
+		tailLines = defaultFetchTailLines
+	}
+
+	containers := []string{req.ContainerName}
+	if req.ContainerName == "" {
+		var err error
+		containers, err = h.k8sClient.GetPodContainers(ctx, req.Namespace, req.PodName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get containers for pod %s: %w", req.PodName, err)
+		}
+	}
+
+	var logs []*agentpb.PodLog
+	for _, container := range containers {
+		lines, err := h.k8sClient.GetPodLogLines(ctx, req.Namespace, req.PodName, container, k8s.LogOptions{TailLines: tailLines, Previous: req.Previous})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get logs for pod %s container %s: %w", req.PodName, container, err)
+		}
+		logs = append(logs, grpcclient.ConvertLogLines(req.Namespace, req.PodName, container, lines, req.Previous)...)
+	}
+	return logs, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	agentpb "github.com/thekubefleet/kubefleet/proto"
+)
+
+func setIntervalCommand(seconds int64) *agentpb.Command {
+	return &agentpb.Command{Action: &agentpb.Command_SetReportInterval{SetReportInterval: &agentpb.SetReportInterval{IntervalSeconds: seconds}}}
+}
+
+func TestSetReportIntervalKeepsLatest(t *testing.T) {
+	intervals := make(chan time.Duration, 1)
+	h := &commandHandler{intervals: intervals}
+
+	// The main loop is busy, so the changes queue up without blocking
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, seconds := range []int64{10, 20, 30} {
+			if result := h.HandleCommand(context.Background(), setIntervalCommand(seconds)); !result.Success {
+				t.Errorf("setting %ds failed: %s", seconds, result.Message)
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("interval changes blocked the command handler")
+	}
+
+	if got := <-intervals; got != 30*time.Second {
+		t.Errorf("main loop got %s, want the latest interval of 30s", got)
+	}
+	select {
+	case got := <-intervals:
+		t.Errorf("main loop got a stale interval %s", got)
+	default:
+	}
+}
+
+func TestSetReportIntervalMinimum(t *testing.T) {
+	h := &commandHandler{intervals: make(chan time.Duration, 1)}
+	if result := h.HandleCommand(context.Background(), setIntervalCommand(1)); result.Success {
+		t.Errorf("an interval below %s was accepted", minReportInterval)
+	}
+}
@@ -2,18 +2,47 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"time"
 
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
+
+	"github.com/thekubefleet/kubefleet/internal/agentmetrics"
+	"github.com/thekubefleet/kubefleet/internal/auth"
 	"github.com/thekubefleet/kubefleet/internal/grpcclient"
 	"github.com/thekubefleet/kubefleet/internal/k8s"
 	"github.com/thekubefleet/kubefleet/internal/metrics"
+	"github.com/thekubefleet/kubefleet/internal/mtls"
+	"github.com/thekubefleet/kubefleet/internal/podlogs"
 	agentpb "github.com/thekubefleet/kubefleet/proto"
 )
 
+// version is set at build time with -ldflags "-X main.version=..."
+var version = "dev"
+
+const (
+	reportInterval = 30 * time.Second
+	// defaultHeartbeatInterval is used until the server tells us otherwise
+	defaultHeartbeatInterval = 10 * time.Second
+	// reportLogLines is how many of each container's last lines a report
+	// carries
+	reportLogLines = 50
+)
+
 func main() {
+	var tlsConfig mtls.Config
+	tlsConfig.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
 	fmt.Println("KubeFleet Agent starting...")
 
 	// Get server address from environment or use default
@@ -34,33 +63,192 @@ func main() {
 		log.Fatalf("Failed to create metrics collector: %v", err)
 	}
 
+	ctx := context.Background()
+
+	// Read cluster state from a watch-driven cache rather than listing it every report
+	if err := k8sClient.StartCache(ctx, 10*time.Minute); err != nil {
+		log.Fatalf("Failed to start Kubernetes cache: %v", err)
+	}
+
+	// Serve health checks and Prometheus metrics when a listen address is configured
+	recorder := agentmetrics.NewRecorder(reportInterval)
+	if httpAddr := os.Getenv("KUBEFLEET_AGENT_HTTP_ADDR"); httpAddr != "" {
+		go func() {
+			log.Printf("Agent HTTP server listening on %s", httpAddr)
+			if err := http.ListenAndServe(httpAddr, recorder.Handler()); err != nil {
+				log.Fatalf("Failed to serve agent HTTP: %v", err)
+			}
+		}()
+	}
+
+	// Ping the server so a dead connection under a long-lived stream is noticed
+	dialOpts := []grpc.DialOption{grpc.WithKeepaliveParams(keepalive.ClientParameters{
+		Time:    30 * time.Second,
+		Timeout: 10 * time.Second,
+	})}
+
+	// Use mutual TLS when certificates are configured
+	if tlsConfig.Enabled() {
+		reloader, err := mtls.NewReloader(tlsConfig)
+		if err != nil {
+			log.Fatalf("Failed to load TLS certificates: %v", err)
+		}
+		go reloader.Watch(ctx, 30*time.Second)
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewTLS(reloader.ClientTLSConfig())))
+	}
+
+	// Authenticate with a bearer token when one is configured
+	if tokenFile := os.Getenv("KUBEFLEET_AGENT_TOKEN_FILE"); tokenFile != "" {
+		tokenCreds, err := auth.NewTokenCredentials(tokenFile)
+		if err != nil {
+			log.Fatalf("Failed to load agent token: %v", err)
+		}
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(tokenCreds))
+	}
+
 	// Initialize gRPC client
-	grpcClient, err := grpcclient.NewClient(serverAddr)
+	grpcClient, err := grpcclient.NewClient(serverAddr, dialOpts...)
 	if err != nil {
 		log.Fatalf("Failed to create gRPC client: %v", err)
 	}
 	defer grpcClient.Close()
 
+	// Identify this agent and the cluster it runs in
+	identity, err := buildIdentity(ctx, k8sClient)
+	if err != nil {
+		log.Fatalf("Failed to determine cluster identity: %v", err)
+	}
+	log.Printf("Reporting as cluster %q (uid %s), agent %s", identity.ClusterName, identity.ClusterUid, identity.AgentId)
+
+	// Register with the server; on failure the heartbeat loop retries
+	heartbeatInterval, err := grpcClient.RegisterAgent(ctx, identity, reportInterval)
+	if err != nil {
+		log.Printf("Failed to register agent: %v", err)
+		heartbeatInterval = defaultHeartbeatInterval
+	}
+	if heartbeatInterval <= 0 {
+		heartbeatInterval = defaultHeartbeatInterval
+	}
+	go runHeartbeat(ctx, grpcClient, identity, heartbeatInterval, err == nil)
+
+	// Send a baseline first, then only what changed
+	reporter, err := grpcclient.NewDeltaReporter(grpcClient)
+	if err != nil {
+		log.Fatalf("Failed to create reporter: %v", err)
+	}
+
+	// Keep a stream open to the server for reports and its commands; unary
+	// calls are used while it is down or if the server doesn't support it
+	intervals := make(chan time.Duration, 1)
+	collectNow := make(chan struct{}, 1)
+	stream := grpcclient.NewStream(grpcClient, identity, reportInterval, &commandHandler{
+		k8sClient: k8sClient,
+		reporter:  reporter,
+		intervals: intervals,
+		collect:   collectNow,
+	})
+	reporter.UseStream(stream)
+	go stream.Run(ctx)
+
+	// Each report carries the last lines of every container, but only the
+	// lines written since the previous report are read
+	logCollector := podlogs.NewCollector(k8sClient, reportLogLines)
+
 	// Main loop
-	ticker := time.NewTicker(30 * time.Second) // Report every 30 seconds
+	ticker := time.NewTicker(reportInterval)
 	defer ticker.Stop()
 
-	ctx := context.Background()
+	for {
+		select {
+		case interval := <-intervals:
+			log.Printf("Report interval changed to %s by the server", interval)
+			ticker.Reset(interval)
+			stream.SetReportInterval(interval)
+			recorder.SetInterval(interval)
+			continue
+		case <-ticker.C:
+		case <-collectNow:
+		}
+
+		err := collectAndReport(ctx, k8sClient, metricsCollector, logCollector, reporter, identity, recorder)
+		if err != nil {
+			log.Printf("Error collecting and reporting data: %v", err)
+			if err := stream.SendEvent("CollectionFailed", err.Error()); err != nil && !errors.Is(err, grpcclient.ErrStreamNotConnected) {
+				log.Printf("Failed to send event: %v", err)
+			}
+		}
+		recorder.ObserveReport(err)
+	}
+}
+
+// buildIdentity describes this agent and its cluster. The cluster name comes
+// from KUBEFLEET_CLUSTER_NAME and falls back to the cluster UID.
+func buildIdentity(ctx context.Context, k8sClient *k8s.Client) (*agentpb.AgentIdentity, error) {
+	clusterUID, err := k8sClient.GetClusterUID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	clusterName := os.Getenv("KUBEFLEET_CLUSTER_NAME")
+	if clusterName == "" {
+		clusterName = clusterUID
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hostname: %w", err)
+	}
+
+	agentID := os.Getenv("KUBEFLEET_AGENT_ID")
+	if agentID == "" {
+		agentID = hostname
+	}
+
+	return &agentpb.AgentIdentity{
+		ClusterName:  clusterName,
+		ClusterUid:   clusterUID,
+		AgentId:      agentID,
+		AgentVersion: version,
+		Hostname:     hostname,
+	}, nil
+}
+
+// runHeartbeat sends heartbeats until ctx is done, registering again whenever
+// the server doesn't know about this agent
+func runHeartbeat(ctx context.Context, grpcClient *grpcclient.Client, identity *agentpb.AgentIdentity, interval time.Duration, registered bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
 	for {
 		select {
+		case <-ctx.Done():
+			return
 		case <-ticker.C:
-			if err := collectAndReport(ctx, k8sClient, metricsCollector, grpcClient); err != nil {
-				log.Printf("Error collecting and reporting data: %v", err)
+			if !registered {
+				if _, err := grpcClient.RegisterAgent(ctx, identity, reportInterval); err != nil {
+					log.Printf("Failed to register agent: %v", err)
+					continue
+				}
+				registered = true
+			}
+
+			if err := grpcClient.SendHeartbeat(ctx, identity); err != nil {
+				log.Printf("Failed to send heartbeat: %v", err)
+				if status.Code(err) == codes.NotFound {
+					registered = false
+				}
 			}
 		}
 	}
 }
 
-func collectAndReport(ctx context.Context, k8sClient *k8s.Client, metricsCollector *metrics.Collector, grpcClient *grpcclient.Client) error {
+func collectAndReport(ctx context.Context, k8sClient *k8s.Client, metricsCollector *metrics.Collector, logCollector *podlogs.Collector, reporter *grpcclient.DeltaReporter, identity *agentpb.AgentIdentity, recorder *agentmetrics.Recorder) error {
 	// Get all namespaces
+	start := time.Now()
 	namespaces, err := k8sClient.GetNamespaces(ctx)
+	recorder.ObservePhase(agentmetrics.PhaseNamespaces, start)
 	if err != nil {
+		recorder.ObserveError(agentmetrics.PhaseNamespaces)
 		return fmt.Errorf("failed to get namespaces: %w", err)
 	}
 
@@ -68,15 +256,23 @@ func collectAndReport(ctx context.Context, k8sClient *k8s.Client, metricsCollect
 	var resourceInfos []*agentpb.ResourceInfo
 	var allLogs []*agentpb.PodLog
 
+	// Pods and logs are collected namespace by namespace, so their phase
+	// durations are summed across the loop
+	var podsDuration, logsDuration time.Duration
 	for _, namespace := range namespaces {
+		start = time.Now()
 		pods, err := k8sClient.GetPodsInNamespace(ctx, namespace)
 		if err != nil {
+			podsDuration += time.Since(start)
+			recorder.ObserveError(agentmetrics.PhasePods)
 			log.Printf("Failed to get pods in namespace %s: %v", namespace, err)
 			continue
 		}
 
 		deployments, err := k8sClient.GetDeploymentsInNamespace(ctx, namespace)
+		podsDuration += time.Since(start)
 		if err != nil {
+			recorder.ObserveError(agentmetrics.PhasePods)
 			log.Printf("Failed to get deployments in namespace %s: %v", namespace, err)
 			continue
 		}
@@ -85,48 +281,89 @@ func collectAndReport(ctx context.Context, k8sClient *k8s.Client, metricsCollect
 		resourceInfos = append(resourceInfos, resourceInfo)
 
 		// Collect logs from pods in this namespace
-		for _, podName := range pods {
+		start = time.Now()
+		for _, pod := range pods {
+			podName := pod.Name
 			containers, err := k8sClient.GetPodContainers(ctx, namespace, podName)
 			if err != nil {
+				recorder.ObserveError(agentmetrics.PhaseLogs)
 				log.Printf("Failed to get containers for pod %s: %v", podName, err)
 				continue
 			}
 
 			for _, containerName := range containers {
-				logLines, err := k8sClient.GetPodLogs(ctx, namespace, podName, containerName, 50, false) // Get last 50 lines
+				podLogs, err := logCollector.Collect(ctx, pod, containerName, false)
 				if err != nil {
+					recorder.ObserveError(agentmetrics.PhaseLogs)
 					log.Printf("Failed to get logs for pod %s container %s: %v", podName, containerName, err)
-					continue
 				}
+				allLogs = append(allLogs, podLogs...)
+			}
 
-				podLogs := grpcclient.ConvertPodLogs(namespace, podName, containerName, logLines)
+			// Include the end of a crashed container's previous instance,
+			// which usually shows why it crashed
+			crashed, err := k8sClient.GetCrashedContainers(ctx, namespace, podName)
+			if err != nil {
+				recorder.ObserveError(agentmetrics.PhaseLogs)
+				log.Printf("Failed to get container statuses for pod %s: %v", podName, err)
+				continue
+			}
+			for _, containerName := range crashed {
+				podLogs, err := logCollector.Collect(ctx, pod, containerName, true)
+				if err != nil {
+					recorder.ObserveError(agentmetrics.PhaseLogs)
+					log.Printf("Failed to get previous logs for pod %s container %s: %v", podName, containerName, err)
+				}
 				allLogs = append(allLogs, podLogs...)
 			}
 		}
+		logsDuration += time.Since(start)
 	}
+	logCollector.Prune()
+	recorder.ObservePhaseDuration(agentmetrics.PhasePods, podsDuration)
+	recorder.ObservePhaseDuration(agentmetrics.PhaseLogs, logsDuration)
 
 	// Collect metrics
+	start = time.Now()
 	metricsData, err := metricsCollector.CollectAllMetrics(ctx, namespaces)
+	recorder.ObservePhase(agentmetrics.PhaseMetrics, start)
 	if err != nil {
+		recorder.ObserveError(agentmetrics.PhaseMetrics)
 		return fmt.Errorf("failed to collect metrics: %w", err)
 	}
 
 	// Convert metrics to protobuf format
 	protoMetrics := grpcclient.ConvertResourceMetrics(metricsData)
 
+	// Collect events; a report without them is still worth sending
+	start = time.Now()
+	events, err := k8sClient.GetEvents(ctx)
+	recorder.ObservePhase(agentmetrics.PhaseEvents, start)
+	if err != nil {
+		recorder.ObserveError(agentmetrics.PhaseEvents)
+		log.Printf("Failed to get events: %v", err)
+	}
+	protoEvents := grpcclient.ConvertEvents(events)
+
 	// Create agent data
 	agentData := &agentpb.AgentData{
 		Resources: resourceInfos,
 		Metrics:   protoMetrics,
 		Logs:      allLogs,
 		Timestamp: time.Now().Unix(),
+		Identity:  identity,
+		Events:    protoEvents,
 	}
-
-	// Send data via gRPC
-	if err := grpcClient.SendAgentData(ctx, agentData); err != nil {
+	// Send the changes since the last report via gRPC
+	start = time.Now()
+	sent, err := reporter.Report(ctx, agentData)
+	recorder.ObservePhase(agentmetrics.PhaseSend, start)
+	recorder.ObservePayload(sent)
+	if err != nil {
+		recorder.ObserveError(agentmetrics.PhaseSend)
 		return fmt.Errorf("failed to send agent data: %w", err)
 	}
 
-	fmt.Printf("Successfully reported data for %d namespaces with %d metrics and %d log entries\n", len(namespaces), len(protoMetrics), len(allLogs))
+	fmt.Printf("Successfully reported data for %d namespaces with %d metrics, %d log entries and %d events\n", len(namespaces), len(protoMetrics), len(allLogs), len(protoEvents))
 	return nil
 }
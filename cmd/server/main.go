@@ -2,174 +2,225 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 
+	"github.com/thekubefleet/kubefleet/internal/alerting"
+	"github.com/thekubefleet/kubefleet/internal/auth"
+	"github.com/thekubefleet/kubefleet/internal/config"
+	"github.com/thekubefleet/kubefleet/internal/eventstore"
 	"github.com/thekubefleet/kubefleet/internal/k8s"
+	"github.com/thekubefleet/kubefleet/internal/logstore"
+	"github.com/thekubefleet/kubefleet/internal/mtls"
+	"github.com/thekubefleet/kubefleet/internal/notify"
 	"github.com/thekubefleet/kubefleet/internal/server"
+	"github.com/thekubefleet/kubefleet/internal/timeseries"
 	agentpb "github.com/thekubefleet/kubefleet/proto"
 )
 
 type grpcServer struct {
 	agentpb.UnimplementedAgentReporterServer
-	dataStore *server.DataStore
-	k8sClient *k8s.Client
+	dataStore server.DataStore
+	views     *server.DeltaViews
+	streams   *server.AgentStreams
+	series    *timeseries.Store
+	logs      *logstore.Store
+	events    *eventstore.Store
+	alerts    *alerting.Engine // nil when alerting is disabled
+	registry  *server.Registry
+	metrics   *server.Metrics
 	mu        sync.RWMutex
 }
 
-func (s *grpcServer) ReportData(ctx context.Context, data *agentpb.AgentData) (*agentpb.ReportResponse, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+func (s *grpcServer) RegisterAgent(ctx context.Context, req *agentpb.RegisterRequest) (*agentpb.RegisterResponse, error) {
+	if req.Identity == nil {
+		return nil, status.Error(codes.InvalidArgument, "identity is required")
+	}
+	if err := auth.CheckCluster(ctx, server.ClusterKey(req.Identity)); err != nil {
+		return nil, err
+	}
 
-	// Store the received data
-	s.dataStore.StoreAgentData(data)
+	s.registry.Register(req.Identity)
 
-	log.Printf("Received data from agent: %d resources, %d metrics, %d logs", len(data.Resources), len(data.Metrics), len(data.Logs))
+	log.Printf("Registered agent %s for cluster %s (version %s)", req.Identity.AgentId, server.ClusterKey(req.Identity), req.Identity.AgentVersion)
 
-	return &agentpb.ReportResponse{
-		Success: true,
-		Message: "Data received successfully",
+	return &agentpb.RegisterResponse{
+		Success:                  true,
+		Message:                  "Agent registered successfully",
+		HeartbeatIntervalSeconds: int64(s.registry.HeartbeatInterval() / time.Second),
 	}, nil
 }
 
-func (s *grpcServer) StreamPodLogs(req *agentpb.LogRequest, stream agentpb.AgentReporter_StreamPodLogsServer) error {
-	ctx := stream.Context()
-
-	// Get containers for the pod
-	containers, err := s.k8sClient.GetPodContainers(ctx, req.Namespace, req.PodName)
-	if err != nil {
-		return err
+func (s *grpcServer) Heartbeat(ctx context.Context, req *agentpb.HeartbeatRequest) (*agentpb.HeartbeatResponse, error) {
+	if req.Identity == nil {
+		return nil, status.Error(codes.InvalidArgument, "identity is required")
+	}
+	if err := auth.CheckCluster(ctx, server.ClusterKey(req.Identity)); err != nil {
+		return nil, err
 	}
 
-	// If no specific container requested, get logs from all containers
-	if req.ContainerName == "" {
-		for _, containerName := range containers {
-			if err := s.streamContainerLogs(ctx, req, containerName, stream); err != nil {
-				log.Printf("Error streaming logs for container %s: %v", containerName, err)
-			}
-		}
-	} else {
-		// Stream logs for specific container
-		if err := s.streamContainerLogs(ctx, req, req.ContainerName, stream); err != nil {
-			return err
-		}
+	if err := s.registry.Heartbeat(req.Identity); err != nil {
+		// Tell the agent to register again, e.g. after a server restart
+		return nil, status.Error(codes.NotFound, err.Error())
 	}
 
-	// Send completion signal
-	return stream.Send(&agentpb.LogStream{
-		Logs:       []*agentpb.PodLog{},
-		IsComplete: true,
-	})
+	return &agentpb.HeartbeatResponse{
+		Success: true,
+		Message: "Heartbeat received",
+	}, nil
 }
 
-func (s *grpcServer) streamContainerLogs(ctx context.Context, req *agentpb.LogRequest, containerName string, stream agentpb.AgentReporter_StreamPodLogsServer) error {
-	// Get initial logs
-	logLines, err := s.k8sClient.GetPodLogs(ctx, req.Namespace, req.PodName, containerName, int64(req.TailLines), false)
-	if err != nil {
-		return err
+// ReportData accepts a full snapshot, as sent by agents that predate delta
+// reports
+func (s *grpcServer) ReportData(ctx context.Context, data *agentpb.AgentData) (*agentpb.ReportResponse, error) {
+	if err := auth.CheckCluster(ctx, server.ClusterKey(data.Identity)); err != nil {
+		return nil, err
 	}
 
-	// Convert and send initial logs
-	podLogs := convertLogsToProto(req.Namespace, req.PodName, containerName, logLines)
-	if len(podLogs) > 0 {
-		if err := stream.Send(&agentpb.LogStream{
-			Logs:       podLogs,
-			IsComplete: false,
-		}); err != nil {
-			return err
-		}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	logCount, eventCount := len(data.Logs), len(data.Events)
+	if err := s.storeData(data, proto.Size(data)); err != nil {
+		return nil, err
 	}
 
-	// If follow is requested, continue streaming
-	if req.Follow {
-		lastLogTime := time.Now()
-		ticker := time.NewTicker(5 * time.Second) // Check for new logs every 5 seconds
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case <-ticker.C:
-				// Get logs since last check
-				newLogLines, err := s.k8sClient.GetPodLogsSince(ctx, req.Namespace, req.PodName, containerName, lastLogTime)
-				if err != nil {
-					log.Printf("Error getting new logs: %v", err)
-					continue
-				}
-
-				if len(newLogLines) > 0 {
-					newPodLogs := convertLogsToProto(req.Namespace, req.PodName, containerName, newLogLines)
-					if err := stream.Send(&agentpb.LogStream{
-						Logs:       newPodLogs,
-						IsComplete: false,
-					}); err != nil {
-						return err
-					}
-					lastLogTime = time.Now()
-				}
-			}
-		}
+	log.Printf("Received data from cluster %s: %d resources, %d metrics, %d logs, %d events", server.ClusterKey(data.Identity), len(data.Resources), len(data.Metrics), logCount, eventCount)
+
+	return &agentpb.ReportResponse{
+		Success: true,
+		Message: "Data received successfully",
+	}, nil
+}
+
+// ReportDelta applies an incremental report to the cluster's view and
+// stores the resulting snapshot
+func (s *grpcServer) ReportDelta(ctx context.Context, report *agentpb.DeltaReport) (*agentpb.DeltaResponse, error) {
+	if err := auth.CheckCluster(ctx, server.ClusterKey(report.Identity)); err != nil {
+		return nil, err
 	}
+	return s.applyDelta(report)
+}
 
-	return nil
+// Connect serves an agent's long-lived stream: reports sent on it are
+// handled like ReportDelta, and commands from the API are passed down it
+func (s *grpcServer) Connect(stream agentpb.AgentReporter_ConnectServer) error {
+	return s.streams.Serve(stream, s.applyDelta)
 }
 
-func convertLogsToProto(namespace, podName, containerName string, logLines []string) []*agentpb.PodLog {
-	var podLogs []*agentpb.PodLog
-	now := time.Now().Unix()
+func (s *grpcServer) applyDelta(report *agentpb.DeltaReport) (*agentpb.DeltaResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	for _, line := range logLines {
-		// Split log lines (they might contain multiple lines)
-		lines := strings.Split(strings.TrimSpace(line), "\n")
-		for _, logLine := range lines {
-			if logLine == "" {
-				continue
-			}
+	cluster := server.ClusterKey(report.Identity)
+	data, err := s.views.Apply(report)
+	var resync *server.ResyncError
+	if errors.As(err, &resync) {
+		log.Printf("Requesting a baseline from cluster %s: %s", cluster, resync.Reason)
+		return &agentpb.DeltaResponse{
+			Success:        false,
+			Message:        resync.Error(),
+			ResyncRequired: true,
+		}, nil
+	}
+	if err != nil {
+		// Newer agents fall back to ReportData when told the protocol is unsupported
+		return nil, status.Error(codes.Unimplemented, err.Error())
+	}
 
-			protoLog := &agentpb.PodLog{
-				Namespace:     namespace,
-				PodName:       podName,
-				ContainerName: containerName,
-				LogLine:       logLine,
-				Timestamp:     now,
-				Level:         parseLogLevel(logLine),
-			}
-			podLogs = append(podLogs, protoLog)
-		}
+	if err := s.storeData(data, proto.Size(report)); err != nil {
+		return nil, err
 	}
 
-	return podLogs
-}
+	kind := "delta"
+	if report.Baseline {
+		kind = "baseline"
+	}
+	log.Printf("Received %s %d from cluster %s: %d resource changes, %d metrics, %d logs, %d events", kind, report.Sequence, cluster, len(report.Resources)+len(report.ResourceDeltas), len(report.Metrics), len(report.Logs), len(report.Events))
 
-func parseLogLevel(logLine string) string {
-	line := strings.ToUpper(strings.TrimSpace(logLine))
+	return &agentpb.DeltaResponse{
+		Success:  true,
+		Message:  "Data received successfully",
+		Sequence: report.Sequence,
+	}, nil
+}
 
-	if strings.Contains(line, "ERROR") || strings.Contains(line, "FATAL") {
-		return "ERROR"
+// storeData records a snapshot received in a report of the given size. Its
+// logs go to the log store, which keeps each line once, and its events to
+// the event store rather than being repeated by every snapshot. Callers
+// must hold s.mu.
+func (s *grpcServer) storeData(data *agentpb.AgentData, size int) error {
+	cluster := server.ClusterKey(data.Identity)
+	lines, err := s.logs.Append(cluster, data.Logs)
+	if err != nil {
+		log.Printf("Failed to store logs from cluster %s: %v", cluster, err)
+	}
+	if s.alerts != nil {
+		s.alerts.ObserveLogs(cluster, lines)
+		s.alerts.ObserveMetrics(cluster, data.Timestamp, data.Metrics)
 	}
-	if strings.Contains(line, "WARN") {
-		return "WARN"
+	if err := s.events.Append(cluster, data.Events); err != nil {
+		log.Printf("Failed to store events from cluster %s: %v", cluster, err)
 	}
-	if strings.Contains(line, "DEBUG") {
-		return "DEBUG"
+	data.Logs = nil
+	data.Events = nil
+	if err := s.dataStore.StoreAgentData(data); err != nil {
+		log.Printf("Failed to store data from cluster %s: %v", cluster, err)
+		return status.Error(codes.Internal, "failed to store data")
 	}
-	if strings.Contains(line, "INFO") {
-		return "INFO"
+	s.metrics.ObserveReport(cluster, size)
+	if err := s.series.IngestMetrics(cluster, data.Timestamp, data.Metrics); err != nil {
+		log.Printf("Failed to store metric series from cluster %s: %v", cluster, err)
+	}
+	s.registry.Observe(data.Identity)
+	return nil
+}
+
+// StreamPodLogs streams a pod's logs, relayed through the agent of the
+// cluster the request names. Callers only reach the clusters they are bound
+// to; the cluster the server runs in isn't exposed since agent credentials
+// shouldn't read it.
+func (s *grpcServer) StreamPodLogs(req *agentpb.LogRequest, stream agentpb.AgentReporter_StreamPodLogsServer) error {
+	ctx := stream.Context()
+	if req.Cluster == "" {
+		return status.Error(codes.InvalidArgument, "cluster is required")
+	}
+	if !auth.ScopeFromContext(ctx).Allows(req.Cluster, req.Namespace) {
+		return status.Errorf(codes.PermissionDenied, "caller may not read logs of %s/%s", req.Cluster, req.Namespace)
 	}
 
-	return "INFO" // Default level
+	err := s.streams.StreamLogs(ctx, req.Cluster, req, stream.Send)
+	switch {
+	case errors.Is(err, server.ErrAgentNotConnected):
+		return status.Errorf(codes.Unavailable, "agent for cluster %s is not connected", req.Cluster)
+	case errors.Is(err, server.ErrAgentBusy), errors.Is(err, server.ErrLogStreamBehind):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	return err
 }
 
 func main() {
+	var tlsConfig mtls.Config
+	tlsConfig.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
 	// Get port from environment or use default
 	grpcPort := os.Getenv("GRPC_PORT")
 	if grpcPort == "" {
@@ -187,14 +238,171 @@ func main() {
 		log.Fatalf("Failed to create Kubernetes client: %v", err)
 	}
 
-	// Initialize data store
-	dataStore := server.NewDataStore()
+	// Initialize data store, on disk when a path is configured
+	dataStore, err := newDataStore()
+	if err != nil {
+		log.Fatalf("Failed to create data store: %v", err)
+	}
+	defer dataStore.Close()
+
+	// Keep metric history in rollup tiers, completed from stored data on
+	// startup
+	seriesRetention := timeseries.Retention{
+		Raw:        getEnvDuration("KUBEFLEET_SERIES_RETENTION_RAW", 24*time.Hour),
+		FiveMinute: getEnvDuration("KUBEFLEET_SERIES_RETENTION_5M", 7*24*time.Hour),
+		Hour:       getEnvDuration("KUBEFLEET_SERIES_RETENTION_1H", 90*24*time.Hour),
+	}
+	series, err := newSeriesStore(seriesRetention)
+	if err != nil {
+		log.Fatalf("Failed to create series store: %v", err)
+	}
+	defer series.Close()
+	backfillSince := time.Unix(0, 0)
+	if seriesRetention.Hour > 0 {
+		backfillSince = time.Now().Add(-seriesRetention.Hour)
+	}
+	server.BackfillSeries(dataStore, series, backfillSince)
+	go series.Run(context.Background(), time.Minute)
+
+	// Keep each log line once, picking up the logs of snapshots stored
+	// before logs had their own store
+	logRetention := logstore.Retention{
+		MaxAge:   getEnvDuration("KUBEFLEET_LOG_RETENTION_MAX_AGE", 24*time.Hour),
+		MaxBytes: int64(getEnvInt("KUBEFLEET_LOG_RETENTION_MAX_BYTES", 256<<20)),
+	}
+	logs, err := newLogStore(logRetention)
+	if err != nil {
+		log.Fatalf("Failed to create log store: %v", err)
+	}
+	defer logs.Close()
+	backfillSince = time.Unix(0, 0)
+	if logRetention.MaxAge > 0 {
+		backfillSince = time.Now().Add(-logRetention.MaxAge)
+	}
+	server.BackfillLogs(dataStore, logs, backfillSince)
+	go logs.Run(context.Background(), time.Minute)
+
+	// Keep the latest version of each Kubernetes event
+	events, err := newEventStore(eventstore.Retention{
+		MaxAge:    getEnvDuration("KUBEFLEET_EVENT_RETENTION_MAX_AGE", 7*24*time.Hour),
+		MaxEvents: getEnvInt("KUBEFLEET_EVENT_RETENTION_MAX_EVENTS", 100000),
+	})
+	if err != nil {
+		log.Fatalf("Failed to create event store: %v", err)
+	}
+	defer events.Close()
+	go events.Run(context.Background(), time.Minute)
+
+	// Evaluate incoming log lines and metrics against alert rules when a
+	// rule file is configured
+	var alerts *alerting.Engine
+	if rulesFile := os.Getenv("KUBEFLEET_ALERT_RULES_FILE"); rulesFile != "" {
+		history, err := newAlertHistory(getEnvDuration("KUBEFLEET_ALERT_HISTORY_MAX_AGE", 30*24*time.Hour))
+		if err != nil {
+			log.Fatalf("Failed to open alert history: %v", err)
+		}
+		defer history.Close()
+		go history.Run(context.Background(), time.Hour)
+
+		engineOpts := []alerting.EngineOption{alerting.WithHistory(history)}
+
+		// Send alerts that fire or resolve to the receivers of a
+		// notification config when one is configured
+		if notifyFile := os.Getenv("KUBEFLEET_NOTIFY_CONFIG_FILE"); notifyFile != "" {
+			notifier, err := notify.NewNotifier(context.Background(), config.FileLoader(notifyFile))
+			if err != nil {
+				log.Fatalf("Failed to load notification config: %v", err)
+			}
+			go notifier.Watch(context.Background(), 30*time.Second)
+			go notifier.Run(context.Background())
+			engineOpts = append(engineOpts, alerting.WithNotifier(notifier))
+			log.Printf("Alert notifications enabled with config from %s", notifyFile)
+		}
+
+		alerts, err = alerting.NewEngine(context.Background(), config.FileLoader(rulesFile), engineOpts...)
+		if err != nil {
+			log.Fatalf("Failed to load alert rules: %v", err)
+		}
+		go alerts.Watch(context.Background(), 30*time.Second)
+		go alerts.Run(context.Background(), 15*time.Second)
+		log.Printf("Alerting enabled with rules from %s", rulesFile)
+	}
+
+	// Initialize agent registry
+	heartbeatInterval := getEnvInt("KUBEFLEET_HEARTBEAT_INTERVAL", 10)
+	if heartbeatInterval == 0 {
+		log.Fatalf("KUBEFLEET_HEARTBEAT_INTERVAL must be at least 1")
+	}
+	registry := server.NewRegistry(
+		time.Duration(heartbeatInterval)*time.Second,
+		getEnvInt("KUBEFLEET_STALE_AFTER_MISSED", 3),
+		getEnvInt("KUBEFLEET_OFFLINE_AFTER_MISSED", 6),
+	)
+
+	// Count gRPC errors, including calls rejected by authentication
+	metrics := server.NewMetrics(dataStore, registry)
+	grpcOpts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(metrics.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(metrics.StreamServerInterceptor()),
+		// Agents ping their Connect streams to detect dead connections
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             20 * time.Second,
+			PermitWithoutStream: true,
+		}),
+	}
+
+	// Require mutual TLS from agents when certificates are configured
+	if tlsConfig.Enabled() {
+		reloader, err := mtls.NewReloader(tlsConfig)
+		if err != nil {
+			log.Fatalf("Failed to load TLS certificates: %v", err)
+		}
+		go reloader.Watch(context.Background(), 30*time.Second)
+		grpcOpts = append(grpcOpts, grpc.Creds(credentials.NewTLS(reloader.ServerTLSConfig())))
+		log.Printf("Mutual TLS enabled for gRPC, %d allowed client names", len(tlsConfig.AllowedClients))
+	}
+
+	// Require agent bearer tokens when a token source is configured
+	agentTokens, err := newTokenStore(k8sClient, "KUBEFLEET_AGENT_TOKENS_FILE", "KUBEFLEET_AGENT_TOKENS_SECRET")
+	if err != nil {
+		log.Fatalf("Failed to load agent tokens: %v", err)
+	}
+	if agentTokens != nil {
+		grpcOpts = append(grpcOpts,
+			grpc.ChainUnaryInterceptor(auth.UnaryServerInterceptor(agentTokens)),
+			grpc.ChainStreamInterceptor(auth.StreamServerInterceptor(agentTokens)),
+		)
+		log.Printf("Token authentication enabled for gRPC")
+	}
+
+	// Bind each agent token subject or certificate CN to the clusters it
+	// may report for when an agent policy is configured
+	if policyFile := os.Getenv("KUBEFLEET_AGENT_POLICY_FILE"); policyFile != "" {
+		agentPolicy, err := auth.NewAuthorizer(context.Background(), config.FileLoader(policyFile))
+		if err != nil {
+			log.Fatalf("Failed to load agent policy: %v", err)
+		}
+		go agentPolicy.Watch(context.Background(), 30*time.Second)
+		grpcOpts = append(grpcOpts,
+			grpc.ChainUnaryInterceptor(auth.UnaryClusterInterceptor(agentPolicy)),
+			grpc.ChainStreamInterceptor(auth.StreamClusterInterceptor(agentPolicy)),
+		)
+		log.Printf("Agents bound to clusters by policy from %s", policyFile)
+	}
 
 	// Create gRPC server
-	grpcSrv := grpc.NewServer()
+	streams := server.NewAgentStreams(registry)
+	grpcSrv := grpc.NewServer(grpcOpts...)
 	agentpb.RegisterAgentReporterServer(grpcSrv, &grpcServer{
 		dataStore: dataStore,
-		k8sClient: k8sClient,
+		views:     server.NewDeltaViews(),
+		streams:   streams,
+		series:    series,
+		logs:      logs,
+		events:    events,
+		alerts:    alerts,
+		registry:  registry,
+		metrics:   metrics,
 	})
 
 	// Enable reflection for debugging
@@ -212,8 +420,53 @@ func main() {
 		}
 	}()
 
+	// Require API bearer tokens when a token source is configured
+	httpOpts := []server.HTTPServerOption{server.WithRegistry(registry), server.WithMetricsStore(series), server.WithLogStore(logs), server.WithEventStore(events), server.WithMetrics(metrics), server.WithAgentStreams(streams)}
+	apiTokens, err := newTokenStore(k8sClient, "KUBEFLEET_API_TOKENS_FILE", "KUBEFLEET_API_TOKENS_SECRET")
+	if err != nil {
+		log.Fatalf("Failed to load API tokens: %v", err)
+	}
+	if apiTokens != nil {
+		httpOpts = append(httpOpts, server.WithAuthenticator(apiTokens))
+		log.Printf("Token authentication enabled for the HTTP API")
+	}
+
+	// Log dashboard users in through OIDC when an issuer is configured
+	if issuer := os.Getenv("KUBEFLEET_OIDC_ISSUER_URL"); issuer != "" {
+		oidc, err := auth.NewOIDC(context.Background(), auth.OIDCConfig{
+			IssuerURL:     issuer,
+			ClientID:      os.Getenv("KUBEFLEET_OIDC_CLIENT_ID"),
+			ClientSecret:  os.Getenv("KUBEFLEET_OIDC_CLIENT_SECRET"),
+			RedirectURL:   os.Getenv("KUBEFLEET_OIDC_REDIRECT_URL"),
+			Scopes:        splitList(os.Getenv("KUBEFLEET_OIDC_SCOPES")),
+			UsernameClaim: os.Getenv("KUBEFLEET_OIDC_USERNAME_CLAIM"),
+			GroupsClaim:   os.Getenv("KUBEFLEET_OIDC_GROUPS_CLAIM"),
+			SessionTTL:    time.Duration(getEnvInt("KUBEFLEET_SESSION_TTL_MINUTES", 480)) * time.Minute,
+		})
+		if err != nil {
+			log.Fatalf("Failed to configure OIDC: %v", err)
+		}
+		httpOpts = append(httpOpts, server.WithOIDC(oidc))
+		log.Printf("OIDC login enabled with issuer %s", issuer)
+	}
+
+	// Restrict callers to their clusters and namespaces when a policy is configured
+	if policyFile := os.Getenv("KUBEFLEET_AUTHZ_POLICY_FILE"); policyFile != "" {
+		authorizer, err := auth.NewAuthorizer(context.Background(), config.FileLoader(policyFile))
+		if err != nil {
+			log.Fatalf("Failed to load authorization policy: %v", err)
+		}
+		go authorizer.Watch(context.Background(), 30*time.Second)
+		httpOpts = append(httpOpts, server.WithAuthorizer(authorizer))
+		log.Printf("Namespace authorization enabled from %s", policyFile)
+	}
+
+	if alerts != nil {
+		httpOpts = append(httpOpts, server.WithAlerts(alerts))
+	}
+
 	// Create HTTP server for the dashboard
-	httpServer := server.NewHTTPServer(dataStore)
+	httpServer := server.NewHTTPServer(dataStore, httpOpts...)
 
 	// Start HTTP server
 	log.Printf("HTTP server listening on port %s", httpPort)
@@ -221,3 +474,160 @@ func main() {
 		log.Fatalf("Failed to serve HTTP: %v", err)
 	}
 }
+
+// getEnvInt returns the integer value of an environment variable, or def if
+// it is unset or not a number. Negative values are fatal, so 0 can be used to
+// lift a limit.
+func getEnvInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid value %q for %s, using default %d", value, key, def)
+		return def
+	}
+	if n < 0 {
+		log.Fatalf("%s must not be negative, got %d", key, n)
+	}
+	return n
+}
+
+// newTokenStore loads tokens from the file or "namespace/name" secret named
+// by the given environment variables and keeps them up to date. It returns
+// nil when neither is set.
+func newTokenStore(k8sClient *k8s.Client, fileEnv, secretEnv string) (*auth.TokenStore, error) {
+	var load config.LoadFunc
+	if path := os.Getenv(fileEnv); path != "" {
+		load = config.FileLoader(path)
+	} else if ref := os.Getenv(secretEnv); ref != "" {
+		namespace, name, ok := strings.Cut(ref, "/")
+		if !ok {
+			return nil, fmt.Errorf("%s must be in the form namespace/name", secretEnv)
+		}
+		load = config.SecretLoader(k8sClient.GetSecretData, namespace, name, "tokens.csv")
+	} else {
+		return nil, nil
+	}
+
+	ctx := context.Background()
+	store, err := auth.NewTokenStore(ctx, load)
+	if err != nil {
+		return nil, err
+	}
+	go store.Watch(ctx, 30*time.Second)
+	return store, nil
+}
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// newDataStore opens the bbolt store at KUBEFLEET_STORE_PATH, or an
+// in-memory store when it is unset. Switching from memory to disk needs no
+// migration since in-memory history doesn't survive a restart; the on-disk
+// schema is migrated automatically when it is opened.
+func newDataStore() (server.DataStore, error) {
+	path := os.Getenv("KUBEFLEET_STORE_PATH")
+	if path == "" {
+		return server.NewMemoryStore(server.Retention{
+			MaxAge:        getEnvDuration("KUBEFLEET_RETENTION_MAX_AGE", 0),
+			MaxBytes:      int64(getEnvInt("KUBEFLEET_RETENTION_MAX_BYTES", 0)),
+			MaxDataPoints: getEnvInt("KUBEFLEET_RETENTION_MAX_DATA_POINTS", 100), // Keep last 100 data points per cluster
+		}), nil
+	}
+
+	store, err := server.NewBoltStore(path, server.Retention{
+		MaxAge:        getEnvDuration("KUBEFLEET_RETENTION_MAX_AGE", 7*24*time.Hour),
+		MaxBytes:      int64(getEnvInt("KUBEFLEET_RETENTION_MAX_BYTES", 1<<30)),
+		MaxDataPoints: getEnvInt("KUBEFLEET_RETENTION_MAX_DATA_POINTS", 0),
+	}, time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Persisting data to %s", path)
+	return store, nil
+}
+
+// newLogStore opens the bbolt log store at KUBEFLEET_LOG_STORE_PATH, or an
+// in-memory one when it is unset
+func newLogStore(retention logstore.Retention) (*logstore.Store, error) {
+	path := os.Getenv("KUBEFLEET_LOG_STORE_PATH")
+	if path == "" {
+		return logstore.NewStore(retention), nil
+	}
+	logs, err := logstore.Open(path, retention)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Persisting logs to %s", path)
+	return logs, nil
+}
+
+// newEventStore opens the bbolt event store at KUBEFLEET_EVENT_STORE_PATH,
+// or an in-memory one when it is unset
+func newEventStore(retention eventstore.Retention) (*eventstore.Store, error) {
+	path := os.Getenv("KUBEFLEET_EVENT_STORE_PATH")
+	if path == "" {
+		return eventstore.NewStore(retention), nil
+	}
+	events, err := eventstore.Open(path, retention)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Persisting events to %s", path)
+	return events, nil
+}
+
+// newSeriesStore opens the bbolt series store at KUBEFLEET_SERIES_STORE_PATH,
+// or an in-memory one when it is unset
+func newSeriesStore(retention timeseries.Retention) (*timeseries.Store, error) {
+	path := os.Getenv("KUBEFLEET_SERIES_STORE_PATH")
+	if path == "" {
+		return timeseries.NewStore(retention), nil
+	}
+	series, err := timeseries.Open(path, retention)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Persisting metric rollups to %s", path)
+	return series, nil
+}
+
+// newAlertHistory opens the bbolt alert history at
+// KUBEFLEET_ALERT_HISTORY_PATH, or an in-memory one when it is unset
+func newAlertHistory(maxAge time.Duration) (*alerting.History, error) {
+	path := os.Getenv("KUBEFLEET_ALERT_HISTORY_PATH")
+	if path == "" {
+		return alerting.NewHistory(maxAge), nil
+	}
+	history, err := alerting.OpenHistory(path, maxAge)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Persisting alert history to %s", path)
+	return history, nil
+}
+
+// getEnvDuration returns the duration value of an environment variable, or
+// def if it is unset or invalid
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		log.Printf("Invalid value %q for %s, using default %s", value, key, def)
+		return def
+	}
+	return d
+}
@@ -31,6 +31,8 @@ interface PodLog {
     log_line: string;
     timestamp: number;
     level: string;
+    previous?: boolean;
+    fields?: { [key: string]: string };
 }
 
 interface PodLogsProps {
@@ -0,0 +1,188 @@
+package agentmetrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Collection phases of a report
+const (
+	PhaseNamespaces = "namespaces"
+	PhasePods       = "pods"
+	PhaseLogs       = "logs"
+	PhaseMetrics    = "metrics"
+	PhaseEvents     = "events"
+	PhaseSend       = "send"
+)
+
+// unhealthyAfter is how many report intervals may pass without a collection
+// attempt (liveness) or a successful report (readiness)
+const unhealthyAfter = 3
+
+// Recorder instruments the agent's collection loop and serves its health
+type Recorder struct {
+	registry      *prometheus.Registry
+	phaseDuration *prometheus.HistogramVec
+	errors        *prometheus.CounterVec
+	payloadBytes  prometheus.Histogram
+	lastSuccessTS prometheus.Gauge
+	reportsTotal  *prometheus.CounterVec
+	startedAt     time.Time
+	mu            sync.RWMutex
+	interval      time.Duration
+	lastAttempt   time.Time
+	lastSuccess   time.Time
+	lastError     string
+}
+
+// NewRecorder creates a recorder for a loop that reports every interval
+func NewRecorder(interval time.Duration) *Recorder {
+	r := &Recorder{
+		registry: prometheus.NewRegistry(),
+		phaseDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "kubefleet_agent",
+			Name:      "collection_phase_duration_seconds",
+			Help:      "Time spent in each phase of collecting and sending a report.",
+			Buckets:   prometheus.ExponentialBuckets(0.005, 2, 14), // 5ms to ~40s
+		}, []string{"phase"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "kubefleet_agent",
+			Name:      "collection_errors_total",
+			Help:      "Errors during collection and sending, by phase.",
+		}, []string{"phase"}),
+		payloadBytes: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "kubefleet_agent",
+			Name:      "report_payload_bytes",
+			Help:      "Encoded size of reports sent to the server.",
+			Buckets:   prometheus.ExponentialBuckets(1024, 4, 8), // 1KiB to 16MiB
+		}),
+		lastSuccessTS: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "kubefleet_agent",
+			Name:      "last_success_timestamp_seconds",
+			Help:      "Unix time of the last report the server accepted.",
+		}),
+		reportsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "kubefleet_agent",
+			Name:      "reports_total",
+			Help:      "Collection attempts, by result.",
+		}, []string{"result"}),
+		interval:  interval,
+		startedAt: time.Now(),
+	}
+
+	r.registry.MustRegister(
+		r.phaseDuration,
+		r.errors,
+		r.payloadBytes,
+		r.lastSuccessTS,
+		r.reportsTotal,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return r
+}
+
+// SetInterval updates the report interval health checks are judged against
+func (r *Recorder) SetInterval(interval time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interval = interval
+}
+
+// ObservePhase records how long a phase took since start
+func (r *Recorder) ObservePhase(phase string, start time.Time) {
+	r.ObservePhaseDuration(phase, time.Since(start))
+}
+
+// ObservePhaseDuration records how long a phase took
+func (r *Recorder) ObservePhaseDuration(phase string, d time.Duration) {
+	r.phaseDuration.WithLabelValues(phase).Observe(d.Seconds())
+}
+
+// ObserveError counts an error in a phase
+func (r *Recorder) ObserveError(phase string) {
+	r.errors.WithLabelValues(phase).Inc()
+}
+
+// ObservePayload records the size of a report
+func (r *Recorder) ObservePayload(bytes int) {
+	r.payloadBytes.Observe(float64(bytes))
+}
+
+// ObserveReport records the outcome of a collection attempt
+func (r *Recorder) ObserveReport(err error) {
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastAttempt = now
+	if err != nil {
+		r.lastError = err.Error()
+		r.reportsTotal.WithLabelValues("error").Inc()
+		return
+	}
+	r.lastSuccess = now
+	r.lastError = ""
+	r.reportsTotal.WithLabelValues("success").Inc()
+	r.lastSuccessTS.Set(float64(now.Unix()))
+}
+
+// Handler serves /healthz, /readyz and /metrics
+func (r *Recorder) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", r.handleHealthz)
+	mux.HandleFunc("/readyz", r.handleReadyz)
+	mux.Handle("/metrics", promhttp.HandlerFor(r.registry, promhttp.HandlerOpts{}))
+	return mux
+}
+
+// handleHealthz fails when the collection loop has stopped running, so a
+// wedged agent is restarted. An unreachable server doesn't fail liveness.
+func (r *Recorder) handleHealthz(w http.ResponseWriter, req *http.Request) {
+	r.mu.RLock()
+	last, interval := r.lastAttempt, r.interval
+	r.mu.RUnlock()
+	if last.IsZero() {
+		last = r.startedAt
+	}
+
+	since := time.Since(last)
+	healthy := since <= unhealthyAfter*interval
+	writeStatus(w, healthy, map[string]interface{}{
+		"lastAttempt": last,
+	})
+}
+
+// handleReadyz fails until a report succeeds and whenever reports have been
+// failing for several intervals
+func (r *Recorder) handleReadyz(w http.ResponseWriter, req *http.Request) {
+	r.mu.RLock()
+	last, lastError, interval := r.lastSuccess, r.lastError, r.interval
+	r.mu.RUnlock()
+
+	ready := !last.IsZero() && time.Since(last) <= unhealthyAfter*interval
+	details := map[string]interface{}{}
+	if !last.IsZero() {
+		details["lastSuccess"] = last
+	}
+	if lastError != "" {
+		details["lastError"] = lastError
+	}
+	writeStatus(w, ready, details)
+}
+
+func writeStatus(w http.ResponseWriter, ok bool, details map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	details["status"] = "ok"
+	if !ok {
+		details["status"] = "unavailable"
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(details)
+}
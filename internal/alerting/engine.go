@@ -0,0 +1,293 @@
+package alerting
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/thekubefleet/kubefleet/internal/auth"
+	"github.com/thekubefleet/kubefleet/internal/config"
+)
+
+// Alert states. An alert is pending while its condition holds for less than
+// the rule's For duration.
+const (
+	StatePending  = "pending"
+	StateFiring   = "firing"
+	StateResolved = "resolved"
+)
+
+// Alert kinds, by what the rule evaluates
+const (
+	KindLog    = "log"
+	KindMetric = "metric"
+)
+
+// resolvedRetention is how long resolved alerts are still listed
+const resolvedRetention = 24 * time.Hour
+
+// Alert is a rule firing for one pod or deployment
+type Alert struct {
+	ID         string     `json:"id"`
+	Rule       string     `json:"rule"`
+	Kind       string     `json:"kind"` // log or metric
+	Severity   string     `json:"severity"`
+	State      string     `json:"state"`
+	Cluster    string     `json:"cluster"`
+	Namespace  string     `json:"namespace"`
+	Pod        string     `json:"pod,omitempty"`
+	Deployment string     `json:"deployment,omitempty"`
+	Message    string     `json:"message"`
+	Count      int        `json:"count,omitempty"`  // Matching lines in the window, up to the threshold
+	Sample     string     `json:"sample,omitempty"` // The latest matching line
+	Value      float64    `json:"value,omitempty"`  // The latest metric value
+	ActiveAt   time.Time  `json:"activeAt"`         // When the condition started holding
+	FiredAt    *time.Time `json:"firedAt,omitempty"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+}
+
+// active reports whether the alert is pending or firing
+func (a *Alert) active() bool {
+	return a != nil && (a.State == StatePending || a.State == StateFiring)
+}
+
+// fire moves the alert to the firing state
+func (a *Alert) fire(now time.Time) {
+	firedAt := now
+	a.State = StateFiring
+	a.FiredAt = &firedAt
+	a.ResolvedAt = nil
+	a.UpdatedAt = now
+}
+
+// resolve moves the alert to the resolved state
+func (a *Alert) resolve(now time.Time) {
+	resolvedAt := now
+	a.State = StateResolved
+	a.ResolvedAt = &resolvedAt
+	a.UpdatedAt = now
+}
+
+// copy returns a copy of the alert that shares no pointers with it
+func (a *Alert) copy() Alert {
+	alert := *a
+	if alert.FiredAt != nil {
+		firedAt := *alert.FiredAt
+		alert.FiredAt = &firedAt
+	}
+	if alert.ResolvedAt != nil {
+		resolvedAt := *alert.ResolvedAt
+		alert.ResolvedAt = &resolvedAt
+	}
+	return alert
+}
+
+// Notifier is told about alerts that fire or resolve
+type Notifier interface {
+	// Notify must not block
+	Notify(alert Alert)
+}
+
+// Engine evaluates the log lines and metrics agents report against alert
+// rules
+type Engine struct {
+	load     config.LoadFunc
+	history  *History // nil when history isn't kept
+	notifier Notifier // nil when nobody is notified
+	now      func() time.Time
+
+	mu           sync.Mutex
+	logRules     []*logRule
+	metricRules  []*MetricRule
+	series       map[seriesKey]*series
+	logClocks    map[string]logClock // By cluster
+	metricSeries map[metricKey]*metricSeries
+}
+
+// EngineOption configures optional Engine dependencies
+type EngineOption func(*Engine)
+
+// WithHistory records alert state changes in a history
+func WithHistory(history *History) EngineOption {
+	return func(e *Engine) {
+		e.history = history
+	}
+}
+
+// WithNotifier tells a notifier about alerts that fire or resolve
+func WithNotifier(notifier Notifier) EngineOption {
+	return func(e *Engine) {
+		e.notifier = notifier
+	}
+}
+
+// NewEngine creates an engine and performs the initial rule load
+func NewEngine(ctx context.Context, load config.LoadFunc, opts ...EngineOption) (*Engine, error) {
+	e := &Engine{
+		load:         load,
+		now:          time.Now,
+		series:       make(map[seriesKey]*series),
+		logClocks:    make(map[string]logClock),
+		metricSeries: make(map[metricKey]*metricSeries),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if err := e.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// History returns the history alert state changes are recorded in, nil
+// when none is kept
+func (e *Engine) History() *History {
+	return e.history
+}
+
+// Reload replaces the rules with the current contents of the source.
+// Alerts of rules that still exist carry on, and those of removed rules
+// resolve.
+func (e *Engine) Reload(ctx context.Context) error {
+	data, err := e.load(ctx)
+	if err != nil {
+		return err
+	}
+	rules, err := ParseRules(data)
+	if err != nil {
+		return err
+	}
+	logRules, err := compileLogRules(rules.LogRules)
+	if err != nil {
+		return err
+	}
+	metricRules, err := compileMetricRules(rules.MetricRules)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.logRules = logRules
+	e.metricRules = metricRules
+
+	now := e.now()
+	names := make(map[string]bool, len(logRules))
+	for _, rule := range logRules {
+		names[rule.Name] = true
+	}
+	for key, s := range e.series {
+		if names[key.rule] {
+			continue
+		}
+		if s.alert.active() {
+			s.alert.resolve(now)
+			e.record(s.alert, true)
+		}
+		delete(e.series, key)
+	}
+	names = make(map[string]bool, len(metricRules))
+	for _, rule := range metricRules {
+		names[rule.Name] = true
+	}
+	for key, s := range e.metricSeries {
+		if names[key.rule] {
+			continue
+		}
+		if s.pending != nil {
+			s.pending.resolve(now)
+			e.record(s.pending, false)
+		}
+		if s.alert.active() {
+			s.alert.resolve(now)
+			e.record(s.alert, true)
+		}
+		delete(e.metricSeries, key)
+	}
+	return nil
+}
+
+// Watch reloads the rules every interval until ctx is done
+func (e *Engine) Watch(ctx context.Context, interval time.Duration) {
+	config.Watch(ctx, interval, "alert rules", e.Reload)
+}
+
+// record logs an alert's new state, adds it to the history and notifies
+// the notifier when it fired or resolved. An alert firing again within its
+// rule's cooldown isn't notified. Callers must hold e.mu.
+func (e *Engine) record(alert *Alert, notify bool) {
+	switch alert.State {
+	case StateFiring:
+		log.Printf("Alert %s firing: %s", alert.ID, alert.Message)
+	case StateResolved:
+		log.Printf("Alert %s resolved", alert.ID)
+	}
+	if e.history != nil {
+		if err := e.history.Record(alert.copy()); err != nil {
+			log.Printf("Failed to record alert %s: %v", alert.ID, err)
+		}
+	}
+	if notify && e.notifier != nil && alert.State != StatePending {
+		e.notifier.Notify(alert.copy())
+	}
+}
+
+// Evaluate resolves alerts whose condition no longer holds without new
+// data: log alerts whose pods wrote fewer matching lines than the threshold
+// within the last window, and metric alerts of resources that stopped
+// reporting. Resolved alerts are forgotten after a day.
+func (e *Engine) Evaluate() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.now()
+	e.evaluateLogs(now)
+	e.evaluateMetrics(now)
+}
+
+// Run evaluates the alerts every interval until ctx is done
+func (e *Engine) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.Evaluate()
+		}
+	}
+}
+
+// Alerts returns the alerts in the namespaces the scope allows, most
+// recently active first. An empty state returns pending, firing and
+// recently resolved alerts.
+func (e *Engine) Alerts(scope *auth.Scope, state string) []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	alerts := make([]Alert, 0)
+	add := func(alert *Alert) {
+		if alert != nil && (state == "" || alert.State == state) && scope.Allows(alert.Cluster, alert.Namespace) {
+			alerts = append(alerts, alert.copy())
+		}
+	}
+	for _, s := range e.series {
+		add(s.alert)
+	}
+	for _, s := range e.metricSeries {
+		add(s.alert)
+		add(s.pending)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if !alerts[i].ActiveAt.Equal(alerts[j].ActiveAt) {
+			return alerts[i].ActiveAt.After(alerts[j].ActiveAt)
+		}
+		return alerts[i].ID < alerts[j].ID
+	})
+	return alerts
+}
@@ -0,0 +1,279 @@
+package alerting
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	agentpb "github.com/thekubefleet/kubefleet/proto"
+)
+
+// fakeNotifier records the alerts it is told about
+type fakeNotifier struct {
+	mu     sync.Mutex
+	alerts []Alert
+}
+
+func (n *fakeNotifier) Notify(alert Alert) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.alerts = append(n.alerts, alert)
+}
+
+// states returns the ID and state of each alert notified
+func (n *fakeNotifier) states() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var states []string
+	for _, alert := range n.alerts {
+		states = append(states, alert.ID+" "+alert.State)
+	}
+	return states
+}
+
+// testEngine is an engine on a clock the test moves, with rules it can
+// change before calling Reload
+type testEngine struct {
+	*Engine
+	rules    string
+	clock    time.Time
+	notifier *fakeNotifier
+}
+
+func newTestEngine(t *testing.T, rules string) *testEngine {
+	t.Helper()
+	te := &testEngine{rules: rules, clock: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), notifier: &fakeNotifier{}}
+	load := func(context.Context) ([]byte, error) { return []byte(te.rules), nil }
+	engine, err := NewEngine(context.Background(), load, WithHistory(NewHistory(0)), WithNotifier(te.notifier))
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	engine.now = func() time.Time { return te.clock }
+	te.Engine = engine
+	return te
+}
+
+func (te *testEngine) advance(d time.Duration) {
+	te.clock = te.clock.Add(d)
+}
+
+func logLine(ts time.Time, pod, text string) *agentpb.PodLog {
+	return &agentpb.PodLog{Namespace: "default", PodName: pod, ContainerName: "app", Timestamp: ts.Unix(), Level: "INFO", LogLine: text}
+}
+
+func podMetrics(name string, cpu, memory float64) *agentpb.ResourceMetrics {
+	return &agentpb.ResourceMetrics{Namespace: "default", Name: name, Kind: "Pod", Cpu: cpu, Memory: memory}
+}
+
+func TestReloadResolvesRemovedRules(t *testing.T) {
+	te := newTestEngine(t, `{
+		"logRules": [{"name": "oom", "pattern": "OOMKilled"}],
+		"metricRules": [
+			{"name": "cpu-high", "metric": "cpu", "above": 1, "for": "5m"},
+			{"name": "memory-high", "metric": "memory", "above": 100}
+		]
+	}`)
+	te.ObserveLogs("prod", []*agentpb.PodLog{logLine(te.clock, "web", "container app was OOMKilled")})
+	te.ObserveMetrics("prod", te.clock.Unix(), []*agentpb.ResourceMetrics{podMetrics("web", 2, 200)})
+
+	te.advance(time.Minute)
+	te.rules = `{"metricRules": [{"name": "memory-high", "metric": "memory", "above": 100}]}`
+	if err := te.Reload(context.Background()); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	want := []string{
+		"oom/prod/default/web firing",
+		"memory-high/prod/default/pod/web firing",
+		"oom/prod/default/web resolved",
+	}
+	if got := te.notifier.states(); !slices.Equal(got, want) {
+		t.Errorf("notified %q, want %q", got, want)
+	}
+
+	resolved := te.History().Query(HistoryQuery{State: StateResolved})
+	if len(resolved) != 2 {
+		t.Fatalf("history has %d resolved events, want the log alert and the pending metric alert", len(resolved))
+	}
+	for _, event := range resolved {
+		if !event.Time.Equal(te.clock) || event.ResolvedAt == nil || !event.ResolvedAt.Equal(te.clock) {
+			t.Errorf("event %s resolved at %v, want the reload time %v", event.ID, event.Time, te.clock)
+		}
+	}
+
+	alerts := te.Alerts(nil, "")
+	if len(alerts) != 1 || alerts[0].Rule != "memory-high" || alerts[0].State != StateFiring {
+		t.Errorf("alerts = %+v, want only the kept rule's alert firing", alerts)
+	}
+}
+
+func errorLine(ts time.Time) *agentpb.PodLog {
+	line := logLine(ts, "web", "request failed")
+	line.Level = "ERROR"
+	return line
+}
+
+func TestLogAlert(t *testing.T) {
+	te := newTestEngine(t, `{"logRules": [
+		{"name": "errors", "levels": ["ERROR"], "threshold": 3, "window": "1m", "cooldown": "5m"}
+	]}`)
+	start := te.clock
+
+	te.ObserveLogs("prod", []*agentpb.PodLog{errorLine(start), errorLine(start), logLine(start, "web", "fine")})
+	if alerts := te.Alerts(nil, ""); len(alerts) != 0 {
+		t.Fatalf("alerts = %+v below the threshold", alerts)
+	}
+	te.advance(10 * time.Second)
+	te.ObserveLogs("prod", []*agentpb.PodLog{errorLine(te.clock)})
+	alerts := te.Alerts(nil, StateFiring)
+	if len(alerts) != 1 || alerts[0].Count != 3 || !alerts[0].FiredAt.Equal(te.clock) {
+		t.Fatalf("alerts = %+v, want one firing with 3 lines at %v", alerts, te.clock)
+	}
+
+	// The lines are within the window of the clock until a minute has passed
+	// since the first of them
+	te.advance(45 * time.Second)
+	te.Evaluate()
+	if alerts := te.Alerts(nil, StateFiring); len(alerts) != 1 {
+		t.Errorf("alert resolved while the lines were within the window")
+	}
+	te.advance(10 * time.Second)
+	te.Evaluate()
+	alerts = te.Alerts(nil, StateResolved)
+	if len(alerts) != 1 || !alerts[0].ResolvedAt.Equal(te.clock) {
+		t.Fatalf("alerts = %+v, want the alert resolved at %v", alerts, te.clock)
+	}
+
+	// Firing again within the cooldown isn't notified
+	te.advance(time.Minute)
+	te.ObserveLogs("prod", []*agentpb.PodLog{errorLine(te.clock), errorLine(te.clock), errorLine(te.clock)})
+	if alerts := te.Alerts(nil, StateFiring); len(alerts) != 1 {
+		t.Errorf("alert didn't fire again")
+	}
+	want := []string{"errors/prod/default/web firing", "errors/prod/default/web resolved"}
+	if got := te.notifier.states(); !slices.Equal(got, want) {
+		t.Errorf("notified %q, want %q", got, want)
+	}
+
+	// Resolved alerts are listed for a day
+	te.advance(2 * time.Minute)
+	te.Evaluate()
+	te.advance(resolvedRetention)
+	te.Evaluate()
+	if alerts := te.Alerts(nil, ""); len(alerts) != 0 {
+		t.Errorf("alerts = %+v a day after resolving", alerts)
+	}
+}
+
+func TestLogAlertSkewedClock(t *testing.T) {
+	for _, skew := range []time.Duration{-10 * time.Minute, 10 * time.Minute} {
+		t.Run(skew.String(), func(t *testing.T) {
+			te := newTestEngine(t, `{"logRules": [{"name": "errors", "levels": ["ERROR"], "window": "1m"}]}`)
+			agentClock := func() time.Time { return te.clock.Add(skew) }
+
+			te.ObserveLogs("prod", []*agentpb.PodLog{errorLine(agentClock())})
+			te.advance(30 * time.Second)
+			// Lines without a match still move the cluster's clock on
+			te.ObserveLogs("prod", []*agentpb.PodLog{logLine(agentClock(), "web", "fine")})
+			te.advance(20 * time.Second)
+			te.Evaluate()
+			if alerts := te.Alerts(nil, StateFiring); len(alerts) != 1 {
+				t.Fatalf("alerts = %+v, want the alert firing within the window of the agent's clock", alerts)
+			}
+
+			te.advance(11 * time.Second)
+			te.Evaluate()
+			if alerts := te.Alerts(nil, StateResolved); len(alerts) != 1 {
+				t.Errorf("alerts = %+v, want the alert resolved over a minute after the line on the agent's clock", alerts)
+			}
+		})
+	}
+}
+
+func TestLogAlertSelector(t *testing.T) {
+	te := newTestEngine(t, `{"logRules": [
+		{"name": "oom", "pattern": "OOMKilled", "selector": "app=web,track!=canary"}
+	]}`)
+	labelled := func(pod string, podLabels map[string]string) *agentpb.PodLog {
+		line := logLine(te.clock, pod, "container app was OOMKilled")
+		line.PodLabels = podLabels
+		return line
+	}
+	te.ObserveLogs("prod", []*agentpb.PodLog{
+		labelled("web-1", map[string]string{"app": "web"}),
+		labelled("web-canary", map[string]string{"app": "web", "track": "canary"}),
+		labelled("worker", map[string]string{"app": "worker"}),
+		// Lines from older agents have no pod labels
+		labelled("web-2", nil),
+	})
+
+	alerts := te.Alerts(nil, StateFiring)
+	if len(alerts) != 1 || alerts[0].Pod != "web-1" {
+		t.Fatalf("alerts = %+v, want one for web-1", alerts)
+	}
+	if want := `1 line matching "OOMKilled" from pods app=web,track!=canary within 1m0s`; alerts[0].Message != want {
+		t.Errorf("message = %q, want %q", alerts[0].Message, want)
+	}
+
+	if _, err := ParseRules([]byte(`{"logRules": [{"name": "oom", "pattern": "OOMKilled", "selector": "app in web"}]}`)); err == nil {
+		t.Errorf("ParseRules accepted an invalid selector")
+	}
+}
+
+func TestMetricAlertFor(t *testing.T) {
+	te := newTestEngine(t, `{"metricRules": [
+		{"name": "cpu-high", "metric": "cpu", "kinds": ["Pod"], "above": 1, "for": "5m"}
+	]}`)
+	report := te.clock.Unix()
+	observe := func(offset int64, cpu float64) {
+		te.ObserveMetrics("prod", report+offset, []*agentpb.ResourceMetrics{podMetrics("web", cpu, 0)})
+	}
+
+	observe(0, 2)
+	pending := te.Alerts(nil, StatePending)
+	if len(pending) != 1 || !pending[0].ActiveAt.Equal(te.clock) {
+		t.Fatalf("alerts = %+v, want one pending since %v", pending, te.clock)
+	}
+
+	// For is timed by report timestamps, not by when reports arrive
+	te.advance(time.Hour)
+	observe(240, 3)
+	if alerts := te.Alerts(nil, StateFiring); len(alerts) != 0 {
+		t.Fatalf("alert fired after 4 minutes of reports")
+	}
+	observe(300, 3)
+	alerts := te.Alerts(nil, StateFiring)
+	if len(alerts) != 1 || alerts[0].Value != 3 || !alerts[0].FiredAt.Equal(te.clock) {
+		t.Fatalf("alerts = %+v, want one firing at %v", alerts, te.clock)
+	}
+
+	// Reports that are sent again are ignored
+	observe(300, 0)
+	observe(330, 0.5)
+	alerts = te.Alerts(nil, StateResolved)
+	if len(alerts) != 1 || alerts[0].Value != 0.5 {
+		t.Errorf("alerts = %+v, want the alert resolved by the 0.5 core report", alerts)
+	}
+	want := []string{"cpu-high/prod/default/pod/web firing", "cpu-high/prod/default/pod/web resolved"}
+	if got := te.notifier.states(); !slices.Equal(got, want) {
+		t.Errorf("notified %q, want %q", got, want)
+	}
+}
+
+func TestMetricAlertStale(t *testing.T) {
+	te := newTestEngine(t, `{"metricRules": [{"name": "memory-high", "metric": "memory", "above": 100}]}`)
+	te.ObserveMetrics("prod", te.clock.Unix(), []*agentpb.ResourceMetrics{podMetrics("web", 0, 200)})
+
+	te.advance(staleAfter - time.Second)
+	te.Evaluate()
+	if alerts := te.Alerts(nil, StateFiring); len(alerts) != 1 {
+		t.Fatalf("alerts = %+v, want one firing", alerts)
+	}
+	te.advance(time.Second)
+	te.Evaluate()
+	if alerts := te.Alerts(nil, StateResolved); len(alerts) != 1 || !alerts[0].ResolvedAt.Equal(te.clock) {
+		t.Errorf("alerts = %+v, want the alert resolved once the pod stopped reporting", alerts)
+	}
+}
@@ -0,0 +1,198 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/thekubefleet/kubefleet/internal/auth"
+	"github.com/thekubefleet/kubefleet/internal/boltdb"
+)
+
+// maxHistoryEvents caps the events kept, dropping the oldest first
+const maxHistoryEvents = 100000
+
+// eventsBucket maps an event id, as 8 big-endian bytes, to its Event
+var eventsBucket = []byte("events")
+
+// Event is an alert entering a state, as recorded in the history
+type Event struct {
+	Time time.Time `json:"time"`
+	Alert
+}
+
+type historyEntry struct {
+	id    uint64
+	event Event
+}
+
+// HistoryQuery selects events. Empty fields match any event.
+type HistoryQuery struct {
+	Scope     *auth.Scope // Events outside the scope are never returned
+	AlertID   string
+	Rule      string
+	Cluster   string
+	Namespace string
+	State     string
+	From      time.Time
+	To        time.Time // Zero for no upper bound
+	Limit     int       // Zero for all
+}
+
+// History keeps the state changes of alerts, oldest first
+type History struct {
+	mu     sync.RWMutex
+	maxAge time.Duration  // Zero keeps events until maxHistoryEvents
+	db     *bolt.DB       // nil for an in-memory history
+	events []historyEntry // ordered by id
+	nextID uint64
+}
+
+// NewHistory creates an in-memory history keeping events for maxAge
+func NewHistory(maxAge time.Duration) *History {
+	return &History{maxAge: maxAge, nextID: 1}
+}
+
+// OpenHistory opens or creates a history persisted in the bbolt database at
+// path
+func OpenHistory(path string, maxAge time.Duration) (*History, error) {
+	h := NewHistory(maxAge)
+	db, err := boltdb.OpenBucket(path, "alert history", eventsBucket, h.load)
+	if err != nil {
+		return nil, err
+	}
+	h.db = db
+
+	boltdb.EnforceRetention("alert history", h)
+	return h, nil
+}
+
+// load reads a stored event into memory
+func (h *History) load(key, value []byte) error {
+	var event Event
+	if err := json.Unmarshal(value, &event); err != nil {
+		log.Printf("Skipping unreadable alert event %d", boltdb.ID(key))
+		return nil
+	}
+	id := boltdb.ID(key)
+	h.events = append(h.events, historyEntry{id, event})
+	h.nextID = id + 1
+	return nil
+}
+
+// Record adds an alert's current state to the history
+func (h *History) Record(alert Alert) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entry := historyEntry{h.nextID, Event{Time: alert.UpdatedAt, Alert: alert}}
+	h.nextID++
+	h.events = append(h.events, entry)
+	evicted := h.evict()
+	if h.db != nil {
+		return h.persist(&entry, evicted)
+	}
+	return nil
+}
+
+// evict drops the oldest events while the history is over its limits and
+// returns their ids. Callers must hold h.mu.
+func (h *History) evict() []uint64 {
+	var cutoff time.Time
+	if h.maxAge > 0 {
+		cutoff = time.Now().Add(-h.maxAge)
+	}
+
+	var evicted []uint64
+	for len(h.events) > 0 && (len(h.events) > maxHistoryEvents || h.events[0].event.Time.Before(cutoff)) {
+		evicted = append(evicted, h.events[0].id)
+		h.events[0] = historyEntry{}
+		h.events = h.events[1:]
+	}
+	return evicted
+}
+
+// persist writes an added event and deletes evicted ones. Callers must hold
+// h.mu.
+func (h *History) persist(added *historyEntry, evicted []uint64) error {
+	err := h.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(eventsBucket)
+		for _, id := range evicted {
+			if err := bucket.Delete(boltdb.Key(id)); err != nil {
+				return err
+			}
+		}
+		if added == nil {
+			return nil
+		}
+		value, err := json.Marshal(added.event)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(boltdb.Key(added.id), value)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to persist alert history: %w", err)
+	}
+	return nil
+}
+
+// EnforceRetention drops events that have aged out
+func (h *History) EnforceRetention() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	evicted := h.evict()
+	if h.db != nil && len(evicted) > 0 {
+		return h.persist(nil, evicted)
+	}
+	return nil
+}
+
+// Run enforces retention every interval until ctx is done
+func (h *History) Run(ctx context.Context, interval time.Duration) {
+	boltdb.RunRetention(ctx, interval, "alert history", h)
+}
+
+// Query returns the events matching q, newest first
+func (h *History) Query(q HistoryQuery) []Event {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	events := make([]Event, 0)
+	for i := len(h.events) - 1; i >= 0; i-- {
+		event := h.events[i].event
+		switch {
+		case !q.Scope.Allows(event.Cluster, event.Namespace),
+			q.AlertID != "" && event.Alert.ID != q.AlertID,
+			q.Rule != "" && event.Rule != q.Rule,
+			q.Cluster != "" && event.Cluster != q.Cluster,
+			q.Namespace != "" && event.Namespace != q.Namespace,
+			q.State != "" && event.State != q.State,
+			event.Time.Before(q.From),
+			!q.To.IsZero() && event.Time.After(q.To):
+			continue
+		}
+		events = append(events, event)
+		if q.Limit > 0 && len(events) == q.Limit {
+			break
+		}
+	}
+	return events
+}
+
+// Close closes the database of a persistent history
+func (h *History) Close() error {
+	if h.db == nil {
+		return nil
+	}
+	if err := h.db.Close(); err != nil {
+		return fmt.Errorf("failed to close alert history: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package alerting
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/thekubefleet/kubefleet/internal/logstore"
+	agentpb "github.com/thekubefleet/kubefleet/proto"
+)
+
+type seriesKey struct {
+	rule      string
+	cluster   string
+	namespace string
+	pod       string
+}
+
+// logClock follows a cluster's clock, which log line timestamps are on,
+// from the newest line seen. Windows are measured on it so a skewed agent
+// clock doesn't resolve alerts early or late.
+type logClock struct {
+	newest int64     // Timestamp of the newest line
+	seenAt time.Time // When it was seen, on the engine's clock
+}
+
+// at returns the cluster's time when the engine's clock reads now
+func (c logClock) at(now time.Time) int64 {
+	return c.newest + int64(now.Sub(c.seenAt)/time.Second)
+}
+
+// series tracks one log rule for one pod
+type series struct {
+	times     []int64 // Timestamps of the latest matching lines, at most the threshold
+	lastFired time.Time
+	alert     *Alert // The current or last alert
+}
+
+// ObserveLogs counts a cluster's new log lines against the log rules. Each
+// line must be observed once; lines repeated by later reports must not be
+// passed again.
+func (e *Engine) ObserveLogs(cluster string, lines []*agentpb.PodLog) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.now()
+	clock, ok := e.logClocks[cluster]
+	for _, line := range lines {
+		if !ok || line.Timestamp > clock.newest {
+			clock = logClock{newest: line.Timestamp, seenAt: now}
+			ok = true
+		}
+	}
+	if ok {
+		e.logClocks[cluster] = clock
+	}
+
+	for _, rule := range e.logRules {
+		if !matchAny(rule.Clusters, cluster) {
+			continue
+		}
+		for _, line := range lines {
+			if rule.matches(cluster, line) {
+				e.count(rule, cluster, line, now)
+			}
+		}
+	}
+}
+
+func (r *logRule) matches(cluster string, line *agentpb.PodLog) bool {
+	switch {
+	case !matchAny(r.Namespaces, line.Namespace),
+		r.levels != nil && !r.levels[line.Level],
+		r.pattern != nil && !r.pattern.MatchString(line.LogLine),
+		r.selector != nil && !r.selector.Matches(labels.Set(line.PodLabels)):
+		return false
+	}
+	return r.fieldSelector == nil || r.fieldSelector.Matches(logstore.FieldSet(cluster, line))
+}
+
+// count records a matching line and fires the rule's alert for the pod once
+// the threshold is reached. Callers must hold e.mu.
+func (e *Engine) count(rule *logRule, cluster string, line *agentpb.PodLog, now time.Time) {
+	key := seriesKey{rule.Name, cluster, line.Namespace, line.PodName}
+	s := e.series[key]
+	if s == nil {
+		s = &series{}
+		e.series[key] = s
+	}
+
+	// Keep the newest threshold timestamps; the rule is over its threshold
+	// when the oldest of them is within the window of the newest
+	i := sort.Search(len(s.times), func(i int) bool { return s.times[i] > line.Timestamp })
+	s.times = append(s.times, 0)
+	copy(s.times[i+1:], s.times[i:])
+	s.times[i] = line.Timestamp
+	if len(s.times) > rule.Threshold {
+		s.times = s.times[len(s.times)-rule.Threshold:]
+	}
+	newest := s.times[len(s.times)-1]
+	inWindow := s.inWindow(newest - int64(time.Duration(rule.Window)/time.Second))
+
+	switch {
+	case s.alert.active():
+		s.alert.Count = inWindow
+		s.alert.Sample = line.LogLine
+		s.alert.UpdatedAt = now
+	case inWindow < rule.Threshold:
+	case s.alert != nil && now.Sub(s.lastFired) < time.Duration(rule.Cooldown):
+		// Within the cooldown the last alert fires again instead of a new one
+		s.alert.Count = inWindow
+		s.alert.Sample = line.LogLine
+		s.alert.State = StateFiring
+		s.alert.ResolvedAt = nil
+		s.alert.UpdatedAt = now
+		e.record(s.alert, false)
+	default:
+		s.lastFired = now
+		s.alert = &Alert{
+			ID:        fmt.Sprintf("%s/%s/%s/%s", rule.Name, cluster, line.Namespace, line.PodName),
+			Rule:      rule.Name,
+			Kind:      KindLog,
+			Severity:  rule.Severity,
+			Cluster:   cluster,
+			Namespace: line.Namespace,
+			Pod:       line.PodName,
+			Message:   rule.message(inWindow),
+			Count:     inWindow,
+			Sample:    line.LogLine,
+			ActiveAt:  now,
+		}
+		s.alert.fire(now)
+		e.record(s.alert, true)
+	}
+}
+
+// inWindow returns how many of the kept timestamps are at or after since
+func (s *series) inWindow(since int64) int {
+	return len(s.times) - sort.Search(len(s.times), func(i int) bool { return s.times[i] >= since })
+}
+
+// evaluateLogs resolves log alerts and forgets idle series. Callers must
+// hold e.mu.
+func (e *Engine) evaluateLogs(now time.Time) {
+	rules := make(map[string]*logRule, len(e.logRules))
+	for _, rule := range e.logRules {
+		rules[rule.Name] = rule
+	}
+	for key, s := range e.series {
+		rule := rules[key.rule]
+		clusterNow := now.Unix()
+		if clock, ok := e.logClocks[key.cluster]; ok {
+			clusterNow = clock.at(now)
+		}
+		inWindow := s.inWindow(clusterNow - int64(time.Duration(rule.Window)/time.Second))
+		if s.alert.active() && inWindow < rule.Threshold {
+			s.alert.resolve(now)
+			e.record(s.alert, true)
+		}
+
+		idle := inWindow == 0 && now.Sub(s.lastFired) >= time.Duration(rule.Cooldown)
+		if idle && (s.alert == nil || now.Sub(*s.alert.ResolvedAt) >= resolvedRetention) {
+			delete(e.series, key)
+		}
+	}
+}
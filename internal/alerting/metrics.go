@@ -0,0 +1,258 @@
+package alerting
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	agentpb "github.com/thekubefleet/kubefleet/proto"
+)
+
+// staleAfter is how long a resource may go unreported before its metric
+// alerts resolve
+const staleAfter = 5 * time.Minute
+
+// minSpread is the least spread of an anomaly baseline, as a fraction of its
+// mean, so a flat baseline doesn't flag every small change
+const minSpread = 0.1
+
+type metricKey struct {
+	rule      string
+	cluster   string
+	namespace string
+	kind      string
+	name      string
+}
+
+type sample struct {
+	timestamp int64
+	value     float64
+}
+
+// metricSeries tracks one metric rule for one pod or deployment
+type metricSeries struct {
+	samples   []sample // Within the anomaly baseline, oldest first
+	latest    int64    // Report timestamp of the latest sample
+	since     int64    // Report timestamp the condition started holding, 0 while it doesn't
+	lastSeen  time.Time
+	lastFired time.Time
+	pending   *Alert
+	alert     *Alert // The current or last firing alert
+}
+
+// ObserveMetrics evaluates a cluster's report against the metric rules. The
+// report timestamp times For durations and anomaly baselines, so they don't
+// depend on when reports arrive.
+func (e *Engine) ObserveMetrics(cluster string, timestamp int64, metrics []*agentpb.ResourceMetrics) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.now()
+	for _, rule := range e.metricRules {
+		if !matchAny(rule.Clusters, cluster) {
+			continue
+		}
+		for _, m := range metrics {
+			if !rule.selects(m) {
+				continue
+			}
+			key := metricKey{rule.Name, cluster, m.Namespace, m.Kind, m.Name}
+			s := e.metricSeries[key]
+			if s == nil {
+				s = &metricSeries{}
+				e.metricSeries[key] = s
+			}
+			if timestamp <= s.latest {
+				// Reports may be re-sent
+				continue
+			}
+			s.latest = timestamp
+			s.lastSeen = now
+
+			value := m.Cpu
+			if rule.Metric == MetricMemory {
+				value = m.Memory
+			}
+			breach, message := rule.check(s, m, timestamp, value)
+			e.transition(rule, key, s, breach, message, timestamp, value, now)
+		}
+	}
+}
+
+// selects reports whether a rule covers a resource
+func (r *MetricRule) selects(m *agentpb.ResourceMetrics) bool {
+	if len(r.Kinds) > 0 {
+		found := false
+		for _, kind := range r.Kinds {
+			found = found || kind == m.Kind
+		}
+		if !found {
+			return false
+		}
+	} else if m.Kind != "Pod" && m.Kind != "Deployment" {
+		return false
+	}
+	return matchAny(r.Namespaces, m.Namespace) && matchAny(r.Names, m.Name)
+}
+
+// check reports whether a value breaches the rule and describes the breach.
+// Anomaly rules add the value to the series' baseline.
+func (r *MetricRule) check(s *metricSeries, m *agentpb.ResourceMetrics, timestamp int64, value float64) (bool, string) {
+	unit := "cores"
+	if r.Metric == MetricMemory {
+		unit = "MiB"
+	}
+
+	if r.Anomaly != nil {
+		since := timestamp - int64(time.Duration(r.Anomaly.Baseline)/time.Second)
+		i := 0
+		for i < len(s.samples) && s.samples[i].timestamp < since {
+			i++
+		}
+		s.samples = s.samples[i:]
+		baseline := s.samples
+		s.samples = append(s.samples, sample{timestamp, value})
+
+		if len(baseline) < r.Anomaly.MinSamples {
+			return false, ""
+		}
+		var sum, squares float64
+		for _, sample := range baseline {
+			sum += sample.value
+		}
+		mean := sum / float64(len(baseline))
+		for _, sample := range baseline {
+			squares += (sample.value - mean) * (sample.value - mean)
+		}
+		spread := math.Max(math.Sqrt(squares/float64(len(baseline))), minSpread*math.Abs(mean))
+		if spread == 0 || math.Abs(value-mean) <= r.Anomaly.Deviations*spread {
+			return false, ""
+		}
+		return true, fmt.Sprintf("%s %.3g %s is outside its baseline of %.3g ± %.3g %s", r.Metric, value, unit, mean, r.Anomaly.Deviations*spread, unit)
+	}
+
+	if r.Of == "" {
+		return value > r.Above, fmt.Sprintf("%s %.3g %s is above %.3g %s", r.Metric, value, unit, r.Above, unit)
+	}
+	base := m.CpuRequest
+	switch {
+	case r.Metric == MetricCPU && r.Of == OfLimit:
+		base = m.CpuLimit
+	case r.Metric == MetricMemory && r.Of == OfRequest:
+		base = m.MemoryRequest
+	case r.Metric == MetricMemory && r.Of == OfLimit:
+		base = m.MemoryLimit
+	}
+	if base == 0 {
+		// Without a request or limit there's nothing to compare against
+		return false, ""
+	}
+	percent := value / base * 100
+	return percent > r.Above, fmt.Sprintf("%s is at %.0f%% of its %s (%.3g of %.3g %s), above %.3g%%", r.Metric, percent, r.Of, value, base, unit, r.Above)
+}
+
+// transition moves a series' alerts through pending, firing and resolved
+// as the condition holds or clears. Callers must hold e.mu.
+func (e *Engine) transition(rule *MetricRule, key metricKey, s *metricSeries, breach bool, message string, timestamp int64, value float64, now time.Time) {
+	if !breach {
+		s.since = 0
+		if s.pending != nil {
+			s.pending.resolve(now)
+			e.record(s.pending, false)
+			s.pending = nil
+		}
+		if s.alert.active() {
+			s.alert.Value = value
+			s.alert.resolve(now)
+			e.record(s.alert, true)
+		}
+		return
+	}
+
+	if s.alert.active() {
+		s.alert.Message = message
+		s.alert.Value = value
+		s.alert.UpdatedAt = now
+		return
+	}
+	if s.since == 0 {
+		s.since = timestamp
+		s.pending = &Alert{
+			ID:        fmt.Sprintf("%s/%s/%s/%s/%s", rule.Name, key.cluster, key.namespace, strings.ToLower(key.kind), key.name),
+			Rule:      rule.Name,
+			Kind:      KindMetric,
+			Severity:  rule.Severity,
+			State:     StatePending,
+			Cluster:   key.cluster,
+			Namespace: key.namespace,
+			Message:   message,
+			Value:     value,
+			ActiveAt:  now,
+			UpdatedAt: now,
+		}
+		if key.kind == "Deployment" {
+			s.pending.Deployment = key.name
+		} else {
+			s.pending.Pod = key.name
+		}
+		if rule.For > 0 {
+			e.record(s.pending, false)
+		}
+	} else {
+		s.pending.Message = message
+		s.pending.Value = value
+		s.pending.UpdatedAt = now
+	}
+	if timestamp-s.since < int64(time.Duration(rule.For)/time.Second) {
+		return
+	}
+
+	if s.alert != nil && now.Sub(s.lastFired) < time.Duration(rule.Cooldown) {
+		// Within the cooldown the last alert fires again instead of a new one
+		s.alert.Message = message
+		s.alert.Value = value
+		s.alert.State = StateFiring
+		s.alert.ResolvedAt = nil
+		s.alert.UpdatedAt = now
+		s.pending = nil
+		e.record(s.alert, false)
+		return
+	}
+	s.lastFired = now
+	s.alert = s.pending
+	s.alert.fire(now)
+	s.pending = nil
+	e.record(s.alert, true)
+}
+
+// evaluateMetrics resolves the alerts of resources that stopped reporting
+// and forgets idle series. Callers must hold e.mu.
+func (e *Engine) evaluateMetrics(now time.Time) {
+	rules := make(map[string]*MetricRule, len(e.metricRules))
+	for _, rule := range e.metricRules {
+		rules[rule.Name] = rule
+	}
+	for key, s := range e.metricSeries {
+		rule := rules[key.rule]
+		if now.Sub(s.lastSeen) < staleAfter {
+			continue
+		}
+		s.since = 0
+		s.samples = nil
+		if s.pending != nil {
+			s.pending.resolve(now)
+			e.record(s.pending, false)
+			s.pending = nil
+		}
+		if s.alert.active() {
+			s.alert.resolve(now)
+			e.record(s.alert, true)
+		}
+
+		idle := now.Sub(s.lastFired) >= time.Duration(rule.Cooldown)
+		if idle && (s.alert == nil || now.Sub(*s.alert.ResolvedAt) >= resolvedRetention) {
+			delete(e.metricSeries, key)
+		}
+	}
+}
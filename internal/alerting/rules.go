@@ -0,0 +1,317 @@
+package alerting
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/thekubefleet/kubefleet/internal/logparse"
+)
+
+// Defaults for optional rule settings
+const (
+	defaultWindow     = time.Minute
+	defaultCooldown   = 5 * time.Minute
+	defaultSeverity   = "warning"
+	defaultBaseline   = time.Hour
+	defaultDeviations = 3
+	defaultMinSamples = 10
+)
+
+// Metrics and the resource values a MetricRule's threshold can be relative to
+const (
+	MetricCPU    = "cpu"    // cores
+	MetricMemory = "memory" // MiB
+
+	OfRequest = "request"
+	OfLimit   = "limit"
+)
+
+// Duration is a time.Duration written in JSON as a string such as "5m"
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("duration must be a string such as \"5m\"")
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	if parsed < 0 {
+		return fmt.Errorf("duration %s is negative", value)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// LogRule fires when a pod writes Threshold matching lines within Window.
+// A line matches when it matches every condition that is set. Clusters and
+// namespaces accept glob patterns such as "team-a-*".
+type LogRule struct {
+	Name       string   `json:"name"`
+	Severity   string   `json:"severity"` // e.g. critical or warning
+	Pattern    string   `json:"pattern"`  // RE2 expression matched against the line
+	Levels     []string `json:"levels"`
+	Clusters   []string `json:"clusters"`
+	Namespaces []string `json:"namespaces"`
+	// Selector is a label selector over the labels of the line's pod
+	Selector string `json:"selector"`
+	// FieldSelector uses label selector syntax over the line's parsed
+	// fields plus cluster, namespace, pod, container and level
+	FieldSelector string   `json:"fieldSelector"`
+	Threshold     int      `json:"threshold"` // 1 when unset
+	Window        Duration `json:"window"`    // 1m when unset
+	// Cooldown is the least time between two firings for the same pod, 5m
+	// when unset
+	Cooldown Duration `json:"cooldown"`
+}
+
+// MetricRule fires when the CPU or memory of a pod or deployment is above a
+// threshold, or outside its rolling baseline, for at least For. Clusters,
+// namespaces and names accept glob patterns.
+type MetricRule struct {
+	Name       string   `json:"name"`
+	Severity   string   `json:"severity"`
+	Metric     string   `json:"metric"` // cpu or memory
+	Kinds      []string `json:"kinds"`  // Pod and/or Deployment, both when unset
+	Clusters   []string `json:"clusters"`
+	Namespaces []string `json:"namespaces"`
+	Names      []string `json:"names"`
+	// Above is in cores or MiB, or a percentage of the resource's requests
+	// or limits when Of is request or limit
+	Above float64 `json:"above"`
+	Of    string  `json:"of"`
+	// Anomaly fires on values outside a rolling baseline instead of above
+	// a threshold
+	Anomaly *Anomaly `json:"anomaly"`
+	// For is how long the condition must hold before the alert fires; the
+	// alert is pending until then
+	For      Duration `json:"for"`
+	Cooldown Duration `json:"cooldown"` // 5m when unset
+}
+
+// Anomaly flags values more than Deviations standard deviations from the
+// mean of the samples within Baseline
+type Anomaly struct {
+	Baseline   Duration `json:"baseline"`   // 1h when unset
+	Deviations float64  `json:"deviations"` // 3 when unset
+	MinSamples int      `json:"minSamples"` // Samples needed before values are judged, 10 when unset
+}
+
+// Rules is the alerting configuration loaded from disk
+type Rules struct {
+	LogRules    []LogRule    `json:"logRules"`
+	MetricRules []MetricRule `json:"metricRules"`
+}
+
+// logRule is a LogRule with its defaults applied and patterns compiled
+type logRule struct {
+	LogRule
+	pattern       *regexp.Regexp
+	levels        map[string]bool
+	selector      labels.Selector
+	fieldSelector labels.Selector
+}
+
+// ParseRules parses a JSON rule file and validates its rules
+func ParseRules(data []byte) (*Rules, error) {
+	var rules Rules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse alert rules: %w", err)
+	}
+	if _, err := compileLogRules(rules.LogRules); err != nil {
+		return nil, err
+	}
+	if _, err := compileMetricRules(rules.MetricRules); err != nil {
+		return nil, err
+	}
+	return &rules, nil
+}
+
+func compileLogRules(rules []LogRule) ([]*logRule, error) {
+	compiled := make([]*logRule, 0, len(rules))
+	names := make(map[string]bool)
+	for i, rule := range rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("log rule %d has no name", i)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("log rule %q is defined twice", rule.Name)
+		}
+		names[rule.Name] = true
+
+		r := &logRule{LogRule: rule}
+		if r.Severity == "" {
+			r.Severity = defaultSeverity
+		}
+		if r.Threshold <= 0 {
+			r.Threshold = 1
+		}
+		if r.Window == 0 {
+			r.Window = Duration(defaultWindow)
+		}
+		if r.Cooldown == 0 {
+			r.Cooldown = Duration(defaultCooldown)
+		}
+
+		if rule.Pattern != "" {
+			pattern, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("log rule %q has invalid pattern: %w", rule.Name, err)
+			}
+			r.pattern = pattern
+		}
+		if len(rule.Levels) > 0 {
+			r.levels = make(map[string]bool)
+			for _, name := range rule.Levels {
+				level := logparse.NormalizeLevel(name)
+				if level == "" {
+					return nil, fmt.Errorf("log rule %q has invalid level %q", rule.Name, name)
+				}
+				r.levels[level] = true
+			}
+		}
+		if rule.Selector != "" {
+			selector, err := labels.Parse(rule.Selector)
+			if err != nil {
+				return nil, fmt.Errorf("log rule %q has invalid selector: %w", rule.Name, err)
+			}
+			r.selector = selector
+		}
+		if rule.FieldSelector != "" {
+			selector, err := labels.Parse(rule.FieldSelector)
+			if err != nil {
+				return nil, fmt.Errorf("log rule %q has invalid fieldSelector: %w", rule.Name, err)
+			}
+			r.fieldSelector = selector
+		}
+		if err := checkPatterns(rule.Clusters, rule.Namespaces); err != nil {
+			return nil, fmt.Errorf("log rule %q has %w", rule.Name, err)
+		}
+		if r.pattern == nil && r.levels == nil && r.fieldSelector == nil {
+			return nil, fmt.Errorf("log rule %q needs a pattern, levels or a fieldSelector", rule.Name)
+		}
+		compiled = append(compiled, r)
+	}
+	return compiled, nil
+}
+
+func compileMetricRules(rules []MetricRule) ([]*MetricRule, error) {
+	compiled := make([]*MetricRule, 0, len(rules))
+	names := make(map[string]bool)
+	for i, rule := range rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("metric rule %d has no name", i)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("metric rule %q is defined twice", rule.Name)
+		}
+		names[rule.Name] = true
+
+		r := rule
+		if r.Severity == "" {
+			r.Severity = defaultSeverity
+		}
+		if r.Cooldown == 0 {
+			r.Cooldown = Duration(defaultCooldown)
+		}
+		if r.Anomaly != nil {
+			anomaly := *r.Anomaly
+			if anomaly.Baseline == 0 {
+				anomaly.Baseline = Duration(defaultBaseline)
+			}
+			if anomaly.Deviations <= 0 {
+				anomaly.Deviations = defaultDeviations
+			}
+			if anomaly.MinSamples <= 0 {
+				anomaly.MinSamples = defaultMinSamples
+			}
+			r.Anomaly = &anomaly
+		}
+
+		switch {
+		case r.Metric != MetricCPU && r.Metric != MetricMemory:
+			return nil, fmt.Errorf("metric rule %q has invalid metric %q, expected cpu or memory", r.Name, r.Metric)
+		case r.Of != "" && r.Of != OfRequest && r.Of != OfLimit:
+			return nil, fmt.Errorf("metric rule %q has invalid of %q, expected request or limit", r.Name, r.Of)
+		case r.Anomaly == nil && r.Above <= 0:
+			return nil, fmt.Errorf("metric rule %q needs above or anomaly", r.Name)
+		case r.Anomaly != nil && (r.Above != 0 || r.Of != ""):
+			return nil, fmt.Errorf("metric rule %q can't have both above and anomaly", r.Name)
+		}
+		for _, kind := range r.Kinds {
+			if kind != "Pod" && kind != "Deployment" {
+				return nil, fmt.Errorf("metric rule %q has invalid kind %q, expected Pod or Deployment", r.Name, kind)
+			}
+		}
+		if err := checkPatterns(r.Clusters, r.Namespaces, r.Names); err != nil {
+			return nil, fmt.Errorf("metric rule %q has %w", r.Name, err)
+		}
+		compiled = append(compiled, &r)
+	}
+	return compiled, nil
+}
+
+// checkPatterns validates lists of glob patterns
+func checkPatterns(lists ...[]string) error {
+	for _, patterns := range lists {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// matchAny reports whether value matches one of the glob patterns. No
+// patterns match everything.
+func matchAny(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// message describes an alert of the rule after count matching lines
+func (r *logRule) message(count int) string {
+	lines := "lines"
+	if count == 1 {
+		lines = "line"
+	}
+	return fmt.Sprintf("%d %s %s within %s", count, lines, r.describe(), time.Duration(r.Window))
+}
+
+// describe summarizes what a rule counts
+func (r *logRule) describe() string {
+	var parts []string
+	if r.Pattern != "" {
+		parts = append(parts, fmt.Sprintf("matching %q", r.Pattern))
+	}
+	if len(r.Levels) > 0 {
+		parts = append(parts, "at level "+strings.Join(r.Levels, "/"))
+	}
+	if r.FieldSelector != "" {
+		parts = append(parts, "with fields "+r.FieldSelector)
+	}
+	if r.Selector != "" {
+		parts = append(parts, "from pods "+r.Selector)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thekubefleet/kubefleet/internal/config"
+)
+
+// Rule grants the listed subjects and groups access to namespaces in
+// clusters. Clusters and namespaces accept glob patterns such as "team-a-*"
+// and "*".
+type Rule struct {
+	Subjects   []string `json:"subjects"`
+	Groups     []string `json:"groups"`
+	Clusters   []string `json:"clusters"`
+	Namespaces []string `json:"namespaces"`
+}
+
+// Policy is the authorization configuration loaded from disk
+type Policy struct {
+	Rules []Rule `json:"rules"`
+}
+
+// ParsePolicy parses a JSON policy and validates its patterns
+func ParsePolicy(data []byte) (*Policy, error) {
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+
+	for i, rule := range policy.Rules {
+		for _, pattern := range append(append([]string{}, rule.Clusters...), rule.Namespaces...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("rule %d has invalid pattern %q: %w", i, pattern, err)
+			}
+		}
+	}
+	return &policy, nil
+}
+
+func (r Rule) appliesTo(identity *Identity) bool {
+	for _, subject := range r.Subjects {
+		if subject == identity.Subject {
+			return true
+		}
+	}
+	for _, group := range r.Groups {
+		for _, member := range identity.Groups {
+			if group == member {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Scope is the set of clusters and namespaces an identity may see. A nil
+// Scope is unrestricted.
+type Scope struct {
+	rules []Rule
+}
+
+// AllowsCluster reports whether any namespace of the cluster is visible
+func (s *Scope) AllowsCluster(cluster string) bool {
+	if s == nil {
+		return true
+	}
+	for _, rule := range s.rules {
+		if matchAny(rule.Clusters, cluster) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows reports whether a namespace of a cluster is visible
+func (s *Scope) Allows(cluster, namespace string) bool {
+	if s == nil {
+		return true
+	}
+	for _, rule := range s.rules {
+		if matchAny(rule.Clusters, cluster) && matchAny(rule.Namespaces, namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsAllNamespaces reports whether the whole cluster is visible, with no
+// namespace restriction
+func (s *Scope) AllowsAllNamespaces(cluster string) bool {
+	if s == nil {
+		return true
+	}
+	for _, rule := range s.rules {
+		if !matchAny(rule.Clusters, cluster) {
+			continue
+		}
+		for _, pattern := range rule.Namespaces {
+			// Only a pattern of stars matches every namespace name
+			if pattern != "" && strings.Trim(pattern, "*") == "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Authorizer maps identities to the clusters and namespaces they may see
+type Authorizer struct {
+	load config.LoadFunc
+
+	mu     sync.RWMutex
+	policy *Policy
+}
+
+// NewAuthorizer creates an authorizer and performs the initial load
+func NewAuthorizer(ctx context.Context, load config.LoadFunc) (*Authorizer, error) {
+	a := &Authorizer{load: load}
+	if err := a.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Reload replaces the policy with the current contents of the source
+func (a *Authorizer) Reload(ctx context.Context) error {
+	data, err := a.load(ctx)
+	if err != nil {
+		return err
+	}
+	policy, err := ParsePolicy(data)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.policy = policy
+	return nil
+}
+
+// Watch reloads the policy every interval until ctx is done
+func (a *Authorizer) Watch(ctx context.Context, interval time.Duration) {
+	config.Watch(ctx, interval, "authorization policy", a.Reload)
+}
+
+// Scope returns what an identity may see. A nil identity sees nothing.
+func (a *Authorizer) Scope(identity *Identity) *Scope {
+	scope := &Scope{}
+	if identity == nil {
+		return scope
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	for _, rule := range a.policy.Rules {
+		if rule.appliesTo(identity) {
+			scope.rules = append(scope.rules, rule)
+		}
+	}
+	return scope
+}
@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"context"
+	"testing"
+)
+
+func TestScope(t *testing.T) {
+	policy := `{"rules": [
+		{"groups": ["platform"], "clusters": ["*"], "namespaces": ["*"]},
+		{"subjects": ["alice"], "clusters": ["prod-eu"], "namespaces": ["team-a-*"]},
+		{"subjects": ["bob"], "clusters": ["staging"], "namespaces": ["?"]}
+	]}`
+	authorizer, err := NewAuthorizer(context.Background(), func(context.Context) ([]byte, error) { return []byte(policy), nil })
+	if err != nil {
+		t.Fatalf("NewAuthorizer: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		identity      *Identity
+		cluster       string
+		namespace     string
+		allowsCluster bool
+		allows        bool
+		allNamespaces bool
+	}{
+		{name: "group", identity: &Identity{Subject: "carol", Groups: []string{"platform"}}, cluster: "prod-us", namespace: "kube-system", allowsCluster: true, allows: true, allNamespaces: true},
+		{name: "namespace pattern", identity: &Identity{Subject: "alice"}, cluster: "prod-eu", namespace: "team-a-web", allowsCluster: true, allows: true},
+		{name: "other namespace", identity: &Identity{Subject: "alice"}, cluster: "prod-eu", namespace: "team-b", allowsCluster: true},
+		{name: "other cluster", identity: &Identity{Subject: "alice"}, cluster: "prod-us", namespace: "team-a-web"},
+		{name: "single character pattern", identity: &Identity{Subject: "bob"}, cluster: "staging", namespace: "*", allowsCluster: true, allows: true},
+		{name: "no rules", identity: &Identity{Subject: "mallory"}, cluster: "prod-eu", namespace: "default"},
+		{name: "anonymous", cluster: "prod-eu", namespace: "default"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scope := authorizer.Scope(tt.identity)
+			if got := scope.AllowsCluster(tt.cluster); got != tt.allowsCluster {
+				t.Errorf("AllowsCluster(%q) = %v, want %v", tt.cluster, got, tt.allowsCluster)
+			}
+			if got := scope.Allows(tt.cluster, tt.namespace); got != tt.allows {
+				t.Errorf("Allows(%q, %q) = %v, want %v", tt.cluster, tt.namespace, got, tt.allows)
+			}
+			if got := scope.AllowsAllNamespaces(tt.cluster); got != tt.allNamespaces {
+				t.Errorf("AllowsAllNamespaces(%q) = %v, want %v", tt.cluster, got, tt.allNamespaces)
+			}
+		})
+	}
+
+	var unrestricted *Scope
+	if !unrestricted.AllowsAllNamespaces("any") {
+		t.Errorf("a nil scope doesn't allow all namespaces")
+	}
+}
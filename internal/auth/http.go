@@ -0,0 +1,16 @@
+package auth
+
+import (
+	"net/http"
+)
+
+// Authenticator identifies the caller of an HTTP request
+type Authenticator interface {
+	AuthenticateRequest(r *http.Request) (*Identity, bool)
+}
+
+// AuthenticateRequest implements Authenticator using the request's
+// "Authorization: Bearer" header
+func (s *TokenStore) AuthenticateRequest(r *http.Request) (*Identity, bool) {
+	return s.Authenticate(bearerToken(r.Header.Get("Authorization")))
+}
@@ -0,0 +1,364 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+const (
+	sessionCookie = "kubefleet_session"
+	stateCookie   = "kubefleet_oidc_state"
+
+	// loginTimeout bounds how long a user may take at the identity provider
+	loginTimeout = 10 * time.Minute
+)
+
+// OIDCConfig configures the dashboard as an OIDC relying party
+type OIDCConfig struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the externally reachable /api/auth/callback URL
+	RedirectURL string
+	Scopes      []string
+	// UsernameClaim is used as the identity subject, falling back to "sub"
+	UsernameClaim string
+	GroupsClaim   string
+	SessionTTL    time.Duration
+}
+
+type session struct {
+	identity *Identity
+	claims   map[string]interface{}
+	idToken  string
+	expires  time.Time
+}
+
+type pendingLogin struct {
+	verifier string
+	nonce    string
+	redirect string
+	expires  time.Time
+}
+
+// OIDC implements the authorization-code flow with PKCE and keeps
+// server-side sessions referenced by an HttpOnly cookie
+type OIDC struct {
+	cfg          OIDCConfig
+	oauth2       oauth2.Config
+	verifier     *oidc.IDTokenVerifier
+	endSession   string
+	secureCookie bool
+	now          func() time.Time
+
+	mu       sync.Mutex
+	sessions map[string]*session
+	pending  map[string]*pendingLogin
+}
+
+// NewOIDC discovers the provider configuration from the issuer
+func NewOIDC(ctx context.Context, cfg OIDCConfig) (*OIDC, error) {
+	if cfg.IssuerURL == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, errors.New("OIDC requires an issuer URL, client ID and redirect URL")
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{oidc.ScopeOpenID, "profile", "email"}
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+	if cfg.SessionTTL <= 0 {
+		cfg.SessionTTL = 8 * time.Hour
+	}
+
+	provider, err := oidc.NewProvider(ctx, cfg.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover OIDC provider %s: %w", cfg.IssuerURL, err)
+	}
+
+	var metadata struct {
+		EndSessionEndpoint string `json:"end_session_endpoint"`
+	}
+	if err := provider.Claims(&metadata); err != nil {
+		return nil, fmt.Errorf("failed to read OIDC provider metadata: %w", err)
+	}
+
+	return &OIDC{
+		cfg: cfg,
+		oauth2: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       cfg.Scopes,
+		},
+		verifier:     provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+		endSession:   metadata.EndSessionEndpoint,
+		secureCookie: strings.HasPrefix(cfg.RedirectURL, "https://"),
+		now:          time.Now,
+		sessions:     make(map[string]*session),
+		pending:      make(map[string]*pendingLogin),
+	}, nil
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// localRedirect only allows redirects to paths on this server
+func localRedirect(target string) string {
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\") {
+		return "/"
+	}
+	return target
+}
+
+// purge drops expired sessions and logins. Callers must hold o.mu.
+func (o *OIDC) purge(now time.Time) {
+	for id, s := range o.sessions {
+		if now.After(s.expires) {
+			delete(o.sessions, id)
+		}
+	}
+	for state, p := range o.pending {
+		if now.After(p.expires) {
+			delete(o.pending, state)
+		}
+	}
+}
+
+func (o *OIDC) setCookie(w http.ResponseWriter, name, value string, maxAge time.Duration) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   int(maxAge / time.Second),
+		HttpOnly: true,
+		Secure:   o.secureCookie,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (o *OIDC) clearCookie(w http.ResponseWriter, name string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   o.secureCookie,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// LoginURL returns the login path that returns the user to target
+func LoginURL(target string) string {
+	return "/api/login?redirect=" + url.QueryEscape(localRedirect(target))
+}
+
+// HandleLogin starts the authorization-code flow
+func (o *OIDC) HandleLogin(w http.ResponseWriter, r *http.Request) {
+	state, err := randomString()
+	if err != nil {
+		http.Error(w, "failed to start login", http.StatusInternalServerError)
+		return
+	}
+	nonce, err := randomString()
+	if err != nil {
+		http.Error(w, "failed to start login", http.StatusInternalServerError)
+		return
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	now := o.now()
+	o.mu.Lock()
+	o.purge(now)
+	o.pending[state] = &pendingLogin{
+		verifier: verifier,
+		nonce:    nonce,
+		redirect: localRedirect(r.URL.Query().Get("redirect")),
+		expires:  now.Add(loginTimeout),
+	}
+	o.mu.Unlock()
+
+	// Bind the login to this browser so a callback can't be replayed elsewhere
+	o.setCookie(w, stateCookie, state, loginTimeout)
+	http.Redirect(w, r, o.oauth2.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), http.StatusFound)
+}
+
+// HandleCallback completes the flow and creates a session
+func (o *OIDC) HandleCallback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if errCode := query.Get("error"); errCode != "" {
+		http.Error(w, fmt.Sprintf("login failed: %s %s", errCode, query.Get("error_description")), http.StatusUnauthorized)
+		return
+	}
+
+	state := query.Get("state")
+	cookie, err := r.Cookie(stateCookie)
+	if err != nil || state == "" || cookie.Value != state {
+		http.Error(w, "invalid login state", http.StatusBadRequest)
+		return
+	}
+	o.clearCookie(w, stateCookie)
+
+	o.mu.Lock()
+	pending, ok := o.pending[state]
+	delete(o.pending, state)
+	o.mu.Unlock()
+	if !ok || o.now().After(pending.expires) {
+		http.Error(w, "login expired, please try again", http.StatusBadRequest)
+		return
+	}
+
+	token, err := o.oauth2.Exchange(r.Context(), query.Get("code"), oauth2.VerifierOption(pending.verifier))
+	if err != nil {
+		log.Printf("OIDC code exchange failed: %v", err)
+		http.Error(w, "login failed", http.StatusUnauthorized)
+		return
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		http.Error(w, "login failed: no ID token returned", http.StatusUnauthorized)
+		return
+	}
+	idToken, err := o.verifier.Verify(r.Context(), rawIDToken)
+	if err != nil {
+		log.Printf("OIDC ID token verification failed: %v", err)
+		http.Error(w, "login failed", http.StatusUnauthorized)
+		return
+	}
+	if idToken.Nonce != pending.nonce {
+		http.Error(w, "login failed: nonce mismatch", http.StatusUnauthorized)
+		return
+	}
+
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		http.Error(w, "login failed: invalid claims", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := randomString()
+	if err != nil {
+		http.Error(w, "failed to create session", http.StatusInternalServerError)
+		return
+	}
+
+	now := o.now()
+	o.mu.Lock()
+	o.purge(now)
+	o.sessions[id] = &session{
+		identity: o.identityFromClaims(idToken.Subject, claims),
+		claims:   claims,
+		idToken:  rawIDToken,
+		expires:  now.Add(o.cfg.SessionTTL),
+	}
+	o.mu.Unlock()
+
+	o.setCookie(w, sessionCookie, id, o.cfg.SessionTTL)
+	http.Redirect(w, r, pending.redirect, http.StatusFound)
+}
+
+func (o *OIDC) identityFromClaims(subject string, claims map[string]interface{}) *Identity {
+	identity := &Identity{Subject: subject, UID: subject}
+	if o.cfg.UsernameClaim != "" {
+		if username, ok := claims[o.cfg.UsernameClaim].(string); ok && username != "" {
+			identity.Subject = username
+		}
+	}
+
+	switch groups := claims[o.cfg.GroupsClaim].(type) {
+	case []interface{}:
+		for _, group := range groups {
+			if g, ok := group.(string); ok {
+				identity.Groups = append(identity.Groups, g)
+			}
+		}
+	case string:
+		identity.Groups = []string{groups}
+	}
+	return identity
+}
+
+func (o *OIDC) session(r *http.Request) (string, *session, bool) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return "", nil, false
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	s, ok := o.sessions[cookie.Value]
+	if !ok {
+		return "", nil, false
+	}
+	if o.now().After(s.expires) {
+		delete(o.sessions, cookie.Value)
+		return "", nil, false
+	}
+	return cookie.Value, s, true
+}
+
+// HandleLogout ends the session and, if the provider supports it, the
+// provider session too
+func (o *OIDC) HandleLogout(w http.ResponseWriter, r *http.Request) {
+	id, s, ok := o.session(r)
+	if ok {
+		o.mu.Lock()
+		delete(o.sessions, id)
+		o.mu.Unlock()
+	}
+	o.clearCookie(w, sessionCookie)
+
+	if ok && o.endSession != "" {
+		redirect, err := url.Parse(o.endSession)
+		if err == nil {
+			postLogout, _ := url.Parse(o.cfg.RedirectURL)
+			postLogout.Path = "/"
+			postLogout.RawQuery = ""
+			query := redirect.Query()
+			query.Set("id_token_hint", s.idToken)
+			query.Set("post_logout_redirect_uri", postLogout.String())
+			redirect.RawQuery = query.Encode()
+			http.Redirect(w, r, redirect.String(), http.StatusFound)
+			return
+		}
+	}
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// AuthenticateRequest implements Authenticator using the session cookie
+func (o *OIDC) AuthenticateRequest(r *http.Request) (*Identity, bool) {
+	_, s, ok := o.session(r)
+	if !ok {
+		return nil, false
+	}
+	return s.identity, true
+}
+
+// Claims returns the ID token claims of the request's session
+func (o *OIDC) Claims(r *http.Request) (map[string]interface{}, bool) {
+	_, s, ok := o.session(r)
+	if !ok {
+		return nil, false
+	}
+	return s.claims, true
+}
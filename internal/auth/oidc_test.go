@@ -0,0 +1,377 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeProvider is an OIDC provider that issues codes for the logins a test
+// approves and checks the PKCE verifier when they are exchanged
+type fakeProvider struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu     sync.Mutex
+	logins map[string]url.Values // Authorization requests by the code issued for them
+	claims map[string]interface{}
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	p := &fakeProvider{t: t, key: key, logins: make(map[string]url.Values)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                p.server.URL,
+			"authorization_endpoint":                p.server.URL + "/authorize",
+			"token_endpoint":                        p.server.URL + "/token",
+			"jwks_uri":                              p.server.URL + "/keys",
+			"end_session_endpoint":                  p.server.URL + "/logout",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"alg": "RS256",
+				"use": "sig",
+				"kid": "test",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", p.handleToken)
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+// approve has the user consent to the authorization request at location and
+// returns the code the provider redirects back with
+func (p *fakeProvider) approve(location string) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		p.t.Fatalf("invalid authorization URL %q: %v", location, err)
+	}
+	if !strings.HasPrefix(location, p.server.URL+"/authorize") {
+		p.t.Fatalf("login redirected to %s, want the provider", location)
+	}
+	query := u.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		p.t.Fatalf("authorization request has no S256 code challenge: %s", u.RawQuery)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	code := "code-" + query.Get("state")
+	p.logins[code] = query
+	return code
+}
+
+func (p *fakeProvider) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	p.mu.Lock()
+	login, ok := p.logins[r.PostForm.Get("code")]
+	delete(p.logins, r.PostForm.Get("code"))
+	claims := p.claims
+	p.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != login.Get("code_challenge") {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	idClaims := map[string]interface{}{
+		"iss":   p.server.URL,
+		"aud":   login.Get("client_id"),
+		"sub":   "user-1",
+		"nonce": login.Get("nonce"),
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}
+	for name, value := range claims {
+		idClaims[name] = value
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     p.sign(idClaims),
+	})
+}
+
+func (p *fakeProvider) sign(claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		p.t.Fatalf("failed to encode claims: %v", err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, digest[:])
+	if err != nil {
+		p.t.Fatalf("failed to sign ID token: %v", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func newTestOIDC(t *testing.T, p *fakeProvider) *OIDC {
+	o, err := NewOIDC(context.Background(), OIDCConfig{
+		IssuerURL:     p.server.URL,
+		ClientID:      "kubefleet",
+		ClientSecret:  "secret",
+		RedirectURL:   "https://kubefleet.example.com/api/auth/callback",
+		UsernameClaim: "email",
+		SessionTTL:    time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("NewOIDC: %v", err)
+	}
+	return o
+}
+
+// startLogin runs HandleLogin and returns the state cookie it set and the
+// provider URL it redirected to
+func startLogin(t *testing.T, o *OIDC, target string) (*http.Cookie, string) {
+	w := httptest.NewRecorder()
+	o.HandleLogin(w, httptest.NewRequest(http.MethodGet, LoginURL(target), nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("login returned %d, want %d", w.Code, http.StatusFound)
+	}
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == stateCookie {
+			return cookie, w.Header().Get("Location")
+		}
+	}
+	t.Fatalf("login set no state cookie")
+	return nil, ""
+}
+
+func callback(o *OIDC, query url.Values, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/api/auth/callback?"+query.Encode(), nil)
+	for _, cookie := range cookies {
+		r.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	o.HandleCallback(w, r)
+	return w
+}
+
+func sessionCookieOf(w *httptest.ResponseRecorder) *http.Cookie {
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == sessionCookie && cookie.Value != "" {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func login(t *testing.T, p *fakeProvider, o *OIDC) *http.Cookie {
+	state, location := startLogin(t, o, "/clusters")
+	code := p.approve(location)
+	w := callback(o, url.Values{"code": {code}, "state": {state.Value}}, state)
+	if w.Code != http.StatusFound {
+		t.Fatalf("callback returned %d: %s", w.Code, w.Body.String())
+	}
+	cookie := sessionCookieOf(w)
+	if cookie == nil {
+		t.Fatalf("callback set no session cookie")
+	}
+	return cookie
+}
+
+func TestOIDCLoginWithPKCE(t *testing.T) {
+	p := newFakeProvider(t)
+	p.claims = map[string]interface{}{"email": "alice@example.com", "groups": []string{"platform", "oncall"}}
+	o := newTestOIDC(t, p)
+
+	state, location := startLogin(t, o, "/clusters")
+	code := p.approve(location)
+	w := callback(o, url.Values{"code": {code}, "state": {state.Value}}, state)
+	if w.Code != http.StatusFound {
+		t.Fatalf("callback returned %d: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Location"); got != "/clusters" {
+		t.Errorf("callback redirected to %q, want /clusters", got)
+	}
+	cookie := sessionCookieOf(w)
+	if cookie == nil {
+		t.Fatalf("callback set no session cookie")
+	}
+	if !cookie.HttpOnly || !cookie.Secure {
+		t.Errorf("session cookie is not HttpOnly and Secure: %+v", cookie)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/api/me", nil)
+	r.AddCookie(cookie)
+	identity, ok := o.AuthenticateRequest(r)
+	if !ok {
+		t.Fatalf("session cookie was not accepted")
+	}
+	if identity.Subject != "alice@example.com" || identity.UID != "user-1" {
+		t.Errorf("identity = %+v, want subject alice@example.com and UID user-1", identity)
+	}
+	if strings.Join(identity.Groups, ",") != "platform,oncall" {
+		t.Errorf("groups = %v, want [platform oncall]", identity.Groups)
+	}
+
+	// The state is single use
+	w = callback(o, url.Values{"code": {code}, "state": {state.Value}}, state)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("replayed callback returned %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestOIDCExchangeRequiresVerifier(t *testing.T) {
+	p := newFakeProvider(t)
+	o := newTestOIDC(t, p)
+
+	state, location := startLogin(t, o, "/")
+	code := p.approve(location)
+
+	// A second login has its own verifier, which doesn't match the code
+	other, _ := startLogin(t, o, "/")
+	o.mu.Lock()
+	o.pending[state.Value].verifier = o.pending[other.Value].verifier
+	o.mu.Unlock()
+
+	w := callback(o, url.Values{"code": {code}, "state": {state.Value}}, state)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("callback with the wrong verifier returned %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if sessionCookieOf(w) != nil {
+		t.Errorf("callback with the wrong verifier created a session")
+	}
+}
+
+func TestOIDCCallbackStateMismatch(t *testing.T) {
+	p := newFakeProvider(t)
+	o := newTestOIDC(t, p)
+
+	state, location := startLogin(t, o, "/")
+	code := p.approve(location)
+	other, _ := startLogin(t, o, "/")
+
+	tests := []struct {
+		name    string
+		state   string
+		cookies []*http.Cookie
+	}{
+		{name: "no cookie", state: state.Value},
+		{name: "cookie of another login", state: state.Value, cookies: []*http.Cookie{other}},
+		{name: "no state", cookies: []*http.Cookie{state}},
+		{name: "unknown state", state: "forged", cookies: []*http.Cookie{{Name: stateCookie, Value: "forged"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := callback(o, url.Values{"code": {code}, "state": {tt.state}}, tt.cookies...)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("callback returned %d, want %d", w.Code, http.StatusBadRequest)
+			}
+			if sessionCookieOf(w) != nil {
+				t.Errorf("callback created a session")
+			}
+		})
+	}
+
+	// The rejected callbacks leave the real login usable
+	w := callback(o, url.Values{"code": {code}, "state": {state.Value}}, state)
+	if w.Code != http.StatusFound {
+		t.Errorf("callback returned %d after rejected attempts: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestOIDCLoginExpiry(t *testing.T) {
+	p := newFakeProvider(t)
+	o := newTestOIDC(t, p)
+	now := time.Now()
+	o.now = func() time.Time { return now }
+
+	state, location := startLogin(t, o, "/")
+	code := p.approve(location)
+
+	now = now.Add(loginTimeout + time.Second)
+	w := callback(o, url.Values{"code": {code}, "state": {state.Value}}, state)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("late callback returned %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestOIDCSessionExpiry(t *testing.T) {
+	p := newFakeProvider(t)
+	o := newTestOIDC(t, p)
+	now := time.Now()
+	o.now = func() time.Time { return now }
+
+	cookie := login(t, p, o)
+	r := httptest.NewRequest(http.MethodGet, "/api/me", nil)
+	r.AddCookie(cookie)
+
+	now = now.Add(59 * time.Minute)
+	if _, ok := o.AuthenticateRequest(r); !ok {
+		t.Fatalf("session expired before its TTL")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := o.AuthenticateRequest(r); ok {
+		t.Fatalf("session was accepted after its TTL")
+	}
+	o.mu.Lock()
+	sessions := len(o.sessions)
+	o.mu.Unlock()
+	if sessions != 0 {
+		t.Errorf("%d sessions kept after expiry, want 0", sessions)
+	}
+}
+
+func TestOIDCLogout(t *testing.T) {
+	p := newFakeProvider(t)
+	o := newTestOIDC(t, p)
+	cookie := login(t, p, o)
+
+	r := httptest.NewRequest(http.MethodPost, "/api/logout", nil)
+	r.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	o.HandleLogout(w, r)
+
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil || !strings.HasPrefix(location.String(), p.server.URL+"/logout") {
+		t.Fatalf("logout redirected to %q, want the provider's end session endpoint", w.Header().Get("Location"))
+	}
+	if got := location.Query().Get("post_logout_redirect_uri"); got != "https://kubefleet.example.com/" {
+		t.Errorf("post_logout_redirect_uri = %q", got)
+	}
+	if _, ok := o.AuthenticateRequest(r); ok {
+		t.Errorf("session was accepted after logout")
+	}
+}
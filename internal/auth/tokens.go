@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thekubefleet/kubefleet/internal/config"
+)
+
+// Identity is an authenticated caller
+type Identity struct {
+	Subject string   `json:"subject"`
+	UID     string   `json:"uid,omitempty"`
+	Groups  []string `json:"groups,omitempty"`
+}
+
+type contextKey struct{}
+
+// ContextWithIdentity returns a copy of ctx carrying the identity
+func ContextWithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, identity)
+}
+
+// IdentityFromContext returns the identity stored in ctx, if any
+func IdentityFromContext(ctx context.Context) (*Identity, bool) {
+	identity, ok := ctx.Value(contextKey{}).(*Identity)
+	return identity, ok
+}
+
+// ParseTokens parses a token file in the Kubernetes static token format:
+// one "token,subject,uid,\"group1,group2\"" record per line. The uid and
+// groups columns are optional and lines starting with # are ignored.
+func ParseTokens(data []byte) (map[string]*Identity, error) {
+	reader := csv.NewReader(strings.NewReader(string(data)))
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	tokens := make(map[string]*Identity)
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse tokens: %w", err)
+		}
+		if len(record) < 2 || record[0] == "" || record[1] == "" {
+			return nil, fmt.Errorf("token record %d needs at least a token and a subject", line)
+		}
+
+		identity := &Identity{Subject: record[1]}
+		if len(record) > 2 {
+			identity.UID = record[2]
+		}
+		if len(record) > 3 && record[3] != "" {
+			for _, group := range strings.Split(record[3], ",") {
+				identity.Groups = append(identity.Groups, strings.TrimSpace(group))
+			}
+		}
+		tokens[hashToken(record[0])] = identity
+	}
+	return tokens, nil
+}
+
+// hashToken keys tokens by their digest so lookups don't compare secrets
+// byte by byte
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return string(sum[:])
+}
+
+// TokenStore validates bearer tokens and reloads them periodically so tokens
+// can be rotated without a restart
+type TokenStore struct {
+	load config.LoadFunc
+
+	mu     sync.RWMutex
+	tokens map[string]*Identity
+}
+
+// NewTokenStore creates a token store and performs the initial load
+func NewTokenStore(ctx context.Context, load config.LoadFunc) (*TokenStore, error) {
+	s := &TokenStore{load: load}
+	if err := s.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload replaces the tokens with the current contents of the source
+func (s *TokenStore) Reload(ctx context.Context) error {
+	data, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+	tokens, err := ParseTokens(data)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = tokens
+	return nil
+}
+
+// Watch reloads the tokens every interval until ctx is done
+func (s *TokenStore) Watch(ctx context.Context, interval time.Duration) {
+	config.Watch(ctx, interval, "tokens", s.Reload)
+}
+
+// Authenticate returns the identity a token belongs to
+func (s *TokenStore) Authenticate(token string) (*Identity, bool) {
+	if token == "" {
+		return nil, false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	identity, ok := s.tokens[hashToken(token)]
+	return identity, ok
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer" value
+func bearerToken(header string) string {
+	const prefix = "bearer "
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return ""
+	}
+	return strings.TrimSpace(header[len(prefix):])
+}
@@ -0,0 +1,86 @@
+package boltdb
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// openTimeout is how long Open waits for another process to release the
+// database
+const openTimeout = 5 * time.Second
+
+// Open opens or creates the bbolt database at path. What names the store
+// in errors.
+func Open(path, what string) (*bolt.DB, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s %s: %w", what, path, err)
+	}
+	return db, nil
+}
+
+// OpenBucket opens or creates the bbolt database at path with a bucket and
+// calls load with each key and value stored in it
+func OpenBucket(path, what string, bucket []byte, load func(key, value []byte) error) (*bolt.DB, error) {
+	db, err := Open(path, what)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(bucket)
+		if err != nil {
+			return fmt.Errorf("failed to create %s bucket: %w", what, err)
+		}
+		return b.ForEach(load)
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Key encodes an id as 8 big-endian bytes, so keys sort by id
+func Key(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
+
+// ID decodes a key created by Key
+func ID(key []byte) uint64 {
+	return binary.BigEndian.Uint64(key)
+}
+
+// Retainer is a store that drops data that has aged out
+type Retainer interface {
+	EnforceRetention() error
+}
+
+// EnforceRetention enforces a store's retention, logging failures
+func EnforceRetention(what string, r Retainer) {
+	if err := r.EnforceRetention(); err != nil {
+		log.Printf("Failed to enforce %s retention: %v", what, err)
+	}
+}
+
+// RunRetention enforces a store's retention every interval until ctx is
+// done
+func RunRetention(ctx context.Context, interval time.Duration, what string, r Retainer) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			EnforceRetention(what, r)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package boltdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestOpenBucket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.db")
+	bucket := []byte("lines")
+	load := func(key, value []byte) error {
+		t.Errorf("loaded %x from a new database", key)
+		return nil
+	}
+	db, err := OpenBucket(path, "log store", bucket, load)
+	if err != nil {
+		t.Fatalf("OpenBucket: %v", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, id := range []uint64{256, 2, 1} {
+			if err := tx.Bucket(bucket).Put(Key(id), []byte("line")); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	db.Close()
+
+	// Records are loaded in id order
+	var ids []uint64
+	db, err = OpenBucket(path, "log store", bucket, func(key, value []byte) error {
+		ids = append(ids, ID(key))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("OpenBucket: %v", err)
+	}
+	db.Close()
+	if !slices.Equal(ids, []uint64{1, 2, 256}) {
+		t.Errorf("loaded ids %v, want 1, 2 and 256", ids)
+	}
+
+	// A failed load closes the database again
+	_, err = OpenBucket(path, "log store", bucket, func(key, value []byte) error {
+		return errors.New("corrupt")
+	})
+	if err == nil {
+		t.Fatalf("OpenBucket succeeded although load failed")
+	}
+	db, err = Open(path, "log store")
+	if err != nil {
+		t.Fatalf("database wasn't closed after the failed load: %v", err)
+	}
+	db.Close()
+}
+
+func TestKey(t *testing.T) {
+	if !bytes.Equal(Key(1), []byte{0, 0, 0, 0, 0, 0, 0, 1}) || ID(Key(1<<40+7)) != 1<<40+7 {
+		t.Errorf("Key(1) = %x", Key(1))
+	}
+	if bytes.Compare(Key(255), Key(256)) >= 0 {
+		t.Errorf("keys don't sort by id")
+	}
+}
+
+type retainer struct {
+	calls atomic.Int32
+	stop  func()
+}
+
+func (r *retainer) EnforceRetention() error {
+	if r.calls.Add(1) >= 3 {
+		r.stop()
+	}
+	return errors.New("disk full")
+}
+
+func TestRunRetention(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &retainer{stop: cancel}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		RunRetention(ctx, time.Millisecond, "log", r)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("RunRetention didn't return once ctx was done")
+	}
+	if n := r.calls.Load(); n < 3 {
+		t.Errorf("enforced retention %d times, want 3", n)
+	}
+}
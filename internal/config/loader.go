@@ -0,0 +1,61 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// LoadFunc returns the raw contents of a configuration source, such as a
+// token file or a rule file
+type LoadFunc func(ctx context.Context) ([]byte, error)
+
+// FileLoader reads a file on disk
+func FileLoader(path string) LoadFunc {
+	return func(ctx context.Context) ([]byte, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		return data, nil
+	}
+}
+
+// SecretGetter fetches the data of a Kubernetes Secret
+type SecretGetter func(ctx context.Context, namespace, name string) (map[string][]byte, error)
+
+// SecretLoader reads a key of a Kubernetes Secret
+func SecretLoader(get SecretGetter, namespace, name, key string) LoadFunc {
+	return func(ctx context.Context) ([]byte, error) {
+		data, err := get(ctx, namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		value, ok := data[key]
+		if !ok {
+			return nil, fmt.Errorf("secret %s/%s has no key %q", namespace, name, key)
+		}
+		return value, nil
+	}
+}
+
+// Watch calls reload every interval until ctx is done, logging failures as
+// failures to reload what. A reload that fails must leave the previous
+// configuration in use.
+func Watch(ctx context.Context, interval time.Duration, what string, reload func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := reload(ctx); err != nil {
+				log.Printf("Failed to reload %s: %v", what, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFileLoader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	load := FileLoader(path)
+	if _, err := load(context.Background()); err == nil {
+		t.Errorf("loading a missing file succeeded")
+	}
+
+	os.WriteFile(path, []byte(`{"logRules": []}`), 0o600)
+	data, err := load(context.Background())
+	if err != nil || string(data) != `{"logRules": []}` {
+		t.Errorf("load() = %q, %v", data, err)
+	}
+}
+
+func TestSecretLoader(t *testing.T) {
+	get := func(ctx context.Context, namespace, name string) (map[string][]byte, error) {
+		if namespace != "kubefleet" || name != "tokens" {
+			return nil, errors.New("not found")
+		}
+		return map[string][]byte{"tokens.csv": []byte("secret,alice")}, nil
+	}
+
+	data, err := SecretLoader(get, "kubefleet", "tokens", "tokens.csv")(context.Background())
+	if err != nil || string(data) != "secret,alice" {
+		t.Errorf("load() = %q, %v", data, err)
+	}
+	if _, err := SecretLoader(get, "kubefleet", "tokens", "other.csv")(context.Background()); err == nil {
+		t.Errorf("loading a missing key succeeded")
+	}
+	if _, err := SecretLoader(get, "default", "tokens", "tokens.csv")(context.Background()); err == nil {
+		t.Errorf("loading a missing secret succeeded")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls atomic.Int32
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Watch(ctx, time.Millisecond, "rules", func(context.Context) error {
+			// Failures are logged and the next tick reloads again
+			if calls.Add(1) >= 3 {
+				cancel()
+			}
+			return errors.New("invalid rules")
+		})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Watch didn't return once ctx was done")
+	}
+	if n := calls.Load(); n < 3 {
+		t.Errorf("reloaded %d times, want 3", n)
+	}
+}
@@ -0,0 +1,100 @@
+package eventstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	bolt "go.etcd.io/bbolt"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/thekubefleet/kubefleet/internal/boltdb"
+	agentpb "github.com/thekubefleet/kubefleet/proto"
+)
+
+// eventsBucket maps a cluster and event UID, joined by a NUL byte, to the
+// event's eventRecord
+var eventsBucket = []byte("events")
+
+type eventRecord struct {
+	Cluster string `json:"cluster"`
+	Event   []byte `json:"event"`
+}
+
+// Open opens or creates a store persisted in the bbolt database at path,
+// loading the events still within retention
+func Open(path string, retention Retention) (*Store, error) {
+	s := NewStore(retention)
+	db, err := boltdb.OpenBucket(path, "event store", eventsBucket, s.load)
+	if err != nil {
+		return nil, err
+	}
+	s.db = db
+
+	boltdb.EnforceRetention("event", s)
+	return s, nil
+}
+
+// load reads a stored event into memory
+func (s *Store) load(key, value []byte) error {
+	var record eventRecord
+	event := &agentpb.Event{}
+	if err := json.Unmarshal(value, &record); err != nil || proto.Unmarshal(record.Event, event) != nil {
+		log.Printf("Skipping unreadable event %s", strings.ReplaceAll(string(key), "\x00", "/"))
+		return nil
+	}
+	s.events[eventKey{record.Cluster, event.Uid}] = event
+	return nil
+}
+
+func (k eventKey) bytes() []byte {
+	return []byte(k.cluster + "\x00" + k.uid)
+}
+
+// persist writes added or changed events and deletes evicted ones. Callers
+// must hold s.mu.
+func (s *Store) persist(added, evicted []eventKey) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(eventsBucket)
+		for _, key := range evicted {
+			if err := bucket.Delete(key.bytes()); err != nil {
+				return err
+			}
+		}
+		for _, key := range added {
+			event, ok := s.events[key]
+			if !ok {
+				// Added and evicted by the same report
+				continue
+			}
+			data, err := proto.Marshal(event)
+			if err != nil {
+				return err
+			}
+			value, err := json.Marshal(eventRecord{Cluster: key.cluster, Event: data})
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put(key.bytes(), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to persist events: %w", err)
+	}
+	return nil
+}
+
+// Close closes the database of a persistent store
+func (s *Store) Close() error {
+	if s.db == nil {
+		return nil
+	}
+	if err := s.db.Close(); err != nil {
+		return fmt.Errorf("failed to close event store: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package eventstore
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/thekubefleet/kubefleet/internal/auth"
+	"github.com/thekubefleet/kubefleet/internal/boltdb"
+	agentpb "github.com/thekubefleet/kubefleet/proto"
+)
+
+// Retention limits how many events the store keeps. Zero values are
+// unlimited.
+type Retention struct {
+	// MaxAge drops events last seen longer ago than this
+	MaxAge time.Duration
+	// MaxEvents caps the events kept, dropping the least recently seen first
+	MaxEvents int
+}
+
+// Event is a stored event, with the cluster it happened in
+type Event struct {
+	Cluster string `json:"cluster"`
+	*agentpb.Event
+}
+
+// eventKey identifies an event across the reports that repeat it
+type eventKey struct {
+	cluster string
+	uid     string
+}
+
+// Query selects events. Empty fields match any event.
+type Query struct {
+	Scope     *auth.Scope // Events outside the scope are never returned
+	Cluster   string
+	Namespace string
+	Kind      string // Of the involved object
+	Name      string // Of the involved object
+	Type      string
+	Reason    string
+	From      int64 // On the last timestamp
+	To        int64 // 0 for no upper bound
+	Limit     int   // 0 for all
+}
+
+// Store keeps the latest version of each event agents report. Agents send
+// an event again whenever it repeats, replacing the stored one.
+type Store struct {
+	mu        sync.RWMutex
+	retention Retention
+	db        *bolt.DB // nil for an in-memory store
+	events    map[eventKey]*agentpb.Event
+	now       func() time.Time
+}
+
+// NewStore creates an in-memory store with the given retention
+func NewStore(retention Retention) *Store {
+	return &Store{
+		retention: retention,
+		events:    make(map[eventKey]*agentpb.Event),
+		now:       time.Now,
+	}
+}
+
+// Append stores the events of a cluster's report that are new or changed
+func (s *Store) Append(cluster string, events []*agentpb.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var cutoff int64
+	if s.retention.MaxAge > 0 {
+		cutoff = s.now().Add(-s.retention.MaxAge).Unix()
+	}
+
+	var added []eventKey
+	for _, event := range events {
+		if event.Uid == "" || event.LastTimestamp < cutoff {
+			continue
+		}
+		key := eventKey{cluster, event.Uid}
+		if old, ok := s.events[key]; ok && proto.Equal(old, event) {
+			continue
+		}
+		s.events[key] = event
+		added = append(added, key)
+	}
+
+	evicted := s.evict()
+	if s.db != nil && (len(added) > 0 || len(evicted) > 0) {
+		return s.persist(added, evicted)
+	}
+	return nil
+}
+
+// evict drops events while the store is over its retention limits and
+// returns their keys. Callers must hold s.mu.
+func (s *Store) evict() []eventKey {
+	var evicted []eventKey
+	if s.retention.MaxAge > 0 {
+		cutoff := s.now().Add(-s.retention.MaxAge).Unix()
+		for key, event := range s.events {
+			if event.LastTimestamp < cutoff {
+				delete(s.events, key)
+				evicted = append(evicted, key)
+			}
+		}
+	}
+
+	if s.retention.MaxEvents > 0 && len(s.events) > s.retention.MaxEvents {
+		keys := make([]eventKey, 0, len(s.events))
+		for key := range s.events {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return s.events[keys[i]].LastTimestamp < s.events[keys[j]].LastTimestamp
+		})
+		for _, key := range keys[:len(keys)-s.retention.MaxEvents] {
+			delete(s.events, key)
+			evicted = append(evicted, key)
+		}
+	}
+	return evicted
+}
+
+// EnforceRetention drops events that have aged out
+func (s *Store) EnforceRetention() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	evicted := s.evict()
+	if s.db != nil && len(evicted) > 0 {
+		return s.persist(nil, evicted)
+	}
+	return nil
+}
+
+// Run enforces retention every interval until ctx is done
+func (s *Store) Run(ctx context.Context, interval time.Duration) {
+	boltdb.RunRetention(ctx, interval, "event", s)
+}
+
+// Query returns the events matching q, most recently seen first
+func (s *Store) Query(q Query) []Event {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := make([]Event, 0)
+	for key, event := range s.events {
+		object := event.InvolvedObject
+		if object == nil {
+			object = &agentpb.ObjectReference{}
+		}
+		switch {
+		case !q.Scope.Allows(key.cluster, event.Namespace),
+			q.Cluster != "" && key.cluster != q.Cluster,
+			q.Namespace != "" && event.Namespace != q.Namespace,
+			q.Kind != "" && object.Kind != q.Kind,
+			q.Name != "" && object.Name != q.Name,
+			q.Type != "" && event.Type != q.Type,
+			q.Reason != "" && event.Reason != q.Reason,
+			event.LastTimestamp < q.From,
+			q.To != 0 && event.LastTimestamp > q.To:
+			continue
+		}
+		events = append(events, Event{Cluster: key.cluster, Event: event})
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		a, b := events[i], events[j]
+		if a.LastTimestamp != b.LastTimestamp {
+			return a.LastTimestamp > b.LastTimestamp
+		}
+		if a.Cluster != b.Cluster {
+			return a.Cluster < b.Cluster
+		}
+		return a.Uid < b.Uid
+	})
+	if q.Limit > 0 && len(events) > q.Limit {
+		events = events[:q.Limit]
+	}
+	return events
+}
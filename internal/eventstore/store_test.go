@@ -0,0 +1,170 @@
+package eventstore
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/thekubefleet/kubefleet/internal/auth"
+	agentpb "github.com/thekubefleet/kubefleet/proto"
+)
+
+var start = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+// newTestStore returns a store whose clock reads now
+func newTestStore(retention Retention, now *time.Time) *Store {
+	s := NewStore(retention)
+	s.now = func() time.Time { return *now }
+	return s
+}
+
+// event returns a pod event last seen at an offset from start
+func event(uid, namespace, pod, eventType, reason string, lastSeen time.Duration) *agentpb.Event {
+	return &agentpb.Event{
+		Namespace:      namespace,
+		Name:           pod + "." + uid,
+		Uid:            uid,
+		Type:           eventType,
+		Reason:         reason,
+		InvolvedObject: &agentpb.ObjectReference{Kind: "Pod", Namespace: namespace, Name: pod},
+		Count:          1,
+		FirstTimestamp: start.Unix(),
+		LastTimestamp:  start.Add(lastSeen).Unix(),
+	}
+}
+
+// uids returns the cluster and UID of each event
+func uids(events []Event) []string {
+	uids := []string{}
+	for _, e := range events {
+		uids = append(uids, e.Cluster+"/"+e.Uid)
+	}
+	return uids
+}
+
+func TestAppendUpdatesByUID(t *testing.T) {
+	now := start
+	s := newTestStore(Retention{}, &now)
+
+	backOff := event("1", "default", "web", "Warning", "BackOff", 0)
+	if err := s.Append("prod", []*agentpb.Event{backOff, event("", "default", "web", "Normal", "Pulled", 0)}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	// The same UID in another cluster is another event
+	s.Append("staging", []*agentpb.Event{event("1", "default", "web", "Warning", "BackOff", 0)})
+
+	// A repeat replaces the stored event
+	repeated := event("1", "default", "web", "Warning", "BackOff", time.Minute)
+	repeated.Count = 4
+	s.Append("prod", []*agentpb.Event{repeated})
+
+	events := s.Query(Query{})
+	if got := uids(events); !reflect.DeepEqual(got, []string{"prod/1", "staging/1"}) {
+		t.Fatalf("events = %q, want one per cluster without the event lacking a UID", got)
+	}
+	if events[0].Count != 4 || events[0].LastTimestamp != start.Add(time.Minute).Unix() {
+		t.Errorf("event = %+v, want the repeat", events[0])
+	}
+}
+
+func TestRetention(t *testing.T) {
+	now := start
+	s := newTestStore(Retention{MaxAge: time.Hour, MaxEvents: 3}, &now)
+
+	s.Append("prod", []*agentpb.Event{
+		event("1", "default", "web", "Normal", "Scheduled", 0),
+		event("2", "default", "web", "Normal", "Pulled", time.Minute),
+		event("3", "default", "web", "Normal", "Started", 2*time.Minute),
+	})
+	// The least recently seen events go first when there are too many
+	s.Append("prod", []*agentpb.Event{event("4", "default", "web", "Warning", "Unhealthy", 3*time.Minute)})
+	if got := uids(s.Query(Query{})); !reflect.DeepEqual(got, []string{"prod/4", "prod/3", "prod/2"}) {
+		t.Errorf("events = %q, want the 3 most recently seen", got)
+	}
+
+	// Events age out from when they were last seen, and aren't stored when
+	// they arrive too old
+	now = start.Add(time.Hour + 90*time.Second)
+	if err := s.EnforceRetention(); err != nil {
+		t.Fatalf("EnforceRetention: %v", err)
+	}
+	s.Append("staging", []*agentpb.Event{event("5", "default", "web", "Normal", "Killing", 0)})
+	if got := uids(s.Query(Query{})); !reflect.DeepEqual(got, []string{"prod/4", "prod/3"}) {
+		t.Errorf("events = %q, want those seen within the hour", got)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	prodDefault := `{"rules": [{"subjects": ["alice"], "clusters": ["prod"], "namespaces": ["default"]}]}`
+	authorizer, err := auth.NewAuthorizer(context.Background(), func(context.Context) ([]byte, error) { return []byte(prodDefault), nil })
+	if err != nil {
+		t.Fatalf("NewAuthorizer: %v", err)
+	}
+
+	now := start
+	s := newTestStore(Retention{}, &now)
+	s.Append("prod", []*agentpb.Event{
+		event("1", "default", "web", "Normal", "Pulled", 0),
+		event("2", "default", "web", "Warning", "BackOff", time.Minute),
+		event("3", "team-a", "worker", "Warning", "BackOff", 2*time.Minute),
+	})
+	deployment := event("4", "default", "web", "Normal", "ScalingReplicaSet", 3*time.Minute)
+	deployment.InvolvedObject.Kind = "Deployment"
+	s.Append("staging", []*agentpb.Event{deployment})
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{name: "all, most recently seen first", query: Query{}, want: []string{"staging/4", "prod/3", "prod/2", "prod/1"}},
+		{name: "cluster", query: Query{Cluster: "prod"}, want: []string{"prod/3", "prod/2", "prod/1"}},
+		{name: "namespace", query: Query{Namespace: "team-a"}, want: []string{"prod/3"}},
+		{name: "kind", query: Query{Kind: "Deployment"}, want: []string{"staging/4"}},
+		{name: "name", query: Query{Kind: "Pod", Name: "web"}, want: []string{"prod/2", "prod/1"}},
+		{name: "type and reason", query: Query{Type: "Warning", Reason: "BackOff", Namespace: "default"}, want: []string{"prod/2"}},
+		{name: "time range", query: Query{From: start.Add(time.Minute).Unix(), To: start.Add(2 * time.Minute).Unix()}, want: []string{"prod/3", "prod/2"}},
+		{name: "limit", query: Query{Limit: 2}, want: []string{"staging/4", "prod/3"}},
+		{name: "scope", query: Query{Scope: authorizer.Scope(&auth.Identity{Subject: "alice"})}, want: []string{"prod/2", "prod/1"}},
+		{name: "empty scope", query: Query{Scope: authorizer.Scope(&auth.Identity{Subject: "mallory"})}, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := uids(s.Query(tt.query)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenReloadsEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+	s, err := Open(path, Retention{MaxEvents: 2})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	// Open enforces retention on the wall clock, so MaxAge isn't used
+	s.Append("prod", []*agentpb.Event{
+		event("1", "default", "web", "Normal", "Pulled", 0),
+		event("2", "default", "web", "Warning", "BackOff", time.Minute),
+	})
+	repeated := event("2", "default", "web", "Warning", "BackOff", 2*time.Minute)
+	repeated.Count = 3
+	s.Append("prod", []*agentpb.Event{repeated, event("3", "default", "web", "Warning", "BackOff", 3*time.Minute)})
+	s.Close()
+
+	s, err = Open(path, Retention{MaxEvents: 2})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+	events := s.Query(Query{})
+	if got := uids(events); !reflect.DeepEqual(got, []string{"prod/3", "prod/2"}) {
+		t.Fatalf("reloaded events = %q, want the evicted one gone", got)
+	}
+	if events[1].Count != 3 {
+		t.Errorf("reloaded event = %+v, want the repeat", events[1])
+	}
+}
@@ -8,7 +8,10 @@ import (
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	corev1 "k8s.io/api/core/v1"
 
+	"github.com/thekubefleet/kubefleet/internal/k8s"
+	"github.com/thekubefleet/kubefleet/internal/logparse"
 	"github.com/thekubefleet/kubefleet/internal/metrics"
 	agentpb "github.com/thekubefleet/kubefleet/proto"
 )
@@ -18,9 +21,11 @@ type Client struct {
 	client agentpb.AgentReporterClient
 }
 
-// NewClient creates a new gRPC client
-func NewClient(serverAddr string) (*Client, error) {
-	conn, err := grpc.Dial(serverAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+// NewClient creates a new gRPC client. The connection is insecure unless
+// opts supply transport credentials.
+func NewClient(serverAddr string, opts ...grpc.DialOption) (*Client, error) {
+	dialOpts := append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, opts...)
+	conn, err := grpc.Dial(serverAddr, dialOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to gRPC server: %w", err)
 	}
@@ -55,13 +60,71 @@ func (c *Client) SendAgentData(ctx context.Context, data *agentpb.AgentData) err
 	return nil
 }
 
-// ConvertResourceInfo converts internal resource info to protobuf format
-func ConvertResourceInfo(namespace string, pods, deployments []string) *agentpb.ResourceInfo {
-	return &agentpb.ResourceInfo{
+// SendDelta sends an incremental report to the server
+func (c *Client) SendDelta(ctx context.Context, report *agentpb.DeltaReport) (*agentpb.DeltaResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	response, err := c.client.ReportDelta(ctx, report)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send delta report: %w", err)
+	}
+	return response, nil
+}
+
+// RegisterAgent registers the agent with the server and returns the
+// heartbeat interval the server expects
+func (c *Client) RegisterAgent(ctx context.Context, identity *agentpb.AgentIdentity, reportInterval time.Duration) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	response, err := c.client.RegisterAgent(ctx, &agentpb.RegisterRequest{
+		Identity:              identity,
+		ReportIntervalSeconds: int64(reportInterval / time.Second),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to register agent: %w", err)
+	}
+
+	if !response.Success {
+		return 0, fmt.Errorf("server returned error: %s", response.Message)
+	}
+
+	return time.Duration(response.HeartbeatIntervalSeconds) * time.Second, nil
+}
+
+// SendHeartbeat tells the server the agent is still alive
+func (c *Client) SendHeartbeat(ctx context.Context, identity *agentpb.AgentIdentity) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	response, err := c.client.Heartbeat(ctx, &agentpb.HeartbeatRequest{
+		Identity:  identity,
+		Timestamp: time.Now().Unix(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send heartbeat: %w", err)
+	}
+
+	if !response.Success {
+		return fmt.Errorf("server returned error: %s", response.Message)
+	}
+
+	return nil
+}
+
+// ConvertResourceInfo converts internal resource info to protobuf format.
+// Pod names are listed alongside their status for older dashboards.
+func ConvertResourceInfo(namespace string, pods []*corev1.Pod, deployments []string) *agentpb.ResourceInfo {
+	info := &agentpb.ResourceInfo{
 		Namespace:   namespace,
-		Pods:        pods,
 		Deployments: deployments,
 	}
+	for _, pod := range pods {
+		info.Pods = append(info.Pods, pod.Name)
+		info.PodInfos = append(info.PodInfos, ConvertPodInfo(pod))
+	}
+	return info
 }
 
 // ConvertResourceMetrics converts internal metrics to protobuf format
@@ -70,11 +133,15 @@ func ConvertResourceMetrics(metricsData []metrics.ResourceMetric) []*agentpb.Res
 
 	for _, metric := range metricsData {
 		protoMetric := &agentpb.ResourceMetrics{
-			Namespace: metric.Namespace,
-			Name:      metric.Name,
-			Kind:      metric.Kind,
-			Cpu:       metric.CPU,
-			Memory:    metric.Memory,
+			Namespace:     metric.Namespace,
+			Name:          metric.Name,
+			Kind:          metric.Kind,
+			Cpu:           metric.CPU,
+			Memory:        metric.Memory,
+			CpuRequest:    metric.Resources.CPURequest,
+			CpuLimit:      metric.Resources.CPULimit,
+			MemoryRequest: metric.Resources.MemoryRequest,
+			MemoryLimit:   metric.Resources.MemoryLimit,
 		}
 		protoMetrics = append(protoMetrics, protoMetric)
 	}
@@ -82,10 +149,36 @@ func ConvertResourceMetrics(metricsData []metrics.ResourceMetric) []*agentpb.Res
 	return protoMetrics
 }
 
+// ConvertEvents converts Kubernetes events to protobuf format
+func ConvertEvents(events []k8s.Event) []*agentpb.Event {
+	protoEvents := make([]*agentpb.Event, 0, len(events))
+	for _, event := range events {
+		protoEvents = append(protoEvents, &agentpb.Event{
+			Namespace: event.Namespace,
+			Name:      event.Name,
+			Uid:       event.UID,
+			Type:      event.Type,
+			Reason:    event.Reason,
+			Message:   event.Message,
+			InvolvedObject: &agentpb.ObjectReference{
+				Kind:      event.Object.Kind,
+				Namespace: event.Object.Namespace,
+				Name:      event.Object.Name,
+				Uid:       string(event.Object.UID),
+				FieldPath: event.Object.FieldPath,
+			},
+			Count:          event.Count,
+			FirstTimestamp: event.FirstTimestamp.Unix(),
+			LastTimestamp:  event.LastTimestamp.Unix(),
+			Source:         event.Source,
+		})
+	}
+	return protoEvents
+}
+
 // ConvertPodLogs converts log strings to protobuf format
 func ConvertPodLogs(namespace, podName, containerName string, logLines []string) []*agentpb.PodLog {
 	var protoLogs []*agentpb.PodLog
-	now := time.Now().Unix()
 
 	for _, line := range logLines {
 		// Split log lines (they might contain multiple lines)
@@ -94,38 +187,49 @@ func ConvertPodLogs(namespace, podName, containerName string, logLines []string)
 			if logLine == "" {
 				continue
 			}
-
-			protoLog := &agentpb.PodLog{
-				Namespace:     namespace,
-				PodName:       podName,
-				ContainerName: containerName,
-				LogLine:       logLine,
-				Timestamp:     now,
-				Level:         ParseLogLevel(logLine),
-			}
-			protoLogs = append(protoLogs, protoLog)
+			protoLogs = append(protoLogs, ConvertLogLine(namespace, podName, containerName, k8s.LogLine{Text: logLine}, false))
 		}
 	}
 
 	return protoLogs
 }
 
-// ParseLogLevel attempts to parse log level from a log line
-func ParseLogLevel(logLine string) string {
-	line := strings.ToUpper(strings.TrimSpace(logLine))
-
-	if strings.Contains(line, "ERROR") || strings.Contains(line, "FATAL") {
-		return "ERROR"
+// ConvertLogLines converts log lines read with their timestamps to protobuf
+// format
+func ConvertLogLines(namespace, podName, containerName string, lines []k8s.LogLine, previous bool) []*agentpb.PodLog {
+	protoLogs := make([]*agentpb.PodLog, 0, len(lines))
+	for _, line := range lines {
+		protoLogs = append(protoLogs, ConvertLogLine(namespace, podName, containerName, line, previous))
 	}
-	if strings.Contains(line, "WARN") {
-		return "WARN"
+	return protoLogs
+}
+
+// ConvertLogLine converts one log line to protobuf format, parsing its
+// level and fields. Lines without a container timestamp use the one in the
+// line, or the current time.
+func ConvertLogLine(namespace, podName, containerName string, line k8s.LogLine, previous bool) *agentpb.PodLog {
+	entry := logparse.Parse(line.Text)
+	timestamp := line.Timestamp
+	if timestamp.IsZero() {
+		timestamp = entry.Timestamp
 	}
-	if strings.Contains(line, "DEBUG") {
-		return "DEBUG"
+	if timestamp.IsZero() {
+		timestamp = time.Now()
 	}
-	if strings.Contains(line, "INFO") {
-		return "INFO"
+	return &agentpb.PodLog{
+		Namespace:     namespace,
+		PodName:       podName,
+		ContainerName: containerName,
+		LogLine:       line.Text,
+		Timestamp:     timestamp.Unix(),
+		Level:         entry.Level,
+		Previous:      previous,
+		Fields:        entry.FieldsMap(),
 	}
+}
 
-	return "INFO" // Default level
+// ParseLogLevel returns the level of a log line: the one its format
+// declares, or a level name among its first words
+func ParseLogLevel(logLine string) string {
+	return logparse.Parse(logLine).Level
 }
@@ -0,0 +1,348 @@
+package grpcclient
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	agentpb "github.com/thekubefleet/kubefleet/proto"
+)
+
+// deltaProtocolVersion is the delta report protocol version the agent speaks
+const deltaProtocolVersion = 1
+
+// DeltaReporter sends reports as deltas against the last state the server
+// acknowledged. It starts each session with a baseline, sends a new baseline
+// whenever the server asks for a resync, and falls back to full snapshots
+// when the server doesn't support deltas. Reports go over a Stream while
+// one is connected and through unary calls otherwise.
+type DeltaReporter struct {
+	client        *Client
+	stream        *Stream
+	sessionID     string
+	sequence      uint64
+	acked         *agentpb.AgentData
+	fullSnapshots bool
+	resync        atomic.Bool
+}
+
+// NewDeltaReporter creates a reporter with a new session
+func NewDeltaReporter(client *Client) (*DeltaReporter, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate session ID: %w", err)
+	}
+	return &DeltaReporter{
+		client:    client,
+		sessionID: hex.EncodeToString(b),
+	}, nil
+}
+
+// UseStream sends reports over stream while it is connected
+func (r *DeltaReporter) UseStream(stream *Stream) {
+	r.stream = stream
+}
+
+// RequestBaseline makes the next report a baseline. It is safe to call
+// while a report is being sent.
+func (r *DeltaReporter) RequestBaseline() {
+	r.resync.Store(true)
+}
+
+// send sends a report over the stream when one is connected
+func (r *DeltaReporter) send(ctx context.Context, report *agentpb.DeltaReport) (*agentpb.DeltaResponse, error) {
+	if r.stream != nil {
+		response, err := r.stream.SendReport(ctx, report)
+		if !errors.Is(err, ErrStreamNotConnected) {
+			return response, err
+		}
+	}
+	return r.client.SendDelta(ctx, report)
+}
+
+// Report sends the changes between the last acknowledged state and data,
+// which must be a full snapshot. It returns the number of bytes sent.
+func (r *DeltaReporter) Report(ctx context.Context, data *agentpb.AgentData) (int, error) {
+	if r.fullSnapshots {
+		return proto.Size(data), r.client.SendAgentData(ctx, data)
+	}
+	if r.resync.Swap(false) {
+		r.acked = nil
+	}
+
+	report := r.build(data)
+	sent := proto.Size(report)
+	response, err := r.send(ctx, report)
+	if status.Code(err) == codes.Unimplemented {
+		log.Printf("Server does not support delta reports, sending full snapshots: %v", err)
+		r.fullSnapshots = true
+		return r.Report(ctx, data)
+	}
+	if err != nil {
+		// The server may or may not have applied the report; resending the
+		// same sequence number makes it ask for a baseline if it did
+		return sent, err
+	}
+
+	if response.ResyncRequired {
+		log.Printf("Server requested a resync: %s", response.Message)
+		r.acked = nil
+		report = r.build(data)
+		sent += proto.Size(report)
+		if response, err = r.send(ctx, report); err != nil {
+			return sent, err
+		}
+	}
+	if !response.Success {
+		return sent, fmt.Errorf("server returned error: %s", response.Message)
+	}
+
+	r.acked = data
+	r.sequence = report.Sequence
+	return sent, nil
+}
+
+// build creates the report taking the server from the acknowledged state to
+// data, or a baseline when nothing has been acknowledged
+func (r *DeltaReporter) build(data *agentpb.AgentData) *agentpb.DeltaReport {
+	report := &agentpb.DeltaReport{
+		Identity:        data.Identity,
+		Timestamp:       data.Timestamp,
+		ProtocolVersion: deltaProtocolVersion,
+		SessionId:       r.sessionID,
+		Sequence:        r.sequence + 1,
+	}
+
+	if r.acked == nil {
+		report.Baseline = true
+		report.Resources = data.Resources
+		report.Metrics = data.Metrics
+		report.Logs = data.Logs
+		report.Events = data.Events
+		return report
+	}
+
+	report.ResourceDeltas = diffResources(r.acked.Resources, data.Resources)
+	report.Metrics, report.RemovedMetrics = diffMetrics(r.acked.Metrics, data.Metrics)
+	report.Logs = newLogLines(r.acked.Logs, data.Logs)
+	report.Events = changedEvents(r.acked.Events, data.Events)
+	return report
+}
+
+// diffSets returns the names in current but not previous, and the reverse
+func diffSets(previous, current []string) (added, removed []string) {
+	prev := make(map[string]bool, len(previous))
+	for _, name := range previous {
+		prev[name] = true
+	}
+	cur := make(map[string]bool, len(current))
+	for _, name := range current {
+		cur[name] = true
+		if !prev[name] {
+			added = append(added, name)
+		}
+	}
+	for _, name := range previous {
+		if !cur[name] {
+			removed = append(removed, name)
+		}
+	}
+	return added, removed
+}
+
+func diffResources(previous, current []*agentpb.ResourceInfo) []*agentpb.ResourceDelta {
+	prev := make(map[string]*agentpb.ResourceInfo, len(previous))
+	for _, resource := range previous {
+		prev[resource.Namespace] = resource
+	}
+
+	var deltas []*agentpb.ResourceDelta
+	seen := make(map[string]bool, len(current))
+	for _, resource := range current {
+		seen[resource.Namespace] = true
+		old := prev[resource.Namespace]
+		if old == nil {
+			old = &agentpb.ResourceInfo{}
+		}
+
+		delta := &agentpb.ResourceDelta{Namespace: resource.Namespace}
+		delta.AddedPods, delta.RemovedPods = diffSets(old.Pods, resource.Pods)
+		delta.AddedDeployments, delta.RemovedDeployments = diffSets(old.Deployments, resource.Deployments)
+		delta.ChangedPods = changedPods(old.PodInfos, resource.PodInfos)
+		// New namespaces are sent even when empty so the server learns of them
+		if prev[resource.Namespace] == nil || len(delta.AddedPods)+len(delta.RemovedPods)+len(delta.AddedDeployments)+len(delta.RemovedDeployments)+len(delta.ChangedPods) > 0 {
+			deltas = append(deltas, delta)
+		}
+	}
+
+	for _, resource := range previous {
+		if !seen[resource.Namespace] {
+			deltas = append(deltas, &agentpb.ResourceDelta{Namespace: resource.Namespace, Removed: true})
+		}
+	}
+	return deltas
+}
+
+// changedPods returns the pods that are new or whose status changed.
+// Removed pods are reported by name.
+func changedPods(previous, current []*agentpb.PodInfo) []*agentpb.PodInfo {
+	prev := make(map[string]*agentpb.PodInfo, len(previous))
+	for _, pod := range previous {
+		prev[pod.Name] = pod
+	}
+
+	var changed []*agentpb.PodInfo
+	for _, pod := range current {
+		if old, ok := prev[pod.Name]; !ok || !proto.Equal(old, pod) {
+			changed = append(changed, pod)
+		}
+	}
+	return changed
+}
+
+func diffMetrics(previous, current []*agentpb.ResourceMetrics) ([]*agentpb.ResourceMetrics, []*agentpb.MetricKey) {
+	type key struct{ namespace, kind, name string }
+
+	prev := make(map[key]*agentpb.ResourceMetrics, len(previous))
+	for _, metric := range previous {
+		prev[key{metric.Namespace, metric.Kind, metric.Name}] = metric
+	}
+
+	var changed []*agentpb.ResourceMetrics
+	seen := make(map[key]bool, len(current))
+	for _, metric := range current {
+		k := key{metric.Namespace, metric.Kind, metric.Name}
+		seen[k] = true
+		if old, ok := prev[k]; !ok || !proto.Equal(old, metric) {
+			changed = append(changed, metric)
+		}
+	}
+
+	var removed []*agentpb.MetricKey
+	for k := range prev {
+		if !seen[k] {
+			removed = append(removed, &agentpb.MetricKey{Namespace: k.namespace, Kind: k.kind, Name: k.name})
+		}
+	}
+	return changed, removed
+}
+
+// changedEvents returns the events that are new or changed, e.g. repeated
+// with a higher count. Events that disappeared aren't reported.
+func changedEvents(previous, current []*agentpb.Event) []*agentpb.Event {
+	prev := make(map[string]*agentpb.Event, len(previous))
+	for _, event := range previous {
+		prev[event.Uid] = event
+	}
+
+	var changed []*agentpb.Event
+	for _, event := range current {
+		if old, ok := prev[event.Uid]; !ok || !proto.Equal(old, event) {
+			changed = append(changed, event)
+		}
+	}
+	return changed
+}
+
+// newLogLines returns the lines of each container's current tail that follow
+// on from its previous tail. A container's previous instance has a tail of
+// its own. Tails are a sliding window, so the longest end of the previous
+// tail that starts the current one is the part already sent. When they don't
+// overlap, e.g. because more lines were written than a tail holds, the lines
+// newer than the last one sent are new.
+func newLogLines(previous, current []*agentpb.PodLog) []*agentpb.PodLog {
+	type key struct {
+		namespace, pod, container string
+		previous                  bool
+	}
+
+	group := func(logs []*agentpb.PodLog) (map[key][]*agentpb.PodLog, []key) {
+		groups := make(map[key][]*agentpb.PodLog)
+		var order []key
+		for _, l := range logs {
+			k := key{l.Namespace, l.PodName, l.ContainerName, l.Previous}
+			if _, ok := groups[k]; !ok {
+				order = append(order, k)
+			}
+			groups[k] = append(groups[k], l)
+		}
+		return groups, order
+	}
+	prevGroups, _ := group(previous)
+	curGroups, order := group(current)
+
+	var logs []*agentpb.PodLog
+	for _, k := range order {
+		prev, cur := prevGroups[k], curGroups[k]
+		if n := overlap(prev, cur); n > 0 || len(prev) == 0 {
+			logs = append(logs, cur[n:]...)
+		} else {
+			logs = append(logs, newerLines(prev, cur)...)
+		}
+	}
+	return logs
+}
+
+// sameLine reports whether two lines are the same write: lines repeating
+// the same text at different times are different lines
+func sameLine(a, b *agentpb.PodLog) bool {
+	return a.Timestamp == b.Timestamp && a.LogLine == b.LogLine
+}
+
+// overlap returns the largest n such that the last n lines of prev are the
+// first n lines of cur
+func overlap(prev, cur []*agentpb.PodLog) int {
+	n := len(prev)
+	if len(cur) < n {
+		n = len(cur)
+	}
+	for ; n > 0; n-- {
+		match := true
+		for i := 0; i < n; i++ {
+			if !sameLine(prev[len(prev)-n+i], cur[i]) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return n
+		}
+	}
+	return 0
+}
+
+// newerLines returns the lines of cur written after the last line of prev.
+// Lines sharing its timestamp are new unless prev ends with them.
+func newerLines(prev, cur []*agentpb.PodLog) []*agentpb.PodLog {
+	last := prev[len(prev)-1].Timestamp
+	var lines []*agentpb.PodLog
+	for _, line := range cur {
+		if line.Timestamp < last {
+			continue
+		}
+		if line.Timestamp == last && sentAt(prev, line) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// sentAt reports whether line is among the lines at the end of prev that
+// share its timestamp
+func sentAt(prev []*agentpb.PodLog, line *agentpb.PodLog) bool {
+	for i := len(prev) - 1; i >= 0 && prev[i].Timestamp == line.Timestamp; i-- {
+		if prev[i].LogLine == line.LogLine {
+			return true
+		}
+	}
+	return false
+}
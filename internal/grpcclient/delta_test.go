@@ -0,0 +1,92 @@
+package grpcclient
+
+import (
+	"reflect"
+	"testing"
+
+	agentpb "github.com/thekubefleet/kubefleet/proto"
+)
+
+func line(ts int64, text string) *agentpb.PodLog {
+	return &agentpb.PodLog{Namespace: "default", PodName: "web", ContainerName: "app", Timestamp: ts, LogLine: text}
+}
+
+func lineTexts(logs []*agentpb.PodLog) []string {
+	texts := []string{}
+	for _, l := range logs {
+		texts = append(texts, l.LogLine)
+	}
+	return texts
+}
+
+func TestNewLogLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous []*agentpb.PodLog
+		current  []*agentpb.PodLog
+		want     []string
+	}{
+		{
+			name:    "first tail",
+			current: []*agentpb.PodLog{line(1, "a"), line(2, "b")},
+			want:    []string{"a", "b"},
+		},
+		{
+			name:     "sliding window",
+			previous: []*agentpb.PodLog{line(1, "a"), line(2, "b"), line(3, "c")},
+			current:  []*agentpb.PodLog{line(2, "b"), line(3, "c"), line(4, "d")},
+			want:     []string{"d"},
+		},
+		{
+			name:     "unchanged",
+			previous: []*agentpb.PodLog{line(1, "a"), line(2, "b")},
+			current:  []*agentpb.PodLog{line(1, "a"), line(2, "b")},
+			want:     []string{},
+		},
+		{
+			name:     "repeated text is a new line",
+			previous: []*agentpb.PodLog{line(1, "retrying"), line(2, "retrying")},
+			current:  []*agentpb.PodLog{line(2, "retrying"), line(3, "retrying")},
+			want:     []string{"retrying"},
+		},
+		{
+			name:     "same text written again",
+			previous: []*agentpb.PodLog{line(1, "tick"), line(2, "tick")},
+			current:  []*agentpb.PodLog{line(3, "tick"), line(4, "tick")},
+			want:     []string{"tick", "tick"},
+		},
+		{
+			name:     "more lines than the tail holds",
+			previous: []*agentpb.PodLog{line(1, "a"), line(2, "b")},
+			current:  []*agentpb.PodLog{line(5, "e"), line(6, "f")},
+			want:     []string{"e", "f"},
+		},
+		{
+			name:     "no overlap keeps lines after the last sent",
+			previous: []*agentpb.PodLog{line(1, "a"), line(3, "c")},
+			current:  []*agentpb.PodLog{line(2, "b"), line(3, "x"), line(3, "c"), line(4, "d")},
+			want:     []string{"x", "d"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := lineTexts(newLogLines(tt.previous, tt.current))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newLogLines() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewLogLinesPerContainer(t *testing.T) {
+	previous := []*agentpb.PodLog{line(1, "a")}
+	restarted := line(1, "a")
+	restarted.Previous = true
+	other := line(1, "a")
+	other.ContainerName = "sidecar"
+
+	got := newLogLines(previous, []*agentpb.PodLog{line(1, "a"), restarted, other})
+	if len(got) != 2 || got[0] != restarted || got[1] != other {
+		t.Errorf("newLogLines() = %v, want the previous instance's and the sidecar's line", got)
+	}
+}
@@ -0,0 +1,192 @@
+package grpcclient
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	agentpb "github.com/thekubefleet/kubefleet/proto"
+)
+
+// Container states
+const (
+	containerWaiting    = "waiting"
+	containerRunning    = "running"
+	containerTerminated = "terminated"
+)
+
+// ConvertPodInfo converts a pod and its status to protobuf format
+func ConvertPodInfo(pod *corev1.Pod) *agentpb.PodInfo {
+	info := &agentpb.PodInfo{
+		Name:      pod.Name,
+		Phase:     string(pod.Status.Phase),
+		Status:    podStatus(pod),
+		Reason:    pod.Status.Reason,
+		Message:   pod.Status.Message,
+		Node:      pod.Spec.NodeName,
+		PodIp:     pod.Status.PodIP,
+		StartTime: unixTime(pod.Status.StartTime),
+	}
+
+	for _, owner := range pod.OwnerReferences {
+		info.Owners = append(info.Owners, &agentpb.OwnerReference{
+			Kind:       owner.Kind,
+			Name:       owner.Name,
+			Controller: owner.Controller != nil && *owner.Controller,
+		})
+	}
+
+	for _, condition := range pod.Status.Conditions {
+		info.Conditions = append(info.Conditions, &agentpb.PodCondition{
+			Type:               string(condition.Type),
+			Status:             string(condition.Status),
+			Reason:             condition.Reason,
+			Message:            condition.Message,
+			LastTransitionTime: unixTime(&condition.LastTransitionTime),
+		})
+		if condition.Type == corev1.PodReady {
+			info.Ready = condition.Status == corev1.ConditionTrue
+		}
+	}
+
+	info.Containers = append(info.Containers, convertContainers(pod.Spec.InitContainers, pod.Status.InitContainerStatuses, true)...)
+	info.Containers = append(info.Containers, convertContainers(pod.Spec.Containers, pod.Status.ContainerStatuses, false)...)
+	for _, container := range info.Containers {
+		info.Restarts += container.RestartCount
+	}
+	return info
+}
+
+// convertContainers converts the statuses of containers in spec order.
+// Containers without a status yet, e.g. of unscheduled pods, only have
+// their name and image.
+func convertContainers(specs []corev1.Container, statuses []corev1.ContainerStatus, init bool) []*agentpb.ContainerInfo {
+	byName := make(map[string]*corev1.ContainerStatus, len(statuses))
+	for i := range statuses {
+		byName[statuses[i].Name] = &statuses[i]
+	}
+
+	containers := make([]*agentpb.ContainerInfo, 0, len(specs))
+	for _, spec := range specs {
+		container := &agentpb.ContainerInfo{Name: spec.Name, Image: spec.Image, Init: init}
+		if status := byName[spec.Name]; status != nil {
+			container.Ready = status.Ready
+			container.RestartCount = status.RestartCount
+			switch state := status.State; {
+			case state.Waiting != nil:
+				container.State = containerWaiting
+				container.Reason = state.Waiting.Reason
+				container.Message = state.Waiting.Message
+			case state.Running != nil:
+				container.State = containerRunning
+				container.StartedAt = unixTime(&state.Running.StartedAt)
+			case state.Terminated != nil:
+				container.State = containerTerminated
+				container.Reason = state.Terminated.Reason
+				container.Message = state.Terminated.Message
+				container.StartedAt = unixTime(&state.Terminated.StartedAt)
+				container.Termination = convertTermination(state.Terminated)
+			}
+			container.LastTermination = convertTermination(status.LastTerminationState.Terminated)
+		}
+		containers = append(containers, container)
+	}
+	return containers
+}
+
+func convertTermination(terminated *corev1.ContainerStateTerminated) *agentpb.ContainerTermination {
+	if terminated == nil {
+		return nil
+	}
+	return &agentpb.ContainerTermination{
+		Reason:     terminated.Reason,
+		ExitCode:   terminated.ExitCode,
+		Signal:     terminated.Signal,
+		Message:    terminated.Message,
+		StartedAt:  unixTime(&terminated.StartedAt),
+		FinishedAt: unixTime(&terminated.FinishedAt),
+	}
+}
+
+// podStatus summarizes a pod's state the way kubectl get pods does: the
+// first init container that hasn't succeeded, else the last container that
+// is waiting or terminated, else the phase
+func podStatus(pod *corev1.Pod) string {
+	status := string(pod.Status.Phase)
+	if pod.Status.Reason != "" {
+		status = pod.Status.Reason
+	}
+
+	initializing := false
+	for i, container := range pod.Status.InitContainerStatuses {
+		state := container.State
+		switch {
+		case state.Terminated != nil && state.Terminated.ExitCode == 0:
+			continue
+		case isSidecar(pod, container.Name) && container.Started != nil && *container.Started:
+			// Sidecars keep running alongside the other containers
+			continue
+		case state.Terminated != nil:
+			status = "Init:" + terminationReason(state.Terminated)
+		case state.Waiting != nil && state.Waiting.Reason != "" && state.Waiting.Reason != "PodInitializing":
+			status = "Init:" + state.Waiting.Reason
+		default:
+			status = fmt.Sprintf("Init:%d/%d", i, len(pod.Spec.InitContainers))
+		}
+		initializing = true
+		break
+	}
+
+	if !initializing {
+		running := false
+		for i := len(pod.Status.ContainerStatuses) - 1; i >= 0; i-- {
+			state := pod.Status.ContainerStatuses[i].State
+			switch {
+			case state.Waiting != nil && state.Waiting.Reason != "":
+				status = state.Waiting.Reason
+			case state.Terminated != nil:
+				status = terminationReason(state.Terminated)
+			case state.Running != nil && pod.Status.ContainerStatuses[i].Ready:
+				running = true
+			}
+		}
+		// Some containers completed while others still run
+		if status == "Completed" && running {
+			status = string(corev1.PodRunning)
+		}
+	}
+
+	if pod.DeletionTimestamp != nil {
+		status = "Terminating"
+	}
+	return status
+}
+
+func terminationReason(terminated *corev1.ContainerStateTerminated) string {
+	switch {
+	case terminated.Reason != "":
+		return terminated.Reason
+	case terminated.Signal != 0:
+		return fmt.Sprintf("Signal:%d", terminated.Signal)
+	}
+	return fmt.Sprintf("ExitCode:%d", terminated.ExitCode)
+}
+
+// isSidecar reports whether an init container restarts like a regular one,
+// running for the pod's lifetime
+func isSidecar(pod *corev1.Pod, name string) bool {
+	for _, container := range pod.Spec.InitContainers {
+		if container.Name == name {
+			return container.RestartPolicy != nil && *container.RestartPolicy == corev1.ContainerRestartPolicyAlways
+		}
+	}
+	return false
+}
+
+func unixTime(t *metav1.Time) int64 {
+	if t == nil || t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
@@ -0,0 +1,299 @@
+package grpcclient
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	agentpb "github.com/thekubefleet/kubefleet/proto"
+)
+
+var started = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+// at returns the time of an offset from started
+func at(offset time.Duration) metav1.Time {
+	return metav1.NewTime(started.Add(offset))
+}
+
+// unix returns the unix time of an offset from started
+func unix(offset time.Duration) int64 {
+	return started.Add(offset).Unix()
+}
+
+func TestConvertPodInfo(t *testing.T) {
+	controller := true
+	readyCondition := func(status corev1.ConditionStatus, reason string) corev1.PodCondition {
+		return corev1.PodCondition{Type: corev1.PodReady, Status: status, Reason: reason, LastTransitionTime: at(time.Minute)}
+	}
+	app := []corev1.Container{{Name: "app", Image: "web:1.2"}}
+
+	tests := []struct {
+		name string
+		pod  *corev1.Pod
+		want *agentpb.PodInfo
+	}{
+		{
+			name: "running and ready",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "web-7d9f-abcde",
+					OwnerReferences: []metav1.OwnerReference{
+						{Kind: "ReplicaSet", Name: "web-7d9f", Controller: &controller},
+						{Kind: "ConfigMap", Name: "web-config"},
+					},
+				},
+				Spec: corev1.PodSpec{NodeName: "node-1", Containers: app},
+				Status: corev1.PodStatus{
+					Phase:     corev1.PodRunning,
+					PodIP:     "10.0.0.7",
+					StartTime: &metav1.Time{Time: started},
+					Conditions: []corev1.PodCondition{
+						{Type: corev1.PodScheduled, Status: corev1.ConditionTrue, LastTransitionTime: at(0)},
+						readyCondition(corev1.ConditionTrue, ""),
+					},
+					ContainerStatuses: []corev1.ContainerStatus{{
+						Name:         "app",
+						Ready:        true,
+						RestartCount: 1,
+						State:        corev1.ContainerState{Running: &corev1.ContainerStateRunning{StartedAt: at(30 * time.Second)}},
+						LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+							Reason: "Error", ExitCode: 1, StartedAt: at(0), FinishedAt: at(20 * time.Second),
+						}},
+					}},
+				},
+			},
+			want: &agentpb.PodInfo{
+				Name:      "web-7d9f-abcde",
+				Phase:     "Running",
+				Status:    "Running",
+				Node:      "node-1",
+				PodIp:     "10.0.0.7",
+				StartTime: unix(0),
+				Owners: []*agentpb.OwnerReference{
+					{Kind: "ReplicaSet", Name: "web-7d9f", Controller: true},
+					{Kind: "ConfigMap", Name: "web-config"},
+				},
+				Conditions: []*agentpb.PodCondition{
+					{Type: "PodScheduled", Status: "True", LastTransitionTime: unix(0)},
+					{Type: "Ready", Status: "True", LastTransitionTime: unix(time.Minute)},
+				},
+				Containers: []*agentpb.ContainerInfo{{
+					Name:            "app",
+					Image:           "web:1.2",
+					Ready:           true,
+					RestartCount:    1,
+					State:           "running",
+					StartedAt:       unix(30 * time.Second),
+					LastTermination: &agentpb.ContainerTermination{Reason: "Error", ExitCode: 1, StartedAt: unix(0), FinishedAt: unix(20 * time.Second)},
+				}},
+				Restarts: 1,
+				Ready:    true,
+			},
+		},
+		{
+			name: "waiting after being OOM killed",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "web"},
+				Spec:       corev1.PodSpec{Containers: app},
+				Status: corev1.PodStatus{
+					Phase:      corev1.PodRunning,
+					Conditions: []corev1.PodCondition{readyCondition(corev1.ConditionFalse, "ContainersNotReady")},
+					ContainerStatuses: []corev1.ContainerStatus{{
+						Name:         "app",
+						RestartCount: 4,
+						State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{
+							Reason: "CrashLoopBackOff", Message: "back-off 1m20s restarting failed container",
+						}},
+						LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+							Reason: "OOMKilled", ExitCode: 137, StartedAt: at(0), FinishedAt: at(5 * time.Second),
+						}},
+					}},
+				},
+			},
+			want: &agentpb.PodInfo{
+				Name:       "web",
+				Phase:      "Running",
+				Status:     "CrashLoopBackOff",
+				Conditions: []*agentpb.PodCondition{{Type: "Ready", Status: "False", Reason: "ContainersNotReady", LastTransitionTime: unix(time.Minute)}},
+				Containers: []*agentpb.ContainerInfo{{
+					Name:            "app",
+					Image:           "web:1.2",
+					RestartCount:    4,
+					State:           "waiting",
+					Reason:          "CrashLoopBackOff",
+					Message:         "back-off 1m20s restarting failed container",
+					LastTermination: &agentpb.ContainerTermination{Reason: "OOMKilled", ExitCode: 137, StartedAt: unix(0), FinishedAt: unix(5 * time.Second)},
+				}},
+				Restarts: 4,
+			},
+		},
+		{
+			name: "failed init container",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "web"},
+				Spec: corev1.PodSpec{
+					InitContainers: []corev1.Container{{Name: "migrate", Image: "migrate:3"}},
+					Containers:     app,
+				},
+				Status: corev1.PodStatus{
+					Phase: corev1.PodPending,
+					InitContainerStatuses: []corev1.ContainerStatus{{
+						Name:         "migrate",
+						RestartCount: 2,
+						State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+							Reason: "Error", ExitCode: 2, Message: "no such table", StartedAt: at(0), FinishedAt: at(3 * time.Second),
+						}},
+					}},
+					ContainerStatuses: []corev1.ContainerStatus{{
+						Name:  "app",
+						State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "PodInitializing"}},
+					}},
+				},
+			},
+			want: &agentpb.PodInfo{
+				Name:   "web",
+				Phase:  "Pending",
+				Status: "Init:Error",
+				Containers: []*agentpb.ContainerInfo{
+					{
+						Name:         "migrate",
+						Image:        "migrate:3",
+						Init:         true,
+						RestartCount: 2,
+						State:        "terminated",
+						Reason:       "Error",
+						Message:      "no such table",
+						StartedAt:    unix(0),
+						Termination:  &agentpb.ContainerTermination{Reason: "Error", ExitCode: 2, Message: "no such table", StartedAt: unix(0), FinishedAt: unix(3 * time.Second)},
+					},
+					{Name: "app", Image: "web:1.2", State: "waiting", Reason: "PodInitializing"},
+				},
+				Restarts: 2,
+			},
+		},
+		{
+			name: "unscheduled",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "web"},
+				Spec:       corev1.PodSpec{Containers: app},
+				Status: corev1.PodStatus{
+					Phase: corev1.PodPending,
+					Conditions: []corev1.PodCondition{{
+						Type: corev1.PodScheduled, Status: corev1.ConditionFalse, Reason: "Unschedulable", Message: "0/3 nodes are available", LastTransitionTime: at(0),
+					}},
+				},
+			},
+			want: &agentpb.PodInfo{
+				Name:       "web",
+				Phase:      "Pending",
+				Status:     "Pending",
+				Conditions: []*agentpb.PodCondition{{Type: "PodScheduled", Status: "False", Reason: "Unschedulable", Message: "0/3 nodes are available", LastTransitionTime: unix(0)}},
+				Containers: []*agentpb.ContainerInfo{{Name: "app", Image: "web:1.2"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ConvertPodInfo(tt.pod); !proto.Equal(got, tt.want) {
+				t.Errorf("ConvertPodInfo() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPodStatus(t *testing.T) {
+	always := corev1.ContainerRestartPolicyAlways
+	yes := true
+	running := corev1.ContainerState{Running: &corev1.ContainerStateRunning{StartedAt: at(0)}}
+	terminated := func(reason string, exitCode, signal int32) corev1.ContainerState {
+		return corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: reason, ExitCode: exitCode, Signal: signal}}
+	}
+	// pod returns a pod with containers in the given states, all ready
+	// while running
+	pod := func(phase corev1.PodPhase, states ...corev1.ContainerState) *corev1.Pod {
+		p := &corev1.Pod{Status: corev1.PodStatus{Phase: phase}}
+		for i, state := range states {
+			name := string(rune('a' + i))
+			p.Spec.Containers = append(p.Spec.Containers, corev1.Container{Name: name})
+			p.Status.ContainerStatuses = append(p.Status.ContainerStatuses, corev1.ContainerStatus{Name: name, State: state, Ready: state.Running != nil})
+		}
+		return p
+	}
+	withInit := func(p *corev1.Pod, init corev1.Container, status corev1.ContainerStatus) *corev1.Pod {
+		p.Spec.InitContainers = append(p.Spec.InitContainers, init)
+		p.Status.InitContainerStatuses = append(p.Status.InitContainerStatuses, status)
+		return p
+	}
+
+	evicted := pod(corev1.PodFailed)
+	evicted.Status.Reason = "Evicted"
+	deleting := pod(corev1.PodRunning, running)
+	deleting.DeletionTimestamp = &metav1.Time{Time: started}
+
+	tests := []struct {
+		name string
+		pod  *corev1.Pod
+		want string
+	}{
+		{name: "running", pod: pod(corev1.PodRunning, running), want: "Running"},
+		{name: "OOM killed", pod: pod(corev1.PodRunning, running, terminated("OOMKilled", 137, 0)), want: "OOMKilled"},
+		{name: "exit code without reason", pod: pod(corev1.PodFailed, terminated("", 3, 0)), want: "ExitCode:3"},
+		{name: "signal without reason", pod: pod(corev1.PodFailed, terminated("", 0, 9)), want: "Signal:9"},
+		{name: "completed", pod: pod(corev1.PodSucceeded, terminated("Completed", 0, 0)), want: "Completed"},
+		{name: "completed alongside running", pod: pod(corev1.PodRunning, terminated("Completed", 0, 0), running), want: "Running"},
+		{name: "pod reason", pod: evicted, want: "Evicted"},
+		{name: "terminating", pod: deleting, want: "Terminating"},
+		{
+			name: "init container running",
+			pod: withInit(pod(corev1.PodPending), corev1.Container{Name: "init"},
+				corev1.ContainerStatus{Name: "init", State: running}),
+			want: "Init:0/1",
+		},
+		{
+			name: "init container waiting",
+			pod: withInit(pod(corev1.PodPending), corev1.Container{Name: "init"},
+				corev1.ContainerStatus{Name: "init", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff"}}}),
+			want: "Init:ImagePullBackOff",
+		},
+		{
+			name: "started sidecar",
+			pod: withInit(pod(corev1.PodRunning, running), corev1.Container{Name: "proxy", RestartPolicy: &always},
+				corev1.ContainerStatus{Name: "proxy", State: running, Started: &yes}),
+			want: "Running",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := podStatus(tt.pod); got != tt.want {
+				t.Errorf("podStatus() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertResourceInfo(t *testing.T) {
+	pods := []*corev1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "web-1"}, Status: corev1.PodStatus{Phase: corev1.PodRunning}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "web-2"}, Status: corev1.PodStatus{Phase: corev1.PodPending}},
+	}
+	info := ConvertResourceInfo("default", pods, []string{"web"})
+
+	// Older dashboards read the pod names alone
+	if !slices.Equal(info.Pods, []string{"web-1", "web-2"}) {
+		t.Errorf("pods = %q, want the pod names in order", info.Pods)
+	}
+	var names []string
+	for _, pod := range info.PodInfos {
+		names = append(names, pod.Name)
+	}
+	if !slices.Equal(names, info.Pods) || info.PodInfos[1].Phase != "Pending" {
+		t.Errorf("pod infos = %v, want one per pod in the same order", info.PodInfos)
+	}
+	if info.Namespace != "default" || !slices.Equal(info.Deployments, []string{"web"}) {
+		t.Errorf("resource info = %v", info)
+	}
+}
@@ -0,0 +1,353 @@
+package grpcclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	agentpb "github.com/thekubefleet/kubefleet/proto"
+)
+
+const (
+	// Reconnect delays grow from minBackoff to maxBackoff and start over once
+	// a stream has stayed up for stableAfter
+	minBackoff  = time.Second
+	maxBackoff  = time.Minute
+	stableAfter = 30 * time.Second
+
+	// ackTimeout bounds how long a report waits for its acknowledgement
+	// before the stream is considered broken
+	ackTimeout = 30 * time.Second
+
+	// maxConcurrentCommands bounds how many commands run at once; further
+	// commands wait, which stops the stream being read until one finishes
+	maxConcurrentCommands = 4
+
+	// maxLogTails bounds how many log tails are relayed at once; further
+	// tail commands are refused
+	maxLogTails = 16
+)
+
+// ErrStreamNotConnected is returned when sending on a Stream that has no
+// open connection
+var ErrStreamNotConnected = errors.New("stream is not connected")
+
+// CommandHandler runs commands the server sends over a Stream
+type CommandHandler interface {
+	HandleCommand(ctx context.Context, cmd *agentpb.Command) *agentpb.CommandResult
+	// TailLogs sends the logs req asks for, following them until ctx is
+	// done when req.Follow is set
+	TailLogs(ctx context.Context, req *agentpb.LogRequest, send func(*agentpb.LogStream) error) error
+}
+
+// streamConn is one open Connect stream
+type streamConn struct {
+	stream agentpb.AgentReporter_ConnectClient
+	cancel context.CancelFunc
+	sendMu sync.Mutex
+	acks   chan *agentpb.DeltaResponse
+	done   chan struct{} // Closed with err set when the stream ends
+	err    error
+}
+
+func (c *streamConn) send(msg *agentpb.AgentMessage) error {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	return c.stream.Send(msg)
+}
+
+// Stream keeps a Connect stream open to the server, reconnecting with
+// backoff, and runs the commands the server sends on it. Reports are sent
+// one at a time, each waiting for its acknowledgement.
+type Stream struct {
+	client          *Client
+	identity        *agentpb.AgentIdentity
+	handler         CommandHandler
+	intervalSeconds atomic.Int64
+
+	mu       sync.Mutex
+	conn     *streamConn
+	reportMu sync.Mutex
+}
+
+// NewStream creates a stream for the agent with the given identity. Commands
+// are passed to handler.
+func NewStream(client *Client, identity *agentpb.AgentIdentity, reportInterval time.Duration, handler CommandHandler) *Stream {
+	s := &Stream{
+		client:   client,
+		identity: identity,
+		handler:  handler,
+	}
+	s.SetReportInterval(reportInterval)
+	return s
+}
+
+// SetReportInterval sets the report interval announced on future connections
+func (s *Stream) SetReportInterval(interval time.Duration) {
+	s.intervalSeconds.Store(int64(interval / time.Second))
+}
+
+// Connected reports whether a stream is open
+func (s *Stream) Connected() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn != nil
+}
+
+// Run keeps a stream open until ctx is done. It returns early if the server
+// doesn't support Connect, in which case callers should keep using unary
+// calls.
+func (s *Stream) Run(ctx context.Context) error {
+	backoff := minBackoff
+	for {
+		start := time.Now()
+		err := s.connect(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if status.Code(err) == codes.Unimplemented {
+			log.Printf("Server does not support streaming, using unary calls: %v", err)
+			return err
+		}
+
+		if time.Since(start) >= stableAfter {
+			backoff = minBackoff
+		}
+		// Jitter spreads out agents reconnecting after a server restart
+		delay := backoff/2 + time.Duration(rand.Int63n(int64(backoff)))
+		log.Printf("Stream to server closed: %v; reconnecting in %s", err, delay.Round(time.Millisecond))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// connect opens a stream and serves it until it ends
+func (s *Stream) connect(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := s.client.client.Connect(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open stream: %w", err)
+	}
+	conn := &streamConn{
+		stream: stream,
+		cancel: cancel,
+		acks:   make(chan *agentpb.DeltaResponse, 1),
+		done:   make(chan struct{}),
+	}
+
+	err = conn.send(&agentpb.AgentMessage{Payload: &agentpb.AgentMessage_Hello{Hello: &agentpb.StreamHello{
+		Identity:              s.identity,
+		ReportIntervalSeconds: s.intervalSeconds.Load(),
+	}}})
+	if err != nil {
+		// The server's reason for ending the stream is reported by Recv
+		_, err = stream.Recv()
+		return err
+	}
+
+	s.mu.Lock()
+	s.conn = conn
+	s.mu.Unlock()
+	log.Printf("Stream to server connected")
+
+	err = s.receive(ctx, conn)
+
+	s.mu.Lock()
+	s.conn = nil
+	s.mu.Unlock()
+	conn.err = err
+	close(conn.done)
+	return err
+}
+
+// receive handles messages from the server until the stream ends
+func (s *Stream) receive(ctx context.Context, conn *streamConn) error {
+	// Running commands are cancelled, then waited for, when the stream ends
+	var commands sync.WaitGroup
+	defer commands.Wait()
+	defer conn.cancel()
+	slots := make(chan struct{}, maxConcurrentCommands)
+
+	// Log tails run until they finish or the server cancels them
+	var tailsMu sync.Mutex
+	tails := make(map[string]context.CancelFunc)
+
+	for {
+		msg, err := conn.stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		switch payload := msg.Payload.(type) {
+		case *agentpb.ServerMessage_Ack:
+			// Only the report waiting for its acknowledgement reads these;
+			// late acknowledgements of abandoned reports are dropped
+			select {
+			case conn.acks <- payload.Ack:
+			default:
+			}
+		case *agentpb.ServerMessage_Command:
+			switch action := payload.Command.Action.(type) {
+			case *agentpb.Command_Cancel:
+				tailsMu.Lock()
+				if cancel, ok := tails[action.Cancel.CommandId]; ok {
+					cancel()
+				}
+				tailsMu.Unlock()
+				continue
+			case *agentpb.Command_TailLogs:
+				tailsMu.Lock()
+				if len(tails) >= maxLogTails {
+					tailsMu.Unlock()
+					s.sendChunk(conn, &agentpb.LogChunk{CommandId: payload.Command.Id, IsComplete: true, Error: "too many log streams"})
+					continue
+				}
+				tailCtx, cancel := context.WithCancel(ctx)
+				tails[payload.Command.Id] = cancel
+				tailsMu.Unlock()
+
+				commands.Add(1)
+				go func(id string, req *agentpb.LogRequest) {
+					defer commands.Done()
+					defer func() {
+						tailsMu.Lock()
+						delete(tails, id)
+						tailsMu.Unlock()
+						cancel()
+					}()
+					s.tailLogs(tailCtx, conn, id, req)
+				}(payload.Command.Id, action.TailLogs)
+				continue
+			}
+
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			commands.Add(1)
+			go func(cmd *agentpb.Command) {
+				defer commands.Done()
+				defer func() { <-slots }()
+
+				result := s.handler.HandleCommand(ctx, cmd)
+				result.CommandId = cmd.Id
+				if err := conn.send(&agentpb.AgentMessage{Payload: &agentpb.AgentMessage_Result{Result: result}}); err != nil {
+					log.Printf("Failed to send result of command %s: %v", cmd.Id, err)
+				}
+			}(payload.Command)
+		}
+	}
+}
+
+// tailLogs relays a tail_logs command's logs as LogChunks
+func (s *Stream) tailLogs(ctx context.Context, conn *streamConn, id string, req *agentpb.LogRequest) {
+	complete := false
+	err := s.handler.TailLogs(ctx, req, func(logs *agentpb.LogStream) error {
+		complete = logs.IsComplete
+		return conn.send(&agentpb.AgentMessage{Payload: &agentpb.AgentMessage_LogChunk{LogChunk: &agentpb.LogChunk{
+			CommandId:  id,
+			Logs:       logs.Logs,
+			IsComplete: logs.IsComplete,
+		}}})
+	})
+	if complete || ctx.Err() != nil {
+		// Done, or cancelled by the server or the stream ending
+		return
+	}
+
+	chunk := &agentpb.LogChunk{CommandId: id, IsComplete: true}
+	if err != nil {
+		chunk.Error = err.Error()
+	}
+	s.sendChunk(conn, chunk)
+}
+
+func (s *Stream) sendChunk(conn *streamConn, chunk *agentpb.LogChunk) {
+	if err := conn.send(&agentpb.AgentMessage{Payload: &agentpb.AgentMessage_LogChunk{LogChunk: chunk}}); err != nil {
+		log.Printf("Failed to send logs for command %s: %v", chunk.CommandId, err)
+	}
+}
+
+func (s *Stream) current() *streamConn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn
+}
+
+// SendReport sends a report and waits for the server to acknowledge it. It
+// returns ErrStreamNotConnected when no stream is open.
+func (s *Stream) SendReport(ctx context.Context, report *agentpb.DeltaReport) (*agentpb.DeltaResponse, error) {
+	s.reportMu.Lock()
+	defer s.reportMu.Unlock()
+
+	conn := s.current()
+	if conn == nil {
+		return nil, ErrStreamNotConnected
+	}
+
+	// Drop an acknowledgement left over from an abandoned report
+	select {
+	case <-conn.acks:
+	default:
+	}
+
+	if err := conn.send(&agentpb.AgentMessage{Payload: &agentpb.AgentMessage_Report{Report: report}}); err != nil {
+		return nil, fmt.Errorf("failed to send report on stream: %w", err)
+	}
+
+	timeout := time.NewTimer(ackTimeout)
+	defer timeout.Stop()
+	for {
+		select {
+		case ack := <-conn.acks:
+			if ack.Sequence != report.Sequence {
+				continue
+			}
+			return ack, nil
+		case <-conn.done:
+			// The status isn't wrapped: a stream ending with Unimplemented
+			// says the server lacks Connect, not delta reports. The next
+			// report goes over a unary call, which tells.
+			return nil, fmt.Errorf("stream closed before report was acknowledged: %v", conn.err)
+		case <-timeout.C:
+			// Start over on a new stream rather than risk matching a late
+			// acknowledgement to the wrong report
+			conn.cancel()
+			return nil, fmt.Errorf("report %d was not acknowledged within %s", report.Sequence, ackTimeout)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// SendEvent sends an event to the server if a stream is open
+func (s *Stream) SendEvent(eventType, message string) error {
+	conn := s.current()
+	if conn == nil {
+		return ErrStreamNotConnected
+	}
+	return conn.send(&agentpb.AgentMessage{Payload: &agentpb.AgentMessage_Event{Event: &agentpb.AgentEvent{
+		Type:      eventType,
+		Message:   message,
+		Timestamp: time.Now().Unix(),
+	}}})
+}
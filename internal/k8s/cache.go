@@ -0,0 +1,103 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+)
+
+// cache holds listers backed by shared informers. Reads are served from
+// memory and kept current by watch events instead of listing every tick.
+type cache struct {
+	namespaces  corelisters.NamespaceLister
+	pods        corelisters.PodLister
+	deployments appslisters.DeploymentLister
+	events      corelisters.EventLister
+}
+
+// stripManagedFields drops server-side apply bookkeeping before objects are
+// cached; it is often the bulk of an object and never read here
+func stripManagedFields(obj interface{}) (interface{}, error) {
+	if accessor, ok := obj.(metav1.ObjectMetaAccessor); ok {
+		accessor.GetObjectMeta().SetManagedFields(nil)
+	}
+	return obj, nil
+}
+
+// StartCache starts shared informers for namespaces, pods, deployments
+// and events and waits for their initial sync. Afterwards the Get* methods
+// read from the cache instead of the API server. Informers run until ctx is
+// done; resync is how often cached objects are re-delivered to handlers.
+func (c *Client) StartCache(ctx context.Context, resync time.Duration) error {
+	factory := informers.NewSharedInformerFactoryWithOptions(c.clientset, resync,
+		informers.WithTransform(stripManagedFields))
+
+	// Requesting each informer registers it with the factory before Start
+	core := factory.Core().V1()
+	apps := factory.Apps().V1()
+	cached := &cache{
+		namespaces:  core.Namespaces().Lister(),
+		pods:        core.Pods().Lister(),
+		deployments: apps.Deployments().Lister(),
+		events:      core.Events().Lister(),
+	}
+
+	start := time.Now()
+	factory.Start(ctx.Done())
+	for informerType, synced := range factory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return fmt.Errorf("failed to sync %v cache", informerType)
+		}
+	}
+	log.Printf("Kubernetes cache synced in %s", time.Since(start).Round(time.Millisecond))
+
+	c.cache = cached
+	return nil
+}
+
+func (c *cache) namespaceNames() ([]string, error) {
+	namespaces, err := c.namespaces.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cached namespaces: %w", err)
+	}
+
+	names := make([]string, 0, len(namespaces))
+	for _, ns := range namespaces {
+		names = append(names, ns.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (c *cache) podList(namespace string) ([]*corev1.Pod, error) {
+	pods, err := c.pods.Pods(namespace).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cached pods in namespace %s: %w", namespace, err)
+	}
+
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+	return pods, nil
+}
+
+func (c *cache) deploymentNames(namespace string) ([]string, error) {
+	deployments, err := c.deployments.Deployments(namespace).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cached deployments in namespace %s: %w", namespace, err)
+	}
+
+	names := make([]string, 0, len(deployments))
+	for _, deployment := range deployments {
+		names = append(names, deployment.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
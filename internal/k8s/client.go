@@ -3,10 +3,12 @@ package k8s
 import (
 	"context"
 	"fmt"
-	"strings"
+	"sort"
 	"time"
 
+	"github.com/thekubefleet/kubefleet/internal/logparse"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -14,7 +16,9 @@ import (
 )
 
 type Client struct {
-	clientset *kubernetes.Clientset
+	clientset kubernetes.Interface
+	// cache serves reads once StartCache has synced, nil until then
+	cache *cache
 }
 
 // NewClient creates a new Kubernetes client
@@ -42,6 +46,10 @@ func NewClient() (*Client, error) {
 
 // GetNamespaces returns all namespaces in the cluster
 func (c *Client) GetNamespaces(ctx context.Context) ([]string, error) {
+	if c.cache != nil {
+		return c.cache.namespaceNames()
+	}
+
 	namespaces, err := c.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list namespaces: %w", err)
@@ -54,22 +62,42 @@ func (c *Client) GetNamespaces(ctx context.Context) ([]string, error) {
 	return names, nil
 }
 
-// GetPodsInNamespace returns all pods in a specific namespace
-func (c *Client) GetPodsInNamespace(ctx context.Context, namespace string) ([]string, error) {
+// GetClusterUID returns the UID of the kube-system namespace, which is stable
+// for the lifetime of a cluster and used to identify it
+func (c *Client) GetClusterUID(ctx context.Context) (string, error) {
+	ns, err := c.clientset.CoreV1().Namespaces().Get(ctx, metav1.NamespaceSystem, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get namespace %s: %w", metav1.NamespaceSystem, err)
+	}
+	return string(ns.UID), nil
+}
+
+// GetPodsInNamespace returns all pods in a specific namespace, ordered by
+// name. Pods read from the cache are shared and must not be modified.
+func (c *Client) GetPodsInNamespace(ctx context.Context, namespace string) ([]*corev1.Pod, error) {
+	if c.cache != nil {
+		return c.cache.podList(namespace)
+	}
+
 	pods, err := c.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list pods in namespace %s: %w", namespace, err)
 	}
 
-	var podNames []string
-	for _, pod := range pods.Items {
-		podNames = append(podNames, pod.Name)
+	podList := make([]*corev1.Pod, 0, len(pods.Items))
+	for i := range pods.Items {
+		podList = append(podList, &pods.Items[i])
 	}
-	return podNames, nil
+	sort.Slice(podList, func(i, j int) bool { return podList[i].Name < podList[j].Name })
+	return podList, nil
 }
 
 // GetDeploymentsInNamespace returns all deployments in a specific namespace
 func (c *Client) GetDeploymentsInNamespace(ctx context.Context, namespace string) ([]string, error) {
+	if c.cache != nil {
+		return c.cache.deploymentNames(namespace)
+	}
+
 	deployments, err := c.clientset.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list deployments in namespace %s: %w", namespace, err)
@@ -82,25 +110,40 @@ func (c *Client) GetDeploymentsInNamespace(ctx context.Context, namespace string
 	return deploymentNames, nil
 }
 
-// GetServicesInNamespace returns all services in a specific namespace
-func (c *Client) GetServicesInNamespace(ctx context.Context, namespace string) ([]string, error) {
-	services, err := c.clientset.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+// GetSecretData returns the data of a secret
+func (c *Client) GetSecretData(ctx context.Context, namespace, name string) (map[string][]byte, error) {
+	secret, err := c.clientset.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
 	if err != nil {
-		return nil, fmt.Errorf("failed to list services in namespace %s: %w", namespace, err)
+		return nil, fmt.Errorf("failed to get secret %s in namespace %s: %w", name, namespace, err)
 	}
+	return secret.Data, nil
+}
 
-	var serviceNames []string
-	for _, service := range services.Items {
-		serviceNames = append(serviceNames, service.Name)
+// getPod reads a pod from the informer cache, whether or not the pod is
+// running, and from the API server before the cache has synced or when the
+// cache hasn't seen the pod yet
+func (c *Client) getPod(ctx context.Context, namespace, podName string) (*corev1.Pod, error) {
+	if c.cache != nil {
+		pod, err := c.cache.pods.Pods(namespace).Get(podName)
+		if err == nil {
+			return pod, nil
+		}
+		if !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to get pod %s in namespace %s: %w", podName, namespace, err)
+		}
+	}
+	pod, err := c.clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod %s in namespace %s: %w", podName, namespace, err)
 	}
-	return serviceNames, nil
+	return pod, nil
 }
 
 // GetPodContainers returns all container names in a pod
 func (c *Client) GetPodContainers(ctx context.Context, namespace, podName string) ([]string, error) {
-	pod, err := c.clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	pod, err := c.getPod(ctx, namespace, podName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get pod %s in namespace %s: %w", podName, namespace, err)
+		return nil, err
 	}
 
 	var containerNames []string
@@ -110,79 +153,40 @@ func (c *Client) GetPodContainers(ctx context.Context, namespace, podName string
 	return containerNames, nil
 }
 
-// GetPodLogs returns logs for a specific pod and container
+// GetPodLogs returns the last tailLines lines of a container's log, or all
+// of it when tailLines is 0
 func (c *Client) GetPodLogs(ctx context.Context, namespace, podName, containerName string, tailLines int64, follow bool) ([]string, error) {
-	req := c.clientset.CoreV1().Pods(namespace).GetLogs(podName, &corev1.PodLogOptions{
-		Container: containerName,
-		TailLines: &tailLines,
-		Follow:    follow,
-	})
-
-	stream, err := req.Stream(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get log stream for pod %s container %s: %w", podName, containerName, err)
-	}
-	defer stream.Close()
-
-	var logs []string
-	buffer := make([]byte, 4096)
-	for {
-		n, err := stream.Read(buffer)
-		if n > 0 {
-			logs = append(logs, string(buffer[:n]))
-		}
-		if err != nil {
-			break
-		}
-	}
-
-	return logs, nil
+	return c.podLogText(ctx, namespace, podName, containerName, LogOptions{TailLines: tailLines, Follow: follow})
 }
 
-// GetPodLogsSince returns logs since a specific time
+// GetPodLogsSince returns the lines of a container's log since a specific time
 func (c *Client) GetPodLogsSince(ctx context.Context, namespace, podName, containerName string, since time.Time) ([]string, error) {
-	req := c.clientset.CoreV1().Pods(namespace).GetLogs(podName, &corev1.PodLogOptions{
-		Container: containerName,
-		SinceTime: &metav1.Time{Time: since},
-	})
-
-	stream, err := req.Stream(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get log stream for pod %s container %s: %w", podName, containerName, err)
-	}
-	defer stream.Close()
+	return c.podLogText(ctx, namespace, podName, containerName, LogOptions{SinceTime: since})
+}
 
+func (c *Client) podLogText(ctx context.Context, namespace, podName, containerName string, opts LogOptions) ([]string, error) {
 	var logs []string
-	buffer := make([]byte, 4096)
-	for {
-		n, err := stream.Read(buffer)
-		if n > 0 {
-			logs = append(logs, string(buffer[:n]))
-		}
-		if err != nil {
-			break
-		}
-	}
-
-	return logs, nil
+	err := c.StreamPodLogLines(ctx, namespace, podName, containerName, opts, func(line LogLine) error {
+		logs = append(logs, line.Text)
+		return nil
+	})
+	return logs, err
 }
 
-// ParseLogLevel attempts to parse log level from a log line
-func ParseLogLevel(logLine string) string {
-	line := strings.ToUpper(strings.TrimSpace(logLine))
-
-	if strings.Contains(line, "ERROR") || strings.Contains(line, "FATAL") {
-		return "ERROR"
-	}
-	if strings.Contains(line, "WARN") {
-		return "WARN"
-	}
-	if strings.Contains(line, "DEBUG") {
-		return "DEBUG"
+// PodExists reports whether a pod exists
+func (c *Client) PodExists(ctx context.Context, namespace, podName string) (bool, error) {
+	_, err := c.getPod(ctx, namespace, podName)
+	if apierrors.IsNotFound(err) {
+		return false, nil
 	}
-	if strings.Contains(line, "INFO") {
-		return "INFO"
+	if err != nil {
+		return false, err
 	}
+	return true, nil
+}
 
-	return "INFO" // Default level
+// ParseLogLevel returns the level of a log line: the one its format
+// declares, or a level name among its first words
+func ParseLogLevel(logLine string) string {
+	return logparse.Parse(logLine).Level
 }
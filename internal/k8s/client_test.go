@@ -0,0 +1,40 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+)
+
+func TestGetPod(t *testing.T) {
+	pod := func(name string, phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+			Status:     corev1.PodStatus{Phase: phase},
+		}
+	}
+	indexer := toolscache.NewIndexer(toolscache.MetaNamespaceKeyFunc, toolscache.Indexers{})
+	indexer.Add(pod("done", corev1.PodSucceeded))
+	c := &Client{
+		// Only the API server knows the pod created since the last sync
+		clientset: fake.NewSimpleClientset(pod("new", corev1.PodPending)),
+		cache:     &cache{pods: corelisters.NewPodLister(indexer)},
+	}
+
+	ctx := context.Background()
+	if got, err := c.getPod(ctx, "default", "done"); err != nil || got.Status.Phase != corev1.PodSucceeded {
+		t.Errorf("getPod(done) = %v, %v, want the cached pod", got, err)
+	}
+	if got, err := c.getPod(ctx, "default", "new"); err != nil || got.Name != "new" {
+		t.Errorf("getPod(new) = %v, %v, want the pod from the API server", got, err)
+	}
+	if _, err := c.getPod(ctx, "default", "gone"); !apierrors.IsNotFound(err) {
+		t.Errorf("getPod(gone) = %v, want not found", err)
+	}
+}
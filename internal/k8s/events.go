@@ -0,0 +1,99 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Event is a Kubernetes Event. Events recorded through the events.k8s.io
+// API keep their repeat count and times in a series instead of the core
+// fields; both are read into the same fields here.
+type Event struct {
+	Namespace      string
+	Name           string
+	UID            string
+	Type           string // Normal or Warning
+	Reason         string
+	Message        string
+	Object         corev1.ObjectReference
+	Count          int32
+	FirstTimestamp time.Time
+	LastTimestamp  time.Time
+	Source         string
+}
+
+// GetEvents returns the events in the cluster, most recent first. The API
+// server keeps events for an hour by default.
+func (c *Client) GetEvents(ctx context.Context) ([]Event, error) {
+	var events []*corev1.Event
+	if c.cache != nil {
+		cached, err := c.cache.events.List(labels.Everything())
+		if err != nil {
+			return nil, fmt.Errorf("failed to list cached events: %w", err)
+		}
+		events = cached
+	} else {
+		list, err := c.clientset.CoreV1().Events(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list events: %w", err)
+		}
+		for i := range list.Items {
+			events = append(events, &list.Items[i])
+		}
+	}
+
+	converted := make([]Event, 0, len(events))
+	for _, event := range events {
+		converted = append(converted, convertEvent(event))
+	}
+	sort.Slice(converted, func(i, j int) bool {
+		if !converted[i].LastTimestamp.Equal(converted[j].LastTimestamp) {
+			return converted[i].LastTimestamp.After(converted[j].LastTimestamp)
+		}
+		return converted[i].UID < converted[j].UID
+	})
+	return converted, nil
+}
+
+func convertEvent(event *corev1.Event) Event {
+	e := Event{
+		Namespace:      event.Namespace,
+		Name:           event.Name,
+		UID:            string(event.UID),
+		Type:           event.Type,
+		Reason:         event.Reason,
+		Message:        event.Message,
+		Object:         event.InvolvedObject,
+		Count:          event.Count,
+		FirstTimestamp: event.FirstTimestamp.Time,
+		LastTimestamp:  event.LastTimestamp.Time,
+		Source:         event.Source.Component,
+	}
+	if e.Source == "" {
+		e.Source = event.ReportingController
+	}
+
+	if e.FirstTimestamp.IsZero() {
+		e.FirstTimestamp = event.EventTime.Time
+	}
+	if e.FirstTimestamp.IsZero() {
+		e.FirstTimestamp = event.CreationTimestamp.Time
+	}
+	if event.Series != nil {
+		e.Count = event.Series.Count
+		e.LastTimestamp = event.Series.LastObservedTime.Time
+	}
+	if e.LastTimestamp.IsZero() {
+		e.LastTimestamp = e.FirstTimestamp
+	}
+	if e.Count == 0 {
+		e.Count = 1
+	}
+	return e
+}
@@ -0,0 +1,66 @@
+package k8s
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestConvertEvent(t *testing.T) {
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	first := created.Add(time.Second)
+	last := created.Add(time.Minute)
+	tests := []struct {
+		name      string
+		event     corev1.Event
+		wantCount int32
+		wantFirst time.Time
+		wantLast  time.Time
+		wantFrom  string
+	}{
+		{
+			name: "core event",
+			event: corev1.Event{
+				Count:          3,
+				FirstTimestamp: metav1.NewTime(first),
+				LastTimestamp:  metav1.NewTime(last),
+				Source:         corev1.EventSource{Component: "kubelet"},
+			},
+			wantCount: 3, wantFirst: first, wantLast: last, wantFrom: "kubelet",
+		},
+		{
+			name: "events.k8s.io event with a series",
+			event: corev1.Event{
+				EventTime:           metav1.NewMicroTime(first),
+				Series:              &corev1.EventSeries{Count: 7, LastObservedTime: metav1.NewMicroTime(last)},
+				ReportingController: "default-scheduler",
+			},
+			wantCount: 7, wantFirst: first, wantLast: last, wantFrom: "default-scheduler",
+		},
+		{
+			name:      "events.k8s.io event seen once",
+			event:     corev1.Event{EventTime: metav1.NewMicroTime(first)},
+			wantCount: 1, wantFirst: first, wantLast: first,
+		},
+		{
+			name:      "event without times",
+			event:     corev1.Event{},
+			wantCount: 1, wantFirst: created, wantLast: created,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.event.ObjectMeta = metav1.ObjectMeta{Namespace: "default", Name: "web.1", UID: "uid-1", CreationTimestamp: metav1.NewTime(created)}
+			got := convertEvent(&tt.event)
+			if got.Count != tt.wantCount || !got.FirstTimestamp.Equal(tt.wantFirst) || !got.LastTimestamp.Equal(tt.wantLast) || got.Source != tt.wantFrom {
+				t.Errorf("convertEvent() = count %d, from %v to %v by %q, want count %d, from %v to %v by %q",
+					got.Count, got.FirstTimestamp, got.LastTimestamp, got.Source, tt.wantCount, tt.wantFirst, tt.wantLast, tt.wantFrom)
+			}
+			if got.UID != "uid-1" || got.Namespace != "default" {
+				t.Errorf("convertEvent() = %+v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package k8s
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MaxLogLineSize caps the length of a log line; the rest of a longer line
+// is dropped
+const MaxLogLineSize = 64 * 1024
+
+// LogLine is one whole line of a container's log
+type LogLine struct {
+	Timestamp time.Time // When the container runtime wrote the line
+	Text      string
+}
+
+// LogOptions selects the part of a container's log to read
+type LogOptions struct {
+	TailLines int64     // Lines to start from the end, 0 for the whole log
+	SinceTime time.Time // If set, only lines from this second on
+	Follow    bool      // Keep the stream open for new lines
+	Previous  bool      // Read the previous instance of a restarted container
+}
+
+// LineReader splits a log stream into whole lines. Each line is expected to
+// start with an RFC3339 timestamp, as the API server adds when asked to.
+type LineReader struct {
+	r       *bufio.Reader
+	maxSize int
+}
+
+// NewLineReader reads lines from r, cutting lines longer than maxSize bytes
+func NewLineReader(r io.Reader, maxSize int) *LineReader {
+	return &LineReader{r: bufio.NewReader(r), maxSize: maxSize}
+}
+
+// Next returns the next line. A final line without a newline is returned
+// when the stream ends, then io.EOF. Until then Next waits for the rest of
+// a partially written line.
+func (lr *LineReader) Next() (LogLine, error) {
+	var buf []byte
+	read := false
+	for {
+		frag, err := lr.r.ReadSlice('\n')
+		read = read || len(frag) > 0
+		complete := err == nil
+		if complete {
+			frag = frag[:len(frag)-1]
+		}
+		if room := lr.maxSize - len(buf); room > 0 {
+			if len(frag) > room {
+				frag = frag[:room]
+			}
+			buf = append(buf, frag...)
+		}
+
+		switch {
+		case complete:
+			return parseLogLine(buf), nil
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		case errors.Is(err, io.EOF) && read:
+			return parseLogLine(buf), nil
+		default:
+			return LogLine{}, err
+		}
+	}
+}
+
+// parseLogLine splits the timestamp off a line. Lines without one keep
+// their full text and a zero timestamp.
+func parseLogLine(raw []byte) LogLine {
+	raw = bytes.TrimSuffix(raw, []byte("\r"))
+	line := string(raw)
+	if prefix, text, ok := strings.Cut(line, " "); ok {
+		if ts, err := time.Parse(time.RFC3339Nano, prefix); err == nil {
+			return LogLine{Timestamp: ts, Text: text}
+		}
+	}
+	return LogLine{Text: line}
+}
+
+// openPodLogs opens a container's log stream with each line prefixed by its
+// RFC3339Nano timestamp. A followed stream ends when the container stops.
+func (c *Client) openPodLogs(ctx context.Context, namespace, podName, containerName string, opts LogOptions) (io.ReadCloser, error) {
+	logOpts := &corev1.PodLogOptions{
+		Container:  containerName,
+		Follow:     opts.Follow,
+		Previous:   opts.Previous,
+		Timestamps: true,
+	}
+	if opts.TailLines > 0 {
+		logOpts.TailLines = &opts.TailLines
+	}
+	if !opts.SinceTime.IsZero() {
+		logOpts.SinceTime = &metav1.Time{Time: opts.SinceTime}
+	}
+
+	stream, err := c.clientset.CoreV1().Pods(namespace).GetLogs(podName, logOpts).Stream(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get log stream for pod %s container %s: %w", podName, containerName, err)
+	}
+	return stream, nil
+}
+
+// StreamPodLogLines calls fn with each line of a container's log until the
+// log ends, fn returns an error or ctx is done
+func (c *Client) StreamPodLogLines(ctx context.Context, namespace, podName, containerName string, opts LogOptions, fn func(LogLine) error) error {
+	stream, err := c.openPodLogs(ctx, namespace, podName, containerName, opts)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	reader := NewLineReader(stream, MaxLogLineSize)
+	for {
+		line, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read logs for pod %s container %s: %w", podName, containerName, err)
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+}
+
+// GetPodLogLines returns the lines of a container's log
+func (c *Client) GetPodLogLines(ctx context.Context, namespace, podName, containerName string, opts LogOptions) ([]LogLine, error) {
+	var lines []LogLine
+	err := c.StreamPodLogLines(ctx, namespace, podName, containerName, opts, func(line LogLine) error {
+		lines = append(lines, line)
+		return nil
+	})
+	return lines, err
+}
+
+// GetCrashedContainers returns the containers of a pod whose previous
+// instance failed, e.g. while crash looping. Their previous logs usually
+// explain why.
+func (c *Client) GetCrashedContainers(ctx context.Context, namespace, podName string) ([]string, error) {
+	pod, err := c.getPod(ctx, namespace, podName)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, status := range pod.Status.ContainerStatuses {
+		if last := status.LastTerminationState.Terminated; last != nil && last.ExitCode != 0 {
+			names = append(names, status.Name)
+		}
+	}
+	return names, nil
+}
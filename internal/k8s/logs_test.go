@@ -0,0 +1,160 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func readLines(t *testing.T, lr *LineReader) []LogLine {
+	t.Helper()
+	var lines []LogLine
+	for {
+		line, err := lr.Next()
+		if errors.Is(err, io.EOF) {
+			return lines
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestLineReader(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 10, 0, 0, 123456789, time.UTC)
+	tests := []struct {
+		name    string
+		input   string
+		maxSize int
+		want    []LogLine
+	}{
+		{
+			name:  "timestamped lines",
+			input: "2024-05-01T10:00:00.123456789Z hello world\n2024-05-01T10:00:00.123456789Z  indented\n",
+			want:  []LogLine{{Timestamp: ts, Text: "hello world"}, {Timestamp: ts, Text: " indented"}},
+		},
+		{
+			name:  "offset timestamp",
+			input: "2024-05-01T12:00:00.123456789+02:00 local\n",
+			want:  []LogLine{{Timestamp: ts.In(time.FixedZone("", 2*60*60)), Text: "local"}},
+		},
+		{
+			name:  "no timestamp",
+			input: "plain text line\nnot-a-time text\n",
+			want:  []LogLine{{Text: "plain text line"}, {Text: "not-a-time text"}},
+		},
+		{
+			name:  "timestamp only",
+			input: "2024-05-01T10:00:00.123456789Z \n",
+			want:  []LogLine{{Timestamp: ts, Text: ""}},
+		},
+		{
+			name:  "windows line endings and empty lines",
+			input: "2024-05-01T10:00:00.123456789Z crlf\r\n\n",
+			want:  []LogLine{{Timestamp: ts, Text: "crlf"}, {Text: ""}},
+		},
+		{
+			name:  "final line without newline",
+			input: "2024-05-01T10:00:00.123456789Z first\n2024-05-01T10:00:00.123456789Z last",
+			want:  []LogLine{{Timestamp: ts, Text: "first"}, {Timestamp: ts, Text: "last"}},
+		},
+		{
+			name:  "empty stream",
+			input: "",
+		},
+		{
+			name:    "overlong line is cut",
+			input:   "2024-05-01T10:00:00.123456789Z " + strings.Repeat("x", 10000) + "\nnext\n",
+			maxSize: 40,
+			want:    []LogLine{{Timestamp: ts, Text: "xxxxxxxxx"}, {Text: "next"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxSize := tt.maxSize
+			if maxSize == 0 {
+				maxSize = MaxLogLineSize
+			}
+			got := readLines(t, NewLineReader(strings.NewReader(tt.input), maxSize))
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d lines %q, want %d", len(got), got, len(tt.want))
+			}
+			for i := range got {
+				if got[i].Text != tt.want[i].Text || !got[i].Timestamp.Equal(tt.want[i].Timestamp) {
+					t.Errorf("line %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLineReaderWaitsForPartialLines(t *testing.T) {
+	r, w := io.Pipe()
+	lr := NewLineReader(r, MaxLogLineSize)
+
+	lines := make(chan LogLine)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			line, err := lr.Next()
+			if err != nil {
+				errs <- err
+				return
+			}
+			lines <- line
+		}
+	}()
+
+	io.WriteString(w, "2024-05-01T10:00:00Z hel")
+	select {
+	case line := <-lines:
+		t.Fatalf("got %+v before the line was complete", line)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	io.WriteString(w, "lo\n2024-05-01T10:00:01Z wor")
+	if line := <-lines; line.Text != "hello" {
+		t.Errorf("got %q, want the joined line", line.Text)
+	}
+	io.WriteString(w, "ld")
+	w.Close()
+	if line := <-lines; line.Text != "world" || line.Timestamp.Second() != 1 {
+		t.Errorf("got %+v, want the partial last line when the stream ends", line)
+	}
+	if err := <-errs; !errors.Is(err, io.EOF) {
+		t.Errorf("Next returned %v after the last line, want io.EOF", err)
+	}
+}
+
+func TestGetCrashedContainers(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+			{Name: "app", LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 137, Reason: "OOMKilled"}}},
+			{Name: "completed", LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 0}}},
+			{Name: "sidecar"},
+		}},
+	}
+	client := &Client{clientset: fake.NewSimpleClientset(pod)}
+
+	crashed, err := client.GetCrashedContainers(context.Background(), "default", "web")
+	if err != nil {
+		t.Fatalf("GetCrashedContainers: %v", err)
+	}
+	if !reflect.DeepEqual(crashed, []string{"app"}) {
+		t.Errorf("crashed containers = %v, want [app]", crashed)
+	}
+
+	if _, err := client.GetCrashedContainers(context.Background(), "default", "gone"); err == nil {
+		t.Errorf("GetCrashedContainers of a missing pod succeeded")
+	}
+}
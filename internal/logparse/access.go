@@ -0,0 +1,128 @@
+package logparse
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// accessLogLine matches the common and combined access log formats nginx
+// and Apache write by default:
+// host ident user [time] "request" status bytes ["referer" "user agent"]
+var accessLogLine = regexp.MustCompile(`^(\S+) (\S+) (\S+) \[([^\]]+)\] "((?:[^"\\]|\\.)*)" (\d{3}) (\S+)(?: "((?:[^"\\]|\\.)*)" "((?:[^"\\]|\\.)*)")?(.*)$`)
+
+// AccessLog parses nginx and Apache access log lines. Requests that failed
+// on the server are errors and those the server refused are warnings.
+var AccessLog = ParserFunc(parseAccessLog)
+
+func parseAccessLog(line string) (Entry, bool) {
+	match := accessLogLine.FindStringSubmatch(line)
+	if match == nil {
+		return Entry{}, false
+	}
+	timestamp, err := time.Parse("02/Jan/2006:15:04:05 -0700", match[4])
+	if err != nil {
+		return Entry{}, false
+	}
+
+	fields := map[string]string{"remote_addr": match[1], "status": match[6]}
+	setField(fields, "remote_user", match[3])
+	setField(fields, "bytes", match[7])
+	setField(fields, "referer", match[8])
+	setField(fields, "user_agent", match[9])
+	if extra := strings.TrimSpace(match[10]); extra != "" {
+		fields["extra"] = extra
+	}
+	if parts := strings.Fields(match[5]); len(parts) == 3 {
+		fields["method"], fields["path"], fields["protocol"] = parts[0], parts[1], parts[2]
+	}
+
+	status, _ := strconv.Atoi(match[6])
+	level := LevelInfo
+	switch {
+	case status >= 500:
+		level = LevelError
+	case status >= 400:
+		level = LevelWarn
+	}
+
+	return Entry{
+		Format:    "access",
+		Level:     level,
+		Message:   match[5],
+		Timestamp: timestamp,
+		Fields:    fields,
+	}, true
+}
+
+// nginxErrorLine matches nginx's error log: date time [level] pid#tid: message
+var nginxErrorLine = regexp.MustCompile(`^(\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}) \[(\w+)\] (\d+)#(\d+): (.*)$`)
+
+// NginxError parses nginx error log lines
+var NginxError = ParserFunc(parseNginxError)
+
+func parseNginxError(line string) (Entry, bool) {
+	match := nginxErrorLine.FindStringSubmatch(line)
+	if match == nil {
+		return Entry{}, false
+	}
+	// nginx writes its local time without a zone; containers run in UTC
+	timestamp, err := time.Parse("2006/01/02 15:04:05", match[1])
+	if err != nil {
+		return Entry{}, false
+	}
+	return Entry{
+		Format:    "nginx-error",
+		Level:     NormalizeLevel(match[2]),
+		Message:   match[5],
+		Timestamp: timestamp,
+		Fields:    map[string]string{"pid": match[3], "tid": match[4]},
+	}, true
+}
+
+// apacheErrorLine matches Apache 2.4's error log:
+// [time] [module:level] [pid n(:tid n)] ([client addr]) message
+var apacheErrorLine = regexp.MustCompile(`^\[(\w{3} \w{3} \d{2} \d{2}:\d{2}:\d{2}(?:\.\d+)? \d{4})\] \[(?:(\w*):)?(\w+)\] \[pid (\d+)(?::tid (\d+))?\](?: \[client ([^\]]+)\])? (.*)$`)
+
+// ApacheError parses Apache error log lines
+var ApacheError = ParserFunc(parseApacheError)
+
+func parseApacheError(line string) (Entry, bool) {
+	match := apacheErrorLine.FindStringSubmatch(line)
+	if match == nil {
+		return Entry{}, false
+	}
+	timestamp, err := time.Parse("Mon Jan 02 15:04:05.999999 2006", match[1])
+	if err != nil {
+		return Entry{}, false
+	}
+
+	fields := map[string]string{"pid": match[4]}
+	setField(fields, "module", match[2])
+	setField(fields, "tid", match[5])
+	setField(fields, "client", match[6])
+	return Entry{
+		Format:    "apache-error",
+		Level:     apacheLevel(match[3]),
+		Message:   match[7],
+		Timestamp: timestamp,
+		Fields:    fields,
+	}, true
+}
+
+// apacheLevel maps Apache's levels, including trace1 to trace8
+func apacheLevel(value string) string {
+	if strings.HasPrefix(value, "trace") {
+		return LevelDebug
+	}
+	return NormalizeLevel(value)
+}
+
+// setField sets key unless value is empty or "-", which access logs write
+// for missing values
+func setField(fields map[string]string, key, value string) {
+	if value != "" && value != "-" {
+		fields[key] = value
+	}
+}
@@ -0,0 +1,125 @@
+package logparse
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Keys JSON loggers (zap, logrus, zerolog, slog, bunyan, pino, ECS, Google
+// Cloud Logging) use for the well-known parts of a line, in order of
+// preference. Nested keys are joined with dots.
+var (
+	jsonLevelKeys   = []string{"level", "lvl", "severity", "log.level", "levelname", "loglevel"}
+	jsonMessageKeys = []string{"msg", "message", "log"}
+	jsonTimeKeys    = []string{"time", "ts", "timestamp", "@timestamp", "t", "datetime", "date"}
+	jsonTraceKeys   = []string{"trace_id", "traceId", "traceID", "trace.id", "dd.trace_id", "logging.googleapis.com/trace", "trace"}
+)
+
+// JSON parses lines that are a JSON object
+var JSON = ParserFunc(parseJSON)
+
+func parseJSON(line string) (Entry, bool) {
+	if !strings.HasPrefix(line, "{") || !strings.HasSuffix(line, "}") {
+		return Entry{}, false
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(line))
+	decoder.UseNumber()
+	var object map[string]interface{}
+	if err := decoder.Decode(&object); err != nil {
+		return Entry{}, false
+	}
+
+	fields := make(map[string]string, len(object))
+	flattenJSON("", object, fields)
+
+	entry := Entry{Format: "json"}
+	if key, value := takeField(fields, jsonLevelKeys); key != "" {
+		entry.Level = NormalizeLevel(value)
+		if entry.Level == "" {
+			// Keep levels we don't know about, e.g. "DEFAULT"
+			fields[key] = value
+		}
+	}
+	_, entry.Message = takeField(fields, jsonMessageKeys)
+	if key, value := takeField(fields, jsonTimeKeys); key != "" {
+		if entry.Timestamp = parseTimestamp(value); entry.Timestamp.IsZero() {
+			fields[key] = value
+		}
+	}
+	_, entry.TraceID = takeField(fields, jsonTraceKeys)
+	entry.Fields = fields
+	return entry, true
+}
+
+// flattenJSON adds the values of an object to fields as strings, joining
+// the keys of nested objects with dots. Arrays are kept as JSON.
+func flattenJSON(prefix string, object map[string]interface{}, fields map[string]string) {
+	for key, value := range object {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			flattenJSON(key, v, fields)
+		case string:
+			fields[key] = v
+		case json.Number:
+			fields[key] = v.String()
+		case bool:
+			fields[key] = strconv.FormatBool(v)
+		case nil:
+			fields[key] = "null"
+		default:
+			var buf bytes.Buffer
+			encoder := json.NewEncoder(&buf)
+			encoder.SetEscapeHTML(false)
+			if err := encoder.Encode(v); err == nil {
+				fields[key] = strings.TrimSuffix(buf.String(), "\n")
+			}
+		}
+	}
+}
+
+// takeField removes the first of keys present in fields, returning it and
+// its value
+func takeField(fields map[string]string, keys []string) (string, string) {
+	for _, key := range keys {
+		if value, ok := fields[key]; ok {
+			delete(fields, key)
+			return key, value
+		}
+	}
+	return "", ""
+}
+
+// parseTimestamp parses the timestamp formats loggers write: RFC3339 and
+// its variants, or a Unix time in seconds, milliseconds or nanoseconds. It
+// returns the zero time for anything else.
+func parseTimestamp(value string) time.Time {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02 15:04:05.999999999Z07:00", "2006-01-02 15:04:05.999999999", "2006-01-02 15:04:05,999"} {
+		if ts, err := time.Parse(layout, value); err == nil {
+			return ts
+		}
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number <= 0 {
+		return time.Time{}
+	}
+	switch {
+	case number < 1e11: // seconds, until the year 5138
+		sec, frac := math.Modf(number)
+		return time.Unix(int64(sec), int64(frac*1e9)).UTC()
+	case number < 1e14: // milliseconds
+		return time.UnixMilli(int64(number)).UTC()
+	case number < 1e17: // microseconds
+		return time.UnixMicro(int64(number)).UTC()
+	default: // nanoseconds
+		return time.Unix(0, int64(number)).UTC()
+	}
+}
@@ -0,0 +1,70 @@
+package logparse
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// klogHeader matches the header klog and glog put before each message:
+// Lmmdd hh:mm:ss.uuuuuu threadid file:line]
+var klogHeader = regexp.MustCompile(`^([IWEF])(\d{4} \d{2}:\d{2}:\d{2}\.\d{6})\s+(\d+) ([^ :\]]+:\d+)\] ?`)
+
+// now is when klog timestamps, which have no year, are assumed to be from
+var now = time.Now
+
+// Klog parses lines written by klog and glog, including klog's structured
+// "message" key=value form
+var Klog = ParserFunc(parseKlog)
+
+func parseKlog(line string) (Entry, bool) {
+	match := klogHeader.FindStringSubmatch(line)
+	if match == nil {
+		return Entry{}, false
+	}
+
+	entry := Entry{
+		Format:    "klog",
+		Level:     NormalizeLevel(match[1]),
+		Timestamp: klogTime(match[2]),
+		Fields: map[string]string{
+			"thread": match[3],
+			"source": match[4],
+		},
+	}
+
+	message := line[len(match[0]):]
+	if strings.HasPrefix(message, `"`) {
+		if quoted, err := strconv.QuotedPrefix(message); err == nil {
+			if pairs, ok := splitLogfmt(message[len(quoted):]); ok {
+				entry.Message, _ = strconv.Unquote(quoted)
+				for _, pair := range pairs {
+					entry.Fields[pair[0]] = pair[1]
+				}
+				_, entry.TraceID = takeField(entry.Fields, logfmtTraceKeys)
+				return entry, true
+			}
+		}
+	}
+	entry.Message = message
+	return entry, true
+}
+
+// klogTime parses a klog timestamp in the latest year that doesn't put it
+// more than a day in the future, allowing for clock skew around New Year. A
+// February 29 is put in the latest leap year.
+func klogTime(value string) time.Time {
+	current := now()
+	ts, err := time.ParseInLocation("0102 15:04:05.000000", value, time.UTC)
+	if err != nil {
+		return time.Time{}
+	}
+	for year := current.Year() + 1; year > current.Year()-8; year-- {
+		t := time.Date(year, ts.Month(), ts.Day(), ts.Hour(), ts.Minute(), ts.Second(), ts.Nanosecond(), time.UTC)
+		if t.Day() == ts.Day() && !t.After(current.Add(24*time.Hour)) {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,95 @@
+package logparse
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Keys logfmt loggers (logrus, go-kit, slog, Heroku) use for the well-known
+// parts of a line
+var (
+	logfmtLevelKeys   = []string{"level", "lvl", "severity"}
+	logfmtMessageKeys = []string{"msg", "message"}
+	logfmtTimeKeys    = []string{"time", "ts", "t", "timestamp"}
+	logfmtTraceKeys   = []string{"trace_id", "traceId", "traceID", "trace"}
+)
+
+// Logfmt parses lines made only of key=value pairs, at least two of them
+var Logfmt = ParserFunc(parseLogfmt)
+
+func parseLogfmt(line string) (Entry, bool) {
+	pairs, ok := splitLogfmt(line)
+	if !ok || len(pairs) < 2 {
+		return Entry{}, false
+	}
+
+	fields := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		fields[pair[0]] = pair[1]
+	}
+
+	entry := Entry{Format: "logfmt"}
+	if key, value := takeField(fields, logfmtLevelKeys); key != "" {
+		if entry.Level = NormalizeLevel(value); entry.Level == "" {
+			fields[key] = value
+		}
+	}
+	_, entry.Message = takeField(fields, logfmtMessageKeys)
+	if key, value := takeField(fields, logfmtTimeKeys); key != "" {
+		if entry.Timestamp = parseTimestamp(value); entry.Timestamp.IsZero() {
+			fields[key] = value
+		}
+	}
+	_, entry.TraceID = takeField(fields, logfmtTraceKeys)
+	entry.Fields = fields
+	return entry, true
+}
+
+// splitLogfmt splits text into key=value pairs, unquoting quoted values. It
+// returns false if any part of text isn't a pair.
+func splitLogfmt(text string) ([][2]string, bool) {
+	var pairs [][2]string
+	for {
+		text = strings.TrimLeft(text, " \t")
+		if text == "" {
+			return pairs, true
+		}
+
+		eq := strings.IndexByte(text, '=')
+		if eq <= 0 || !isLogfmtKey(text[:eq]) {
+			return nil, false
+		}
+		key := text[:eq]
+		text = text[eq+1:]
+
+		var value string
+		if strings.HasPrefix(text, `"`) {
+			quoted, err := strconv.QuotedPrefix(text)
+			if err != nil {
+				return nil, false
+			}
+			value, _ = strconv.Unquote(quoted)
+			text = text[len(quoted):]
+			if text != "" && text[0] != ' ' && text[0] != '\t' {
+				return nil, false
+			}
+		} else {
+			end := strings.IndexAny(text, " \t")
+			if end < 0 {
+				end = len(text)
+			}
+			value = text[:end]
+			text = text[end:]
+			if strings.ContainsRune(value, '"') {
+				return nil, false
+			}
+		}
+		pairs = append(pairs, [2]string{key, value})
+	}
+}
+
+// isLogfmtKey reports whether s can be a key: no spaces, quotes or equals
+// signs
+func isLogfmtKey(s string) bool {
+	return !strings.ContainsAny(s, " \t\"=")
+}
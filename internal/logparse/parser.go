@@ -0,0 +1,155 @@
+package logparse
+
+import (
+	"strings"
+	"time"
+)
+
+// Levels a parsed line is normalized to
+const (
+	LevelError = "ERROR"
+	LevelWarn  = "WARN"
+	LevelInfo  = "INFO"
+	LevelDebug = "DEBUG"
+)
+
+// Well-known keys of the fields map PodLog carries
+const (
+	FieldFormat  = "format"
+	FieldMessage = "message"
+	FieldTime    = "time"
+	FieldTraceID = "trace_id"
+)
+
+// Entry is what a parser pulls out of a log line. Empty values mean the
+// line didn't have them.
+type Entry struct {
+	Format    string
+	Level     string
+	Message   string
+	Timestamp time.Time
+	TraceID   string
+	Fields    map[string]string
+}
+
+// Parser recognizes one log format. It returns false for lines that aren't
+// in its format.
+type Parser interface {
+	Parse(line string) (Entry, bool)
+}
+
+// ParserFunc adapts a function to a Parser
+type ParserFunc func(line string) (Entry, bool)
+
+// Parse calls f
+func (f ParserFunc) Parse(line string) (Entry, bool) {
+	return f(line)
+}
+
+// Pipeline tries its parsers in order, falling back to plain text
+type Pipeline struct {
+	parsers []Parser
+}
+
+// NewPipeline creates a pipeline trying parsers in the given order
+func NewPipeline(parsers ...Parser) *Pipeline {
+	return &Pipeline{parsers: parsers}
+}
+
+// Default is the pipeline agents parse log lines with. More specific formats
+// come first, as some access log lines also look like logfmt.
+var Default = NewPipeline(JSON, Klog, AccessLog, NginxError, ApacheError, Logfmt)
+
+// Parse parses a line with the default pipeline
+func Parse(line string) Entry {
+	return Default.Parse(line)
+}
+
+// Parse returns the entry of the first parser that recognizes line. Lines
+// no parser recognizes are plain text. Entries always have a level.
+func (p *Pipeline) Parse(line string) Entry {
+	line = strings.TrimSpace(line)
+	for _, parser := range p.parsers {
+		entry, ok := parser.Parse(line)
+		if !ok {
+			continue
+		}
+		if entry.Level == "" {
+			entry.Level = plainLevel(entry.Message)
+		}
+		return entry
+	}
+	return Entry{Level: plainLevel(line), Message: line}
+}
+
+// FieldsMap flattens an entry into the fields map PodLog carries, with the
+// message, time, trace ID and format under their well-known keys. Plain
+// text lines have no fields.
+func (e Entry) FieldsMap() map[string]string {
+	if e.Format == "" {
+		return nil
+	}
+	fields := make(map[string]string, len(e.Fields)+4)
+	for key, value := range e.Fields {
+		fields[key] = value
+	}
+	fields[FieldFormat] = e.Format
+	if e.Message != "" {
+		fields[FieldMessage] = e.Message
+	}
+	if !e.Timestamp.IsZero() {
+		fields[FieldTime] = e.Timestamp.UTC().Format(time.RFC3339Nano)
+	}
+	if e.TraceID != "" {
+		fields[FieldTraceID] = e.TraceID
+	}
+	return fields
+}
+
+// NormalizeLevel maps the level names and numbers used by common logging
+// libraries to one of the four levels, or "" when value isn't a level
+func NormalizeLevel(value string) string {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "error", "err", "eror", "fatal", "fatl", "panic", "dpanic", "critical", "crit", "alert", "emerg", "emergency", "severe",
+		"e", "f", "50", "60":
+		return LevelError
+	case "warn", "warning", "wrn", "w", "40":
+		return LevelWarn
+	case "info", "information", "informational", "inf", "notice", "i", "30":
+		return LevelInfo
+	case "debug", "dbg", "dbug", "trace", "trce", "d", "t", "10", "20":
+		return LevelDebug
+	}
+	return ""
+}
+
+// plainLevelWords is how many leading words of a plain text line are
+// searched for a level
+const plainLevelWords = 6
+
+// plainLevel finds a level name among the first words of a line, such as
+// "ERROR", "[warn]" or "INFO:root:". Words inside key=value pairs, URLs or
+// the rest of the message don't count. Lines without one are INFO.
+func plainLevel(line string) string {
+	words := strings.Fields(line)
+	if len(words) > plainLevelWords {
+		words = words[:plainLevelWords]
+	}
+	for _, word := range words {
+		if strings.ContainsAny(word, "=/") {
+			continue
+		}
+		word = strings.Trim(word, "[]()<>{}|:,.-")
+		if name, _, ok := strings.Cut(word, ":"); ok {
+			word = name
+		}
+		// Single letters and numbers are only levels in structured formats
+		if len(word) < 3 || word[0] >= '0' && word[0] <= '9' {
+			continue
+		}
+		if level := NormalizeLevel(word); level != "" {
+			return level
+		}
+	}
+	return LevelInfo
+}
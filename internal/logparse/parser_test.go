@@ -0,0 +1,367 @@
+package logparse
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// setNow makes klog timestamps relative to ts for the rest of the test
+func setNow(t *testing.T, ts time.Time) {
+	t.Helper()
+	saved := now
+	now = func() time.Time { return ts }
+	t.Cleanup(func() { now = saved })
+}
+
+func TestParse(t *testing.T) {
+	setNow(t, time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name string
+		line string
+		want Entry
+	}{
+		// klog and glog
+		{
+			name: "klog structured",
+			line: `I0501 10:00:00.123456       1 controller.go:123] "Reconciling" object="default/web" attempt=2`,
+			want: Entry{
+				Format:    "klog",
+				Level:     LevelInfo,
+				Message:   "Reconciling",
+				Timestamp: time.Date(2024, 5, 1, 10, 0, 0, 123456000, time.UTC),
+				Fields:    map[string]string{"thread": "1", "source": "controller.go:123", "object": "default/web", "attempt": "2"},
+			},
+		},
+		{
+			name: "klog text",
+			line: `E0501 10:00:00.000001   12345 reflector.go:138] k8s.io/client-go: failed to list *v1.Pod: Unauthorized`,
+			want: Entry{
+				Format:    "klog",
+				Level:     LevelError,
+				Message:   "k8s.io/client-go: failed to list *v1.Pod: Unauthorized",
+				Timestamp: time.Date(2024, 5, 1, 10, 0, 0, 1000, time.UTC),
+				Fields:    map[string]string{"thread": "12345", "source": "reflector.go:138"},
+			},
+		},
+		{
+			name: "klog trace ID",
+			line: `W0501 10:00:00.000000 7 server.go:9] "Slow request" latency="1.5s" trace_id=4bf92f3577b34da6`,
+			want: Entry{
+				Format:    "klog",
+				Level:     LevelWarn,
+				Message:   "Slow request",
+				Timestamp: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+				TraceID:   "4bf92f3577b34da6",
+				Fields:    map[string]string{"thread": "7", "source": "server.go:9", "latency": "1.5s"},
+			},
+		},
+		{
+			name: "klog unterminated quote is text",
+			line: `F0501 10:00:00.000000 1 main.go:1] "unterminated`,
+			want: Entry{
+				Format:    "klog",
+				Level:     LevelError,
+				Message:   `"unterminated`,
+				Timestamp: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+				Fields:    map[string]string{"thread": "1", "source": "main.go:1"},
+			},
+		},
+		{
+			name: "klog invalid date",
+			line: `I1301 10:00:00.000000 1 main.go:1] month thirteen`,
+			want: Entry{
+				Format:  "klog",
+				Level:   LevelInfo,
+				Message: "month thirteen",
+				Fields:  map[string]string{"thread": "1", "source": "main.go:1"},
+			},
+		},
+		{
+			name: "klog header without microseconds",
+			line: `I0501 10:00:00 1 main.go:1] short`,
+			want: Entry{Level: LevelInfo, Message: `I0501 10:00:00 1 main.go:1] short`},
+		},
+
+		// JSON
+		{
+			name: "zap",
+			line: `{"level":"error","ts":1714557600.5,"caller":"api/handler.go:42","msg":"request failed","trace_id":"4bf92f3577b34da6","error":"context deadline exceeded","attempt":3,"retry":true,"tags":["a","b"],"extra":null}`,
+			want: Entry{
+				Format:    "json",
+				Level:     LevelError,
+				Message:   "request failed",
+				Timestamp: time.Date(2024, 5, 1, 10, 0, 0, 500000000, time.UTC),
+				TraceID:   "4bf92f3577b34da6",
+				Fields: map[string]string{
+					"caller":  "api/handler.go:42",
+					"error":   "context deadline exceeded",
+					"attempt": "3",
+					"retry":   "true",
+					"tags":    `["a","b"]`,
+					"extra":   "null",
+				},
+			},
+		},
+		{
+			name: "logrus",
+			line: `{"level":"warning","msg":"cache miss","time":"2024-05-01T12:00:00+02:00","key":"user:1"}`,
+			want: Entry{
+				Format:    "json",
+				Level:     LevelWarn,
+				Message:   "cache miss",
+				Timestamp: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+				Fields:    map[string]string{"key": "user:1"},
+			},
+		},
+		{
+			name: "bunyan numeric level and milliseconds",
+			line: `{"name":"api","hostname":"web-1","pid":1,"level":50,"msg":"upstream down","time":1714557600123,"v":0}`,
+			want: Entry{
+				Format:    "json",
+				Level:     LevelError,
+				Message:   "upstream down",
+				Timestamp: time.Date(2024, 5, 1, 10, 0, 0, 123000000, time.UTC),
+				Fields:    map[string]string{"name": "api", "hostname": "web-1", "pid": "1", "v": "0"},
+			},
+		},
+		{
+			name: "Google Cloud unknown severity",
+			line: `{"severity":"DEFAULT","message":"ready","logging.googleapis.com/trace":"projects/p/traces/t1"}`,
+			want: Entry{
+				Format:  "json",
+				Level:   LevelInfo,
+				Message: "ready",
+				TraceID: "projects/p/traces/t1",
+				Fields:  map[string]string{"severity": "DEFAULT"},
+			},
+		},
+		{
+			name: "ECS nested objects",
+			line: `{"@timestamp":"2024-05-01T10:00:00.000Z","log":{"level":"debug","logger":"app"},"message":"tick","http":{"response":{"status_code":200}}}`,
+			want: Entry{
+				Format:    "json",
+				Level:     LevelDebug,
+				Message:   "tick",
+				Timestamp: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+				Fields:    map[string]string{"log.logger": "app", "http.response.status_code": "200"},
+			},
+		},
+		{
+			name: "JSON unparseable time is kept",
+			line: `{"level":"info","msg":"backup done","time":"yesterday"}`,
+			want: Entry{
+				Format:  "json",
+				Level:   LevelInfo,
+				Message: "backup done",
+				Fields:  map[string]string{"time": "yesterday"},
+			},
+		},
+		{
+			name: "JSON without a level",
+			line: `{"msg":"Error connecting to db","attempt":1}`,
+			want: Entry{
+				Format:  "json",
+				Level:   LevelError,
+				Message: "Error connecting to db",
+				Fields:  map[string]string{"attempt": "1"},
+			},
+		},
+		{
+			name: "truncated JSON",
+			line: `{"level":"error","msg":"request failed","error":"conn`,
+			want: Entry{Level: LevelInfo, Message: `{"level":"error","msg":"request failed","error":"conn`},
+		},
+		{
+			name: "invalid JSON",
+			line: `{"level":"error", "msg"}`,
+			want: Entry{Level: LevelInfo, Message: `{"level":"error", "msg"}`},
+		},
+		{
+			name: "JSON array",
+			line: `["not","an","object"]`,
+			want: Entry{Level: LevelInfo, Message: `["not","an","object"]`},
+		},
+
+		// logfmt
+		{
+			name: "logrus text",
+			line: `time="2024-05-01T10:00:00.5Z" level=warning msg="disk almost full" path=/var used=91%`,
+			want: Entry{
+				Format:    "logfmt",
+				Level:     LevelWarn,
+				Message:   "disk almost full",
+				Timestamp: time.Date(2024, 5, 1, 10, 0, 0, 500000000, time.UTC),
+				Fields:    map[string]string{"path": "/var", "used": "91%"},
+			},
+		},
+		{
+			name: "go-kit",
+			line: `ts=1714557600123 caller=main.go:20 lvl=crit msg=shutdown trace=abc`,
+			want: Entry{
+				Format:    "logfmt",
+				Level:     LevelError,
+				Message:   "shutdown",
+				Timestamp: time.Date(2024, 5, 1, 10, 0, 0, 123000000, time.UTC),
+				TraceID:   "abc",
+				Fields:    map[string]string{"caller": "main.go:20"},
+			},
+		},
+		{
+			name: "logfmt unknown level is kept",
+			line: `level=verbose msg="cache warmed"`,
+			want: Entry{
+				Format:  "logfmt",
+				Level:   LevelInfo,
+				Message: "cache warmed",
+				Fields:  map[string]string{"level": "verbose"},
+			},
+		},
+		{
+			name: "single pair isn't logfmt",
+			line: `status=ok`,
+			want: Entry{Level: LevelInfo, Message: "status=ok"},
+		},
+		{
+			name: "logfmt unterminated quote",
+			line: `level=error msg="unterminated`,
+			want: Entry{Level: LevelInfo, Message: `level=error msg="unterminated`},
+		},
+		{
+			name: "logfmt stray quote",
+			line: `level=error msg=say"hi"`,
+			want: Entry{Level: LevelInfo, Message: `level=error msg=say"hi"`},
+		},
+
+		// Plain text
+		{
+			name: "plain level prefix",
+			line: "ERROR: something broke",
+			want: Entry{Level: LevelError, Message: "ERROR: something broke"},
+		},
+		{
+			name: "plain level after the time",
+			line: "2024-05-01 10:00:00 WARNING [main] disk space low",
+			want: Entry{Level: LevelWarn, Message: "2024-05-01 10:00:00 WARNING [main] disk space low"},
+		},
+		{
+			name: "python logging",
+			line: "DEBUG:urllib3.connectionpool:Starting new HTTPS connection (1): example.com:443",
+			want: Entry{Level: LevelDebug, Message: "DEBUG:urllib3.connectionpool:Starting new HTTPS connection (1): example.com:443"},
+		},
+		{
+			name: "level in a URL doesn't count",
+			line: "GET /api/errors returned 200",
+			want: Entry{Level: LevelInfo, Message: "GET /api/errors returned 200"},
+		},
+		{
+			name: "surrounding whitespace",
+			line: "  warn: retrying \r",
+			want: Entry{Level: LevelWarn, Message: "warn: retrying"},
+		},
+		{
+			name: "empty line",
+			line: "",
+			want: Entry{Level: LevelInfo},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.line)
+			if got.Format != tt.want.Format || got.Level != tt.want.Level || got.Message != tt.want.Message || got.TraceID != tt.want.TraceID {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+			if !got.Timestamp.Equal(tt.want.Timestamp) {
+				t.Errorf("Timestamp = %v, want %v", got.Timestamp, tt.want.Timestamp)
+			}
+			if len(got.Fields) != 0 || len(tt.want.Fields) != 0 {
+				if !reflect.DeepEqual(got.Fields, tt.want.Fields) {
+					t.Errorf("Fields = %v, want %v", got.Fields, tt.want.Fields)
+				}
+			}
+		})
+	}
+}
+
+func TestKlogYear(t *testing.T) {
+	tests := []struct {
+		name string
+		now  time.Time
+		line string
+		want time.Time
+	}{
+		{
+			name: "this year",
+			now:  time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC),
+			line: "I0614 08:30:00.000000 1 main.go:1] x",
+			want: time.Date(2024, 6, 14, 8, 30, 0, 0, time.UTC),
+		},
+		{
+			name: "last year's December in January",
+			now:  time.Date(2024, 1, 1, 0, 30, 0, 0, time.UTC),
+			line: "I1231 23:59:59.000000 1 main.go:1] x",
+			want: time.Date(2023, 12, 31, 23, 59, 59, 0, time.UTC),
+		},
+		{
+			name: "just after midnight on New Year",
+			now:  time.Date(2024, 1, 1, 0, 30, 0, 0, time.UTC),
+			line: "I0101 00:10:00.000000 1 main.go:1] x",
+			want: time.Date(2024, 1, 1, 0, 10, 0, 0, time.UTC),
+		},
+		{
+			name: "clock skew of less than a day",
+			now:  time.Date(2023, 12, 31, 23, 0, 0, 0, time.UTC),
+			line: "I0101 01:00:00.000000 1 main.go:1] x",
+			want: time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "more than a day ahead is last year",
+			now:  time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC),
+			line: "I0701 00:00:00.000000 1 main.go:1] x",
+			want: time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "leap day in a leap year",
+			now:  time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			line: "I0229 12:00:00.000000 1 main.go:1] x",
+			want: time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "leap day the year after",
+			now:  time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+			line: "I0229 12:00:00.000000 1 main.go:1] x",
+			want: time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setNow(t, tt.now)
+			entry, ok := Klog.Parse(tt.line)
+			if !ok {
+				t.Fatalf("Klog didn't parse %q", tt.line)
+			}
+			if !entry.Timestamp.Equal(tt.want) {
+				t.Errorf("Timestamp = %v, want %v", entry.Timestamp, tt.want)
+			}
+		})
+	}
+}
+
+func TestFieldsMap(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 10, 0, 0, 0, time.FixedZone("", 2*60*60))
+	entry := Entry{Format: "json", Level: LevelInfo, Message: "m", Timestamp: ts, TraceID: "abc", Fields: map[string]string{"user": "1"}}
+	want := map[string]string{
+		"user":       "1",
+		FieldFormat:  "json",
+		FieldMessage: "m",
+		FieldTime:    "2024-05-01T08:00:00Z",
+		FieldTraceID: "abc",
+	}
+	if got := entry.FieldsMap(); !reflect.DeepEqual(got, want) {
+		t.Errorf("FieldsMap() = %v, want %v", got, want)
+	}
+	if got := Parse("plain text").FieldsMap(); got != nil {
+		t.Errorf("FieldsMap() of plain text = %v, want nil", got)
+	}
+}
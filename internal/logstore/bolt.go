@@ -0,0 +1,94 @@
+package logstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	bolt "go.etcd.io/bbolt"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/thekubefleet/kubefleet/internal/boltdb"
+	agentpb "github.com/thekubefleet/kubefleet/proto"
+)
+
+// linesBucket maps an entry id, as 8 big-endian bytes, to its lineRecord
+var linesBucket = []byte("lines")
+
+type lineRecord struct {
+	Cluster string `json:"cluster"`
+	Line    []byte `json:"line"`
+}
+
+// Open opens or creates a store persisted in the bbolt database at path,
+// loading and indexing the lines still within retention
+func Open(path string, retention Retention) (*Store, error) {
+	s := NewStore(retention)
+	db, err := boltdb.OpenBucket(path, "log store", linesBucket, s.load)
+	if err != nil {
+		return nil, err
+	}
+	s.db = db
+
+	boltdb.EnforceRetention("log", s)
+	return s, nil
+}
+
+// load reads a stored line into memory and indexes it
+func (s *Store) load(key, value []byte) error {
+	var record lineRecord
+	line := &agentpb.PodLog{}
+	if err := json.Unmarshal(value, &record); err != nil || proto.Unmarshal(record.Line, line) != nil {
+		log.Printf("Skipping unreadable log line %d", boltdb.ID(key))
+		return nil
+	}
+
+	e := &entry{
+		id:      boltdb.ID(key),
+		cluster: record.Cluster,
+		line:    line,
+		size:    int64(proto.Size(line) + len(record.Cluster)),
+	}
+	// Identical lines were stored in the order they were counted
+	e.key = newLineKey(record.Cluster, line)
+	for {
+		if _, ok := s.seen[e.key]; !ok {
+			break
+		}
+		e.key.N++
+	}
+	s.nextID = e.id + 1
+	s.insert(e)
+	return nil
+}
+
+// persist writes added entries and deletes evicted ones. Callers must hold
+// s.mu.
+func (s *Store) persist(added []*entry, evicted []uint64) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(linesBucket)
+		for _, id := range evicted {
+			if err := bucket.Delete(boltdb.Key(id)); err != nil {
+				return err
+			}
+		}
+		for _, e := range added {
+			line, err := proto.Marshal(e.line)
+			if err != nil {
+				return err
+			}
+			value, err := json.Marshal(lineRecord{Cluster: e.cluster, Line: line})
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put(boltdb.Key(e.id), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to persist log lines: %w", err)
+	}
+	return nil
+}
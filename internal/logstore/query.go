@@ -0,0 +1,192 @@
+package logstore
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/thekubefleet/kubefleet/internal/auth"
+	agentpb "github.com/thekubefleet/kubefleet/proto"
+)
+
+// ErrInvalidCursor is returned for a cursor Search didn't hand out
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Query selects log lines. Empty fields match any line.
+type Query struct {
+	Scope     *auth.Scope // Lines outside the scope are never returned
+	Cluster   string
+	Namespace string
+	Pod       string
+	Container string
+	Levels    map[string]bool
+	Terms     []string // Words every line must contain, found with the index
+	Contains  string   // Case-insensitive substring
+	Pattern   *regexp.Regexp
+	// Selector is matched against the labels of the line's pod
+	Selector labels.Selector
+	// FieldSelector is matched against FieldSet
+	FieldSelector labels.Selector
+	From          int64
+	To            int64 // 0 for no upper bound
+	Limit         int   // Lines per page, 0 for all
+	Cursor        string
+}
+
+// Result is a log line found by a search, with the cluster it came from
+type Result struct {
+	Cluster string `json:"cluster"`
+	*agentpb.PodLog
+}
+
+// FieldSet is the set a field selector is matched against: the line's
+// parsed fields along with where it came from and its level, which take
+// precedence over parsed fields of the same name
+func FieldSet(cluster string, line *agentpb.PodLog) labels.Set {
+	set := make(labels.Set, len(line.Fields)+5)
+	for key, value := range line.Fields {
+		set[key] = value
+	}
+	set["cluster"] = cluster
+	set["namespace"] = line.Namespace
+	set["pod"] = line.PodName
+	set["container"] = line.ContainerName
+	set["level"] = line.Level
+	return set
+}
+
+// matches reports whether an entry matches every condition of q but the
+// terms and the cursor
+func (q *Query) matches(e *entry) bool {
+	line := e.line
+	to := q.To
+	if to == 0 {
+		to = math.MaxInt64
+	}
+	switch {
+	case !q.Scope.Allows(e.cluster, line.Namespace),
+		q.Cluster != "" && e.cluster != q.Cluster,
+		q.Namespace != "" && line.Namespace != q.Namespace,
+		q.Pod != "" && line.PodName != q.Pod,
+		q.Container != "" && line.ContainerName != q.Container,
+		line.Timestamp < q.From || line.Timestamp > to,
+		q.Levels != nil && !q.Levels[line.Level],
+		q.Contains != "" && !strings.Contains(strings.ToLower(line.LogLine), q.Contains),
+		q.Pattern != nil && !q.Pattern.MatchString(line.LogLine):
+		return false
+	}
+	return (q.Selector == nil || q.Selector.Matches(labels.Set(line.PodLabels))) &&
+		(q.FieldSelector == nil || q.FieldSelector.Matches(FieldSet(e.cluster, line)))
+}
+
+// Search returns the lines matching q, newest first, and a cursor for the
+// next page, which is empty on the last page. Pages stay consistent while
+// new lines arrive, since those come before the first page.
+func (s *Store) Search(q Query) ([]Result, string, error) {
+	var after *lineKey
+	if q.Cursor != "" {
+		key, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		after = key
+	}
+
+	q.Contains = strings.ToLower(q.Contains)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var matches []*entry
+	check := func(e *entry) {
+		if e != nil && q.matches(e) && (after == nil || after.before(e.key)) {
+			matches = append(matches, e)
+		}
+	}
+	if len(q.Terms) > 0 {
+		for _, id := range s.lookup(q.Terms) {
+			check(s.get(id))
+		}
+	} else {
+		for _, e := range s.entries {
+			check(e)
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].key.before(matches[j].key)
+	})
+
+	var cursor string
+	if q.Limit > 0 && len(matches) > q.Limit {
+		matches = matches[:q.Limit]
+		cursor = encodeCursor(matches[len(matches)-1].key)
+	}
+	results := make([]Result, len(matches))
+	for i, e := range matches {
+		results[i] = Result{Cluster: e.cluster, PodLog: e.line}
+	}
+	return results, cursor, nil
+}
+
+// lookup returns the ids of the lines containing every term, intersecting
+// the shortest posting lists first. Callers must hold s.mu.
+func (s *Store) lookup(terms []string) []uint64 {
+	lists := make([][]uint64, 0, len(terms))
+	for _, term := range terms {
+		ids, ok := s.postings[strings.ToLower(term)]
+		if !ok {
+			return nil
+		}
+		lists = append(lists, ids)
+	}
+	sort.Slice(lists, func(i, j int) bool {
+		return len(lists[i]) < len(lists[j])
+	})
+
+	result := lists[0]
+	for _, ids := range lists[1:] {
+		var both []uint64
+		i, j := 0, 0
+		for i < len(result) && j < len(ids) {
+			switch {
+			case result[i] < ids[j]:
+				i++
+			case result[i] > ids[j]:
+				j++
+			default:
+				both = append(both, result[i])
+				i++
+				j++
+			}
+		}
+		if len(both) == 0 {
+			return nil
+		}
+		result = both
+	}
+	return result
+}
+
+func encodeCursor(key lineKey) string {
+	raw, _ := json.Marshal(key)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(value string) (*lineKey, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var key lineKey
+	if err := json.Unmarshal(raw, &key); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &key, nil
+}
@@ -0,0 +1,251 @@
+package logstore
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/thekubefleet/kubefleet/internal/auth"
+	agentpb "github.com/thekubefleet/kubefleet/proto"
+)
+
+func podLog(namespace, pod, container string, ts int64, level, text string, fields map[string]string) *agentpb.PodLog {
+	return &agentpb.PodLog{
+		Namespace:     namespace,
+		PodName:       pod,
+		ContainerName: container,
+		Timestamp:     ts,
+		Level:         level,
+		LogLine:       text,
+		Fields:        fields,
+	}
+}
+
+// testStore holds lines a to f of two clusters, f being the newest. Each
+// pod has an app label; the prod web pod is in the frontend tier and the
+// staging one is also a canary.
+func testStore(t *testing.T) *Store {
+	t.Helper()
+	s := NewStore(Retention{})
+	reports := map[string][]*agentpb.PodLog{
+		"prod": {
+			podLog("default", "web", "app", 100, "INFO", "a GET /healthz 200", map[string]string{"status": "200", "method": "GET"}),
+			podLog("default", "web", "app", 101, "ERROR", "b GET /api/orders 500 upstream timeout", map[string]string{"status": "500", "method": "GET"}),
+			podLog("default", "web", "app", 102, "WARN", "c POST /api/orders 502 upstream reset", map[string]string{"status": "502", "method": "POST"}),
+			podLog("team-a", "worker", "main", 103, "ERROR", "d job failed: Timeout waiting for lock", map[string]string{"job": "sync"}),
+			podLog("default", "web", "sidecar", 104, "INFO", "e proxy ready", map[string]string{"pod": "spoofed"}),
+		},
+		"staging": {
+			podLog("default", "web", "app", 105, "ERROR", "f GET /api/orders 500 upstream timeout", map[string]string{"status": "500"}),
+		},
+	}
+	podLabels := map[string]map[string]string{
+		"prod/web":    {"app": "web", "tier": "frontend"},
+		"prod/worker": {"app": "worker"},
+		"staging/web": {"app": "web", "tier": "frontend", "track": "canary"},
+	}
+	for _, cluster := range []string{"prod", "staging"} {
+		for _, line := range reports[cluster] {
+			line.PodLabels = podLabels[cluster+"/"+line.PodName]
+		}
+		if _, err := s.Append(cluster, reports[cluster]); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	return s
+}
+
+// ids returns the first letter of each result's line
+func ids(results []Result) []string {
+	ids := []string{}
+	for _, r := range results {
+		ids = append(ids, r.LogLine[:1])
+	}
+	return ids
+}
+
+func TestSearch(t *testing.T) {
+	prodDefault := `{"rules": [{"subjects": ["alice"], "clusters": ["prod"], "namespaces": ["default"]}]}`
+	authorizer, err := auth.NewAuthorizer(context.Background(), func(context.Context) ([]byte, error) { return []byte(prodDefault), nil })
+	if err != nil {
+		t.Fatalf("NewAuthorizer: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{name: "everything, newest first", want: []string{"f", "e", "d", "c", "b", "a"}},
+		{name: "cluster", query: Query{Cluster: "prod"}, want: []string{"e", "d", "c", "b", "a"}},
+		{name: "namespace", query: Query{Namespace: "team-a"}, want: []string{"d"}},
+		{name: "pod and container", query: Query{Pod: "web", Container: "app"}, want: []string{"f", "c", "b", "a"}},
+		{name: "levels", query: Query{Levels: map[string]bool{"ERROR": true, "WARN": true}}, want: []string{"f", "d", "c", "b"}},
+		{name: "every term", query: Query{Terms: []string{"upstream", "timeout"}}, want: []string{"f", "b"}},
+		{name: "terms ignore case", query: Query{Terms: []string{"TIMEOUT"}}, want: []string{"f", "d", "b"}},
+		{name: "unknown term", query: Query{Terms: []string{"upstream", "nothing"}}, want: []string{}},
+		{name: "substring ignores case", query: Query{Contains: "TimeOut Wait"}, want: []string{"d"}},
+		{name: "regex is case-sensitive", query: Query{Pattern: regexp.MustCompile(`POST|timeout`)}, want: []string{"f", "c", "b"}},
+		{name: "from and to are inclusive", query: Query{From: 101, To: 103}, want: []string{"d", "c", "b"}},
+		{name: "no upper bound", query: Query{From: 104}, want: []string{"f", "e"}},
+		{name: "scope", query: Query{Scope: authorizer.Scope(&auth.Identity{Subject: "alice"})}, want: []string{"e", "c", "b", "a"}},
+		{name: "empty scope", query: Query{Scope: authorizer.Scope(&auth.Identity{Subject: "mallory"})}, want: []string{}},
+		{name: "conditions combine", query: Query{Cluster: "prod", Levels: map[string]bool{"ERROR": true}, Terms: []string{"upstream"}}, want: []string{"b"}},
+	}
+	s := testStore(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, cursor, err := s.Search(tt.query)
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			if got := ids(results); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search() = %q, want %q", got, tt.want)
+			}
+			if cursor != "" {
+				t.Errorf("Search() without a limit returned cursor %q", cursor)
+			}
+		})
+	}
+}
+
+func TestSearchFieldSelector(t *testing.T) {
+	tests := []struct {
+		selector string
+		want     []string
+	}{
+		{selector: "status in (500,502)", want: []string{"f", "c", "b"}},
+		{selector: "status=500,cluster=staging", want: []string{"f"}},
+		// As with labels, != also matches lines without the field
+		{selector: "method!=GET", want: []string{"f", "e", "d", "c"}},
+		{selector: "job", want: []string{"d"}},
+		{selector: "level=ERROR,namespace=team-a", want: []string{"d"}},
+		// Where a line came from wins over a parsed field of the same name
+		{selector: "pod=spoofed", want: []string{}},
+		{selector: "pod=web,container=sidecar", want: []string{"e"}},
+		// Pod labels aren't fields
+		{selector: "app=web", want: []string{}},
+	}
+	s := testStore(t)
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			selector, err := labels.Parse(tt.selector)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			results, _, err := s.Search(Query{FieldSelector: selector})
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			if got := ids(results); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search(%s) = %q, want %q", tt.selector, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchSelector(t *testing.T) {
+	tests := []struct {
+		selector string
+		want     []string
+	}{
+		{selector: "app=web", want: []string{"f", "e", "c", "b", "a"}},
+		{selector: "app=web,track=canary", want: []string{"f"}},
+		{selector: "tier notin (frontend)", want: []string{"d"}},
+		{selector: "!track", want: []string{"e", "d", "c", "b", "a"}},
+		// Parsed fields and where a line came from aren't labels
+		{selector: "status=500", want: []string{}},
+		{selector: "pod=web", want: []string{}},
+	}
+	s := testStore(t)
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			selector, err := labels.Parse(tt.selector)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			results, _, err := s.Search(Query{Selector: selector})
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			if got := ids(results); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search(%s) = %q, want %q", tt.selector, got, tt.want)
+			}
+		})
+	}
+
+	// Both selectors must match
+	selector, _ := labels.Parse("app=web")
+	fieldSelector, _ := labels.Parse("status=500")
+	results, _, err := s.Search(Query{Selector: selector, FieldSelector: fieldSelector, Cluster: "prod"})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if got := ids(results); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Errorf("Search() with both selectors = %q, want b", got)
+	}
+}
+
+func TestSearchPages(t *testing.T) {
+	s := testStore(t)
+	query := Query{Limit: 2}
+
+	var got []string
+	for page := 0; ; page++ {
+		if page > 3 {
+			t.Fatalf("more pages than lines")
+		}
+		results, cursor, err := s.Search(query)
+		if err != nil {
+			t.Fatalf("Search: %v", err)
+		}
+		got = append(got, ids(results)...)
+		if cursor == "" {
+			break
+		}
+		if len(results) != query.Limit {
+			t.Errorf("page %d has %d lines, want %d", page, len(results), query.Limit)
+		}
+
+		// Newer lines, and lines at the timestamp of the last one returned
+		// that sort before it, don't shift the later pages
+		s.Append("prod", []*agentpb.PodLog{
+			podLog("default", "web", "app", 200+int64(page), "INFO", "new line", nil),
+			podLog("default", "aaa", "app", results[len(results)-1].Timestamp, "INFO", "late line", nil),
+		})
+		query.Cursor = cursor
+	}
+	if want := []string{"f", "e", "d", "c", "b", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("pages = %q, want %q", got, want)
+	}
+
+	if _, _, err := s.Search(Query{Cursor: "not a cursor"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Search() with a bad cursor returned %v, want ErrInvalidCursor", err)
+	}
+}
+
+func TestAppendKeepsLinesOnce(t *testing.T) {
+	s := NewStore(Retention{})
+	report := []*agentpb.PodLog{
+		podLog("default", "web", "app", 100, "INFO", "tick", nil),
+		podLog("default", "web", "app", 100, "INFO", "tick", nil),
+	}
+	if added, _ := s.Append("prod", report); len(added) != 2 {
+		t.Errorf("first report added %d lines, want both identical lines", len(added))
+	}
+	// The next report repeats the tail and adds a third tick in the second
+	report = append(report, podLog("default", "web", "app", 100, "INFO", "tick", nil))
+	if added, _ := s.Append("prod", report); len(added) != 1 {
+		t.Errorf("second report added %d lines, want only the new one", len(added))
+	}
+	if added, _ := s.Append("staging", report[:1]); len(added) != 1 {
+		t.Errorf("another cluster's line wasn't added")
+	}
+	if s.Count() != 4 {
+		t.Errorf("store holds %d lines, want 4", s.Count())
+	}
+}
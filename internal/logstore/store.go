@@ -0,0 +1,376 @@
+package logstore
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	bolt "go.etcd.io/bbolt"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/thekubefleet/kubefleet/internal/boltdb"
+	agentpb "github.com/thekubefleet/kubefleet/proto"
+)
+
+// Retention limits how many log lines the store keeps. Zero values are
+// unlimited.
+type Retention struct {
+	// MaxAge drops lines older than this
+	MaxAge time.Duration
+	// MaxBytes caps the size of all lines, dropping the oldest first
+	MaxBytes int64
+}
+
+// Tokenization limits: shorter and longer words aren't indexed, and only
+// the first maxLineTerms distinct words of a line are
+const (
+	minTermLength = 2
+	maxTermLength = 64
+	maxLineTerms  = 256
+)
+
+// lineKey identifies a line however many reports repeat it. N tells apart
+// identical lines written by a container in the same second.
+type lineKey struct {
+	Timestamp int64  `json:"t"`
+	Cluster   string `json:"c"`
+	Namespace string `json:"n"`
+	Pod       string `json:"p"`
+	Container string `json:"k"`
+	Previous  bool   `json:"v,omitempty"`
+	Hash      uint64 `json:"h"`
+	N         int    `json:"i,omitempty"`
+}
+
+func newLineKey(cluster string, line *agentpb.PodLog) lineKey {
+	h := fnv.New64a()
+	h.Write([]byte(line.LogLine))
+	return lineKey{
+		Timestamp: line.Timestamp,
+		Cluster:   cluster,
+		Namespace: line.Namespace,
+		Pod:       line.PodName,
+		Container: line.ContainerName,
+		Previous:  line.Previous,
+		Hash:      h.Sum64(),
+	}
+}
+
+// before reports whether a sorts before b: newest first, then by where the
+// line came from
+func (a lineKey) before(b lineKey) bool {
+	if a.Timestamp != b.Timestamp {
+		return a.Timestamp > b.Timestamp
+	}
+	if a.Cluster != b.Cluster {
+		return a.Cluster < b.Cluster
+	}
+	if a.Namespace != b.Namespace {
+		return a.Namespace < b.Namespace
+	}
+	if a.Pod != b.Pod {
+		return a.Pod < b.Pod
+	}
+	if a.Container != b.Container {
+		return a.Container < b.Container
+	}
+	if a.Previous != b.Previous {
+		return a.Previous
+	}
+	if a.Hash != b.Hash {
+		return a.Hash < b.Hash
+	}
+	return a.N < b.N
+}
+
+// containerKey groups the lines of one container instance
+type containerKey struct {
+	cluster   string
+	namespace string
+	pod       string
+	container string
+	previous  bool
+}
+
+type entry struct {
+	id      uint64
+	key     lineKey
+	cluster string
+	line    *agentpb.PodLog
+	size    int64
+}
+
+// Store keeps the log lines agents report, each once, with an inverted
+// index of the words in them. Lines are dropped oldest first by timestamp,
+// whatever order they arrived in.
+type Store struct {
+	mu        sync.RWMutex
+	retention Retention
+	db        *bolt.DB // nil for an in-memory store
+
+	entries    map[uint64]*entry
+	byAge      []*entry // ordered by timestamp, then id
+	nextID     uint64
+	size       int64
+	seen       map[lineKey]uint64
+	postings   map[string][]uint64       // term -> ids in ascending order
+	containers map[containerKey][]uint64 // ids in ascending order
+}
+
+// NewStore creates an in-memory store with the given retention
+func NewStore(retention Retention) *Store {
+	return &Store{
+		retention:  retention,
+		entries:    make(map[uint64]*entry),
+		nextID:     1,
+		seen:       make(map[lineKey]uint64),
+		postings:   make(map[string][]uint64),
+		containers: make(map[containerKey][]uint64),
+	}
+}
+
+// Append adds the lines of a cluster's report that the store doesn't have
+// yet and returns them. Reports may repeat lines earlier reports carried;
+// identical lines are counted per report, so a line written twice in a
+// second is kept twice.
+func (s *Store) Append(cluster string, lines []*agentpb.PodLog) ([]*agentpb.PodLog, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var cutoff int64
+	if s.retention.MaxAge > 0 {
+		cutoff = time.Now().Add(-s.retention.MaxAge).Unix()
+	}
+
+	counts := make(map[lineKey]int)
+	var added []*entry
+	var newLines []*agentpb.PodLog
+	for _, line := range lines {
+		if line.Timestamp < cutoff {
+			continue
+		}
+		key := newLineKey(cluster, line)
+		n := counts[key]
+		counts[key]++
+		key.N = n
+		if _, ok := s.seen[key]; ok {
+			continue
+		}
+
+		e := &entry{
+			id:      s.nextID,
+			key:     key,
+			cluster: cluster,
+			line:    line,
+			size:    int64(proto.Size(line) + len(cluster)),
+		}
+		s.nextID++
+		s.insert(e)
+		added = append(added, e)
+		newLines = append(newLines, line)
+	}
+
+	evicted := s.evict()
+	if s.db != nil && (len(added) > 0 || len(evicted) > 0) {
+		if err := s.persist(added, evicted); err != nil {
+			return newLines, err
+		}
+	}
+	return newLines, nil
+}
+
+// insert adds an entry with the newest id. Callers must hold s.mu.
+func (s *Store) insert(e *entry) {
+	s.entries[e.id] = e
+	// Lines mostly arrive newest last, so the search starts from the end
+	i := len(s.byAge)
+	for i > 0 && s.byAge[i-1].line.Timestamp > e.line.Timestamp {
+		i--
+	}
+	s.byAge = slices.Insert(s.byAge, i, e)
+	s.size += e.size
+	s.seen[e.key] = e.id
+	for _, term := range Tokenize(e.line.LogLine) {
+		s.postings[term] = append(s.postings[term], e.id)
+	}
+	ck := containerKey{e.cluster, e.line.Namespace, e.line.PodName, e.line.ContainerName, e.line.Previous}
+	s.containers[ck] = append(s.containers[ck], e.id)
+}
+
+// evict drops the oldest entries while the store is over its retention
+// limits and returns their ids. Callers must hold s.mu.
+func (s *Store) evict() []uint64 {
+	var cutoff int64
+	if s.retention.MaxAge > 0 {
+		cutoff = time.Now().Add(-s.retention.MaxAge).Unix()
+	}
+
+	var evicted []uint64
+	for len(s.byAge) > 0 {
+		e := s.byAge[0]
+		overSize := s.retention.MaxBytes > 0 && s.size > s.retention.MaxBytes
+		if !overSize && e.line.Timestamp >= cutoff {
+			break
+		}
+		s.byAge[0] = nil
+		s.byAge = s.byAge[1:]
+		s.remove(e)
+		evicted = append(evicted, e.id)
+	}
+	return evicted
+}
+
+// remove drops an entry from the store's maps and lists. Callers must hold
+// s.mu.
+func (s *Store) remove(e *entry) {
+	delete(s.entries, e.id)
+	s.size -= e.size
+	delete(s.seen, e.key)
+
+	for _, term := range Tokenize(e.line.LogLine) {
+		if ids := removeID(s.postings[term], e.id); len(ids) > 0 {
+			s.postings[term] = ids
+		} else {
+			delete(s.postings, term)
+		}
+	}
+	ck := containerKey{e.cluster, e.line.Namespace, e.line.PodName, e.line.ContainerName, e.line.Previous}
+	if ids := removeID(s.containers[ck], e.id); len(ids) > 0 {
+		s.containers[ck] = ids
+	} else {
+		delete(s.containers, ck)
+	}
+}
+
+// removeID removes id from ascending ids. The oldest line usually heads the
+// list, which then only needs reslicing.
+func removeID(ids []uint64, id uint64) []uint64 {
+	i, found := slices.BinarySearch(ids, id)
+	switch {
+	case !found:
+		return ids
+	case i == 0:
+		return ids[1:]
+	default:
+		return slices.Delete(ids, i, i+1)
+	}
+}
+
+// EnforceRetention drops lines that have aged out since they were added
+func (s *Store) EnforceRetention() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	evicted := s.evict()
+	if s.db != nil && len(evicted) > 0 {
+		return s.persist(nil, evicted)
+	}
+	return nil
+}
+
+// Run enforces retention every interval until ctx is done
+func (s *Store) Run(ctx context.Context, interval time.Duration) {
+	boltdb.RunRetention(ctx, interval, "log", s)
+}
+
+// get returns the entry with an id. Callers must hold s.mu.
+func (s *Store) get(id uint64) *entry {
+	return s.entries[id]
+}
+
+// Tail returns the last n lines of each container of a cluster, optionally
+// limited to a namespace, pod and container, ordered by container and then
+// by arrival. A previous instance's lines come before the current one's.
+func (s *Store) Tail(cluster, namespace, pod, container string, n int) []*agentpb.PodLog {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []containerKey
+	for key := range s.containers {
+		if key.cluster == cluster && (namespace == "" || key.namespace == namespace) &&
+			(pod == "" || key.pod == pod) && (container == "" || key.container == container) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.namespace != b.namespace {
+			return a.namespace < b.namespace
+		}
+		if a.pod != b.pod {
+			return a.pod < b.pod
+		}
+		if a.container != b.container {
+			return a.container < b.container
+		}
+		return a.previous && !b.previous
+	})
+
+	var lines []*agentpb.PodLog
+	for _, key := range keys {
+		ids := s.containers[key]
+		if len(ids) > n {
+			ids = ids[len(ids)-n:]
+		}
+		for _, id := range ids {
+			lines = append(lines, s.get(id).line)
+		}
+	}
+	return lines
+}
+
+// Count returns the number of lines stored
+func (s *Store) Count() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.entries)
+}
+
+// Size returns the size of the lines stored in bytes
+func (s *Store) Size() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.size
+}
+
+// Close closes the database of a persistent store
+func (s *Store) Close() error {
+	if s.db == nil {
+		return nil
+	}
+	if err := s.db.Close(); err != nil {
+		return fmt.Errorf("failed to close log store: %w", err)
+	}
+	return nil
+}
+
+// Tokenize returns the distinct lowercased words of a line that are
+// indexed: runs of letters and digits between minTermLength and
+// maxTermLength long
+func Tokenize(text string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(word) < minTermLength || len(word) > maxTermLength {
+			continue
+		}
+		word = strings.ToLower(word)
+		if seen[word] {
+			continue
+		}
+		seen[word] = true
+		terms = append(terms, word)
+		if len(terms) == maxLineTerms {
+			break
+		}
+	}
+	return terms
+}
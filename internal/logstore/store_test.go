@@ -0,0 +1,118 @@
+package logstore
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	agentpb "github.com/thekubefleet/kubefleet/proto"
+)
+
+func texts(lines []*agentpb.PodLog) []string {
+	texts := []string{}
+	for _, line := range lines {
+		texts = append(texts, line.LogLine)
+	}
+	return texts
+}
+
+func TestEvictOldestByTimestamp(t *testing.T) {
+	line := func(ts int64, text string) *agentpb.PodLog {
+		return podLog("default", "web", "app", ts, "INFO", text, nil)
+	}
+	// Room for two lines of the same size
+	size := int64(proto.Size(line(200, "line one")) + len("prod"))
+	s := NewStore(Retention{MaxBytes: 2 * size})
+
+	s.Append("prod", []*agentpb.PodLog{line(200, "line one")})
+	// A line from a backlog arrives after a newer one
+	s.Append("prod", []*agentpb.PodLog{line(100, "line old")})
+	s.Append("prod", []*agentpb.PodLog{line(300, "line two")})
+
+	if got := texts(s.Tail("prod", "", "", "", 10)); !reflect.DeepEqual(got, []string{"line one", "line two"}) {
+		t.Errorf("lines kept = %q, want the two newest", got)
+	}
+	if s.Size() != 2*size || s.Count() != 2 {
+		t.Errorf("store holds %d lines of %d bytes, want 2 of %d", s.Count(), s.Size(), 2*size)
+	}
+	if results, _, _ := s.Search(Query{Terms: []string{"old"}}); len(results) != 0 {
+		t.Errorf("evicted line is still indexed")
+	}
+	if results, _, _ := s.Search(Query{Terms: []string{"line"}}); len(results) != 2 {
+		t.Errorf("found %d lines, want 2", len(results))
+	}
+
+	// An evicted line can be added again once there is room
+	s.retention.MaxBytes = 0
+	if added, _ := s.Append("prod", []*agentpb.PodLog{line(100, "line old")}); len(added) != 1 {
+		t.Errorf("evicted line wasn't added again")
+	}
+}
+
+func TestEnforceRetentionByAge(t *testing.T) {
+	now := time.Now().Unix()
+	s := NewStore(Retention{MaxAge: time.Hour})
+	s.Append("prod", []*agentpb.PodLog{
+		podLog("default", "web", "app", now-10, "INFO", "recent", nil),
+		podLog("default", "web", "app", now-2*3600, "INFO", "too old to keep", nil),
+		podLog("default", "web", "app", now-3590, "INFO", "about to age out", nil),
+	})
+	if got := texts(s.Tail("prod", "", "", "", 10)); !reflect.DeepEqual(got, []string{"recent", "about to age out"}) {
+		t.Fatalf("lines = %q, want those within an hour", got)
+	}
+
+	s.retention.MaxAge = time.Minute
+	if err := s.EnforceRetention(); err != nil {
+		t.Fatalf("EnforceRetention: %v", err)
+	}
+	if got := texts(s.Tail("prod", "", "", "", 10)); !reflect.DeepEqual(got, []string{"recent"}) {
+		t.Errorf("lines = %q, want the line within a minute", got)
+	}
+}
+
+func TestOpenReloadsLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.db")
+	s, err := Open(path, Retention{})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	lines := []*agentpb.PodLog{
+		podLog("default", "web", "app", 200, "INFO", "tick", nil),
+		podLog("default", "web", "app", 200, "INFO", "tick", nil),
+		podLog("default", "web", "app", 100, "INFO", "late", nil),
+	}
+	for _, line := range lines {
+		line.PodLabels = map[string]string{"app": "web"}
+	}
+	s.Append("prod", lines)
+	s.retention.MaxBytes = s.Size() - 1
+	if err := s.EnforceRetention(); err != nil {
+		t.Fatalf("EnforceRetention: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	s, err = Open(path, Retention{})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+	if got := texts(s.Tail("prod", "", "", "", 10)); !reflect.DeepEqual(got, []string{"tick", "tick"}) {
+		t.Errorf("reloaded lines = %q, want both ticks without the evicted line", got)
+	}
+	if line := s.Tail("prod", "", "", "", 1)[0]; line.PodLabels["app"] != "web" {
+		t.Errorf("reloaded line has pod labels %v, want app=web", line.PodLabels)
+	}
+	// Reloaded lines are known, so a report repeating them adds nothing
+	added, err := s.Append("prod", []*agentpb.PodLog{
+		podLog("default", "web", "app", 200, "INFO", "tick", nil),
+		podLog("default", "web", "app", 200, "INFO", "tick", nil),
+	})
+	if err != nil || len(added) != 0 {
+		t.Errorf("Append() of reloaded lines added %d, %v", len(added), err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -19,9 +20,64 @@ type ResourceMetric struct {
 	Kind      string
 	CPU       float64
 	Memory    float64
+	Resources Resources
 	Timestamp time.Time
 }
 
+// Resources are the requests and limits of a pod's containers, in cores and
+// MiB. A limit is 0 when any container has none.
+type Resources struct {
+	CPURequest    float64
+	CPULimit      float64
+	MemoryRequest float64
+	MemoryLimit   float64
+}
+
+// podResources sums the requests and limits of a pod's containers
+func podResources(pod *corev1.Pod) Resources {
+	var r Resources
+	cpuLimited, memoryLimited := true, true
+	for _, container := range pod.Spec.Containers {
+		requests, limits := container.Resources.Requests, container.Resources.Limits
+		r.CPURequest += float64(requests.Cpu().MilliValue()) / 1000.0
+		r.MemoryRequest += float64(requests.Memory().Value()) / (1024.0 * 1024.0)
+		if limit, ok := limits[corev1.ResourceCPU]; ok {
+			r.CPULimit += float64(limit.MilliValue()) / 1000.0
+		} else {
+			cpuLimited = false
+		}
+		if limit, ok := limits[corev1.ResourceMemory]; ok {
+			r.MemoryLimit += float64(limit.Value()) / (1024.0 * 1024.0)
+		} else {
+			memoryLimited = false
+		}
+	}
+	if !cpuLimited {
+		r.CPULimit = 0
+	}
+	if !memoryLimited {
+		r.MemoryLimit = 0
+	}
+	return r
+}
+
+// add sums the resources of two pods
+func (r Resources) add(other Resources) Resources {
+	sum := Resources{
+		CPURequest:    r.CPURequest + other.CPURequest,
+		CPULimit:      r.CPULimit + other.CPULimit,
+		MemoryRequest: r.MemoryRequest + other.MemoryRequest,
+		MemoryLimit:   r.MemoryLimit + other.MemoryLimit,
+	}
+	if r.CPULimit == 0 || other.CPULimit == 0 {
+		sum.CPULimit = 0
+	}
+	if r.MemoryLimit == 0 || other.MemoryLimit == 0 {
+		sum.MemoryLimit = 0
+	}
+	return sum
+}
+
 type Collector struct {
 	clientset     *kubernetes.Clientset
 	metricsClient *versioned.Clientset
@@ -84,7 +140,7 @@ func (c *Collector) CollectPodMetrics(ctx context.Context, namespace string) ([]
 	}
 
 	var metrics []ResourceMetric
-	for _, pod := range pods.Items {
+	for i, pod := range pods.Items {
 		m := metricsMap[pod.Name]
 		metric := ResourceMetric{
 			Namespace: namespace,
@@ -92,6 +148,7 @@ func (c *Collector) CollectPodMetrics(ctx context.Context, namespace string) ([]
 			Kind:      "Pod",
 			CPU:       m.cpu,
 			Memory:    m.mem,
+			Resources: podResources(&pods.Items[i]),
 			Timestamp: time.Now(),
 		}
 		metrics = append(metrics, metric)
@@ -137,10 +194,16 @@ func (c *Collector) CollectDeploymentMetrics(ctx context.Context, namespace stri
 			return nil, fmt.Errorf("failed to list pods for deployment %s: %w", deployment.Name, err)
 		}
 		var totalCPU, totalMem float64
-		for _, pod := range pods.Items {
+		var resources Resources
+		for i, pod := range pods.Items {
 			m := metricsMap[pod.Name]
 			totalCPU += m.cpu
 			totalMem += m.mem
+			if i == 0 {
+				resources = podResources(&pods.Items[i])
+			} else {
+				resources = resources.add(podResources(&pods.Items[i]))
+			}
 		}
 		metric := ResourceMetric{
 			Namespace: namespace,
@@ -148,6 +211,7 @@ func (c *Collector) CollectDeploymentMetrics(ctx context.Context, namespace stri
 			Kind:      "Deployment",
 			CPU:       totalCPU,
 			Memory:    totalMem,
+			Resources: resources,
 			Timestamp: time.Now(),
 		}
 		metrics = append(metrics, metric)
@@ -0,0 +1,272 @@
+package mtls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config holds the certificate paths for mutual TLS between agent and server
+type Config struct {
+	CAFile   string
+	CertFile string
+	KeyFile  string
+	// AllowedClients lists the client certificate CNs or SANs the server
+	// accepts. Empty accepts any client certificate signed by the CA.
+	AllowedClients []string
+	// ServerName overrides the name the client expects in the server certificate
+	ServerName string
+}
+
+// RegisterFlags registers the TLS flags on fs, defaulting to the
+// KUBEFLEET_TLS_* environment variables
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.CAFile, "tls-ca", os.Getenv("KUBEFLEET_TLS_CA"), "path to the CA bundle used to verify the peer")
+	fs.StringVar(&c.CertFile, "tls-cert", os.Getenv("KUBEFLEET_TLS_CERT"), "path to the TLS certificate")
+	fs.StringVar(&c.KeyFile, "tls-key", os.Getenv("KUBEFLEET_TLS_KEY"), "path to the TLS private key")
+	fs.StringVar(&c.ServerName, "tls-server-name", os.Getenv("KUBEFLEET_TLS_SERVER_NAME"), "expected server name in the server certificate")
+	fs.Func("tls-allowed-clients", "comma-separated client certificate CNs/SANs the server accepts", func(value string) error {
+		c.AllowedClients = splitList(value)
+		return nil
+	})
+	c.AllowedClients = splitList(os.Getenv("KUBEFLEET_TLS_ALLOWED_CLIENTS"))
+}
+
+// Enabled reports whether TLS has been configured
+func (c *Config) Enabled() bool {
+	return c.CAFile != "" || c.CertFile != "" || c.KeyFile != ""
+}
+
+func (c *Config) validate() error {
+	if c.CAFile == "" || c.CertFile == "" || c.KeyFile == "" {
+		return errors.New("mutual TLS requires a CA bundle, certificate and key")
+	}
+	return nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// Reloader keeps the certificate and CA pool in memory and reloads them when
+// the files on disk change, so rotated certificates are picked up without a
+// restart
+type Reloader struct {
+	cfg Config
+
+	mu     sync.RWMutex
+	cert   *tls.Certificate
+	caPool *x509.CertPool
+	files  map[string]fileState
+}
+
+// NewReloader loads the configured certificates
+func NewReloader(cfg Config) (*Reloader, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	r := &Reloader{cfg: cfg}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Reloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate %s: %w", r.cfg.CertFile, err)
+	}
+
+	caPEM, err := os.ReadFile(r.cfg.CAFile)
+	if err != nil {
+		return fmt.Errorf("failed to read CA bundle %s: %w", r.cfg.CAFile, err)
+	}
+	caPool := x509.NewCertPool()
+	if !caPool.AppendCertsFromPEM(caPEM) {
+		return fmt.Errorf("no certificates found in CA bundle %s", r.cfg.CAFile)
+	}
+
+	files, err := r.stat()
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	r.caPool = caPool
+	r.files = files
+	return nil
+}
+
+func (r *Reloader) stat() (map[string]fileState, error) {
+	files := make(map[string]fileState)
+	for _, path := range []string{r.cfg.CAFile, r.cfg.CertFile, r.cfg.KeyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		files[path] = fileState{modTime: info.ModTime(), size: info.Size()}
+	}
+	return files, nil
+}
+
+func (r *Reloader) changed() bool {
+	files, err := r.stat()
+	if err != nil {
+		return false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for path, state := range files {
+		if r.files[path] != state {
+			return true
+		}
+	}
+	return false
+}
+
+// Watch polls the certificate files and reloads them on change until ctx is
+// done. A failed reload keeps the previous certificates in use.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+			if err := r.reload(); err != nil {
+				log.Printf("Failed to reload TLS certificates: %v", err)
+				continue
+			}
+			log.Printf("Reloaded TLS certificates from %s", r.cfg.CertFile)
+		}
+	}
+}
+
+func (r *Reloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, r.caPool
+}
+
+// ServerTLSConfig returns a TLS config that requires client certificates
+// signed by the CA and, if configured, on the allow list
+func (r *Reloader) ServerTLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, caPool := r.current()
+			return &tls.Config{
+				MinVersion:       tls.VersionTLS12,
+				Certificates:     []tls.Certificate{*cert},
+				ClientCAs:        caPool,
+				ClientAuth:       tls.RequireAndVerifyClientCert,
+				VerifyConnection: r.verifyClient,
+			}, nil
+		},
+	}
+}
+
+func (r *Reloader) verifyClient(state tls.ConnectionState) error {
+	if len(r.cfg.AllowedClients) == 0 {
+		return nil
+	}
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("no client certificate presented")
+	}
+
+	leaf := state.PeerCertificates[0]
+	for _, name := range certificateNames(leaf) {
+		for _, allowed := range r.cfg.AllowedClients {
+			if name == allowed {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("client certificate %q is not on the allow list", leaf.Subject.CommonName)
+}
+
+// certificateNames returns the CN and all SANs of a certificate
+func certificateNames(cert *x509.Certificate) []string {
+	names := []string{cert.Subject.CommonName}
+	names = append(names, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+	return names
+}
+
+// ClientTLSConfig returns a TLS config that presents the client certificate
+// and verifies the server against the current CA pool. Verification is done
+// in VerifyConnection rather than through RootCAs so a reloaded CA bundle
+// takes effect for new connections.
+func (r *Reloader) ClientTLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: r.cfg.ServerName,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _ := r.current()
+			return cert, nil
+		},
+		// Verified against the current CA pool in VerifyConnection below
+		InsecureSkipVerify: true,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return errors.New("no server certificate presented")
+			}
+
+			_, caPool := r.current()
+			intermediates := x509.NewCertPool()
+			for _, cert := range state.PeerCertificates[1:] {
+				intermediates.AddCert(cert)
+			}
+
+			serverName := r.cfg.ServerName
+			if serverName == "" {
+				serverName = state.ServerName
+			}
+			// Verify skips the hostname check for an empty name
+			if serverName == "" {
+				return errors.New("no server name to verify the server certificate against")
+			}
+			_, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+				DNSName:       serverName,
+				Roots:         caPool,
+				Intermediates: intermediates,
+			})
+			return err
+		},
+	}
+}
@@ -0,0 +1,341 @@
+package mtls
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCA is a throwaway CA issuing certificates for a test
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+var serial int64
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return key
+}
+
+func newCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key := newKey(t)
+	serial++
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create CA: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns the PEM certificate and key of a leaf for cn and dnsNames,
+// usable by servers and clients
+func (ca *testCA) issue(t *testing.T, cn string, dnsNames ...string) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key := newKey(t)
+	serial++
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("failed to issue certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to encode key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// writeConfig writes a CA bundle, certificate and key to dir and returns a
+// config pointing at them
+func writeConfig(t *testing.T, dir string, caPEM, certPEM, keyPEM []byte) Config {
+	t.Helper()
+	cfg := Config{
+		CAFile:   filepath.Join(dir, "ca.crt"),
+		CertFile: filepath.Join(dir, "tls.crt"),
+		KeyFile:  filepath.Join(dir, "tls.key"),
+	}
+	for path, data := range map[string][]byte{cfg.CAFile: caPEM, cfg.CertFile: certPEM, cfg.KeyFile: keyPEM} {
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+	return cfg
+}
+
+func newReloader(t *testing.T, cfg Config) *Reloader {
+	t.Helper()
+	r, err := NewReloader(cfg)
+	if err != nil {
+		t.Fatalf("NewReloader: %v", err)
+	}
+	return r
+}
+
+// connect opens a TLS connection from client to server and returns the
+// errors each side saw. The server answers a successful handshake with
+// "ok", so a client only succeeds once the server accepted it.
+func connect(t *testing.T, server, client *tls.Config) (clientErr, serverErr error) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	serverErrs := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			serverErrs <- err
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		tlsConn := tls.Server(conn, server)
+		if err := tlsConn.Handshake(); err != nil {
+			serverErrs <- err
+			return
+		}
+		_, err = tlsConn.Write([]byte("ok"))
+		serverErrs <- err
+	}()
+
+	clientErr = func() error {
+		conn, err := net.DialTimeout("tcp", ln.Addr().String(), 5*time.Second)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		tlsConn := tls.Client(conn, client)
+		if err := tlsConn.Handshake(); err != nil {
+			return err
+		}
+		// With TLS 1.3 the server checks the client certificate after the
+		// client's handshake completed, so rejections arrive on read
+		_, err = io.ReadFull(tlsConn, make([]byte, 2))
+		return err
+	}()
+	return clientErr, <-serverErrs
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca := newCA(t, "kubefleet-ca")
+	otherCA := newCA(t, "other-ca")
+
+	serverCert, serverKey := ca.issue(t, "kubefleet-server", "kubefleet-server", "kubefleet.example.com")
+	serverCfg := writeConfig(t, t.TempDir(), ca.pem, serverCert, serverKey)
+	serverCfg.AllowedClients = []string{"agent-prod", "agent-staging.example.com"}
+	server := newReloader(t, serverCfg)
+
+	tests := []struct {
+		name string
+		// client returns the client's CA bundle, certificate and key
+		client     func() (caPEM, certPEM, keyPEM []byte)
+		serverName string
+		wantErr    string // Substring of the error either side saw
+	}{
+		{
+			name: "allowed by CN",
+			client: func() ([]byte, []byte, []byte) {
+				cert, key := ca.issue(t, "agent-prod")
+				return ca.pem, cert, key
+			},
+			serverName: "kubefleet-server",
+		},
+		{
+			name: "allowed by SAN",
+			client: func() ([]byte, []byte, []byte) {
+				cert, key := ca.issue(t, "agent", "agent-staging.example.com")
+				return ca.pem, cert, key
+			},
+			serverName: "kubefleet.example.com",
+		},
+		{
+			name: "not on the allow list",
+			client: func() ([]byte, []byte, []byte) {
+				cert, key := ca.issue(t, "agent-dev", "agent-dev.example.com")
+				return ca.pem, cert, key
+			},
+			serverName: "kubefleet-server",
+			wantErr:    `"agent-dev" is not on the allow list`,
+		},
+		{
+			name: "client signed by another CA",
+			client: func() ([]byte, []byte, []byte) {
+				cert, key := otherCA.issue(t, "agent-prod")
+				return ca.pem, cert, key
+			},
+			serverName: "kubefleet-server",
+			wantErr:    "certificate signed by unknown authority",
+		},
+		{
+			name: "server with the wrong name",
+			client: func() ([]byte, []byte, []byte) {
+				cert, key := ca.issue(t, "agent-prod")
+				return ca.pem, cert, key
+			},
+			serverName: "kubefleet.other.com",
+			wantErr:    "not kubefleet.other.com",
+		},
+		{
+			name: "server signed by a CA the client doesn't trust",
+			client: func() ([]byte, []byte, []byte) {
+				cert, key := ca.issue(t, "agent-prod")
+				return otherCA.pem, cert, key
+			},
+			serverName: "kubefleet-server",
+			wantErr:    "certificate signed by unknown authority",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caPEM, cert, key := tt.client()
+			clientCfg := writeConfig(t, t.TempDir(), caPEM, cert, key)
+			clientCfg.ServerName = tt.serverName
+			client := newReloader(t, clientCfg)
+
+			clientErr, serverErr := connect(t, server.ServerTLSConfig(), client.ClientTLSConfig())
+			if tt.wantErr == "" {
+				if clientErr != nil || serverErr != nil {
+					t.Errorf("connection failed: client %v, server %v", clientErr, serverErr)
+				}
+				return
+			}
+			if clientErr == nil {
+				t.Errorf("client connected, want %q", tt.wantErr)
+			}
+			if !strings.Contains(errString(clientErr)+errString(serverErr), tt.wantErr) {
+				t.Errorf("errors = client %v, server %v, want %q", clientErr, serverErr, tt.wantErr)
+			}
+		})
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func TestClientRequiresServerName(t *testing.T) {
+	ca := newCA(t, "kubefleet-ca")
+	serverCert, serverKey := ca.issue(t, "kubefleet-server", "kubefleet-server")
+	server := newReloader(t, writeConfig(t, t.TempDir(), ca.pem, serverCert, serverKey))
+	clientCert, clientKey := ca.issue(t, "agent-prod")
+	client := newReloader(t, writeConfig(t, t.TempDir(), ca.pem, clientCert, clientKey))
+
+	// A client without a ServerName that dials a bare connection has no name
+	// to check the certificate against
+	clientErr, _ := connect(t, server.ServerTLSConfig(), client.ClientTLSConfig())
+	if clientErr == nil || !strings.Contains(clientErr.Error(), "no server name") {
+		t.Errorf("client error = %v, want the missing server name rejected", clientErr)
+	}
+}
+
+func TestReloaderPicksUpRotatedCertificates(t *testing.T) {
+	ca := newCA(t, "kubefleet-ca")
+	serverCert, serverKey := ca.issue(t, "kubefleet-server", "kubefleet-server")
+	serverCfg := writeConfig(t, t.TempDir(), ca.pem, serverCert, serverKey)
+	serverCfg.AllowedClients = []string{"agent-new"}
+	server := newReloader(t, serverCfg)
+
+	clientDir := t.TempDir()
+	clientCert, clientKey := ca.issue(t, "agent-old")
+	clientCfg := writeConfig(t, clientDir, ca.pem, clientCert, clientKey)
+	clientCfg.ServerName = "kubefleet-server"
+	client := newReloader(t, clientCfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.Watch(ctx, 10*time.Millisecond)
+	go client.Watch(ctx, 10*time.Millisecond)
+
+	if clientErr, _ := connect(t, server.ServerTLSConfig(), client.ClientTLSConfig()); clientErr == nil {
+		t.Fatalf("client connected with a certificate that isn't allowed")
+	}
+
+	// The client's certificate is rotated to an allowed one
+	clientCert, clientKey = ca.issue(t, "agent-new")
+	writeConfig(t, clientDir, ca.pem, clientCert, clientKey)
+	eventually(t, "the rotated client certificate is used", func() bool {
+		clientErr, serverErr := connect(t, server.ServerTLSConfig(), client.ClientTLSConfig())
+		return clientErr == nil && serverErr == nil
+	})
+
+	// Both sides move to a new CA
+	ca2 := newCA(t, "kubefleet-ca-2")
+	bundle := append(append([]byte{}, ca.pem...), ca2.pem...)
+	serverCert, serverKey = ca2.issue(t, "kubefleet-server", "kubefleet-server")
+	clientCert, clientKey = ca2.issue(t, "agent-new")
+	writeConfig(t, filepath.Dir(serverCfg.CAFile), bundle, serverCert, serverKey)
+	writeConfig(t, clientDir, bundle, clientCert, clientKey)
+	eventually(t, "the certificates of the new CA are used", func() bool {
+		cert, _ := server.current()
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil || leaf.Issuer.CommonName != "kubefleet-ca-2" {
+			return false
+		}
+		clientErr, serverErr := connect(t, server.ServerTLSConfig(), client.ClientTLSConfig())
+		return clientErr == nil && serverErr == nil
+	})
+
+	// A broken rotation keeps the previous certificates in use
+	os.WriteFile(clientCfg.KeyFile, []byte("not a key"), 0o600)
+	time.Sleep(50 * time.Millisecond)
+	if clientErr, serverErr := connect(t, server.ServerTLSConfig(), client.ClientTLSConfig()); clientErr != nil || serverErr != nil {
+		t.Errorf("connection failed after a broken rotation: client %v, server %v", clientErr, serverErr)
+	}
+}
+
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting until %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,234 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"text/template"
+	"time"
+
+	"github.com/thekubefleet/kubefleet/internal/alerting"
+)
+
+// Receiver types
+const (
+	TypeWebhook      = "webhook"
+	TypeSlack        = "slack"
+	TypeAlertmanager = "alertmanager"
+	TypeEmail        = "email"
+)
+
+// Labels alerts can be grouped by
+var groupLabels = map[string]bool{
+	"rule":      true,
+	"severity":  true,
+	"cluster":   true,
+	"namespace": true,
+	"kind":      true,
+}
+
+// Defaults for optional receiver settings
+var (
+	defaultGroupBy   = []string{"rule", "cluster"}
+	defaultGroupWait = 30 * time.Second
+
+	defaultAlertmanagerRepeat = time.Minute
+)
+
+// Config is the notification configuration loaded from disk
+type Config struct {
+	// ExternalURL is the dashboard's address, linked from notifications
+	ExternalURL string     `json:"externalURL"`
+	Receivers   []Receiver `json:"receivers"`
+	Silences    []Silence  `json:"silences"`
+}
+
+// Matcher selects alerts. Each list accepts glob patterns and matches every
+// alert when empty.
+type Matcher struct {
+	Rules      []string `json:"rules"`
+	Severities []string `json:"severities"`
+	Clusters   []string `json:"clusters"`
+	Namespaces []string `json:"namespaces"`
+}
+
+// Receiver is a destination for the alerts it matches. Alerts are sent in
+// groups: the first alert of a group waits GroupWait for others sharing its
+// GroupBy labels.
+type Receiver struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // webhook, slack, alertmanager or email
+	Matcher
+	GroupBy      []string          `json:"groupBy"`      // rule and cluster when unset
+	GroupWait    alerting.Duration `json:"groupWait"`    // 30s when unset
+	SendResolved *bool             `json:"sendResolved"` // true when unset
+	// RepeatInterval is how often firing alerts are sent again while they
+	// fire. Unset never repeats them, except for alertmanager, which
+	// resolves alerts that aren't sent again within its resolve timeout.
+	RepeatInterval alerting.Duration `json:"repeatInterval"`
+
+	// URL is the webhook, the incoming webhook for slack or the
+	// Alertmanager base URL
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	// Secret signs webhook bodies with HMAC-SHA256
+	Secret string `json:"secret"`
+	// Channel, Username and IconEmoji override the incoming webhook's
+	// defaults for slack
+	Channel   string `json:"channel"`
+	Username  string `json:"username"`
+	IconEmoji string `json:"iconEmoji"`
+
+	// SMTP, From and To configure email
+	SMTP SMTP     `json:"smtp"`
+	From string   `json:"from"`
+	To   []string `json:"to"`
+
+	// Title and Text are text/template templates rendered with the
+	// Notification, used as the message for slack, the subject and body
+	// for email and included in webhook bodies
+	Title string `json:"title"`
+	Text  string `json:"text"`
+}
+
+// SMTP is the mail server email receivers send through. STARTTLS is used
+// when the server offers it.
+type SMTP struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"` // 587 when unset
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Silence mutes the alerts it matches between StartsAt and EndsAt
+type Silence struct {
+	Matcher
+	StartsAt time.Time `json:"startsAt"`
+	EndsAt   time.Time `json:"endsAt"`
+	Comment  string    `json:"comment"`
+}
+
+// ParseConfig parses a JSON notification configuration and validates it
+func ParseConfig(data []byte) (*Config, error) {
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse notification config: %w", err)
+	}
+	if _, err := compileReceivers(&config, nil); err != nil {
+		return nil, err
+	}
+	for i, silence := range config.Silences {
+		if !silence.EndsAt.After(silence.StartsAt) {
+			return nil, fmt.Errorf("silence %d must end after it starts", i)
+		}
+		if err := silence.Matcher.check(); err != nil {
+			return nil, fmt.Errorf("silence %d has %w", i, err)
+		}
+	}
+	return &config, nil
+}
+
+// matches reports whether an alert matches every list of the matcher
+func (m *Matcher) matches(alert *alerting.Alert) bool {
+	return matchAny(m.Rules, alert.Rule) && matchAny(m.Severities, alert.Severity) &&
+		matchAny(m.Clusters, alert.Cluster) && matchAny(m.Namespaces, alert.Namespace)
+}
+
+func (m *Matcher) check() error {
+	for _, patterns := range [][]string{m.Rules, m.Severities, m.Clusters, m.Namespaces} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+func matchAny(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// receiver is a Receiver with its defaults applied, templates parsed and
+// sink created
+type receiver struct {
+	Receiver
+	title *template.Template
+	text  *template.Template
+	sink  sink
+}
+
+// compileReceivers validates the receivers of a config and creates their
+// sinks, which send with client
+func compileReceivers(config *Config, client httpClient) ([]*receiver, error) {
+	compiled := make([]*receiver, 0, len(config.Receivers))
+	names := make(map[string]bool)
+	for i, rcv := range config.Receivers {
+		if rcv.Name == "" {
+			return nil, fmt.Errorf("receiver %d has no name", i)
+		}
+		if names[rcv.Name] {
+			return nil, fmt.Errorf("receiver %q is defined twice", rcv.Name)
+		}
+		names[rcv.Name] = true
+
+		r := &receiver{Receiver: rcv}
+		if len(r.GroupBy) == 0 {
+			r.GroupBy = defaultGroupBy
+		}
+		if r.GroupWait == 0 {
+			r.GroupWait = alerting.Duration(defaultGroupWait)
+		}
+		if r.SendResolved == nil {
+			sendResolved := true
+			r.SendResolved = &sendResolved
+		}
+		if r.RepeatInterval == 0 && r.Type == TypeAlertmanager {
+			r.RepeatInterval = alerting.Duration(defaultAlertmanagerRepeat)
+		}
+		if r.SMTP.Port == 0 {
+			r.SMTP.Port = 587
+		}
+		for _, label := range r.GroupBy {
+			if !groupLabels[label] {
+				return nil, fmt.Errorf("receiver %q can't group by %q", r.Name, label)
+			}
+		}
+		if err := r.Matcher.check(); err != nil {
+			return nil, fmt.Errorf("receiver %q has %w", r.Name, err)
+		}
+
+		var err error
+		if r.title, err = parseTemplate("title", r.Title, defaultTitle); err != nil {
+			return nil, fmt.Errorf("receiver %q has invalid title: %w", r.Name, err)
+		}
+		if r.text, err = parseTemplate("text", r.Text, defaultText); err != nil {
+			return nil, fmt.Errorf("receiver %q has invalid text: %w", r.Name, err)
+		}
+
+		switch r.Type {
+		case TypeWebhook, TypeSlack, TypeAlertmanager:
+			if u, err := url.Parse(r.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				return nil, fmt.Errorf("receiver %q needs an http or https url", r.Name)
+			}
+		case TypeEmail:
+			if r.SMTP.Host == "" || r.From == "" || len(r.To) == 0 {
+				return nil, fmt.Errorf("receiver %q needs an smtp host, from and to", r.Name)
+			}
+		default:
+			return nil, fmt.Errorf("receiver %q has invalid type %q, expected webhook, slack, alertmanager or email", r.Name, r.Type)
+		}
+		r.sink = newSink(r, client)
+		compiled = append(compiled, r)
+	}
+	return compiled, nil
+}
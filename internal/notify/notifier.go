@@ -0,0 +1,349 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/thekubefleet/kubefleet/internal/alerting"
+	"github.com/thekubefleet/kubefleet/internal/config"
+)
+
+// Delivery limits: a notification is tried maxAttempts times, waiting
+// retryDelay after the first failure and twice as long after each next one
+const (
+	maxAttempts   = 5
+	retryDelay    = time.Second
+	maxRetryDelay = time.Minute
+	queueSize     = 1024
+	flushInterval = time.Second
+	sendTimeout   = 30 * time.Second
+)
+
+const defaultTitle = `[{{ upper .Status }}{{ if gt (len .Alerts) 1 }}:{{ len .Alerts }}{{ end }}] {{ .Group }}`
+
+const defaultText = `{{ range .Alerts }}{{ upper .State }} [{{ .Severity }}] {{ .Cluster }}/{{ .Namespace }}/{{ or .Pod .Deployment }}: {{ .Message }}
+{{ end }}{{ if .ExternalURL }}{{ .ExternalURL }}
+{{ end }}`
+
+var templateFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"join":  strings.Join,
+}
+
+func parseTemplate(name, text, def string) (*template.Template, error) {
+	if text == "" {
+		text = def
+	}
+	return template.New(name).Funcs(templateFuncs).Parse(text)
+}
+
+// Notification is a group of alerts sent to a receiver
+type Notification struct {
+	Receiver    string            `json:"receiver"`
+	Status      string            `json:"status"` // firing when any alert is firing, else resolved
+	Group       string            `json:"group"`  // The group label values joined by "/"
+	GroupLabels map[string]string `json:"groupLabels"`
+	Alerts      []alerting.Alert  `json:"alerts"`
+	ExternalURL string            `json:"externalURL,omitempty"`
+}
+
+// httpClient sends the requests of HTTP sinks
+type httpClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// group collects the alerts a receiver sends together
+type group struct {
+	receiver string
+	labels   map[string]string
+	name     string
+	alerts   map[string]alerting.Alert
+	due      time.Time
+}
+
+// Notifier sends alerts that fire or resolve to the receivers that match
+// them, in groups, skipping silenced alerts and retrying failed sends
+type Notifier struct {
+	load   config.LoadFunc
+	client httpClient
+	queue  chan alerting.Alert
+	now    func() time.Time
+	retry  time.Duration
+
+	mu        sync.Mutex
+	config    *Config
+	receivers []*receiver
+	groups    map[string]*group
+	firing    map[string]map[string]alerting.Alert // receiver -> alert id -> alert
+	repeated  map[string]time.Time                 // receiver -> last repeat
+}
+
+// NewNotifier creates a notifier and performs the initial config load
+func NewNotifier(ctx context.Context, load config.LoadFunc) (*Notifier, error) {
+	n := &Notifier{
+		load:     load,
+		client:   &http.Client{Timeout: sendTimeout},
+		queue:    make(chan alerting.Alert, queueSize),
+		now:      time.Now,
+		retry:    retryDelay,
+		groups:   make(map[string]*group),
+		firing:   make(map[string]map[string]alerting.Alert),
+		repeated: make(map[string]time.Time),
+	}
+	if err := n.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// Reload replaces the config with the current contents of the source.
+// Groups waiting to be sent to receivers that no longer exist are dropped.
+func (n *Notifier) Reload(ctx context.Context) error {
+	data, err := n.load(ctx)
+	if err != nil {
+		return err
+	}
+	config, err := ParseConfig(data)
+	if err != nil {
+		return err
+	}
+	receivers, err := compileReceivers(config, n.client)
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.config = config
+	n.receivers = receivers
+	return nil
+}
+
+// Watch reloads the config every interval until ctx is done
+func (n *Notifier) Watch(ctx context.Context, interval time.Duration) {
+	config.Watch(ctx, interval, "notification config", n.Reload)
+}
+
+// Notify queues an alert that fired or resolved. It doesn't block; when the
+// queue is full the alert is dropped.
+func (n *Notifier) Notify(alert alerting.Alert) {
+	select {
+	case n.queue <- alert:
+	default:
+		log.Printf("Notification queue full, dropping alert %s", alert.ID)
+	}
+}
+
+// Run groups queued alerts and sends groups as they come due until ctx is
+// done
+func (n *Notifier) Run(ctx context.Context) {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case alert := <-n.queue:
+			n.add(alert)
+		case <-ticker.C:
+			n.flush(ctx)
+		}
+	}
+}
+
+// add puts an alert into the group of each receiver it matches unless a
+// silence mutes it
+func (n *Notifier) add(alert alerting.Alert) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	now := n.now()
+	for _, silence := range n.config.Silences {
+		if !now.Before(silence.StartsAt) && now.Before(silence.EndsAt) && silence.matches(&alert) {
+			log.Printf("Alert %s is silenced: %s", alert.ID, silence.Comment)
+			return
+		}
+	}
+
+	for _, r := range n.receivers {
+		if !r.matches(&alert) {
+			continue
+		}
+		if alert.State == alerting.StateFiring {
+			if n.firing[r.Name] == nil {
+				n.firing[r.Name] = make(map[string]alerting.Alert)
+			}
+			n.firing[r.Name][alert.ID] = alert
+		} else {
+			delete(n.firing[r.Name], alert.ID)
+			if !*r.SendResolved {
+				continue
+			}
+		}
+		n.group(r, alert, now)
+	}
+}
+
+// group adds an alert to its group of a receiver. Callers must hold n.mu.
+func (n *Notifier) group(r *receiver, alert alerting.Alert, now time.Time) {
+	labels := make(map[string]string, len(r.GroupBy))
+	values := make([]string, len(r.GroupBy))
+	for i, label := range r.GroupBy {
+		values[i] = alertLabel(&alert, label)
+		labels[label] = values[i]
+	}
+	name := strings.Join(values, "/")
+	key := r.Name + "\x00" + name
+	g := n.groups[key]
+	if g == nil {
+		g = &group{
+			receiver: r.Name,
+			labels:   labels,
+			name:     name,
+			alerts:   make(map[string]alerting.Alert),
+			due:      now.Add(time.Duration(r.GroupWait)),
+		}
+		n.groups[key] = g
+	}
+	// A later state of the same alert replaces the earlier one
+	g.alerts[alert.ID] = alert
+}
+
+func alertLabel(alert *alerting.Alert, label string) string {
+	switch label {
+	case "rule":
+		return alert.Rule
+	case "severity":
+		return alert.Severity
+	case "cluster":
+		return alert.Cluster
+	case "namespace":
+		return alert.Namespace
+	case "kind":
+		return alert.Kind
+	}
+	return ""
+}
+
+// flush groups the firing alerts of receivers that repeat them and sends
+// the groups that are due
+func (n *Notifier) flush(ctx context.Context) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	now := n.now()
+	receivers := make(map[string]*receiver, len(n.receivers))
+	for _, r := range n.receivers {
+		receivers[r.Name] = r
+	}
+	for name, alerts := range n.firing {
+		r := receivers[name]
+		if r == nil {
+			delete(n.firing, name)
+			delete(n.repeated, name)
+			continue
+		}
+		if r.RepeatInterval == 0 {
+			continue
+		}
+		if last, ok := n.repeated[name]; ok && now.Sub(last) < time.Duration(r.RepeatInterval) {
+			continue
+		}
+		n.repeated[name] = now
+		for _, alert := range alerts {
+			n.group(r, alert, now)
+		}
+	}
+	for key, g := range n.groups {
+		if now.Before(g.due) {
+			continue
+		}
+		delete(n.groups, key)
+		r := receivers[g.receiver]
+		if r == nil {
+			continue
+		}
+
+		notification := &Notification{
+			Receiver:    r.Name,
+			Status:      alerting.StateResolved,
+			Group:       g.name,
+			GroupLabels: g.labels,
+			ExternalURL: n.config.ExternalURL,
+		}
+		for _, alert := range g.alerts {
+			notification.Alerts = append(notification.Alerts, alert)
+			if alert.State == alerting.StateFiring {
+				notification.Status = alerting.StateFiring
+			}
+		}
+		sort.Slice(notification.Alerts, func(i, j int) bool {
+			return notification.Alerts[i].ID < notification.Alerts[j].ID
+		})
+		go n.deliver(ctx, r, notification)
+	}
+}
+
+// deliver renders a notification and sends it, retrying with backoff
+func (n *Notifier) deliver(ctx context.Context, r *receiver, notification *Notification) {
+	title, err := render(r.title, notification)
+	if err == nil {
+		var text string
+		if text, err = render(r.text, notification); err == nil {
+			err = n.send(ctx, r, notification, title, text)
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to notify receiver %s of %d alerts: %v", r.Name, len(notification.Alerts), err)
+	}
+}
+
+func (n *Notifier) send(ctx context.Context, r *receiver, notification *Notification, title, text string) error {
+	delay := n.retry
+	var err error
+	for attempt := 1; ; attempt++ {
+		sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+		err = r.sink.send(sendCtx, notification, title, text)
+		cancel()
+
+		var permanent *permanentError
+		if err == nil || errors.As(err, &permanent) || attempt == maxAttempts {
+			return err
+		}
+		log.Printf("Failed to notify receiver %s (attempt %d), retrying in %s: %v", r.Name, attempt, delay, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxRetryDelay)
+	}
+}
+
+func render(tmpl *template.Template, notification *Notification) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, notification); err != nil {
+		return "", fmt.Errorf("failed to render %s: %w", tmpl.Name(), err)
+	}
+	return buf.String(), nil
+}
+
+// permanentError is a failure that retrying won't fix, such as a rejected
+// request
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+func (e *permanentError) Unwrap() error { return e.err }
@@ -0,0 +1,340 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thekubefleet/kubefleet/internal/alerting"
+)
+
+// request is what a test server received
+type request struct {
+	path   string
+	header http.Header
+	body   []byte
+}
+
+// testServer records requests and answers them with the queued statuses,
+// then with 200
+type testServer struct {
+	*httptest.Server
+	requests chan request
+
+	mu       sync.Mutex
+	statuses []int
+}
+
+func newTestServer(t *testing.T, statuses ...int) *testServer {
+	s := &testServer{requests: make(chan request, 32), statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.requests <- request{path: r.URL.Path, header: r.Header, body: body}
+
+		s.mu.Lock()
+		status := http.StatusOK
+		if len(s.statuses) > 0 {
+			status, s.statuses = s.statuses[0], s.statuses[1:]
+		}
+		s.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// next returns the next request the server received
+func (s *testServer) next(t *testing.T) request {
+	t.Helper()
+	select {
+	case req := <-s.requests:
+		return req
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no request received")
+		return request{}
+	}
+}
+
+// testNotifier is a notifier on a clock the test moves, sending through
+// server's client without waiting between retries
+type testNotifier struct {
+	*Notifier
+	clock time.Time
+}
+
+// newTestNotifier creates a notifier from config, with $URL replaced by the
+// server's address
+func newTestNotifier(t *testing.T, server *testServer, config string) *testNotifier {
+	t.Helper()
+	config = strings.ReplaceAll(config, "$URL", server.URL)
+	n, err := NewNotifier(context.Background(), func(context.Context) ([]byte, error) { return []byte(config), nil })
+	if err != nil {
+		t.Fatalf("NewNotifier: %v", err)
+	}
+	tn := &testNotifier{Notifier: n, clock: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)}
+	n.now = func() time.Time { return tn.clock }
+	n.retry = time.Millisecond
+	// The sinks are created with the client on reload
+	n.client = server.Client()
+	if err := n.Reload(context.Background()); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	return tn
+}
+
+func (tn *testNotifier) advance(d time.Duration) {
+	tn.clock = tn.clock.Add(d)
+}
+
+// flush sends the groups that are due. Deliveries run in the background,
+// so tests wait for them with server.next.
+func (tn *testNotifier) flush() {
+	tn.Notifier.flush(context.Background())
+}
+
+// pending returns how many groups wait to be sent
+func (tn *testNotifier) pending() int {
+	tn.mu.Lock()
+	defer tn.mu.Unlock()
+	return len(tn.groups)
+}
+
+var start = time.Date(2024, 5, 1, 9, 55, 0, 0, time.UTC)
+
+func testAlert(rule, cluster, pod, state string) alerting.Alert {
+	firedAt := start
+	alert := alerting.Alert{
+		ID:        rule + "/" + cluster + "/default/" + pod,
+		Rule:      rule,
+		Kind:      alerting.KindLog,
+		Severity:  "critical",
+		State:     state,
+		Cluster:   cluster,
+		Namespace: "default",
+		Pod:       pod,
+		Message:   `1 line matching "OOMKilled" within 1m0s`,
+		Count:     1,
+		Sample:    "container app was OOMKilled",
+		ActiveAt:  start,
+		FiredAt:   &firedAt,
+		UpdatedAt: start,
+	}
+	if state == alerting.StateResolved {
+		resolvedAt := start.Add(5 * time.Minute)
+		alert.ResolvedAt = &resolvedAt
+		alert.UpdatedAt = resolvedAt
+	}
+	return alert
+}
+
+// decode parses a webhook body
+func decode(t *testing.T, req request) Notification {
+	t.Helper()
+	var notification Notification
+	if err := json.Unmarshal(req.body, &notification); err != nil {
+		t.Fatalf("failed to decode %s: %v", req.body, err)
+	}
+	return notification
+}
+
+func alertIDs(n Notification) []string {
+	var ids []string
+	for _, alert := range n.Alerts {
+		ids = append(ids, alert.ID+" "+alert.State)
+	}
+	return ids
+}
+
+func TestGrouping(t *testing.T) {
+	server := newTestServer(t)
+	tn := newTestNotifier(t, server, `{"receivers": [{"name": "hook", "type": "webhook", "url": "$URL", "groupWait": "30s"}]}`)
+
+	tn.add(testAlert("oom", "prod", "web", alerting.StateFiring))
+	tn.advance(10 * time.Second)
+	tn.add(testAlert("oom", "prod", "worker", alerting.StateFiring))
+	tn.add(testAlert("oom", "staging", "web", alerting.StateFiring))
+
+	// Groups are sent GroupWait after their first alert
+	tn.advance(19 * time.Second)
+	tn.flush()
+	if tn.pending() != 2 {
+		t.Fatalf("%d groups waiting, want 2 before the wait is over", tn.pending())
+	}
+	tn.advance(time.Second)
+	tn.flush()
+	n := decode(t, server.next(t))
+	if n.Group != "oom/prod" || n.Status != alerting.StateFiring || n.GroupLabels["cluster"] != "prod" {
+		t.Errorf("notification = %+v, want the oom/prod group firing", n)
+	}
+	if got := alertIDs(n); strings.Join(got, ",") != "oom/prod/default/web firing,oom/prod/default/worker firing" {
+		t.Errorf("alerts = %q", got)
+	}
+	if tn.pending() != 1 {
+		t.Errorf("%d groups waiting, want the staging group", tn.pending())
+	}
+
+	// A later state of an alert replaces the earlier one in its group
+	tn.add(testAlert("oom", "prod", "web", alerting.StateResolved))
+	tn.add(testAlert("oom", "prod", "worker", alerting.StateResolved))
+	tn.advance(30 * time.Second)
+	tn.flush()
+	requests := []request{server.next(t), server.next(t)}
+	var resolved Notification
+	for _, req := range requests {
+		if n := decode(t, req); n.Group == "oom/prod" {
+			resolved = n
+		}
+	}
+	if resolved.Status != alerting.StateResolved || len(resolved.Alerts) != 2 {
+		t.Errorf("notification = %+v, want both prod alerts resolved", resolved)
+	}
+}
+
+func TestSendResolved(t *testing.T) {
+	server := newTestServer(t)
+	tn := newTestNotifier(t, server, `{"receivers": [
+		{"name": "hook", "type": "webhook", "url": "$URL", "sendResolved": false, "groupBy": ["severity"]}
+	]}`)
+
+	tn.add(testAlert("oom", "prod", "web", alerting.StateResolved))
+	if tn.pending() != 0 {
+		t.Errorf("resolved alert was grouped for a receiver that doesn't send them")
+	}
+	tn.add(testAlert("oom", "prod", "web", alerting.StateFiring))
+	tn.advance(defaultGroupWait)
+	tn.flush()
+	if n := decode(t, server.next(t)); n.Group != "critical" {
+		t.Errorf("group = %q, want the severity", n.Group)
+	}
+}
+
+func TestMatchersAndSilences(t *testing.T) {
+	server := newTestServer(t)
+	tn := newTestNotifier(t, server, `{
+		"receivers": [
+			{"name": "prod", "type": "webhook", "url": "$URL/prod", "clusters": ["prod-*"]},
+			{"name": "all", "type": "webhook", "url": "$URL/all"}
+		],
+		"silences": [
+			{"rules": ["oom"], "namespaces": ["default"], "startsAt": "2024-05-01T09:00:00Z", "endsAt": "2024-05-01T11:00:00Z", "comment": "upgrade"},
+			{"rules": ["cpu-high"], "startsAt": "2024-05-01T08:00:00Z", "endsAt": "2024-05-01T09:00:00Z", "comment": "over"}
+		]
+	}`)
+
+	tn.add(testAlert("oom", "prod-eu", "web", alerting.StateFiring))
+	if tn.pending() != 0 {
+		t.Fatalf("silenced alert was grouped")
+	}
+
+	tn.add(testAlert("cpu-high", "prod-eu", "web", alerting.StateFiring))
+	tn.add(testAlert("cpu-high", "staging", "web", alerting.StateFiring))
+	tn.advance(defaultGroupWait)
+	tn.flush()
+
+	paths := map[string]int{}
+	for i := 0; i < 3; i++ {
+		req := server.next(t)
+		paths[req.path] += len(decode(t, req).Alerts)
+	}
+	if paths["/prod"] != 1 || paths["/all"] != 2 {
+		t.Errorf("alerts sent per receiver = %v, want prod-eu to both and staging to all", paths)
+	}
+}
+
+func TestRepeat(t *testing.T) {
+	server := newTestServer(t)
+	tn := newTestNotifier(t, server, `{"receivers": [
+		{"name": "hook", "type": "webhook", "url": "$URL", "groupWait": "10s", "repeatInterval": "1m"}
+	]}`)
+
+	tn.add(testAlert("oom", "prod", "web", alerting.StateFiring))
+	tn.advance(10 * time.Second)
+	tn.flush()
+	server.next(t)
+
+	// Firing alerts are grouped again once the repeat interval has passed
+	tn.advance(59 * time.Second)
+	tn.flush()
+	if tn.pending() != 0 {
+		t.Fatalf("alert repeated before the repeat interval")
+	}
+	tn.advance(time.Second)
+	tn.flush()
+	tn.advance(10 * time.Second)
+	tn.flush()
+	if n := decode(t, server.next(t)); n.Status != alerting.StateFiring || len(n.Alerts) != 1 {
+		t.Errorf("repeat = %+v, want the firing alert", n)
+	}
+
+	// Resolved alerts aren't repeated
+	tn.add(testAlert("oom", "prod", "web", alerting.StateResolved))
+	tn.advance(10 * time.Second)
+	tn.flush()
+	if n := decode(t, server.next(t)); n.Status != alerting.StateResolved {
+		t.Errorf("notification = %+v, want the alert resolved", n)
+	}
+	tn.advance(time.Hour)
+	tn.flush()
+	if tn.pending() != 0 {
+		t.Errorf("resolved alert was repeated")
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		attempts int
+		wantErr  bool
+	}{
+		{name: "success", attempts: 1},
+		{name: "server errors are retried", statuses: []int{503, 502}, attempts: 3},
+		{name: "rate limiting is retried", statuses: []int{429}, attempts: 2},
+		{name: "timeouts are retried", statuses: []int{408}, attempts: 2},
+		{name: "rejected requests aren't retried", statuses: []int{400}, attempts: 1, wantErr: true},
+		{name: "attempts are limited", statuses: []int{500, 500, 500, 500, 500, 500}, attempts: maxAttempts, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t, tt.statuses...)
+			tn := newTestNotifier(t, server, `{"receivers": [{"name": "hook", "type": "webhook", "url": "$URL"}]}`)
+
+			notification := &Notification{Receiver: "hook", Status: alerting.StateFiring, Alerts: []alerting.Alert{testAlert("oom", "prod", "web", alerting.StateFiring)}}
+			err := tn.send(context.Background(), tn.receivers[0], notification, "title", "text")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("send() = %v, want error %v", err, tt.wantErr)
+			}
+			if n := len(server.requests); n != tt.attempts {
+				t.Errorf("sent %d times, want %d", n, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestReloadDropsRemovedReceivers(t *testing.T) {
+	server := newTestServer(t)
+	tn := newTestNotifier(t, server, `{"receivers": [{"name": "hook", "type": "webhook", "url": "$URL"}]}`)
+	tn.add(testAlert("oom", "prod", "web", alerting.StateFiring))
+
+	config := `{"receivers": []}`
+	tn.load = func(context.Context) ([]byte, error) { return []byte(config), nil }
+	if err := tn.Reload(context.Background()); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	tn.advance(defaultGroupWait)
+	tn.flush()
+	if tn.pending() != 0 || len(tn.firing) != 0 {
+		t.Errorf("groups and firing alerts of a removed receiver were kept")
+	}
+
+	config = `{"receivers": [{"name": "hook", "type": "pager"}]}`
+	if err := tn.Reload(context.Background()); err == nil {
+		t.Errorf("Reload of an invalid config succeeded")
+	}
+}
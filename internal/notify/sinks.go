@@ -0,0 +1,273 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thekubefleet/kubefleet/internal/alerting"
+)
+
+// Headers of signed webhook requests. The signature is the hex HMAC-SHA256
+// of the timestamp, a dot and the body, keyed with the receiver's secret.
+const (
+	timestampHeader = "X-KubeFleet-Timestamp"
+	signatureHeader = "X-KubeFleet-Signature"
+)
+
+// sink delivers rendered notifications to one kind of destination
+type sink interface {
+	send(ctx context.Context, n *Notification, title, text string) error
+}
+
+func newSink(r *receiver, client httpClient) sink {
+	switch r.Type {
+	case TypeSlack:
+		return &slackSink{r: r, client: client}
+	case TypeAlertmanager:
+		return &alertmanagerSink{r: r, client: client}
+	case TypeEmail:
+		return &emailSink{r: r}
+	}
+	return &webhookSink{r: r, client: client}
+}
+
+// post sends a JSON body. Client errors other than timeouts and rate
+// limiting are permanent.
+func post(ctx context.Context, client httpClient, url string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return &permanentError{fmt.Errorf("failed to create request: %w", err)}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("%s returned %s: %s", url, resp.Status, strings.TrimSpace(string(msg)))
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return &permanentError{err}
+	}
+	return err
+}
+
+// webhookSink posts the notification as JSON, signed when a secret is set
+type webhookSink struct {
+	r      *receiver
+	client httpClient
+}
+
+func (s *webhookSink) send(ctx context.Context, n *Notification, title, text string) error {
+	body, err := json.Marshal(struct {
+		*Notification
+		Title string `json:"title"`
+		Text  string `json:"text"`
+	}{n, title, text})
+	if err != nil {
+		return &permanentError{fmt.Errorf("failed to encode notification: %w", err)}
+	}
+
+	headers := make(map[string]string, len(s.r.Headers)+2)
+	for name, value := range s.r.Headers {
+		headers[name] = value
+	}
+	if s.r.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		headers[timestampHeader] = timestamp
+		headers[signatureHeader] = "sha256=" + sign(s.r.Secret, timestamp, body)
+	}
+	return post(ctx, s.client, s.r.URL, body, headers)
+}
+
+func sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// slackSink posts to a Slack or Mattermost incoming webhook
+type slackSink struct {
+	r      *receiver
+	client httpClient
+}
+
+func (s *slackSink) send(ctx context.Context, n *Notification, title, text string) error {
+	body, err := json.Marshal(map[string]string{
+		"text":       strings.TrimSpace("*" + title + "*\n" + text),
+		"channel":    s.r.Channel,
+		"username":   s.r.Username,
+		"icon_emoji": s.r.IconEmoji,
+	})
+	if err != nil {
+		return &permanentError{fmt.Errorf("failed to encode message: %w", err)}
+	}
+	return post(ctx, s.client, s.r.URL, body, s.r.Headers)
+}
+
+// alertmanagerSink pushes alerts to the Alertmanager v2 API. Alertmanager
+// deduplicates them by their labels.
+type alertmanagerSink struct {
+	r      *receiver
+	client httpClient
+}
+
+type alertmanagerAlert struct {
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       *time.Time        `json:"endsAt,omitempty"`
+	GeneratorURL string            `json:"generatorURL,omitempty"`
+}
+
+func (s *alertmanagerSink) send(ctx context.Context, n *Notification, title, text string) error {
+	alerts := make([]alertmanagerAlert, 0, len(n.Alerts))
+	for _, alert := range n.Alerts {
+		labels := map[string]string{
+			"alertname": alert.Rule,
+			"severity":  alert.Severity,
+			"cluster":   alert.Cluster,
+			"namespace": alert.Namespace,
+			"kind":      alert.Kind,
+		}
+		if alert.Pod != "" {
+			labels["pod"] = alert.Pod
+		}
+		if alert.Deployment != "" {
+			labels["deployment"] = alert.Deployment
+		}
+		annotations := map[string]string{"summary": alert.Message}
+		if alert.Sample != "" {
+			annotations["sample"] = alert.Sample
+		}
+		if alert.Value != 0 {
+			annotations["value"] = strconv.FormatFloat(alert.Value, 'f', -1, 64)
+		}
+
+		a := alertmanagerAlert{
+			Labels:       labels,
+			Annotations:  annotations,
+			StartsAt:     alert.ActiveAt,
+			GeneratorURL: n.ExternalURL,
+		}
+		if alert.FiredAt != nil {
+			a.StartsAt = *alert.FiredAt
+		}
+		if alert.State == alerting.StateResolved && alert.ResolvedAt != nil {
+			a.EndsAt = alert.ResolvedAt
+		}
+		alerts = append(alerts, a)
+	}
+
+	body, err := json.Marshal(alerts)
+	if err != nil {
+		return &permanentError{fmt.Errorf("failed to encode alerts: %w", err)}
+	}
+	return post(ctx, s.client, strings.TrimSuffix(s.r.URL, "/")+"/api/v2/alerts", body, s.r.Headers)
+}
+
+// emailSink sends a plain text email through an SMTP server
+type emailSink struct {
+	r *receiver
+}
+
+func (s *emailSink) send(ctx context.Context, n *Notification, title, text string) error {
+	smtpConfig := s.r.SMTP
+	addr := net.JoinHostPort(smtpConfig.Host, strconv.Itoa(smtpConfig.Port))
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, smtpConfig.Host)
+	if err != nil {
+		conn.Close()
+		return smtpError(fmt.Errorf("failed to start SMTP session with %s: %w", addr, err))
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: smtpConfig.Host}); err != nil {
+			return smtpError(fmt.Errorf("failed to start TLS: %w", err))
+		}
+	}
+	if smtpConfig.Username != "" {
+		auth := smtp.PlainAuth("", smtpConfig.Username, smtpConfig.Password, smtpConfig.Host)
+		if err := client.Auth(auth); err != nil {
+			return smtpError(fmt.Errorf("failed to authenticate: %w", err))
+		}
+	}
+
+	if err := client.Mail(s.r.From); err != nil {
+		return smtpError(fmt.Errorf("failed to set sender: %w", err))
+	}
+	for _, to := range s.r.To {
+		if err := client.Rcpt(to); err != nil {
+			return smtpError(fmt.Errorf("failed to add recipient %s: %w", to, err))
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return smtpError(fmt.Errorf("failed to start message: %w", err))
+	}
+	if _, err := w.Write(s.message(title, text)); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return smtpError(fmt.Errorf("failed to send message: %w", err))
+	}
+	return client.Quit()
+}
+
+func (s *emailSink) message(title, text string) []byte {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.r.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.r.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", title))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\n", "\r\n"))
+	return msg.Bytes()
+}
+
+// smtpError marks errors the server rejected with a 5xx reply as permanent
+func smtpError(err error) error {
+	var reply *textproto.Error
+	if errors.As(err, &reply) && reply.Code >= 500 {
+		return &permanentError{err}
+	}
+	return err
+}
@@ -0,0 +1,197 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"maps"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thekubefleet/kubefleet/internal/alerting"
+)
+
+// notification returns the notification a receiver would get for alerts
+func notification(receiver, group string, alerts ...alerting.Alert) *Notification {
+	n := &Notification{
+		Receiver:    receiver,
+		Status:      alerting.StateResolved,
+		Group:       group,
+		GroupLabels: map[string]string{"rule": "oom", "cluster": "prod"},
+		Alerts:      alerts,
+		ExternalURL: "https://kubefleet.example.com",
+	}
+	for _, alert := range alerts {
+		if alert.State == alerting.StateFiring {
+			n.Status = alerting.StateFiring
+		}
+	}
+	return n
+}
+
+func TestWebhookSink(t *testing.T) {
+	server := newTestServer(t)
+	tn := newTestNotifier(t, server, `{"receivers": [{
+		"name": "hook", "type": "webhook", "url": "$URL/hook",
+		"secret": "s3cret", "headers": {"Authorization": "Bearer token"}
+	}]}`)
+
+	tn.deliver(context.Background(), tn.receivers[0], notification("hook", "oom/prod",
+		testAlert("oom", "prod", "web", alerting.StateFiring),
+		testAlert("oom", "prod", "worker", alerting.StateFiring)))
+	req := server.next(t)
+
+	if req.path != "/hook" || req.header.Get("Authorization") != "Bearer token" || req.header.Get("Content-Type") != "application/json" {
+		t.Errorf("request to %s with headers %v", req.path, req.header)
+	}
+	timestamp := req.header.Get(timestampHeader)
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(req.body)
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); timestamp == "" || req.header.Get(signatureHeader) != want {
+		t.Errorf("signature = %q at %q, want %q", req.header.Get(signatureHeader), timestamp, want)
+	}
+
+	var body struct {
+		Notification
+		Title string `json:"title"`
+		Text  string `json:"text"`
+	}
+	if err := json.Unmarshal(req.body, &body); err != nil {
+		t.Fatalf("failed to decode %s: %v", req.body, err)
+	}
+	if body.Receiver != "hook" || body.Status != alerting.StateFiring || len(body.Alerts) != 2 {
+		t.Errorf("notification = %+v", body.Notification)
+	}
+	if body.Title != "[FIRING:2] oom/prod" {
+		t.Errorf("title = %q", body.Title)
+	}
+	wantText := "FIRING [critical] prod/default/web: 1 line matching \"OOMKilled\" within 1m0s\n" +
+		"FIRING [critical] prod/default/worker: 1 line matching \"OOMKilled\" within 1m0s\n" +
+		"https://kubefleet.example.com\n"
+	if body.Text != wantText {
+		t.Errorf("text = %q, want %q", body.Text, wantText)
+	}
+}
+
+func TestWebhookSinkUnsigned(t *testing.T) {
+	server := newTestServer(t)
+	tn := newTestNotifier(t, server, `{"receivers": [{"name": "hook", "type": "webhook", "url": "$URL"}]}`)
+
+	tn.deliver(context.Background(), tn.receivers[0], notification("hook", "oom/prod", testAlert("oom", "prod", "web", alerting.StateFiring)))
+	req := server.next(t)
+	if req.header.Get(timestampHeader) != "" || req.header.Get(signatureHeader) != "" {
+		t.Errorf("request without a secret was signed: %v", req.header)
+	}
+}
+
+func TestSlackSink(t *testing.T) {
+	server := newTestServer(t)
+	tn := newTestNotifier(t, server, `{"receivers": [{
+		"name": "slack", "type": "slack", "url": "$URL/services/T0/B0/x",
+		"channel": "#alerts", "username": "kubefleet", "iconEmoji": ":rotating_light:",
+		"text": "{{ range .Alerts }}{{ .Pod }} {{ .State }}\n{{ end }}"
+	}]}`)
+
+	tn.deliver(context.Background(), tn.receivers[0], notification("slack", "oom/prod", testAlert("oom", "prod", "web", alerting.StateResolved)))
+	req := server.next(t)
+
+	if req.path != "/services/T0/B0/x" {
+		t.Errorf("request to %s", req.path)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(req.body, &body); err != nil {
+		t.Fatalf("failed to decode %s: %v", req.body, err)
+	}
+	want := map[string]string{
+		"text":       "*[RESOLVED] oom/prod*\nweb resolved",
+		"channel":    "#alerts",
+		"username":   "kubefleet",
+		"icon_emoji": ":rotating_light:",
+	}
+	for key, value := range want {
+		if body[key] != value {
+			t.Errorf("%s = %q, want %q", key, body[key], value)
+		}
+	}
+}
+
+func TestAlertmanagerSink(t *testing.T) {
+	server := newTestServer(t)
+	tn := newTestNotifier(t, server, `{"receivers": [{"name": "am", "type": "alertmanager", "url": "$URL/"}]}`)
+	if time.Duration(tn.receivers[0].RepeatInterval) != defaultAlertmanagerRepeat {
+		t.Errorf("repeat interval = %v, want %v", tn.receivers[0].RepeatInterval, defaultAlertmanagerRepeat)
+	}
+
+	activeAt := start.Add(-5 * time.Minute)
+	metric := alerting.Alert{
+		ID:         "cpu-high/prod/default/deployment/api",
+		Rule:       "cpu-high",
+		Kind:       alerting.KindMetric,
+		Severity:   "warning",
+		State:      alerting.StatePending,
+		Cluster:    "prod",
+		Namespace:  "default",
+		Deployment: "api",
+		Message:    "cpu 2.5 above 1",
+		Value:      2.5,
+		ActiveAt:   activeAt,
+	}
+	tn.deliver(context.Background(), tn.receivers[0], notification("am", "oom/prod", testAlert("oom", "prod", "web", alerting.StateResolved), metric))
+	req := server.next(t)
+
+	if req.path != "/api/v2/alerts" {
+		t.Errorf("request to %s, want /api/v2/alerts", req.path)
+	}
+	var alerts []alertmanagerAlert
+	if err := json.Unmarshal(req.body, &alerts); err != nil {
+		t.Fatalf("failed to decode %s: %v", req.body, err)
+	}
+	if len(alerts) != 2 {
+		t.Fatalf("sent %d alerts, want 2", len(alerts))
+	}
+
+	oom := alerts[0]
+	wantLabels := map[string]string{"alertname": "oom", "severity": "critical", "cluster": "prod", "namespace": "default", "kind": "log", "pod": "web"}
+	if !maps.Equal(oom.Labels, wantLabels) {
+		t.Errorf("labels = %v, want %v", oom.Labels, wantLabels)
+	}
+	if oom.Annotations["sample"] != "container app was OOMKilled" || oom.Annotations["value"] != "" {
+		t.Errorf("annotations = %v", oom.Annotations)
+	}
+	if !oom.StartsAt.Equal(start) || oom.EndsAt == nil || !oom.EndsAt.Equal(start.Add(5*time.Minute)) {
+		t.Errorf("resolved alert from %v to %v, want from when it fired to when it resolved", oom.StartsAt, oom.EndsAt)
+	}
+	if oom.GeneratorURL != "https://kubefleet.example.com" {
+		t.Errorf("generator url = %q", oom.GeneratorURL)
+	}
+
+	cpu := alerts[1]
+	if cpu.Labels["deployment"] != "api" || cpu.Labels["pod"] != "" || cpu.Annotations["value"] != "2.5" {
+		t.Errorf("alert = %+v", cpu)
+	}
+	if !cpu.StartsAt.Equal(activeAt) || cpu.EndsAt != nil {
+		t.Errorf("unfired alert from %v to %v, want from when it became active", cpu.StartsAt, cpu.EndsAt)
+	}
+}
+
+func TestPostErrors(t *testing.T) {
+	server := newTestServer(t, 400, 503)
+	tn := newTestNotifier(t, server, `{"receivers": [{"name": "hook", "type": "webhook", "url": "$URL"}]}`)
+	sink := tn.receivers[0].sink
+	n := notification("hook", "oom/prod", testAlert("oom", "prod", "web", alerting.StateFiring))
+
+	var permanent *permanentError
+	err := sink.send(context.Background(), n, "", "")
+	if err == nil || !errors.As(err, &permanent) || !strings.Contains(err.Error(), "400 Bad Request") {
+		t.Errorf("send() = %v, want a permanent error for the rejected request", err)
+	}
+	err = sink.send(context.Background(), n, "", "")
+	if err == nil || errors.As(err, &permanent) {
+		t.Errorf("send() = %v, want a temporary error for the unavailable server", err)
+	}
+}
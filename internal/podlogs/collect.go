@@ -0,0 +1,94 @@
+package podlogs
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/thekubefleet/kubefleet/internal/grpcclient"
+	"github.com/thekubefleet/kubefleet/internal/k8s"
+	agentpb "github.com/thekubefleet/kubefleet/proto"
+)
+
+// Collector keeps the last lines of each container's log for reports. After
+// the first collection of a container, only the lines written since the
+// newest one it has are read. It is not safe for concurrent use.
+type Collector struct {
+	k8sClient LogSource
+	tailLines int
+	logs      map[containerKey]*containerLog
+}
+
+// containerKey identifies a container instance's log
+type containerKey struct {
+	namespace string
+	pod       string
+	container string
+	previous  bool
+}
+
+// containerLog is the end of a container's log and what is needed to read
+// on from it
+type containerLog struct {
+	lines     []*agentpb.PodLog
+	collected bool // Collected since the last Prune
+	resumePoint
+}
+
+// NewCollector creates a collector keeping the last tailLines lines of each
+// container
+func NewCollector(k8sClient LogSource, tailLines int) *Collector {
+	return &Collector{
+		k8sClient: k8sClient,
+		tailLines: tailLines,
+		logs:      make(map[containerKey]*containerLog),
+	}
+}
+
+// Collect reads the new lines of a container's log, or of its previous
+// instance, and returns the container's last lines. New lines carry the
+// pod's current labels. On an error, the lines read before it are kept.
+func (c *Collector) Collect(ctx context.Context, pod *corev1.Pod, container string, previous bool) ([]*agentpb.PodLog, error) {
+	namespace := pod.Namespace
+	key := containerKey{namespace, pod.Name, container, previous}
+	l := c.logs[key]
+	if l == nil {
+		l = &containerLog{}
+		c.logs[key] = l
+	}
+	l.collected = true
+
+	opts := k8s.LogOptions{TailLines: int64(c.tailLines), Previous: previous}
+	if since := l.resume(); !since.IsZero() {
+		opts = k8s.LogOptions{SinceTime: since, Previous: previous}
+	}
+
+	err := c.k8sClient.StreamPodLogLines(ctx, namespace, pod.Name, container, opts, func(line k8s.LogLine) error {
+		if line.Timestamp.IsZero() {
+			line.Timestamp = time.Now()
+		}
+		if !l.add(line.Timestamp, line.Text) {
+			return nil
+		}
+		podLog := grpcclient.ConvertLogLine(namespace, pod.Name, container, line, previous)
+		podLog.PodLabels = pod.Labels
+		l.lines = append(l.lines, podLog)
+		return nil
+	})
+	if len(l.lines) > c.tailLines {
+		l.lines = append([]*agentpb.PodLog(nil), l.lines[len(l.lines)-c.tailLines:]...)
+	}
+	return l.lines, err
+}
+
+// Prune forgets the containers that haven't been collected since the last
+// Prune, such as those of deleted pods
+func (c *Collector) Prune() {
+	for key, l := range c.logs {
+		if !l.collected {
+			delete(c.logs, key)
+		}
+		l.collected = false
+	}
+}
@@ -0,0 +1,95 @@
+package podlogs
+
+import (
+	"context"
+	"maps"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/thekubefleet/kubefleet/internal/k8s"
+)
+
+var web = &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+	Namespace: "default",
+	Name:      "web",
+	Labels:    map[string]string{"app": "web", "tier": "frontend"},
+}}
+
+func TestCollector(t *testing.T) {
+	source := newFakeSource(map[string][][]k8s.LogLine{
+		"app": {
+			{at(0, "starting"), at(1, "tick"), at(1, "tick")},
+			// Reading from the second of the newest line repeats the lines
+			// written in it
+			{at(1, "tick"), at(1, "tick"), at(1, "tock"), at(2, "ready")},
+			{at(2, "ready")},
+		},
+	})
+	c := NewCollector(source, 4)
+	ctx := context.Background()
+
+	collect := func() []string {
+		t.Helper()
+		logs, err := c.Collect(ctx, web, "app", false)
+		if err != nil {
+			t.Fatalf("Collect: %v", err)
+		}
+		for _, line := range logs {
+			if line.Namespace != "default" || line.PodName != "web" || !maps.Equal(line.PodLabels, web.Labels) {
+				t.Errorf("line %+v isn't labelled with its pod", line)
+			}
+		}
+		return texts(logs)
+	}
+
+	if got := collect(); !reflect.DeepEqual(got, []string{"starting", "tick", "tick"}) {
+		t.Errorf("first collection = %q", got)
+	}
+	if got := collect(); !reflect.DeepEqual(got, []string{"tick", "tick", "tock", "ready"}) {
+		t.Errorf("second collection = %q, want the last 4 lines without repeats", got)
+	}
+	if got := collect(); !reflect.DeepEqual(got, []string{"tick", "tick", "tock", "ready"}) {
+		t.Errorf("collection without new lines = %q, want the same tail", got)
+	}
+
+	opts := source.openOptions("app")
+	want := []k8s.LogOptions{
+		{TailLines: 4},
+		{SinceTime: at(1, "").Timestamp},
+		{SinceTime: at(2, "").Timestamp},
+	}
+	if !reflect.DeepEqual(opts, want) {
+		t.Errorf("log opened with %+v, want %+v", opts, want)
+	}
+}
+
+func TestCollectorPrune(t *testing.T) {
+	source := newFakeSource(map[string][][]k8s.LogLine{
+		"app": {{at(0, "first")}, {at(5, "again")}},
+	})
+	c := NewCollector(source, 10)
+	ctx := context.Background()
+
+	c.Collect(ctx, web, "app", false)
+	c.Prune()
+	c.Prune()
+
+	// The pod wasn't collected in a round, so it is read from its tail again
+	logs, err := c.Collect(ctx, web, "app", false)
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if got := texts(logs); !reflect.DeepEqual(got, []string{"again"}) {
+		t.Errorf("lines = %q", got)
+	}
+	if opts := source.openOptions("app"); opts[1] != (k8s.LogOptions{TailLines: 10}) {
+		t.Errorf("log reopened with %+v, want the tail", opts[1])
+	}
+
+	if _, err := c.Collect(ctx, web, "app", true); err == nil {
+		t.Errorf("Collect of a log that can't be read succeeded")
+	}
+}
@@ -0,0 +1,50 @@
+package podlogs
+
+import (
+	"maps"
+	"time"
+)
+
+// resumePoint tracks the newest lines read from a container's log, so a log
+// reopened from the newest line's time doesn't repeat lines. The API server
+// truncates the start time to the second, so lines up to the newest one come
+// again and are skipped.
+type resumePoint struct {
+	// last is the timestamp of the newest line read, and atLast the lines
+	// read with exactly that timestamp
+	last   time.Time
+	atLast map[string]int
+
+	// skipBefore and skip drop the lines a reopened log repeats
+	skipBefore time.Time
+	skip       map[string]int
+}
+
+// resume prepares for the log to be reopened and returns the time to read it
+// from, which is zero when no line has been read
+func (r *resumePoint) resume() time.Time {
+	r.skipBefore = r.last
+	r.skip = maps.Clone(r.atLast)
+	return r.last
+}
+
+// add records a line read from the log. It returns false for a line the
+// reopened log repeats, which is dropped.
+func (r *resumePoint) add(timestamp time.Time, text string) bool {
+	if !r.skipBefore.IsZero() {
+		if timestamp.Before(r.skipBefore) {
+			return false
+		}
+		if timestamp.Equal(r.skipBefore) && r.skip[text] > 0 {
+			r.skip[text]--
+			return false
+		}
+	}
+
+	if !timestamp.Equal(r.last) {
+		r.last = timestamp
+		r.atLast = make(map[string]int)
+	}
+	r.atLast[text]++
+	return true
+}
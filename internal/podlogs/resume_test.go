@@ -0,0 +1,84 @@
+package podlogs
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestResumePoint(t *testing.T) {
+	sec := func(n int) time.Time {
+		return time.Date(2024, 5, 1, 10, 0, n, 0, time.UTC)
+	}
+	type line struct {
+		ts   time.Time
+		text string
+	}
+	tests := []struct {
+		name      string
+		first     []line // Read before the log is reopened
+		reopened  []line
+		wantSince time.Time
+		want      []string
+	}{
+		{
+			name:     "nothing read yet",
+			reopened: []line{{sec(0), "a"}, {sec(0), "a"}},
+			want:     []string{"a", "a"},
+		},
+		{
+			name:      "lines before the newest second",
+			first:     []line{{sec(0), "a"}, {sec(1), "b"}},
+			reopened:  []line{{sec(0), "a"}, {sec(1), "b"}, {sec(2), "c"}},
+			wantSince: sec(1),
+			want:      []string{"c"},
+		},
+		{
+			name:      "repeated text in the newest second",
+			first:     []line{{sec(1), "tick"}, {sec(1), "tick"}},
+			reopened:  []line{{sec(1), "tick"}, {sec(1), "tick"}, {sec(1), "tick"}, {sec(1), "tock"}},
+			wantSince: sec(1),
+			want:      []string{"tick", "tock"},
+		},
+		{
+			name:      "newest second read before the last line",
+			first:     []line{{sec(0), "a"}, {sec(1), "b"}, {sec(1), "c"}},
+			reopened:  []line{{sec(1), "c"}, {sec(1), "b"}, {sec(1), "d"}},
+			wantSince: sec(1),
+			want:      []string{"d"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r resumePoint
+			for _, l := range tt.first {
+				if !r.add(l.ts, l.text) {
+					t.Fatalf("add(%v, %q) dropped a line before the log was reopened", l.ts, l.text)
+				}
+			}
+			if since := r.resume(); !since.Equal(tt.wantSince) {
+				t.Errorf("resume() = %v, want %v", since, tt.wantSince)
+			}
+			got := []string{}
+			for _, l := range tt.reopened {
+				if r.add(l.ts, l.text) {
+					got = append(got, l.text)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("kept %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// Reopening again resumes after the lines the last reopen added
+	var r resumePoint
+	r.add(sec(1), "tick")
+	r.resume()
+	r.add(sec(1), "tick")
+	r.add(sec(1), "tick")
+	r.resume()
+	if r.add(sec(1), "tick") || r.add(sec(1), "tick") || !r.add(sec(1), "tick") {
+		t.Errorf("second reopen didn't skip exactly the 2 ticks read")
+	}
+}
@@ -0,0 +1,242 @@
+package podlogs
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/thekubefleet/kubefleet/internal/grpcclient"
+	"github.com/thekubefleet/kubefleet/internal/k8s"
+	agentpb "github.com/thekubefleet/kubefleet/proto"
+)
+
+// LogSource reads pods' logs from a cluster. It is implemented by
+// *k8s.Client.
+type LogSource interface {
+	GetPodContainers(ctx context.Context, namespace, podName string) ([]string, error)
+	StreamPodLogLines(ctx context.Context, namespace, podName, containerName string, opts k8s.LogOptions, fn func(k8s.LogLine) error) error
+	PodExists(ctx context.Context, namespace, podName string) (bool, error)
+}
+
+// Tail sends the logs a LogRequest asks for from the cluster k8sClient talks
+// to, following them until ctx is done when req.Follow is set. A final
+// message with IsComplete is sent once every container is done.
+func Tail(ctx context.Context, k8sClient LogSource, req *agentpb.LogRequest, send func(*agentpb.LogStream) error) error {
+	// Get containers for the pod
+	containers, err := k8sClient.GetPodContainers(ctx, req.Namespace, req.PodName)
+	if err != nil {
+		return err
+	}
+
+	if req.ContainerName != "" {
+		// Stream logs for specific container
+		if err := tailContainer(ctx, k8sClient, req, req.ContainerName, send); err != nil {
+			return err
+		}
+	} else if req.Follow && !req.Previous {
+		// Followed containers never finish, so they are tailed side by side
+		var mu sync.Mutex
+		lockedSend := func(logs *agentpb.LogStream) error {
+			mu.Lock()
+			defer mu.Unlock()
+			return send(logs)
+		}
+		var wg sync.WaitGroup
+		for _, containerName := range containers {
+			wg.Add(1)
+			go func(containerName string) {
+				defer wg.Done()
+				if err := tailContainer(ctx, k8sClient, req, containerName, lockedSend); err != nil && ctx.Err() == nil {
+					log.Printf("Error streaming logs for container %s: %v", containerName, err)
+				}
+			}(containerName)
+		}
+		wg.Wait()
+	} else {
+		// If no specific container requested, get logs from all containers
+		for _, containerName := range containers {
+			if err := tailContainer(ctx, k8sClient, req, containerName, send); err != nil {
+				log.Printf("Error streaming logs for container %s: %v", containerName, err)
+			}
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	// Send completion signal
+	return send(&agentpb.LogStream{
+		Logs:       []*agentpb.PodLog{},
+		IsComplete: true,
+	})
+}
+
+const (
+	// Lines are sent in batches of up to batchLines, and whatever has been
+	// read is sent every batchDelay
+	batchLines = 100
+	batchDelay = 250 * time.Millisecond
+
+	// defaultTailLines is used when a request doesn't say how many lines to
+	// start from
+	defaultTailLines = 100
+
+	// Reopening a followed log waits between minRetryDelay and maxRetryDelay,
+	// e.g. while a restarting container is waiting to start
+	minRetryDelay = time.Second
+	maxRetryDelay = 30 * time.Second
+)
+
+// containerTail reads one container's log, keeping track of what it has
+// sent so a followed log can be reopened without repeating or losing lines
+type containerTail struct {
+	k8sClient LogSource
+	namespace string
+	pod       string
+	container string
+	previous  bool
+	send      func(*agentpb.LogStream) error
+	sendErr   error // Set when sending failed, which ends the tail
+	resumePoint
+}
+
+func tailContainer(ctx context.Context, k8sClient LogSource, req *agentpb.LogRequest, containerName string, send func(*agentpb.LogStream) error) error {
+	t := &containerTail{
+		k8sClient: k8sClient,
+		namespace: req.Namespace,
+		pod:       req.PodName,
+		container: containerName,
+		previous:  req.Previous,
+		send:      send,
+	}
+
+	tailLines := int64(req.TailLines)
+	if tailLines <= 0 {
+		tailLines = defaultTailLines
+	}
+
+	// A previous instance's log is complete, so there is nothing to follow
+	follow := req.Follow && !req.Previous
+
+	start := time.Now()
+	opts := k8s.LogOptions{TailLines: tailLines, Follow: follow, Previous: req.Previous}
+	delay := minRetryDelay
+	for {
+		before := t.last
+		err := t.read(ctx, opts)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if t.sendErr != nil {
+			return t.sendErr
+		}
+		if !follow {
+			return err
+		}
+
+		// A followed log ends when the container stops. Unless the pod is
+		// gone, carry on from the last line once it is running again.
+		exists, existsErr := k8sClient.PodExists(ctx, req.Namespace, req.PodName)
+		if existsErr == nil && !exists {
+			return nil
+		}
+		if err != nil {
+			log.Printf("Log stream for pod %s container %s ended: %v", req.PodName, containerName, err)
+		}
+
+		if t.last.After(before) {
+			delay = minRetryDelay
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+
+		since := t.resume()
+		if since.IsZero() {
+			since = start
+		}
+		opts = k8s.LogOptions{SinceTime: since, Follow: true}
+	}
+}
+
+// read sends the lines of one log stream until it ends
+func (t *containerTail) read(ctx context.Context, opts k8s.LogOptions) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Lines are read in the background so a batch can be sent while the
+	// stream waits for more
+	lines := make(chan k8s.LogLine, batchLines)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(lines)
+		readErr <- t.k8sClient.StreamPodLogLines(ctx, t.namespace, t.pod, t.container, opts, func(line k8s.LogLine) error {
+			select {
+			case lines <- line:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+
+	var batch []*agentpb.PodLog
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		t.sendErr = t.send(&agentpb.LogStream{Logs: batch})
+		batch = nil
+		return t.sendErr
+	}
+
+	ticker := time.NewTicker(batchDelay)
+	defer ticker.Stop()
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				if err := flush(); err != nil {
+					return err
+				}
+				return <-readErr
+			}
+			if podLog := t.parse(line); podLog != nil {
+				batch = append(batch, podLog)
+			}
+			if len(batch) >= batchLines {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		case <-ticker.C:
+			if err := flush(); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// parse converts a line, returning nil for lines already sent from a
+// previous stream
+func (t *containerTail) parse(line k8s.LogLine) *agentpb.PodLog {
+	timestamp := line.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	if !t.add(timestamp, line.Text) {
+		return nil
+	}
+	line.Timestamp = timestamp
+	return grpcclient.ConvertLogLine(t.namespace, t.pod, t.container, line, t.previous)
+}
@@ -0,0 +1,199 @@
+package podlogs
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thekubefleet/kubefleet/internal/k8s"
+	agentpb "github.com/thekubefleet/kubefleet/proto"
+)
+
+// fakeSource serves each open of a container's log from the next of its
+// scripted streams. The pod exists until every stream has been read.
+type fakeSource struct {
+	containers []string
+
+	mu      sync.Mutex
+	streams map[string][][]k8s.LogLine
+	opens   map[string][]k8s.LogOptions
+}
+
+func newFakeSource(streams map[string][][]k8s.LogLine) *fakeSource {
+	s := &fakeSource{streams: streams, opens: make(map[string][]k8s.LogOptions)}
+	for container := range streams {
+		s.containers = append(s.containers, container)
+	}
+	return s
+}
+
+func (s *fakeSource) GetPodContainers(ctx context.Context, namespace, podName string) ([]string, error) {
+	return s.containers, nil
+}
+
+func (s *fakeSource) StreamPodLogLines(ctx context.Context, namespace, podName, containerName string, opts k8s.LogOptions, fn func(k8s.LogLine) error) error {
+	s.mu.Lock()
+	s.opens[containerName] = append(s.opens[containerName], opts)
+	streams := s.streams[containerName]
+	if len(streams) == 0 {
+		s.mu.Unlock()
+		return errors.New("container is not running")
+	}
+	lines := streams[0]
+	s.streams[containerName] = streams[1:]
+	s.mu.Unlock()
+
+	for _, line := range lines {
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *fakeSource) PodExists(ctx context.Context, namespace, podName string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, streams := range s.streams {
+		if len(streams) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *fakeSource) openOptions(container string) []k8s.LogOptions {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.opens[container]
+}
+
+// collect runs Tail and returns the lines it sent and whether it completed
+func collect(t *testing.T, source LogSource, req *agentpb.LogRequest) ([]*agentpb.PodLog, bool) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var logs []*agentpb.PodLog
+	complete := false
+	var mu sync.Mutex
+	err := Tail(ctx, source, req, func(s *agentpb.LogStream) error {
+		mu.Lock()
+		defer mu.Unlock()
+		logs = append(logs, s.Logs...)
+		complete = s.IsComplete
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Tail: %v", err)
+	}
+	return logs, complete
+}
+
+func texts(logs []*agentpb.PodLog) []string {
+	var texts []string
+	for _, l := range logs {
+		texts = append(texts, l.LogLine)
+	}
+	return texts
+}
+
+func at(sec int, text string) k8s.LogLine {
+	return k8s.LogLine{Timestamp: time.Date(2024, 5, 1, 10, 0, sec, 0, time.UTC), Text: text}
+}
+
+func TestTail(t *testing.T) {
+	source := newFakeSource(map[string][][]k8s.LogLine{
+		"app": {{at(0, "starting"), at(1, "ready")}},
+	})
+	logs, complete := collect(t, source, &agentpb.LogRequest{Namespace: "default", PodName: "web", ContainerName: "app", TailLines: 20})
+
+	if !complete {
+		t.Errorf("Tail didn't send a final complete message")
+	}
+	if got := texts(logs); !reflect.DeepEqual(got, []string{"starting", "ready"}) {
+		t.Errorf("lines = %q", got)
+	}
+	if logs[1].Timestamp != at(1, "").Timestamp.Unix() || logs[1].ContainerName != "app" || logs[1].Previous {
+		t.Errorf("line = %+v, want the container's timestamp", logs[1])
+	}
+	if opts := source.openOptions("app"); len(opts) != 1 || opts[0] != (k8s.LogOptions{TailLines: 20}) {
+		t.Errorf("log opened with %+v, want the last 20 lines", opts)
+	}
+}
+
+func TestTailPrevious(t *testing.T) {
+	source := newFakeSource(map[string][][]k8s.LogLine{
+		"app":     {{at(0, "panic: out of memory")}},
+		"sidecar": {{at(0, "proxy stopped")}},
+	})
+	logs, complete := collect(t, source, &agentpb.LogRequest{Namespace: "default", PodName: "web", Follow: true, Previous: true})
+
+	if !complete {
+		t.Errorf("Tail didn't send a final complete message")
+	}
+	if len(logs) != 2 {
+		t.Fatalf("got %d lines, want one from each container's previous instance", len(logs))
+	}
+	for _, l := range logs {
+		if !l.Previous {
+			t.Errorf("line %q isn't marked as from the previous instance", l.LogLine)
+		}
+	}
+	// A previous instance's log is complete, so it isn't followed
+	for _, container := range []string{"app", "sidecar"} {
+		opts := source.openOptions(container)
+		if len(opts) != 1 || !opts[0].Previous || opts[0].Follow || opts[0].TailLines != defaultTailLines {
+			t.Errorf("%s log opened with %+v, want the previous instance's last %d lines once", container, opts, defaultTailLines)
+		}
+	}
+}
+
+func TestTailFollowReconnects(t *testing.T) {
+	source := newFakeSource(map[string][][]k8s.LogLine{
+		"app": {
+			{at(0, "starting"), at(1, "tick"), at(1, "tick")},
+			// The container restarted. The reopened stream starts from the
+			// second of the last line, so it repeats the lines sent at 1s.
+			{at(1, "tick"), at(1, "tick"), at(1, "tock"), at(2, "restarted")},
+		},
+	})
+	logs, complete := collect(t, source, &agentpb.LogRequest{Namespace: "default", PodName: "web", ContainerName: "app", Follow: true})
+
+	if !complete {
+		t.Errorf("Tail didn't complete once the pod was gone")
+	}
+	if got := texts(logs); !reflect.DeepEqual(got, []string{"starting", "tick", "tick", "tock", "restarted"}) {
+		t.Errorf("lines = %q, want each line once", got)
+	}
+
+	opts := source.openOptions("app")
+	if len(opts) != 2 {
+		t.Fatalf("log opened %d times, want 2", len(opts))
+	}
+	if !opts[0].Follow || opts[0].TailLines != defaultTailLines {
+		t.Errorf("first open = %+v, want to follow from the last %d lines", opts[0], defaultTailLines)
+	}
+	if want := (k8s.LogOptions{SinceTime: at(1, "").Timestamp, Follow: true}); opts[1] != want {
+		t.Errorf("reopened with %+v, want %+v", opts[1], want)
+	}
+}
+
+func TestTailSendError(t *testing.T) {
+	source := newFakeSource(map[string][][]k8s.LogLine{
+		"app": {{at(0, "line")}, {at(1, "never read")}},
+	})
+	errClosed := errors.New("caller went away")
+	err := Tail(context.Background(), source, &agentpb.LogRequest{Namespace: "default", PodName: "web", ContainerName: "app", Follow: true}, func(*agentpb.LogStream) error {
+		return errClosed
+	})
+	if !errors.Is(err, errClosed) {
+		t.Errorf("Tail returned %v, want the send error", err)
+	}
+	if opts := source.openOptions("app"); len(opts) != 1 {
+		t.Errorf("log reopened after sending failed")
+	}
+}
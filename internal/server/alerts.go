@@ -0,0 +1,125 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/thekubefleet/kubefleet/internal/alerting"
+)
+
+// Page sizes of /api/alerts/history
+const (
+	defaultAlertHistoryLimit = 100
+	maxAlertHistoryLimit     = 1000
+)
+
+// WithAlerts exposes the alerts of an alerting engine at /api/alerts and
+// their history at /api/alerts/history
+func WithAlerts(alerts *alerting.Engine) HTTPServerOption {
+	return func(s *HTTPServer) {
+		s.alerts = alerts
+	}
+}
+
+func (s *HTTPServer) handleGetAlerts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if s.alerts == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Alerting is not enabled"})
+		return
+	}
+
+	// Firing alerts by default; state=all adds pending and recently resolved ones
+	state := alerting.StateFiring
+	switch value := r.URL.Query().Get("state"); value {
+	case "", alerting.StateFiring:
+	case "all":
+		state = ""
+	case alerting.StatePending, alerting.StateResolved:
+		state = value
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "state must be pending, firing, resolved or all"})
+		return
+	}
+
+	alerts := s.alerts.Alerts(s.scope(r), state)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"alerts": alerts,
+		"count":  len(alerts),
+	})
+}
+
+// parseAlertHistoryQuery reads the query parameters of /api/alerts/history
+func parseAlertHistoryQuery(query url.Values) (alerting.HistoryQuery, error) {
+	q := alerting.HistoryQuery{
+		AlertID:   query.Get("id"),
+		Rule:      query.Get("rule"),
+		Cluster:   query.Get("cluster"),
+		Namespace: query.Get("namespace"),
+		State:     query.Get("state"),
+		Limit:     defaultAlertHistoryLimit,
+	}
+
+	switch q.State {
+	case "", alerting.StatePending, alerting.StateFiring, alerting.StateResolved:
+	default:
+		return q, fmt.Errorf("state must be pending, firing or resolved")
+	}
+
+	if value := query.Get("from"); value != "" {
+		from, err := parseTime(value)
+		if err != nil {
+			return q, fmt.Errorf("invalid from: %w", err)
+		}
+		q.From = time.Unix(from, 0)
+	}
+	if value := query.Get("to"); value != "" {
+		to, err := parseTime(value)
+		if err != nil {
+			return q, fmt.Errorf("invalid to: %w", err)
+		}
+		q.To = time.Unix(to, 0)
+	}
+	if !q.To.IsZero() && q.From.After(q.To) {
+		return q, fmt.Errorf("from must not be after to")
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return q, fmt.Errorf("invalid limit %q", value)
+		}
+		q.Limit = min(limit, maxAlertHistoryLimit)
+	}
+	return q, nil
+}
+
+func (s *HTTPServer) handleGetAlertHistory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if s.alerts == nil || s.alerts.History() == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Alerting is not enabled"})
+		return
+	}
+
+	q, err := parseAlertHistoryQuery(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	q.Scope = s.scope(r)
+
+	events := s.alerts.History().Query(q)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"events": events,
+		"count":  len(events),
+	})
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/thekubefleet/kubefleet/internal/auth"
+	agentpb "github.com/thekubefleet/kubefleet/proto"
+)
+
+// scope returns what the caller may see, or nil when authorization is disabled
+func (s *HTTPServer) scope(r *http.Request) *auth.Scope {
+	if s.authorizer == nil {
+		return nil
+	}
+	identity, _ := auth.IdentityFromContext(r.Context())
+	return s.authorizer.Scope(identity)
+}
+
+// latestData returns the latest data point for the requested cluster,
+// filtered to the caller's scope. Without a cluster it picks the most recent
+// data point among the clusters the caller may see.
+func (s *HTTPServer) latestData(r *http.Request) *agentpb.AgentData {
+	scope := s.scope(r)
+	cluster := r.URL.Query().Get("cluster")
+	if cluster != "" || scope == nil {
+		return filterAgentData(scope, s.dataStore.GetLatestData(cluster))
+	}
+
+	var latest *agentpb.AgentData
+	for _, c := range s.dataStore.GetClusters() {
+		if !scope.AllowsCluster(c.Name) {
+			continue
+		}
+		data := s.dataStore.GetLatestData(c.Name)
+		if data != nil && (latest == nil || data.Timestamp > latest.Timestamp) {
+			latest = data
+		}
+	}
+	return filterAgentData(scope, latest)
+}
+
+// filterAgentData returns a copy of data holding only the resources,
+// metrics, logs and events in namespaces the scope allows, or nil if the
+// whole cluster is out of scope
+func filterAgentData(scope *auth.Scope, data *agentpb.AgentData) *agentpb.AgentData {
+	if scope == nil || data == nil {
+		return data
+	}
+
+	cluster := ClusterKey(data.Identity)
+	if !scope.AllowsCluster(cluster) {
+		return nil
+	}
+
+	filtered := proto.Clone(data).(*agentpb.AgentData)
+	filtered.Resources = slices.DeleteFunc(filtered.Resources, func(resource *agentpb.ResourceInfo) bool {
+		return !scope.Allows(cluster, resource.Namespace)
+	})
+	filtered.Metrics = slices.DeleteFunc(filtered.Metrics, func(metric *agentpb.ResourceMetrics) bool {
+		return !scope.Allows(cluster, metric.Namespace)
+	})
+	filtered.Logs = slices.DeleteFunc(filtered.Logs, func(log *agentpb.PodLog) bool {
+		return !scope.Allows(cluster, log.Namespace)
+	})
+	filtered.Events = slices.DeleteFunc(filtered.Events, func(event *agentpb.Event) bool {
+		return !scope.Allows(cluster, event.Namespace)
+	})
+	return filtered
+}
+
+// filterAllData filters every data point, dropping those out of scope
+func filterAllData(scope *auth.Scope, data []*agentpb.AgentData) []*agentpb.AgentData {
+	if scope == nil {
+		return data
+	}
+
+	filtered := make([]*agentpb.AgentData, 0, len(data))
+	for _, d := range data {
+		if f := filterAgentData(scope, d); f != nil {
+			filtered = append(filtered, f)
+		}
+	}
+	return filtered
+}
+
+// filterLogs returns the logs in namespaces of the cluster the scope allows
+func filterLogs(scope *auth.Scope, cluster string, logs []*agentpb.PodLog) []*agentpb.PodLog {
+	if scope == nil {
+		return logs
+	}
+
+	var filtered []*agentpb.PodLog
+	for _, log := range logs {
+		if scope.Allows(cluster, log.Namespace) {
+			filtered = append(filtered, log)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,385 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/thekubefleet/kubefleet/internal/boltdb"
+	agentpb "github.com/thekubefleet/kubefleet/proto"
+)
+
+var (
+	metaBucket     = []byte("meta")
+	clustersBucket = []byte("clusters")
+	dataBucket     = []byte("data")
+
+	schemaVersionKey = []byte("schema_version")
+)
+
+// migrations upgrade the on-disk layout one version at a time. migrations[i]
+// moves a database from schema version i to i+1. Append new steps here when
+// the layout changes; existing steps must never be edited.
+var migrations = []func(tx *bolt.Tx) error{
+	// 0 -> 1: initial layout. "clusters" maps a cluster key to its
+	// clusterRecord, "data" holds one bucket per cluster keyed by dataKey.
+	func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{clustersBucket, dataBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+type clusterRecord struct {
+	Identity []byte    `json:"identity"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+// BoltStore is a DataStore persisted in an embedded bbolt database, so
+// history survives server restarts
+type BoltStore struct {
+	db        *bolt.DB
+	retention Retention
+	stop      context.CancelFunc
+}
+
+// NewBoltStore opens or creates the database at path, migrates it to the
+// current schema and starts enforcing retention every interval
+func NewBoltStore(path string, retention Retention, interval time.Duration) (*BoltStore, error) {
+	db, err := boltdb.Open(path, "store")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &BoltStore{db: db, retention: retention}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	boltdb.EnforceRetention("store", s)
+
+	ctx, stop := context.WithCancel(context.Background())
+	s.stop = stop
+	go boltdb.RunRetention(ctx, interval, "store", s)
+	return s, nil
+}
+
+// migrate applies any migrations the database hasn't seen yet
+func (s *BoltStore) migrate() error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return err
+		}
+
+		version := uint64(0)
+		if v := meta.Get(schemaVersionKey); v != nil {
+			version = boltdb.ID(v)
+		}
+		if version > uint64(len(migrations)) {
+			return fmt.Errorf("store schema version %d is newer than supported version %d", version, len(migrations))
+		}
+
+		for ; version < uint64(len(migrations)); version++ {
+			if err := migrations[version](tx); err != nil {
+				return fmt.Errorf("failed to migrate store to schema version %d: %w", version+1, err)
+			}
+			log.Printf("Migrated store to schema version %d", version+1)
+		}
+
+		return meta.Put(schemaVersionKey, boltdb.Key(version))
+	})
+}
+
+// dataKey orders data points by timestamp, using the bucket sequence to
+// keep points with the same timestamp unique
+func dataKey(timestamp int64, seq uint64) []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key, uint64(timestamp))
+	binary.BigEndian.PutUint64(key[8:], seq)
+	return key
+}
+
+func keyTimestamp(key []byte) int64 {
+	return int64(binary.BigEndian.Uint64(key))
+}
+
+func (s *BoltStore) StoreAgentData(data *agentpb.AgentData) error {
+	// Add timestamp if not present
+	if data.Timestamp == 0 {
+		data.Timestamp = time.Now().Unix()
+	}
+
+	value, err := proto.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode agent data: %w", err)
+	}
+
+	key := ClusterKey(data.Identity)
+	return s.db.Update(func(tx *bolt.Tx) error {
+		clusters := tx.Bucket(clustersBucket)
+
+		var record clusterRecord
+		if existing := clusters.Get([]byte(key)); existing != nil {
+			if err := json.Unmarshal(existing, &record); err != nil {
+				return fmt.Errorf("failed to decode cluster %s: %w", key, err)
+			}
+		}
+		if data.Identity != nil {
+			identity, err := proto.Marshal(data.Identity)
+			if err != nil {
+				return fmt.Errorf("failed to encode identity: %w", err)
+			}
+			record.Identity = identity
+		}
+		record.LastSeen = time.Now()
+		encoded, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		if err := clusters.Put([]byte(key), encoded); err != nil {
+			return err
+		}
+
+		bucket, err := tx.Bucket(dataBucket).CreateBucketIfNotExists([]byte(key))
+		if err != nil {
+			return err
+		}
+		seq, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		return bucket.Put(dataKey(data.Timestamp, seq), value)
+	})
+}
+
+func decodeAgentData(value []byte) (*agentpb.AgentData, bool) {
+	data := &agentpb.AgentData{}
+	if err := proto.Unmarshal(value, data); err != nil {
+		log.Printf("Failed to decode stored agent data: %v", err)
+		return nil, false
+	}
+	return data, true
+}
+
+func (s *BoltStore) GetLatestData(cluster string) *agentpb.AgentData {
+	var latest *agentpb.AgentData
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var latestKey []byte
+		return tx.Bucket(dataBucket).ForEachBucket(func(name []byte) error {
+			if cluster != "" && string(name) != cluster {
+				return nil
+			}
+			key, value := tx.Bucket(dataBucket).Bucket(name).Cursor().Last()
+			if key == nil {
+				return nil
+			}
+			if latestKey == nil || keyTimestamp(key) > keyTimestamp(latestKey) {
+				if data, ok := decodeAgentData(value); ok {
+					latestKey, latest = key, data
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		log.Printf("Failed to read latest data: %v", err)
+	}
+	return latest
+}
+
+func (s *BoltStore) GetAllData(cluster string) []*agentpb.AgentData {
+	result := make([]*agentpb.AgentData, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(dataBucket)
+		return data.ForEachBucket(func(name []byte) error {
+			if cluster != "" && string(name) != cluster {
+				return nil
+			}
+			return data.Bucket(name).ForEach(func(key, value []byte) error {
+				if d, ok := decodeAgentData(value); ok {
+					result = append(result, d)
+				}
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		log.Printf("Failed to read data: %v", err)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Timestamp < result[j].Timestamp
+	})
+	return result
+}
+
+func (s *BoltStore) GetDataRange(cluster string, from, to int64) []*agentpb.AgentData {
+	result := make([]*agentpb.AgentData, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(dataBucket)
+		return data.ForEachBucket(func(name []byte) error {
+			if cluster != "" && string(name) != cluster {
+				return nil
+			}
+			// Keys sort by timestamp, so seek straight to the start of the window
+			c := data.Bucket(name).Cursor()
+			for key, value := c.Seek(dataKey(from, 0)); key != nil && keyTimestamp(key) <= to; key, value = c.Next() {
+				if d, ok := decodeAgentData(value); ok {
+					result = append(result, d)
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		log.Printf("Failed to read data range: %v", err)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Timestamp < result[j].Timestamp
+	})
+	return result
+}
+
+func (s *BoltStore) GetClusters() []ClusterInfo {
+	clusters := make([]ClusterInfo, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(dataBucket)
+		return tx.Bucket(clustersBucket).ForEach(func(key, value []byte) error {
+			var record clusterRecord
+			if err := json.Unmarshal(value, &record); err != nil {
+				return fmt.Errorf("failed to decode cluster %s: %w", key, err)
+			}
+			identity := &agentpb.AgentIdentity{}
+			if err := proto.Unmarshal(record.Identity, identity); err != nil {
+				return fmt.Errorf("failed to decode identity of cluster %s: %w", key, err)
+			}
+
+			dataPoints := 0
+			if bucket := data.Bucket(key); bucket != nil {
+				dataPoints = bucket.Stats().KeyN
+			}
+			clusters = append(clusters, clusterInfo(string(key), identity, record.LastSeen, dataPoints))
+			return nil
+		})
+	})
+	if err != nil {
+		log.Printf("Failed to read clusters: %v", err)
+	}
+
+	sortClusters(clusters)
+	return clusters
+}
+
+func (s *BoltStore) GetDataCount() int {
+	count := 0
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(dataBucket)
+		return data.ForEachBucket(func(name []byte) error {
+			count += data.Bucket(name).Stats().KeyN
+			return nil
+		})
+	})
+	if err != nil {
+		log.Printf("Failed to count data: %v", err)
+	}
+	return count
+}
+
+// GetStoreSize returns the size of the database file
+func (s *BoltStore) GetStoreSize() int64 {
+	var size int64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		size = tx.Size()
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to read store size: %v", err)
+	}
+	return size
+}
+
+// EnforceRetention deletes the oldest data points until the store is
+// within its retention limits
+func (s *BoltStore) EnforceRetention() error {
+	if s.retention == (Retention{}) {
+		return nil
+	}
+
+	cutoff := int64(0)
+	if s.retention.MaxAge > 0 {
+		cutoff = time.Now().Add(-s.retention.MaxAge).Unix()
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		type entry struct {
+			cluster string
+			key     []byte
+			size    int64
+		}
+
+		data := tx.Bucket(dataBucket)
+		var entries []entry
+		var total int64
+		counts := make(map[string]int)
+		err := data.ForEachBucket(func(name []byte) error {
+			return data.Bucket(name).ForEach(func(key, value []byte) error {
+				entries = append(entries, entry{
+					cluster: string(name),
+					key:     bytes.Clone(key),
+					size:    int64(len(value)),
+				})
+				total += int64(len(value))
+				counts[string(name)]++
+				return nil
+			})
+		})
+		if err != nil {
+			return err
+		}
+
+		// Oldest first across all clusters
+		sort.SliceStable(entries, func(i, j int) bool {
+			return keyTimestamp(entries[i].key) < keyTimestamp(entries[j].key)
+		})
+
+		deleted := 0
+		for _, e := range entries {
+			expired := cutoff > 0 && keyTimestamp(e.key) < cutoff
+			overSize := s.retention.MaxBytes > 0 && total > s.retention.MaxBytes
+			overCount := s.retention.MaxDataPoints > 0 && counts[e.cluster] > s.retention.MaxDataPoints
+			if !expired && !overSize && !overCount {
+				continue
+			}
+
+			if err := data.Bucket([]byte(e.cluster)).Delete(e.key); err != nil {
+				return err
+			}
+			total -= e.size
+			counts[e.cluster]--
+			deleted++
+		}
+
+		if deleted > 0 {
+			log.Printf("Retention removed %d data points from the store", deleted)
+		}
+		return nil
+	})
+}
+
+func (s *BoltStore) Close() error {
+	s.stop()
+	return s.db.Close()
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	agentpb "github.com/thekubefleet/kubefleet/proto"
+)
+
+// commandTimeout bounds how long a command request waits for the agent
+const commandTimeout = 30 * time.Second
+
+// WithAgentStreams lets API callers send commands to agents connected over
+// Connect streams, and reports which agents are connected
+func WithAgentStreams(streams *AgentStreams) HTTPServerOption {
+	return func(s *HTTPServer) {
+		s.streams = streams
+	}
+}
+
+// commandRequest is the body of POST /api/agents/{cluster}/commands
+type commandRequest struct {
+	Type            string `json:"type"` // setInterval, resync or fetchLogs
+	IntervalSeconds int64  `json:"intervalSeconds"`
+	Namespace       string `json:"namespace"`
+	Pod             string `json:"pod"`
+	Container       string `json:"container"`
+	TailLines       int32  `json:"tailLines"`
+	Previous        bool   `json:"previous"`
+}
+
+func (s *HTTPServer) handlePostAgentCommand(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	cluster := mux.Vars(r)["cluster"]
+
+	var req commandRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
+		return
+	}
+
+	// Commands that affect the whole agent need access to every namespace
+	// of the cluster; fetching logs needs access to the pod's namespace
+	scope := s.scope(r)
+	cmd := &agentpb.Command{}
+	allowed := scope.AllowsAllNamespaces(cluster)
+	switch req.Type {
+	case "setInterval":
+		if req.IntervalSeconds <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "intervalSeconds must be positive"})
+			return
+		}
+		cmd.Action = &agentpb.Command_SetReportInterval{SetReportInterval: &agentpb.SetReportInterval{IntervalSeconds: req.IntervalSeconds}}
+	case "resync":
+		cmd.Action = &agentpb.Command_Resync{Resync: &agentpb.RequestResync{}}
+	case "fetchLogs":
+		if req.Namespace == "" || req.Pod == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "namespace and pod are required"})
+			return
+		}
+		allowed = scope.Allows(cluster, req.Namespace)
+		cmd.Action = &agentpb.Command_FetchLogs{FetchLogs: &agentpb.LogRequest{
+			Namespace:     req.Namespace,
+			PodName:       req.Pod,
+			ContainerName: req.Container,
+			TailLines:     req.TailLines,
+			Previous:      req.Previous,
+		}}
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "type must be one of setInterval, resync or fetchLogs"})
+		return
+	}
+
+	if !allowed {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Forbidden"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), commandTimeout)
+	defer cancel()
+
+	result, err := s.streams.SendCommand(ctx, cluster, cmd)
+	switch {
+	case errors.Is(err, ErrAgentNotConnected):
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Agent is not connected"})
+		return
+	case errors.Is(err, ErrAgentBusy):
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Agent has too many pending commands"})
+		return
+	case errors.Is(err, context.DeadlineExceeded):
+		w.WriteHeader(http.StatusGatewayTimeout)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Agent did not respond in time"})
+		return
+	case err != nil:
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	if !result.Success {
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	response := map[string]interface{}{
+		"success": result.Success,
+		"message": result.Message,
+	}
+	if req.Type == "fetchLogs" {
+		response["logs"] = result.Logs
+		response["count"] = len(result.Logs)
+	}
+	json.NewEncoder(w).Encode(response)
+}
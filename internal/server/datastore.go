@@ -1,26 +1,98 @@
 package server
 
 import (
+	"sort"
 	"sync"
 	"time"
 
+	"google.golang.org/protobuf/proto"
+
 	agentpb "github.com/thekubefleet/kubefleet/proto"
 )
 
-type DataStore struct {
-	mu            sync.RWMutex
-	agentData     []*agentpb.AgentData
-	maxDataPoints int
+// DefaultCluster is the cluster key used for agents that don't identify themselves
+const DefaultCluster = "default"
+
+// ClusterInfo describes a cluster that has reported to the server
+type ClusterInfo struct {
+	Name         string    `json:"name"`
+	UID          string    `json:"uid"`
+	AgentID      string    `json:"agentId"`
+	AgentVersion string    `json:"agentVersion"`
+	Hostname     string    `json:"hostname"`
+	LastSeen     time.Time `json:"lastSeen"`
+	DataPoints   int       `json:"dataPoints"`
+}
+
+// DataStore keeps the history of agent data per cluster
+type DataStore interface {
+	// StoreAgentData appends a data point to its cluster's history
+	StoreAgentData(data *agentpb.AgentData) error
+	// GetLatestData returns the most recent data point for a cluster. An
+	// empty cluster returns the most recent data point across all clusters.
+	GetLatestData(cluster string) *agentpb.AgentData
+	// GetAllData returns the stored history for a cluster. An empty cluster
+	// returns the history of all clusters ordered by timestamp.
+	GetAllData(cluster string) []*agentpb.AgentData
+	// GetDataRange returns the data points with from <= timestamp <= to,
+	// ordered by timestamp. An empty cluster covers all clusters.
+	GetDataRange(cluster string, from, to int64) []*agentpb.AgentData
+	// GetClusters returns all known clusters sorted by name
+	GetClusters() []ClusterInfo
+	// GetDataCount returns the number of stored data points
+	GetDataCount() int
+	// GetStoreSize returns the size of the stored data in bytes
+	GetStoreSize() int64
+	// Close releases the store's resources
+	Close() error
 }
 
-func NewDataStore() *DataStore {
-	return &DataStore{
-		agentData:     make([]*agentpb.AgentData, 0),
-		maxDataPoints: 100, // Keep last 100 data points
+// Retention limits how much history a store keeps. Zero values are unlimited.
+type Retention struct {
+	// MaxAge drops data points older than this
+	MaxAge time.Duration
+	// MaxBytes caps the encoded size of all data points, dropping the oldest first
+	MaxBytes int64
+	// MaxDataPoints caps the number of data points kept per cluster
+	MaxDataPoints int
+}
+
+// ClusterKey returns the key a cluster's data is stored under: its name,
+// falling back to its UID and then to DefaultCluster
+func ClusterKey(identity *agentpb.AgentIdentity) string {
+	if identity.GetClusterName() != "" {
+		return identity.GetClusterName()
 	}
+	if identity.GetClusterUid() != "" {
+		return identity.GetClusterUid()
+	}
+	return DefaultCluster
 }
 
-func (ds *DataStore) StoreAgentData(data *agentpb.AgentData) {
+type clusterData struct {
+	identity  *agentpb.AgentIdentity
+	agentData []*agentpb.AgentData
+	sizes     []int
+	lastSeen  time.Time
+}
+
+// MemoryStore is a DataStore that keeps history in memory only
+type MemoryStore struct {
+	mu        sync.RWMutex
+	clusters  map[string]*clusterData
+	retention Retention
+	size      int64
+}
+
+// NewMemoryStore creates an in-memory store with the given retention
+func NewMemoryStore(retention Retention) *MemoryStore {
+	return &MemoryStore{
+		clusters:  make(map[string]*clusterData),
+		retention: retention,
+	}
+}
+
+func (ds *MemoryStore) StoreAgentData(data *agentpb.AgentData) error {
 	ds.mu.Lock()
 	defer ds.mu.Unlock()
 
@@ -29,38 +101,208 @@ func (ds *DataStore) StoreAgentData(data *agentpb.AgentData) {
 		data.Timestamp = time.Now().Unix()
 	}
 
+	key := ClusterKey(data.Identity)
+	cluster, ok := ds.clusters[key]
+	if !ok {
+		cluster = &clusterData{agentData: make([]*agentpb.AgentData, 0)}
+		ds.clusters[key] = cluster
+	}
+
 	// Add new data
-	ds.agentData = append(ds.agentData, data)
+	if data.Identity != nil {
+		cluster.identity = data.Identity
+	}
+	size := proto.Size(data)
+	cluster.lastSeen = time.Now()
+	ds.size += int64(size)
+
+	// Keep the history ordered by timestamp so range queries can binary
+	// search it; data points normally arrive in order and are appended
+	i := len(cluster.agentData)
+	if i > 0 && cluster.agentData[i-1].Timestamp > data.Timestamp {
+		i = sort.Search(len(cluster.agentData), func(j int) bool {
+			return cluster.agentData[j].Timestamp > data.Timestamp
+		})
+	}
+	cluster.agentData = append(cluster.agentData, nil)
+	copy(cluster.agentData[i+1:], cluster.agentData[i:])
+	cluster.agentData[i] = data
+	cluster.sizes = append(cluster.sizes, 0)
+	copy(cluster.sizes[i+1:], cluster.sizes[i:])
+	cluster.sizes[i] = size
 
-	// Keep only the last maxDataPoints
-	if len(ds.agentData) > ds.maxDataPoints {
-		ds.agentData = ds.agentData[len(ds.agentData)-ds.maxDataPoints:]
+	ds.enforceRetention()
+	return nil
+}
+
+// dropOldest removes the first n data points of a cluster. Callers must
+// hold ds.mu.
+func (ds *MemoryStore) dropOldest(cluster *clusterData, n int) {
+	for _, size := range cluster.sizes[:n] {
+		ds.size -= int64(size)
 	}
+	cluster.agentData = cluster.agentData[n:]
+	cluster.sizes = cluster.sizes[n:]
 }
 
-func (ds *DataStore) GetLatestData() *agentpb.AgentData {
+// enforceRetention drops data points outside the retention limits. Callers
+// must hold ds.mu.
+func (ds *MemoryStore) enforceRetention() {
+	cutoff := int64(0)
+	if ds.retention.MaxAge > 0 {
+		cutoff = time.Now().Add(-ds.retention.MaxAge).Unix()
+	}
+
+	for _, cluster := range ds.clusters {
+		// Keep only the last MaxDataPoints
+		if max := ds.retention.MaxDataPoints; max > 0 && len(cluster.agentData) > max {
+			ds.dropOldest(cluster, len(cluster.agentData)-max)
+		}
+
+		expired := 0
+		for expired < len(cluster.agentData) && cluster.agentData[expired].Timestamp < cutoff {
+			expired++
+		}
+		ds.dropOldest(cluster, expired)
+	}
+
+	// Drop the oldest data point across all clusters until under MaxBytes
+	for ds.retention.MaxBytes > 0 && ds.size > ds.retention.MaxBytes {
+		var oldest *clusterData
+		for _, cluster := range ds.clusters {
+			if len(cluster.agentData) == 0 {
+				continue
+			}
+			if oldest == nil || cluster.agentData[0].Timestamp < oldest.agentData[0].Timestamp {
+				oldest = cluster
+			}
+		}
+		if oldest == nil {
+			break
+		}
+		ds.dropOldest(oldest, 1)
+	}
+}
+
+func (ds *MemoryStore) GetLatestData(cluster string) *agentpb.AgentData {
 	ds.mu.RLock()
 	defer ds.mu.RUnlock()
 
-	if len(ds.agentData) == 0 {
-		return nil
+	var latest *agentpb.AgentData
+	for key, c := range ds.clusters {
+		if cluster != "" && key != cluster {
+			continue
+		}
+		if len(c.agentData) == 0 {
+			continue
+		}
+		last := c.agentData[len(c.agentData)-1]
+		if latest == nil || last.Timestamp > latest.Timestamp {
+			latest = last
+		}
 	}
 
-	return ds.agentData[len(ds.agentData)-1]
+	return latest
 }
 
-func (ds *DataStore) GetAllData() []*agentpb.AgentData {
+func (ds *MemoryStore) GetAllData(cluster string) []*agentpb.AgentData {
 	ds.mu.RLock()
 	defer ds.mu.RUnlock()
 
 	// Return a copy to avoid race conditions
-	result := make([]*agentpb.AgentData, len(ds.agentData))
-	copy(result, ds.agentData)
+	if cluster != "" {
+		c, ok := ds.clusters[cluster]
+		if !ok {
+			return []*agentpb.AgentData{}
+		}
+		result := make([]*agentpb.AgentData, len(c.agentData))
+		copy(result, c.agentData)
+		return result
+	}
+
+	result := make([]*agentpb.AgentData, 0)
+	for _, c := range ds.clusters {
+		result = append(result, c.agentData...)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Timestamp < result[j].Timestamp
+	})
 	return result
 }
 
-func (ds *DataStore) GetDataCount() int {
+func (ds *MemoryStore) GetDataRange(cluster string, from, to int64) []*agentpb.AgentData {
 	ds.mu.RLock()
 	defer ds.mu.RUnlock()
-	return len(ds.agentData)
+
+	result := make([]*agentpb.AgentData, 0)
+	for key, c := range ds.clusters {
+		if cluster != "" && key != cluster {
+			continue
+		}
+		start := sort.Search(len(c.agentData), func(i int) bool {
+			return c.agentData[i].Timestamp >= from
+		})
+		end := sort.Search(len(c.agentData), func(i int) bool {
+			return c.agentData[i].Timestamp > to
+		})
+		if start < end {
+			result = append(result, c.agentData[start:end]...)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Timestamp < result[j].Timestamp
+	})
+	return result
+}
+
+func (ds *MemoryStore) GetClusters() []ClusterInfo {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	clusters := make([]ClusterInfo, 0, len(ds.clusters))
+	for key, c := range ds.clusters {
+		clusters = append(clusters, clusterInfo(key, c.identity, c.lastSeen, len(c.agentData)))
+	}
+	sortClusters(clusters)
+	return clusters
+}
+
+func (ds *MemoryStore) GetDataCount() int {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	count := 0
+	for _, c := range ds.clusters {
+		count += len(c.agentData)
+	}
+	return count
+}
+
+func (ds *MemoryStore) GetStoreSize() int64 {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	return ds.size
+}
+
+func (ds *MemoryStore) Close() error {
+	return nil
+}
+
+func clusterInfo(key string, identity *agentpb.AgentIdentity, lastSeen time.Time, dataPoints int) ClusterInfo {
+	return ClusterInfo{
+		Name:         key,
+		UID:          identity.GetClusterUid(),
+		AgentID:      identity.GetAgentId(),
+		AgentVersion: identity.GetAgentVersion(),
+		Hostname:     identity.GetHostname(),
+		LastSeen:     lastSeen,
+		DataPoints:   dataPoints,
+	}
+}
+
+func sortClusters(clusters []ClusterInfo) {
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i].Name < clusters[j].Name
+	})
 }
@@ -0,0 +1,273 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	agentpb "github.com/thekubefleet/kubefleet/proto"
+)
+
+// DeltaProtocolVersion is the delta report protocol version this server speaks
+const DeltaProtocolVersion = 1
+
+// LogTailLines is how many recent log lines per container a snapshot holds,
+// matching what agents sending full snapshots include
+const LogTailLines = 50
+
+// ResyncError is returned when a delta can't be applied and the agent must
+// send a baseline
+type ResyncError struct {
+	Reason string
+}
+
+func (e *ResyncError) Error() string {
+	return "resync required: " + e.Reason
+}
+
+type metricKey struct {
+	namespace string
+	kind      string
+	name      string
+}
+
+type containerKey struct {
+	namespace string
+	pod       string
+	container string
+	previous  bool
+}
+
+type namespaceView struct {
+	pods        map[string]bool
+	podInfos    map[string]*agentpb.PodInfo // Empty for agents that only send names
+	deployments map[string]bool
+}
+
+// clusterView is the server's copy of an agent's current state
+type clusterView struct {
+	sessionID  string
+	sequence   uint64
+	namespaces map[string]*namespaceView
+	metrics    map[metricKey]*agentpb.ResourceMetrics
+	logs       map[containerKey][]*agentpb.PodLog
+}
+
+// DeltaViews rebuilds full snapshots from agents' delta reports
+type DeltaViews struct {
+	mu    sync.Mutex
+	views map[string]*clusterView
+}
+
+// NewDeltaViews creates an empty set of views
+func NewDeltaViews() *DeltaViews {
+	return &DeltaViews{views: make(map[string]*clusterView)}
+}
+
+// Apply updates the reporting cluster's view and returns the resulting full
+// snapshot. It returns a *ResyncError when the report doesn't follow on from
+// the view, e.g. after a missed report or a server restart.
+func (d *DeltaViews) Apply(report *agentpb.DeltaReport) (*agentpb.AgentData, error) {
+	if report.ProtocolVersion != DeltaProtocolVersion {
+		return nil, fmt.Errorf("unsupported delta protocol version %d", report.ProtocolVersion)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	cluster := ClusterKey(report.Identity)
+	view := d.views[cluster]
+
+	if report.Baseline {
+		view = &clusterView{
+			namespaces: make(map[string]*namespaceView),
+			metrics:    make(map[metricKey]*agentpb.ResourceMetrics),
+			logs:       make(map[containerKey][]*agentpb.PodLog),
+		}
+		for _, resource := range report.Resources {
+			ns := view.namespace(resource.Namespace)
+			for _, pod := range resource.Pods {
+				ns.pods[pod] = true
+			}
+			for _, pod := range resource.PodInfos {
+				ns.podInfos[pod.Name] = pod
+			}
+			for _, deployment := range resource.Deployments {
+				ns.deployments[deployment] = true
+			}
+		}
+	} else {
+		switch {
+		case view == nil:
+			return nil, &ResyncError{Reason: "no baseline for cluster " + cluster}
+		case view.sessionID != report.SessionId:
+			return nil, &ResyncError{Reason: "agent session changed"}
+		case report.Sequence != view.sequence+1:
+			return nil, &ResyncError{Reason: fmt.Sprintf("expected sequence %d, got %d", view.sequence+1, report.Sequence)}
+		}
+		view.applyResourceDeltas(report.ResourceDeltas)
+		for _, removed := range report.RemovedMetrics {
+			delete(view.metrics, metricKey{removed.Namespace, removed.Kind, removed.Name})
+		}
+	}
+
+	view.sessionID = report.SessionId
+	view.sequence = report.Sequence
+	for _, metric := range report.Metrics {
+		view.metrics[metricKey{metric.Namespace, metric.Kind, metric.Name}] = metric
+	}
+	view.appendLogs(report.Logs)
+	d.views[cluster] = view
+
+	// Events aren't part of the view; each is stored as it arrives
+	data := view.snapshot(report.Identity, report.Timestamp)
+	data.Events = report.Events
+	return data, nil
+}
+
+func (v *clusterView) namespace(name string) *namespaceView {
+	ns, ok := v.namespaces[name]
+	if !ok {
+		ns = &namespaceView{
+			pods:        make(map[string]bool),
+			podInfos:    make(map[string]*agentpb.PodInfo),
+			deployments: make(map[string]bool),
+		}
+		v.namespaces[name] = ns
+	}
+	return ns
+}
+
+func (v *clusterView) applyResourceDeltas(deltas []*agentpb.ResourceDelta) {
+	for _, delta := range deltas {
+		if delta.Removed {
+			delete(v.namespaces, delta.Namespace)
+			for key := range v.metrics {
+				if key.namespace == delta.Namespace {
+					delete(v.metrics, key)
+				}
+			}
+			for key := range v.logs {
+				if key.namespace == delta.Namespace {
+					delete(v.logs, key)
+				}
+			}
+			continue
+		}
+
+		ns := v.namespace(delta.Namespace)
+		for _, pod := range delta.AddedPods {
+			ns.pods[pod] = true
+		}
+		for _, pod := range delta.ChangedPods {
+			ns.podInfos[pod.Name] = pod
+		}
+		for _, pod := range delta.RemovedPods {
+			delete(ns.pods, pod)
+			delete(ns.podInfos, pod)
+			for key := range v.logs {
+				if key.namespace == delta.Namespace && key.pod == pod {
+					delete(v.logs, key)
+				}
+			}
+		}
+		for _, deployment := range delta.AddedDeployments {
+			ns.deployments[deployment] = true
+		}
+		for _, deployment := range delta.RemovedDeployments {
+			delete(ns.deployments, deployment)
+		}
+	}
+}
+
+// appendLogs adds new lines to each container's tail, keeping the last
+// LogTailLines of the current and the previous instance
+func (v *clusterView) appendLogs(logs []*agentpb.PodLog) {
+	for _, log := range logs {
+		key := containerKey{log.Namespace, log.PodName, log.ContainerName, log.Previous}
+		tail := append(v.logs[key], log)
+		if len(tail) > LogTailLines {
+			tail = tail[len(tail)-LogTailLines:]
+		}
+		v.logs[key] = tail
+	}
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// snapshot renders the view as a full AgentData, ordered by namespace and name
+func (v *clusterView) snapshot(identity *agentpb.AgentIdentity, timestamp int64) *agentpb.AgentData {
+	data := &agentpb.AgentData{
+		Timestamp: timestamp,
+		Identity:  identity,
+	}
+
+	namespaces := make([]string, 0, len(v.namespaces))
+	for name := range v.namespaces {
+		namespaces = append(namespaces, name)
+	}
+	sort.Strings(namespaces)
+	for _, name := range namespaces {
+		ns := v.namespaces[name]
+		resource := &agentpb.ResourceInfo{
+			Namespace:   name,
+			Pods:        sortedKeys(ns.pods),
+			Deployments: sortedKeys(ns.deployments),
+		}
+		for _, pod := range resource.Pods {
+			if info := ns.podInfos[pod]; info != nil {
+				resource.PodInfos = append(resource.PodInfos, info)
+			}
+		}
+		data.Resources = append(data.Resources, resource)
+	}
+
+	metricKeys := make([]metricKey, 0, len(v.metrics))
+	for key := range v.metrics {
+		metricKeys = append(metricKeys, key)
+	}
+	sort.Slice(metricKeys, func(i, j int) bool {
+		a, b := metricKeys[i], metricKeys[j]
+		if a.namespace != b.namespace {
+			return a.namespace < b.namespace
+		}
+		if a.kind != b.kind {
+			return a.kind < b.kind
+		}
+		return a.name < b.name
+	})
+	for _, key := range metricKeys {
+		data.Metrics = append(data.Metrics, v.metrics[key])
+	}
+
+	containers := make([]containerKey, 0, len(v.logs))
+	for key := range v.logs {
+		containers = append(containers, key)
+	}
+	sort.Slice(containers, func(i, j int) bool {
+		a, b := containers[i], containers[j]
+		if a.namespace != b.namespace {
+			return a.namespace < b.namespace
+		}
+		if a.pod != b.pod {
+			return a.pod < b.pod
+		}
+		if a.container != b.container {
+			return a.container < b.container
+		}
+		// A previous instance's lines come before the current one's
+		return a.previous && !b.previous
+	})
+	for _, key := range containers {
+		data.Logs = append(data.Logs, v.logs[key]...)
+	}
+
+	return data
+}
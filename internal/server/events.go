@@ -0,0 +1,86 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/thekubefleet/kubefleet/internal/eventstore"
+)
+
+// Page sizes of /api/events
+const (
+	defaultEventLimit = 100
+	maxEventLimit     = 1000
+)
+
+// WithEventStore serves the Kubernetes events agents report from an event
+// store at /api/events
+func WithEventStore(events *eventstore.Store) HTTPServerOption {
+	return func(s *HTTPServer) {
+		s.events = events
+	}
+}
+
+// parseEventQuery reads the query parameters of /api/events
+func parseEventQuery(query url.Values) (eventstore.Query, error) {
+	q := eventstore.Query{
+		Cluster:   query.Get("cluster"),
+		Namespace: query.Get("namespace"),
+		Kind:      query.Get("kind"),
+		Name:      query.Get("name"),
+		Type:      query.Get("type"),
+		Reason:    query.Get("reason"),
+		Limit:     defaultEventLimit,
+	}
+
+	var err error
+	if value := query.Get("from"); value != "" {
+		if q.From, err = parseTime(value); err != nil {
+			return q, fmt.Errorf("invalid from: %w", err)
+		}
+	}
+	if value := query.Get("to"); value != "" {
+		if q.To, err = parseTime(value); err != nil {
+			return q, fmt.Errorf("invalid to: %w", err)
+		}
+	}
+	if q.To != 0 && q.From > q.To {
+		return q, fmt.Errorf("from must not be after to")
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return q, fmt.Errorf("invalid limit %q", value)
+		}
+		q.Limit = min(limit, maxEventLimit)
+	}
+	return q, nil
+}
+
+func (s *HTTPServer) handleGetEvents(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if s.events == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Event storage is not enabled"})
+		return
+	}
+
+	q, err := parseEventQuery(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	q.Scope = s.scope(r)
+
+	events := s.events.Query(q)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"events": events,
+		"count":  len(events),
+	})
+}
@@ -8,27 +8,110 @@ import (
 	"strings"
 
 	"github.com/gorilla/mux"
-	agentpb "github.com/thekubefleet/kubefleet/proto"
+	"github.com/thekubefleet/kubefleet/internal/alerting"
+	"github.com/thekubefleet/kubefleet/internal/auth"
+	"github.com/thekubefleet/kubefleet/internal/eventstore"
+	"github.com/thekubefleet/kubefleet/internal/logstore"
+	"github.com/thekubefleet/kubefleet/internal/timeseries"
 )
 
 type HTTPServer struct {
-	dataStore *DataStore
-	router    *mux.Router
+	dataStore      DataStore
+	registry       *Registry
+	authenticators []auth.Authenticator
+	authorizer     *auth.Authorizer
+	oidc           *auth.OIDC
+	series         *timeseries.Store
+	metrics        *Metrics
+	streams        *AgentStreams
+	logs           *logstore.Store
+	events         *eventstore.Store
+	alerts         *alerting.Engine
+	router         *mux.Router
 }
 
-func NewHTTPServer(dataStore *DataStore) *HTTPServer {
+// publicPaths are API paths served without authentication
+var publicPaths = map[string]bool{
+	"/api/health":        true,
+	"/api/login":         true,
+	"/api/auth/callback": true,
+	"/api/logout":        true,
+}
+
+// HTTPServerOption configures optional HTTPServer dependencies
+type HTTPServerOption func(*HTTPServer)
+
+// WithRegistry exposes agent registration status through the API
+func WithRegistry(registry *Registry) HTTPServerOption {
+	return func(s *HTTPServer) {
+		s.registry = registry
+	}
+}
+
+// WithAuthenticator requires callers of /api/* to be authenticated. Several
+// authenticators may be given; the first that accepts a request wins.
+func WithAuthenticator(authenticator auth.Authenticator) HTTPServerOption {
+	return func(s *HTTPServer) {
+		s.authenticators = append(s.authenticators, authenticator)
+	}
+}
+
+// WithAuthorizer restricts what each authenticated caller sees to the
+// clusters and namespaces the authorizer grants them
+func WithAuthorizer(authorizer *auth.Authorizer) HTTPServerOption {
+	return func(s *HTTPServer) {
+		s.authorizer = authorizer
+	}
+}
+
+// WithOIDC enables dashboard login through an OIDC provider. Sessions it
+// creates authenticate /api/* requests.
+func WithOIDC(oidc *auth.OIDC) HTTPServerOption {
+	return func(s *HTTPServer) {
+		s.oidc = oidc
+		s.authenticators = append(s.authenticators, oidc)
+	}
+}
+
+func NewHTTPServer(dataStore DataStore, opts ...HTTPServerOption) *HTTPServer {
 	server := &HTTPServer{
 		dataStore: dataStore,
 		router:    mux.NewRouter(),
 	}
+	for _, opt := range opts {
+		opt(server)
+	}
 
 	// API routes
 	server.router.HandleFunc("/api/data", server.handleGetData).Methods("GET")
 	server.router.HandleFunc("/api/data/latest", server.handleGetLatestData).Methods("GET")
+	server.router.HandleFunc("/api/clusters", server.handleGetClusters).Methods("GET")
+	server.router.HandleFunc("/api/agents", server.handleGetAgents).Methods("GET")
+	if server.streams != nil {
+		server.router.HandleFunc("/api/agents/{cluster}/commands", server.handlePostAgentCommand).Methods("POST")
+	}
+	server.router.HandleFunc("/api/metrics/series", server.handleGetMetricSeries).Methods("GET")
 	server.router.HandleFunc("/api/logs", server.handleGetLogs).Methods("GET")
+	server.router.HandleFunc("/api/logs/search", server.handleSearchLogs).Methods("GET")
 	server.router.HandleFunc("/api/logs/{namespace}/{pod}", server.handleGetPodLogs).Methods("GET")
 	server.router.HandleFunc("/api/logs/{namespace}/{pod}/{container}", server.handleGetContainerLogs).Methods("GET")
+	server.router.HandleFunc("/api/events", server.handleGetEvents).Methods("GET")
+	server.router.HandleFunc("/api/alerts", server.handleGetAlerts).Methods("GET")
+	server.router.HandleFunc("/api/alerts/history", server.handleGetAlertHistory).Methods("GET")
 	server.router.HandleFunc("/api/health", server.handleHealth).Methods("GET")
+	server.router.HandleFunc("/api/me", server.handleMe).Methods("GET")
+
+	// Prometheus metrics
+	if server.metrics != nil {
+		server.router.HandleFunc("/metrics", server.handleMetrics).Methods("GET")
+	}
+
+	// OIDC login flow
+	if server.oidc != nil {
+		server.router.HandleFunc("/api/login", server.oidc.HandleLogin).Methods("GET")
+		server.router.HandleFunc("/api/auth/callback", server.oidc.HandleCallback).Methods("GET")
+		server.router.HandleFunc("/api/logout", server.oidc.HandleLogout).Methods("GET", "POST")
+	}
 
 	// Serve React app
 	server.router.PathPrefix("/").HandlerFunc(server.handleReactApp)
@@ -37,39 +120,111 @@ func NewHTTPServer(dataStore *DataStore) *HTTPServer {
 }
 
 func (s *HTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		setCORSHeaders(w)
+		// Preflight requests carry no credentials, so answer them before
+		// authentication
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+
+	if len(s.authenticators) > 0 && requiresAuth(r) {
+		identity, ok := s.authenticate(r)
+		if !ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("WWW-Authenticate", `Bearer realm="kubefleet"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Unauthorized"})
+			return
+		}
+		r = r.WithContext(auth.ContextWithIdentity(r.Context(), identity))
+	}
+
 	s.router.ServeHTTP(w, r)
 }
 
-func (s *HTTPServer) handleGetData(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
+// setCORSHeaders lets dashboards served from another origin call the API
+// with a bearer token
+func setCORSHeaders(w http.ResponseWriter) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+}
 
+// requiresAuth reports whether a request must be authenticated when
+// authentication is enabled: the API except its public paths, and /metrics
+func requiresAuth(r *http.Request) bool {
 	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
+		return false
+	}
+	if r.URL.Path == "/metrics" {
+		return true
+	}
+	return strings.HasPrefix(r.URL.Path, "/api/") && !publicPaths[r.URL.Path]
+}
+
+func (s *HTTPServer) authenticate(r *http.Request) (*auth.Identity, bool) {
+	for _, authenticator := range s.authenticators {
+		if identity, ok := authenticator.AuthenticateRequest(r); ok {
+			return identity, true
+		}
+	}
+	return nil, false
+}
+
+func (s *HTTPServer) handleGetData(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+	tr, ranged, err := parseTimeRange(query)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	if !ranged {
+		data := filterAllData(s.scope(r), s.dataStore.GetAllData(query.Get("cluster")))
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data":  data,
+			"count": len(data),
+		})
 		return
 	}
 
-	data := s.dataStore.GetAllData()
+	data := s.dataStore.GetDataRange(query.Get("cluster"), tr.from, tr.to)
+	data = filterAllData(s.scope(r), downsample(data, tr.from, tr.step))
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"data":  data,
 		"count": len(data),
+		"from":  tr.from,
+		"to":    tr.to,
+		"step":  tr.step,
 	})
 }
 
-func (s *HTTPServer) handleGetLatestData(w http.ResponseWriter, r *http.Request) {
+func (s *HTTPServer) handleGetClusters(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
+	scope := s.scope(r)
+	clusters := make([]ClusterInfo, 0)
+	for _, cluster := range s.dataStore.GetClusters() {
+		if scope.AllowsCluster(cluster.Name) {
+			clusters = append(clusters, cluster)
+		}
 	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"clusters": clusters,
+		"count":    len(clusters),
+	})
+}
+
+func (s *HTTPServer) handleGetLatestData(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
 
-	data := s.dataStore.GetLatestData()
+	data := s.latestData(r)
 	if data == nil {
 		w.WriteHeader(http.StatusNotFound)
 		json.NewEncoder(w).Encode(map[string]string{"error": "No data available"})
@@ -81,14 +236,70 @@ func (s *HTTPServer) handleGetLatestData(w http.ResponseWriter, r *http.Request)
 	})
 }
 
-func (s *HTTPServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+func (s *HTTPServer) handleGetAgents(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 
+	agents := []AgentInfo{}
+	if s.registry != nil {
+		scope := s.scope(r)
+		for _, agent := range s.registry.Agents() {
+			if scope.AllowsCluster(agent.Cluster) {
+				if s.streams != nil {
+					agent.Connected = s.streams.Connected(agent.Cluster)
+				}
+				agents = append(agents, agent)
+			}
+		}
+	}
 	json.NewEncoder(w).Encode(map[string]interface{}{
+		"agents": agents,
+		"count":  len(agents),
+	})
+}
+
+func (s *HTTPServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	response := map[string]interface{}{
 		"status":     "healthy",
 		"dataPoints": s.dataStore.GetDataCount(),
-	})
+		"clusters":   len(s.dataStore.GetClusters()),
+	}
+
+	// Report degraded when any agent has stopped heartbeating, so alerting
+	// can key off the status without failing the server's own probes
+	if s.registry != nil {
+		counts := s.registry.StatusCounts()
+		response["agents"] = counts
+		if counts[AgentStale] > 0 || counts[AgentOffline] > 0 {
+			response["status"] = "degraded"
+		}
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func (s *HTTPServer) handleMe(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	identity, ok := auth.IdentityFromContext(r.Context())
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Authentication is not enabled"})
+		return
+	}
+
+	response := map[string]interface{}{
+		"subject": identity.Subject,
+		"groups":  identity.Groups,
+	}
+	if s.oidc != nil {
+		if claims, ok := s.oidc.Claims(r); ok {
+			response["claims"] = claims
+		}
+	}
+
+	json.NewEncoder(w).Encode(response)
 }
 
 func (s *HTTPServer) handleReactApp(w http.ResponseWriter, r *http.Request) {
@@ -98,6 +309,14 @@ func (s *HTTPServer) handleReactApp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Send users without a session to the identity provider
+	if s.oidc != nil {
+		if _, ok := s.authenticate(r); !ok {
+			http.Redirect(w, r, auth.LoginURL(r.URL.RequestURI()), http.StatusFound)
+			return
+		}
+	}
+
 	// Check if we're in development mode (no build directory)
 	if _, err := os.Stat("build"); os.IsNotExist(err) {
 		// Development mode - serve a simple HTML page that loads from localhost:3001
@@ -142,57 +361,34 @@ func (s *HTTPServer) handleReactApp(w http.ResponseWriter, r *http.Request) {
 
 func (s *HTTPServer) handleGetLogs(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
 
-	data := s.dataStore.GetLatestData()
-	if data == nil {
+	logs, ok := s.recentLogs(r, "", "", "")
+	if !ok {
 		w.WriteHeader(http.StatusNotFound)
 		json.NewEncoder(w).Encode(map[string]string{"error": "No data available"})
 		return
 	}
 
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"logs":  data.Logs,
-		"count": len(data.Logs),
+		"logs":  logs,
+		"count": len(logs),
 	})
 }
 
 func (s *HTTPServer) handleGetPodLogs(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
 
 	vars := mux.Vars(r)
 	namespace := vars["namespace"]
 	podName := vars["pod"]
 
-	data := s.dataStore.GetLatestData()
-	if data == nil {
+	podLogs, ok := s.recentLogs(r, namespace, podName, "")
+	if !ok {
 		w.WriteHeader(http.StatusNotFound)
 		json.NewEncoder(w).Encode(map[string]string{"error": "No data available"})
 		return
 	}
 
-	var podLogs []*agentpb.PodLog
-	for _, log := range data.Logs {
-		if log.Namespace == namespace && log.PodName == podName {
-			podLogs = append(podLogs, log)
-		}
-	}
-
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"logs":  podLogs,
 		"count": len(podLogs),
@@ -201,34 +397,19 @@ func (s *HTTPServer) handleGetPodLogs(w http.ResponseWriter, r *http.Request) {
 
 func (s *HTTPServer) handleGetContainerLogs(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
 
 	vars := mux.Vars(r)
 	namespace := vars["namespace"]
 	podName := vars["pod"]
 	containerName := vars["container"]
 
-	data := s.dataStore.GetLatestData()
-	if data == nil {
+	containerLogs, ok := s.recentLogs(r, namespace, podName, containerName)
+	if !ok {
 		w.WriteHeader(http.StatusNotFound)
 		json.NewEncoder(w).Encode(map[string]string{"error": "No data available"})
 		return
 	}
 
-	var containerLogs []*agentpb.PodLog
-	for _, log := range data.Logs {
-		if log.Namespace == namespace && log.PodName == podName && log.ContainerName == containerName {
-			containerLogs = append(containerLogs, log)
-		}
-	}
-
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"logs":  containerLogs,
 		"count": len(containerLogs),
@@ -0,0 +1,205 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/thekubefleet/kubefleet/internal/logparse"
+	"github.com/thekubefleet/kubefleet/internal/logstore"
+	agentpb "github.com/thekubefleet/kubefleet/proto"
+)
+
+// Page sizes of /api/logs/search
+const (
+	defaultLogSearchLimit = 100
+	maxLogSearchLimit     = 1000
+)
+
+// WithLogStore serves logs from a log store, which keeps each line once,
+// instead of from the snapshots in the data store
+func WithLogStore(logs *logstore.Store) HTTPServerOption {
+	return func(s *HTTPServer) {
+		s.logs = logs
+	}
+}
+
+// BackfillLogs adds the logs of stored history newer than since to a log
+// store, a day at a time. Lines the store already has are skipped, so this
+// only picks up snapshots stored before logs had their own store.
+func BackfillLogs(dataStore DataStore, logs *logstore.Store, since time.Time) {
+	const window = 24 * 60 * 60
+
+	now := time.Now().Unix()
+	added := 0
+	for from := since.Unix(); from <= now; from += window {
+		for _, data := range dataStore.GetDataRange("", from, from+window-1) {
+			lines, err := logs.Append(ClusterKey(data.Identity), data.Logs)
+			if err != nil {
+				log.Printf("Failed to backfill logs: %v", err)
+				return
+			}
+			added += len(lines)
+		}
+	}
+	if added > 0 {
+		log.Printf("Backfilled %d log lines", added)
+	}
+}
+
+// recentLogs returns the last LogTailLines lines of each container of the
+// requested cluster, optionally limited to a namespace, pod and container.
+// It returns false when no cluster has reported.
+func (s *HTTPServer) recentLogs(r *http.Request, namespace, pod, container string) ([]*agentpb.PodLog, bool) {
+	data := s.latestData(r)
+	if data == nil {
+		return nil, false
+	}
+
+	if s.logs != nil {
+		cluster := ClusterKey(data.Identity)
+		return filterLogs(s.scope(r), cluster, s.logs.Tail(cluster, namespace, pod, container, LogTailLines)), true
+	}
+
+	var logs []*agentpb.PodLog
+	for _, log := range data.Logs {
+		if (namespace == "" || log.Namespace == namespace) && (pod == "" || log.PodName == pod) &&
+			(container == "" || log.ContainerName == container) {
+			logs = append(logs, log)
+		}
+	}
+	return logs, true
+}
+
+// parseLogQuery reads the query parameters of /api/logs/search
+func parseLogQuery(query url.Values) (logstore.Query, error) {
+	q := logstore.Query{
+		Cluster:   query.Get("cluster"),
+		Namespace: query.Get("namespace"),
+		Pod:       query.Get("pod"),
+		Container: query.Get("container"),
+		Terms:     logstore.Tokenize(query.Get("terms")),
+		Contains:  query.Get("q"),
+		Cursor:    query.Get("cursor"),
+		Limit:     defaultLogSearchLimit,
+	}
+
+	if value := query.Get("level"); value != "" {
+		q.Levels = make(map[string]bool)
+		for _, name := range splitComma(value) {
+			level := logparse.NormalizeLevel(name)
+			if level == "" {
+				return q, fmt.Errorf("invalid level %q", name)
+			}
+			q.Levels[level] = true
+		}
+	}
+
+	if value := query.Get("regex"); value != "" {
+		pattern, err := regexp.Compile(value)
+		if err != nil {
+			return q, fmt.Errorf("invalid regex: %w", err)
+		}
+		q.Pattern = pattern
+	}
+
+	if value := query.Get("selector"); value != "" {
+		selector, err := labels.Parse(value)
+		if err != nil {
+			return q, fmt.Errorf("invalid selector: %w", err)
+		}
+		q.Selector = selector
+	}
+
+	if value := query.Get("fieldSelector"); value != "" {
+		selector, err := labels.Parse(value)
+		if err != nil {
+			return q, fmt.Errorf("invalid fieldSelector: %w", err)
+		}
+		q.FieldSelector = selector
+	}
+
+	var err error
+	if value := query.Get("from"); value != "" {
+		if q.From, err = parseTime(value); err != nil {
+			return q, fmt.Errorf("invalid from: %w", err)
+		}
+	}
+	q.To = math.MaxInt64
+	if value := query.Get("to"); value != "" {
+		if q.To, err = parseTime(value); err != nil {
+			return q, fmt.Errorf("invalid to: %w", err)
+		}
+	}
+	if q.From > q.To {
+		return q, fmt.Errorf("from must not be after to")
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return q, fmt.Errorf("invalid limit %q", value)
+		}
+		q.Limit = min(limit, maxLogSearchLimit)
+	}
+	return q, nil
+}
+
+func splitComma(value string) []string {
+	var parts []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+func (s *HTTPServer) handleSearchLogs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if s.logs == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Log storage is not enabled"})
+		return
+	}
+
+	q, err := parseLogQuery(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	q.Scope = s.scope(r)
+
+	results, cursor, err := s.logs.Search(q)
+	if errors.Is(err, logstore.ErrInvalidCursor) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	response := map[string]interface{}{
+		"logs":  results,
+		"count": len(results),
+	}
+	if cursor != "" {
+		response["nextCursor"] = cursor
+	}
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"math"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/thekubefleet/kubefleet/internal/logstore"
+)
+
+func TestParseLogQuery(t *testing.T) {
+	tests := []struct {
+		query   string
+		wantErr bool
+		check   func(t *testing.T, q logstore.Query)
+	}{
+		{
+			query: "",
+			check: func(t *testing.T, q logstore.Query) {
+				if q.Limit != defaultLogSearchLimit || q.From != 0 || q.To != math.MaxInt64 || q.Levels != nil || q.Selector != nil || q.FieldSelector != nil {
+					t.Errorf("query = %+v, want the defaults", q)
+				}
+			},
+		},
+		{
+			query: "level=error,Warning&terms=Upstream+timeout&q=Reset&limit=5000&from=2024-05-01T10:00:00Z&to=1714557700",
+			check: func(t *testing.T, q logstore.Query) {
+				if want := map[string]bool{"ERROR": true, "WARN": true}; !reflect.DeepEqual(q.Levels, want) {
+					t.Errorf("levels = %v, want %v", q.Levels, want)
+				}
+				if want := []string{"upstream", "timeout"}; !reflect.DeepEqual(q.Terms, want) {
+					t.Errorf("terms = %v, want %v", q.Terms, want)
+				}
+				if q.Contains != "Reset" || q.Limit != maxLogSearchLimit || q.From != 1714557600 || q.To != 1714557700 {
+					t.Errorf("query = %+v", q)
+				}
+			},
+		},
+		{
+			query: "fieldSelector=" + url.QueryEscape("status in (500,502),method=POST"),
+			check: func(t *testing.T, q logstore.Query) {
+				if q.FieldSelector == nil || !q.FieldSelector.Matches(labels.Set{"status": "502", "method": "POST"}) ||
+					q.FieldSelector.Matches(labels.Set{"status": "200", "method": "POST"}) {
+					t.Errorf("fieldSelector = %v", q.FieldSelector)
+				}
+			},
+		},
+		{
+			query: "selector=" + url.QueryEscape("app=web,tier in (frontend,edge)"),
+			check: func(t *testing.T, q logstore.Query) {
+				if q.Selector == nil || !q.Selector.Matches(labels.Set{"app": "web", "tier": "edge"}) ||
+					q.Selector.Matches(labels.Set{"app": "web"}) || q.FieldSelector != nil {
+					t.Errorf("selector = %v", q.Selector)
+				}
+			},
+		},
+		{query: "level=loud", wantErr: true},
+		{query: "regex=" + url.QueryEscape("(unclosed"), wantErr: true},
+		{query: "selector=" + url.QueryEscape("app in web"), wantErr: true},
+		{query: "fieldSelector=" + url.QueryEscape("status in 500"), wantErr: true},
+		{query: "from=yesterday", wantErr: true},
+		{query: "from=200&to=100", wantErr: true},
+		{query: "limit=0", wantErr: true},
+		{query: "limit=ten", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("ParseQuery: %v", err)
+			}
+			q, err := parseLogQuery(values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseLogQuery() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.check != nil {
+				tt.check(t, q)
+			}
+		})
+	}
+}
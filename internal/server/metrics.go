@@ -0,0 +1,207 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+
+	"github.com/thekubefleet/kubefleet/internal/auth"
+)
+
+const metricsNamespace = "kubefleet"
+
+// Metrics holds the server's own Prometheus metrics
+type Metrics struct {
+	registry        *prometheus.Registry
+	reportsReceived *prometheus.CounterVec
+	reportBytes     *prometheus.HistogramVec
+	grpcErrors      *prometheus.CounterVec
+}
+
+// NewMetrics creates the server metrics. Store and agent gauges are read
+// from dataStore and registry when scraped; registry may be nil.
+func NewMetrics(dataStore DataStore, registry *Registry) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		reportsReceived: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "reports_received_total",
+			Help:      "Agent reports received, by cluster.",
+		}, []string{"cluster"}),
+		reportBytes: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "report_size_bytes",
+			Help:      "Encoded size of agent reports, by cluster.",
+			Buckets:   prometheus.ExponentialBuckets(1024, 4, 8), // 1KiB to 16MiB
+		}, []string{"cluster"}),
+		grpcErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "grpc_errors_total",
+			Help:      "gRPC calls that returned an error, by method and status code.",
+		}, []string{"method", "code"}),
+	}
+
+	m.registry.MustRegister(
+		m.reportsReceived,
+		m.reportBytes,
+		m.grpcErrors,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "store_data_points",
+			Help:      "Data points held in the data store.",
+		}, func() float64 { return float64(dataStore.GetDataCount()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "store_size_bytes",
+			Help:      "Size of the data store in bytes.",
+		}, func() float64 { return float64(dataStore.GetStoreSize()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "store_clusters",
+			Help:      "Clusters with data in the data store.",
+		}, func() float64 { return float64(len(dataStore.GetClusters())) }),
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	if registry != nil {
+		m.registry.MustRegister(&agentCollector{registry: registry})
+	}
+	return m
+}
+
+// ObserveReport records a report received from a cluster
+func (m *Metrics) ObserveReport(cluster string, size int) {
+	m.reportsReceived.WithLabelValues(cluster).Inc()
+	m.reportBytes.WithLabelValues(cluster).Observe(float64(size))
+}
+
+func (m *Metrics) observeError(method string, err error) {
+	if err != nil {
+		m.grpcErrors.WithLabelValues(method, status.Code(err).String()).Inc()
+	}
+}
+
+// UnaryServerInterceptor counts failed unary calls
+func (m *Metrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		m.observeError(info.FullMethod, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor counts failed streaming calls
+func (m *Metrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		err := handler(srv, ss)
+		m.observeError(info.FullMethod, err)
+		return err
+	}
+}
+
+var (
+	agentLastSeenDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "agent", "last_seen_timestamp_seconds"),
+		"Unix time an agent last reported or heartbeated.",
+		[]string{"cluster", "agent_id", "version"}, nil,
+	)
+	agentUpDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "agent", "up"),
+		"Whether an agent is online (1) or stale or offline (0).",
+		[]string{"cluster", "agent_id", "status"}, nil,
+	)
+	resourceCPUDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "resource", "cpu_cores"),
+		"CPU usage of a resource in the latest report, in cores.",
+		[]string{"cluster", "namespace", "kind", "name"}, nil,
+	)
+	resourceMemoryDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "resource", "memory_bytes"),
+		"Memory usage of a resource in the latest report, in bytes.",
+		[]string{"cluster", "namespace", "kind", "name"}, nil,
+	)
+)
+
+// agentCollector exports the registry's agents
+type agentCollector struct {
+	registry *Registry
+}
+
+func (c *agentCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- agentLastSeenDesc
+	ch <- agentUpDesc
+}
+
+func (c *agentCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, agent := range c.registry.Agents() {
+		ch <- prometheus.MustNewConstMetric(agentLastSeenDesc, prometheus.GaugeValue,
+			float64(agent.LastSeen.Unix()), agent.Cluster, agent.AgentID, agent.AgentVersion)
+
+		up := 0.0
+		if agent.Status == AgentOnline {
+			up = 1
+		}
+		ch <- prometheus.MustNewConstMetric(agentUpDesc, prometheus.GaugeValue,
+			up, agent.Cluster, agent.AgentID, string(agent.Status))
+	}
+}
+
+// resourceCollector exports the metrics of each cluster's latest report
+// that the scope allows
+type resourceCollector struct {
+	dataStore DataStore
+	scope     *auth.Scope
+}
+
+func (c *resourceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- resourceCPUDesc
+	ch <- resourceMemoryDesc
+}
+
+func (c *resourceCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, cluster := range c.dataStore.GetClusters() {
+		if !c.scope.AllowsCluster(cluster.Name) {
+			continue
+		}
+		data := filterAgentData(c.scope, c.dataStore.GetLatestData(cluster.Name))
+		if data == nil {
+			continue
+		}
+
+		// A report may list a resource twice; the gatherer rejects duplicates
+		seen := make(map[[3]string]bool)
+		for _, m := range data.Metrics {
+			key := [3]string{m.Namespace, m.Kind, m.Name}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			ch <- prometheus.MustNewConstMetric(resourceCPUDesc, prometheus.GaugeValue,
+				m.Cpu, cluster.Name, m.Namespace, m.Kind, m.Name)
+			ch <- prometheus.MustNewConstMetric(resourceMemoryDesc, prometheus.GaugeValue,
+				m.Memory*1024*1024, cluster.Name, m.Namespace, m.Kind, m.Name)
+		}
+	}
+}
+
+// WithMetrics serves Prometheus metrics at /metrics: the server's own
+// metrics plus the latest resource metrics of every cluster
+func WithMetrics(metrics *Metrics) HTTPServerOption {
+	return func(s *HTTPServer) {
+		s.metrics = metrics
+	}
+}
+
+func (s *HTTPServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	// Resource gauges are gathered per request so they honour the caller's scope
+	resources := prometheus.NewRegistry()
+	resources.MustRegister(&resourceCollector{dataStore: s.dataStore, scope: s.scope(r)})
+
+	promhttp.HandlerFor(prometheus.Gatherers{s.metrics.registry, resources}, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	agentpb "github.com/thekubefleet/kubefleet/proto"
+)
+
+// ErrAgentNotRegistered is returned when a heartbeat arrives from an agent
+// the registry doesn't know, e.g. after a server restart
+var ErrAgentNotRegistered = errors.New("agent is not registered")
+
+// AgentStatus is the liveness state of a registered agent
+type AgentStatus string
+
+const (
+	AgentOnline  AgentStatus = "online"
+	AgentStale   AgentStatus = "stale"
+	AgentOffline AgentStatus = "offline"
+)
+
+// AgentInfo describes a registered agent and its current status
+type AgentInfo struct {
+	Cluster           string      `json:"cluster"`
+	ClusterUID        string      `json:"clusterUid"`
+	AgentID           string      `json:"agentId"`
+	AgentVersion      string      `json:"agentVersion"`
+	Hostname          string      `json:"hostname"`
+	RegisteredAt      time.Time   `json:"registeredAt"`
+	LastSeen          time.Time   `json:"lastSeen"`
+	HeartbeatInterval int64       `json:"heartbeatIntervalSeconds"`
+	Status            AgentStatus `json:"status"`
+	Connected         bool        `json:"connected"` // Has a Connect stream open
+}
+
+type agentRecord struct {
+	identity     *agentpb.AgentIdentity
+	registeredAt time.Time
+	lastSeen     time.Time
+}
+
+// Registry tracks registered agents and derives their status from how many
+// heartbeat intervals they have missed
+type Registry struct {
+	mu           sync.RWMutex
+	agents       map[string]*agentRecord
+	interval     time.Duration
+	staleAfter   int
+	offlineAfter int
+	now          func() time.Time
+}
+
+// NewRegistry creates a registry that expects a heartbeat every interval and
+// marks agents stale or offline after the given number of missed intervals
+func NewRegistry(interval time.Duration, staleAfter, offlineAfter int) *Registry {
+	return &Registry{
+		agents:       make(map[string]*agentRecord),
+		interval:     interval,
+		staleAfter:   staleAfter,
+		offlineAfter: offlineAfter,
+		now:          time.Now,
+	}
+}
+
+// HeartbeatInterval returns the interval agents are expected to heartbeat at
+func (r *Registry) HeartbeatInterval() time.Duration {
+	return r.interval
+}
+
+func agentKey(identity *agentpb.AgentIdentity) string {
+	return ClusterKey(identity) + "/" + identity.GetAgentId()
+}
+
+// Register records an agent, replacing any previous registration
+func (r *Registry) Register(identity *agentpb.AgentIdentity) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	r.agents[agentKey(identity)] = &agentRecord{
+		identity:     identity,
+		registeredAt: now,
+		lastSeen:     now,
+	}
+}
+
+// Heartbeat marks a registered agent as seen
+func (r *Registry) Heartbeat(identity *agentpb.AgentIdentity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record, ok := r.agents[agentKey(identity)]
+	if !ok {
+		return ErrAgentNotRegistered
+	}
+	record.lastSeen = r.now()
+	return nil
+}
+
+// Observe marks an agent as seen when it reports data, registering it
+// implicitly so agents that predate registration are still tracked
+func (r *Registry) Observe(identity *agentpb.AgentIdentity) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	key := agentKey(identity)
+	record, ok := r.agents[key]
+	if !ok {
+		r.agents[key] = &agentRecord{
+			identity:     identity,
+			registeredAt: now,
+			lastSeen:     now,
+		}
+		return
+	}
+	record.lastSeen = now
+}
+
+func (r *Registry) status(lastSeen, now time.Time) AgentStatus {
+	missed := int(now.Sub(lastSeen) / r.interval)
+	switch {
+	case missed >= r.offlineAfter:
+		return AgentOffline
+	case missed >= r.staleAfter:
+		return AgentStale
+	default:
+		return AgentOnline
+	}
+}
+
+// Agents returns all registered agents sorted by cluster and agent ID
+func (r *Registry) Agents() []AgentInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := r.now()
+	agents := make([]AgentInfo, 0, len(r.agents))
+	for _, record := range r.agents {
+		agents = append(agents, AgentInfo{
+			Cluster:           ClusterKey(record.identity),
+			ClusterUID:        record.identity.GetClusterUid(),
+			AgentID:           record.identity.GetAgentId(),
+			AgentVersion:      record.identity.GetAgentVersion(),
+			Hostname:          record.identity.GetHostname(),
+			RegisteredAt:      record.registeredAt,
+			LastSeen:          record.lastSeen,
+			HeartbeatInterval: int64(r.interval / time.Second),
+			Status:            r.status(record.lastSeen, now),
+		})
+	}
+	sort.Slice(agents, func(i, j int) bool {
+		if agents[i].Cluster != agents[j].Cluster {
+			return agents[i].Cluster < agents[j].Cluster
+		}
+		return agents[i].AgentID < agents[j].AgentID
+	})
+	return agents
+}
+
+// StatusCounts returns the number of agents in each status
+func (r *Registry) StatusCounts() map[AgentStatus]int {
+	counts := map[AgentStatus]int{
+		AgentOnline:  0,
+		AgentStale:   0,
+		AgentOffline: 0,
+	}
+	for _, agent := range r.Agents() {
+		counts[agent.Status]++
+	}
+	return counts
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	agentpb "github.com/thekubefleet/kubefleet/proto"
+)
+
+// testRegistry is a registry on a clock the test moves, expecting a
+// heartbeat every 30s and marking agents stale after 2 missed heartbeats
+// and offline after 5
+type testRegistry struct {
+	*Registry
+	clock time.Time
+}
+
+func newTestRegistry() *testRegistry {
+	tr := &testRegistry{
+		Registry: NewRegistry(30*time.Second, 2, 5),
+		clock:    time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+	}
+	tr.now = func() time.Time { return tr.clock }
+	return tr
+}
+
+func (tr *testRegistry) advance(d time.Duration) {
+	tr.clock = tr.clock.Add(d)
+}
+
+func agentIdentity(cluster, agentID string) *agentpb.AgentIdentity {
+	return &agentpb.AgentIdentity{ClusterName: cluster, AgentId: agentID, Hostname: agentID + ".local"}
+}
+
+func TestRegistryStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		want    AgentStatus
+	}{
+		{name: "just seen", elapsed: 0, want: AgentOnline},
+		{name: "one missed heartbeat", elapsed: 59 * time.Second, want: AgentOnline},
+		{name: "two missed heartbeats", elapsed: time.Minute, want: AgentStale},
+		{name: "four missed heartbeats", elapsed: 149 * time.Second, want: AgentStale},
+		{name: "five missed heartbeats", elapsed: 150 * time.Second, want: AgentOffline},
+		{name: "gone for a day", elapsed: 24 * time.Hour, want: AgentOffline},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRegistry()
+			r.Register(agentIdentity("prod", "agent-1"))
+			r.advance(tt.elapsed)
+
+			agents := r.Agents()
+			if len(agents) != 1 || agents[0].Status != tt.want {
+				t.Errorf("agents = %+v, want one %s", agents, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistryHeartbeat(t *testing.T) {
+	r := newTestRegistry()
+	if err := r.Heartbeat(agentIdentity("prod", "agent-1")); !errors.Is(err, ErrAgentNotRegistered) {
+		t.Errorf("Heartbeat() of an unknown agent = %v, want ErrAgentNotRegistered", err)
+	}
+
+	registeredAt := r.clock
+	r.Register(agentIdentity("prod", "agent-1"))
+	r.advance(2 * time.Minute)
+	if status := r.Agents()[0].Status; status != AgentStale {
+		t.Fatalf("status = %s before the heartbeat, want stale", status)
+	}
+	if err := r.Heartbeat(agentIdentity("prod", "agent-1")); err != nil {
+		t.Fatalf("Heartbeat: %v", err)
+	}
+	agent := r.Agents()[0]
+	if agent.Status != AgentOnline || !agent.LastSeen.Equal(r.clock) || !agent.RegisteredAt.Equal(registeredAt) {
+		t.Errorf("agent = %+v, want online, seen now and registered at %v", agent, registeredAt)
+	}
+	if agent.HeartbeatInterval != 30 || agent.Hostname != "agent-1.local" {
+		t.Errorf("agent = %+v", agent)
+	}
+
+	// Registering again starts a new registration
+	r.advance(time.Minute)
+	r.Register(agentIdentity("prod", "agent-1"))
+	if agent := r.Agents()[0]; !agent.RegisteredAt.Equal(r.clock) {
+		t.Errorf("registered at %v, want %v", agent.RegisteredAt, r.clock)
+	}
+}
+
+func TestRegistryObserve(t *testing.T) {
+	r := newTestRegistry()
+
+	// Agents that report without registering are tracked
+	r.Observe(agentIdentity("prod", "agent-2"))
+	r.Register(agentIdentity("prod", "agent-1"))
+	r.Register(&agentpb.AgentIdentity{ClusterUid: "uid-1", AgentId: "agent-1"})
+	r.advance(3 * time.Minute)
+	r.Observe(agentIdentity("prod", "agent-2"))
+	r.advance(time.Minute)
+
+	var got []string
+	for _, agent := range r.Agents() {
+		got = append(got, agent.Cluster+"/"+agent.AgentID+" "+string(agent.Status))
+	}
+	want := []string{"prod/agent-1 offline", "prod/agent-2 stale", "uid-1/agent-1 offline"}
+	if !slices.Equal(got, want) {
+		t.Errorf("agents = %q, want %q", got, want)
+	}
+
+	counts := r.StatusCounts()
+	if counts[AgentOnline] != 0 || counts[AgentStale] != 1 || counts[AgentOffline] != 2 {
+		t.Errorf("status counts = %v", counts)
+	}
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/thekubefleet/kubefleet/internal/timeseries"
+)
+
+// WithMetricsStore serves metric history from a time-series store at
+// /api/metrics/series
+func WithMetricsStore(series *timeseries.Store) HTTPServerOption {
+	return func(s *HTTPServer) {
+		s.series = series
+	}
+}
+
+// BackfillSeries replays the metrics of stored history newer than since
+// into a time-series store, a day at a time. This rebuilds the raw tier and
+// any rollup periods the series store didn't persist, for as long as the
+// data store keeps the underlying data points.
+func BackfillSeries(dataStore DataStore, series *timeseries.Store, since time.Time) {
+	const window = 24 * 60 * 60
+
+	now := time.Now().Unix()
+	samples := 0
+	for from := since.Unix(); from <= now; from += window {
+		for _, data := range dataStore.GetDataRange("", from, from+window-1) {
+			if err := series.IngestMetrics(ClusterKey(data.Identity), data.Timestamp, data.Metrics); err != nil {
+				log.Printf("Failed to backfill metric series: %v", err)
+				return
+			}
+			samples += len(data.Metrics)
+		}
+	}
+	if samples > 0 {
+		log.Printf("Backfilled %d metric samples into %d series", samples, series.SeriesCount())
+	}
+}
+
+func (s *HTTPServer) handleGetMetricSeries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if s.series == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Metric history is not enabled"})
+		return
+	}
+
+	query := r.URL.Query()
+	tr, ranged, err := parseTimeRange(query)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if !ranged {
+		tr.to = time.Now().Unix()
+	}
+	if query.Get("from") == "" {
+		// Default to the last hour
+		tr.from = tr.to - 60*60
+	}
+
+	// Use the finest tier that still covers the window unless one is asked for
+	tier := s.series.TierFor(tr.from, time.Now())
+	if value := query.Get("tier"); value != "" {
+		if tier, err = timeseries.ParseTier(value); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+	}
+
+	result := s.series.Query(timeseries.Query{
+		SeriesKey: timeseries.SeriesKey{
+			Cluster:   query.Get("cluster"),
+			Namespace: query.Get("namespace"),
+			Kind:      query.Get("kind"),
+			Name:      query.Get("name"),
+			Metric:    query.Get("metric"),
+		},
+		From: tr.from,
+		To:   tr.to,
+		Tier: tier,
+	})
+
+	scope := s.scope(r)
+	series := make([]timeseries.Series, 0, len(result))
+	for _, ser := range result {
+		if scope.Allows(ser.Cluster, ser.Namespace) {
+			series = append(series, ser)
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"series": series,
+		"count":  len(series),
+		"tier":   tier,
+		"from":   tr.from,
+		"to":     tr.to,
+	})
+}
@@ -0,0 +1,356 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/thekubefleet/kubefleet/internal/auth"
+	agentpb "github.com/thekubefleet/kubefleet/proto"
+)
+
+const (
+	// commandQueueSize bounds the commands waiting to be sent to one agent.
+	// Further commands are rejected rather than queued without limit.
+	commandQueueSize = 16
+
+	// logChunkBuffer is how many relayed log chunks may wait for a slow
+	// reader before its stream is dropped; the agent's stream isn't held up
+	logChunkBuffer = 64
+)
+
+var (
+	// ErrAgentNotConnected is returned for commands to a cluster without a
+	// connected agent stream
+	ErrAgentNotConnected = errors.New("agent is not connected")
+	// ErrAgentBusy is returned when an agent's command queue is full
+	ErrAgentBusy = errors.New("agent has too many pending commands")
+	// ErrLogStreamBehind is returned when a relayed log stream is read too
+	// slowly to keep up with the agent
+	ErrLogStreamBehind = errors.New("log stream fell behind")
+)
+
+// ReportHandler applies a report received on a stream and returns its
+// acknowledgement
+type ReportHandler func(report *agentpb.DeltaReport) (*agentpb.DeltaResponse, error)
+
+// agentStream is one connected agent
+type agentStream struct {
+	owner    string // Authenticated subject of the agent, empty without authentication
+	commands chan *agentpb.Command
+	done     chan struct{} // Closed when the stream ends
+
+	mu      sync.Mutex
+	pending map[string]chan *agentpb.CommandResult
+	tails   map[string]chan *agentpb.LogChunk
+}
+
+// AgentStreams tracks the agents connected over Connect streams and routes
+// commands to them
+type AgentStreams struct {
+	registry *Registry
+	mu       sync.RWMutex
+	streams  map[string]*agentStream
+}
+
+// NewAgentStreams creates an empty set of streams. Agents that connect are
+// marked as seen in registry, which may be nil.
+func NewAgentStreams(registry *Registry) *AgentStreams {
+	return &AgentStreams{registry: registry, streams: make(map[string]*agentStream)}
+}
+
+// Connected reports whether a cluster's agent has a stream open
+func (a *AgentStreams) Connected(cluster string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	_, ok := a.streams[cluster]
+	return ok
+}
+
+// Serve runs a Connect stream until the agent disconnects. The first message
+// must be a StreamHello naming a cluster the caller is bound to, and reports
+// must be for that cluster. Reports are passed to handleReport and
+// acknowledged in order.
+func (a *AgentStreams) Serve(stream agentpb.AgentReporter_ConnectServer, handleReport ReportHandler) error {
+	ctx := stream.Context()
+
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	hello := first.GetHello()
+	if hello == nil || hello.Identity == nil {
+		return status.Error(codes.InvalidArgument, "the first message must be a hello with the agent identity")
+	}
+
+	cluster := ClusterKey(hello.Identity)
+	if err := auth.CheckCluster(ctx, cluster); err != nil {
+		return err
+	}
+	conn := &agentStream{
+		commands: make(chan *agentpb.Command, commandQueueSize),
+		done:     make(chan struct{}),
+		pending:  make(map[string]chan *agentpb.CommandResult),
+		tails:    make(map[string]chan *agentpb.LogChunk),
+	}
+	if identity, ok := auth.CallerIdentity(ctx); ok {
+		conn.owner = identity.Subject
+	}
+	if err := a.add(cluster, conn); err != nil {
+		return err
+	}
+	defer a.remove(cluster, conn)
+	defer close(conn.done)
+	if a.registry != nil {
+		a.registry.Observe(hello.Identity)
+	}
+	log.Printf("Agent %s of cluster %s connected a stream", hello.Identity.AgentId, cluster)
+
+	// Only one goroutine may send on a stream, so acknowledgements and
+	// commands are funnelled through the sender
+	acks := make(chan *agentpb.DeltaResponse, 1)
+	sendErr := make(chan error, 1)
+	go func() {
+		for {
+			var msg *agentpb.ServerMessage
+			select {
+			case <-ctx.Done():
+				return
+			case ack := <-acks:
+				msg = &agentpb.ServerMessage{Payload: &agentpb.ServerMessage_Ack{Ack: ack}}
+			case cmd := <-conn.commands:
+				msg = &agentpb.ServerMessage{Payload: &agentpb.ServerMessage_Command{Command: cmd}}
+			}
+			if err := stream.Send(msg); err != nil {
+				sendErr <- err
+				return
+			}
+		}
+	}()
+
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			log.Printf("Agent stream of cluster %s closed: %v", cluster, err)
+			return err
+		}
+
+		switch payload := msg.Payload.(type) {
+		case *agentpb.AgentMessage_Report:
+			if reported := ClusterKey(payload.Report.Identity); reported != cluster {
+				return status.Errorf(codes.PermissionDenied, "stream of cluster %s may not report for cluster %s", cluster, reported)
+			}
+			ack, err := handleReport(payload.Report)
+			if status.Code(err) == codes.Unimplemented {
+				// Tells the agent to fall back to full snapshots
+				return err
+			}
+			if err != nil {
+				ack = &agentpb.DeltaResponse{Success: false, Message: err.Error()}
+			}
+			ack.Sequence = payload.Report.Sequence
+			// The agent waits for each acknowledgement before sending its
+			// next report, so this never blocks for long
+			select {
+			case acks <- ack:
+			case err := <-sendErr:
+				return err
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		case *agentpb.AgentMessage_Event:
+			log.Printf("Event from cluster %s: %s: %s", cluster, payload.Event.Type, payload.Event.Message)
+		case *agentpb.AgentMessage_Result:
+			conn.deliver(payload.Result)
+		case *agentpb.AgentMessage_LogChunk:
+			conn.deliverChunk(payload.LogChunk)
+		case *agentpb.AgentMessage_Hello:
+			log.Printf("Ignoring repeated hello from cluster %s", cluster)
+		}
+	}
+}
+
+// add makes conn the cluster's stream. A reconnecting agent replaces its
+// previous stream, but another agent can't take over the cluster's stream.
+func (a *AgentStreams) add(cluster string, conn *agentStream) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if existing, ok := a.streams[cluster]; ok && existing.owner != conn.owner {
+		return status.Errorf(codes.AlreadyExists, "cluster %s is connected by another agent", cluster)
+	}
+	a.streams[cluster] = conn
+	return nil
+}
+
+func (a *AgentStreams) remove(cluster string, conn *agentStream) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.streams[cluster] == conn {
+		delete(a.streams, cluster)
+	}
+}
+
+func (c *agentStream) deliver(result *agentpb.CommandResult) {
+	c.mu.Lock()
+	ch, ok := c.pending[result.CommandId]
+	delete(c.pending, result.CommandId)
+	c.mu.Unlock()
+	if ok {
+		ch <- result
+	}
+}
+
+// deliverChunk passes a relayed log chunk to its reader. A reader that has
+// fallen behind has its channel closed and gets no more chunks.
+func (c *agentStream) deliverChunk(chunk *agentpb.LogChunk) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch, ok := c.tails[chunk.CommandId]
+	if !ok {
+		return
+	}
+	select {
+	case ch <- chunk:
+		if chunk.IsComplete {
+			delete(c.tails, chunk.CommandId)
+		}
+	default:
+		delete(c.tails, chunk.CommandId)
+		close(ch)
+	}
+}
+
+// enqueue queues a command for the agent with a new ID
+func (c *agentStream) enqueue(cmd *agentpb.Command) error {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Errorf("failed to generate command ID: %w", err)
+	}
+	cmd.Id = hex.EncodeToString(id)
+
+	select {
+	case c.commands <- cmd:
+		return nil
+	default:
+		return ErrAgentBusy
+	}
+}
+
+// cancel tells the agent to stop a running command. It waits briefly for
+// room in the queue since a lost cancel would leave a tail running.
+func (c *agentStream) cancel(commandID string) {
+	cmd := &agentpb.Command{Action: &agentpb.Command_Cancel{Cancel: &agentpb.CancelCommand{CommandId: commandID}}}
+	timeout := time.NewTimer(5 * time.Second)
+	defer timeout.Stop()
+	select {
+	case c.commands <- cmd:
+	case <-c.done:
+	case <-timeout.C:
+		log.Printf("Failed to cancel command %s: command queue is full", commandID)
+	}
+}
+
+func (a *AgentStreams) get(cluster string) (*agentStream, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	conn, ok := a.streams[cluster]
+	if !ok {
+		return nil, ErrAgentNotConnected
+	}
+	return conn, nil
+}
+
+// SendCommand sends a command to a cluster's agent and waits for its result
+// until ctx is done
+func (a *AgentStreams) SendCommand(ctx context.Context, cluster string, cmd *agentpb.Command) (*agentpb.CommandResult, error) {
+	conn, err := a.get(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	// Register for the result before the agent can answer
+	result := make(chan *agentpb.CommandResult, 1)
+	conn.mu.Lock()
+	err = conn.enqueue(cmd)
+	if err == nil {
+		conn.pending[cmd.Id] = result
+	}
+	conn.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		conn.mu.Lock()
+		delete(conn.pending, cmd.Id)
+		conn.mu.Unlock()
+	}()
+
+	select {
+	case r := <-result:
+		return r, nil
+	case <-conn.done:
+		return nil, ErrAgentNotConnected
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// StreamLogs has a cluster's agent tail the logs req asks for and passes
+// them to send until the agent is done or ctx is. The agent reads the logs
+// from its own API server, so this works for clusters the server can't reach.
+func (a *AgentStreams) StreamLogs(ctx context.Context, cluster string, req *agentpb.LogRequest, send func(*agentpb.LogStream) error) error {
+	conn, err := a.get(cluster)
+	if err != nil {
+		return err
+	}
+
+	cmd := &agentpb.Command{Action: &agentpb.Command_TailLogs{TailLogs: req}}
+	chunks := make(chan *agentpb.LogChunk, logChunkBuffer)
+	conn.mu.Lock()
+	err = conn.enqueue(cmd)
+	if err == nil {
+		conn.tails[cmd.Id] = chunks
+	}
+	conn.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		conn.mu.Lock()
+		delete(conn.tails, cmd.Id)
+		conn.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				conn.cancel(cmd.Id)
+				return ErrLogStreamBehind
+			}
+			if chunk.Error != "" {
+				return fmt.Errorf("agent failed to tail logs: %s", chunk.Error)
+			}
+			if err := send(&agentpb.LogStream{Logs: chunk.Logs, IsComplete: chunk.IsComplete}); err != nil {
+				conn.cancel(cmd.Id)
+				return err
+			}
+			if chunk.IsComplete {
+				return nil
+			}
+		case <-conn.done:
+			return ErrAgentNotConnected
+		case <-ctx.Done():
+			conn.cancel(cmd.Id)
+			return ctx.Err()
+		}
+	}
+}
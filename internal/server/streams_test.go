@@ -0,0 +1,334 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/thekubefleet/kubefleet/internal/auth"
+	"github.com/thekubefleet/kubefleet/internal/grpcclient"
+	agentpb "github.com/thekubefleet/kubefleet/proto"
+)
+
+const testTokens = `token-a,agent-a
+token-b,agent-b
+`
+
+// connectServer serves Connect streams with AgentStreams
+type connectServer struct {
+	agentpb.UnimplementedAgentReporterServer
+	streams *AgentStreams
+	reports chan *agentpb.DeltaReport
+}
+
+func (s *connectServer) Connect(stream agentpb.AgentReporter_ConnectServer) error {
+	return s.streams.Serve(stream, func(report *agentpb.DeltaReport) (*agentpb.DeltaResponse, error) {
+		s.reports <- report
+		return &agentpb.DeltaResponse{Success: true}, nil
+	})
+}
+
+type testServer struct {
+	streams *AgentStreams
+	reports chan *agentpb.DeltaReport
+	lis     *bufconn.Listener
+}
+
+// newTestServer serves Connect in process over bufconn, authenticating
+// agents with testTokens and binding them to clusters with policy if set
+func newTestServer(t *testing.T, policy string) *testServer {
+	ctx := context.Background()
+	tokens, err := auth.NewTokenStore(ctx, func(context.Context) ([]byte, error) { return []byte(testTokens), nil })
+	if err != nil {
+		t.Fatalf("failed to load tokens: %v", err)
+	}
+	interceptors := []grpc.StreamServerInterceptor{auth.StreamServerInterceptor(tokens)}
+	if policy != "" {
+		authorizer, err := auth.NewAuthorizer(ctx, func(context.Context) ([]byte, error) { return []byte(policy), nil })
+		if err != nil {
+			t.Fatalf("failed to load policy: %v", err)
+		}
+		interceptors = append(interceptors, auth.StreamClusterInterceptor(authorizer))
+	}
+
+	ts := &testServer{
+		streams: NewAgentStreams(nil),
+		reports: make(chan *agentpb.DeltaReport, 16),
+		lis:     bufconn.Listen(1 << 20),
+	}
+	srv := grpc.NewServer(grpc.ChainStreamInterceptor(interceptors...))
+	agentpb.RegisterAgentReporterServer(srv, &connectServer{streams: ts.streams, reports: ts.reports})
+	go srv.Serve(ts.lis)
+	t.Cleanup(srv.Stop)
+	return ts
+}
+
+func (ts *testServer) dialOptions() []grpc.DialOption {
+	return []grpc.DialOption{grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return ts.lis.DialContext(ctx)
+	})}
+}
+
+// agent connects an agent using the grpcclient Stream with token
+func (ts *testServer) agent(t *testing.T, token string, handler grpcclient.CommandHandler) (*grpcclient.Stream, context.CancelFunc) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte(token), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	creds, err := auth.NewTokenCredentials(tokenFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := grpcclient.NewClient("passthrough:///bufnet", append(ts.dialOptions(), grpc.WithPerRPCCredentials(creds))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	identity := &agentpb.AgentIdentity{ClusterName: "prod", AgentId: "agent-1"}
+	stream := grpcclient.NewStream(client, identity, 30*time.Second, handler)
+	ctx, cancel := context.WithCancel(context.Background())
+	go stream.Run(ctx)
+	t.Cleanup(cancel)
+	return stream, cancel
+}
+
+// rawStream opens a Connect stream with token and sends a hello for cluster
+func (ts *testServer) rawStream(t *testing.T, token, cluster string) (agentpb.AgentReporter_ConnectClient, context.CancelFunc) {
+	conn, err := grpc.NewClient("passthrough:///bufnet", append(ts.dialOptions(), grpc.WithTransportCredentials(insecure.NewCredentials()))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	ctx, cancel := context.WithCancel(metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token))
+	t.Cleanup(cancel)
+	stream, err := agentpb.NewAgentReporterClient(conn).Connect(ctx)
+	if err != nil {
+		t.Fatalf("failed to open stream: %v", err)
+	}
+	err = stream.Send(&agentpb.AgentMessage{Payload: &agentpb.AgentMessage_Hello{Hello: &agentpb.StreamHello{
+		Identity: &agentpb.AgentIdentity{ClusterName: cluster, AgentId: token},
+	}}})
+	if err != nil {
+		t.Fatalf("failed to send hello: %v", err)
+	}
+	return stream, cancel
+}
+
+// streamFor returns the stream registered for a cluster
+func (ts *testServer) streamFor(cluster string) *agentStream {
+	ts.streams.mu.RLock()
+	defer ts.streams.mu.RUnlock()
+	return ts.streams.streams[cluster]
+}
+
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting until %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// fakeHandler answers commands and log tails like an agent
+type fakeHandler struct {
+	commands chan *agentpb.Command
+}
+
+func (h *fakeHandler) HandleCommand(ctx context.Context, cmd *agentpb.Command) *agentpb.CommandResult {
+	h.commands <- cmd
+	return &agentpb.CommandResult{Success: true, Message: "done"}
+}
+
+func (h *fakeHandler) TailLogs(ctx context.Context, req *agentpb.LogRequest, send func(*agentpb.LogStream) error) error {
+	if err := send(&agentpb.LogStream{Logs: []*agentpb.PodLog{{PodName: req.PodName, LogLine: "hello"}}}); err != nil {
+		return err
+	}
+	return send(&agentpb.LogStream{IsComplete: true})
+}
+
+func TestStreamHelloAndReports(t *testing.T) {
+	ts := newTestServer(t, "")
+	stream, _ := ts.agent(t, "token-a", &fakeHandler{commands: make(chan *agentpb.Command, 1)})
+	eventually(t, "the agent is connected", func() bool { return ts.streams.Connected("prod") && stream.Connected() })
+	if owner := ts.streamFor("prod").owner; owner != "agent-a" {
+		t.Errorf("stream owner = %q, want agent-a", owner)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	report := &agentpb.DeltaReport{Identity: &agentpb.AgentIdentity{ClusterName: "prod"}, Sequence: 7}
+	ack, err := stream.SendReport(ctx, report)
+	if err != nil {
+		t.Fatalf("SendReport: %v", err)
+	}
+	if !ack.Success || ack.Sequence != 7 {
+		t.Errorf("ack = %+v, want success for sequence 7", ack)
+	}
+	if got := <-ts.reports; got.Sequence != 7 {
+		t.Errorf("server handled report %d, want 7", got.Sequence)
+	}
+}
+
+func TestStreamCommands(t *testing.T) {
+	ts := newTestServer(t, "")
+	handler := &fakeHandler{commands: make(chan *agentpb.Command, 1)}
+	ts.agent(t, "token-a", handler)
+	eventually(t, "the agent is connected", func() bool { return ts.streams.Connected("prod") })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cmd := &agentpb.Command{Action: &agentpb.Command_SetReportInterval{SetReportInterval: &agentpb.SetReportInterval{IntervalSeconds: 60}}}
+	result, err := ts.streams.SendCommand(ctx, "prod", cmd)
+	if err != nil {
+		t.Fatalf("SendCommand: %v", err)
+	}
+	if !result.Success || result.CommandId != cmd.Id {
+		t.Errorf("result = %+v, want success for command %s", result, cmd.Id)
+	}
+	if got := <-handler.commands; got.GetSetReportInterval().GetIntervalSeconds() != 60 {
+		t.Errorf("agent received %v, want an interval of 60s", got)
+	}
+
+	var logs []*agentpb.PodLog
+	err = ts.streams.StreamLogs(ctx, "prod", &agentpb.LogRequest{Namespace: "default", PodName: "web"}, func(s *agentpb.LogStream) error {
+		logs = append(logs, s.Logs...)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamLogs: %v", err)
+	}
+	if len(logs) != 1 || logs[0].LogLine != "hello" || logs[0].PodName != "web" {
+		t.Errorf("relayed logs = %v, want the agent's line", logs)
+	}
+
+	if _, err := ts.streams.SendCommand(ctx, "staging", cmd); !errors.Is(err, ErrAgentNotConnected) {
+		t.Errorf("SendCommand to a cluster without an agent returned %v, want ErrAgentNotConnected", err)
+	}
+}
+
+func TestStreamReplacement(t *testing.T) {
+	ts := newTestServer(t, "")
+	first, closeFirst := ts.rawStream(t, "token-a", "prod")
+	eventually(t, "the first stream is connected", func() bool { return ts.streams.Connected("prod") })
+	old := ts.streamFor("prod")
+
+	// Another agent can't take over the cluster
+	other, _ := ts.rawStream(t, "token-b", "prod")
+	if _, err := other.Recv(); status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("second agent's stream ended with %v, want AlreadyExists", err)
+	}
+	if ts.streamFor("prod") != old {
+		t.Fatalf("another agent replaced the cluster's stream")
+	}
+
+	// The same agent reconnecting replaces its stream
+	second, _ := ts.rawStream(t, "token-a", "prod")
+	eventually(t, "the stream is replaced", func() bool { return ts.streamFor("prod") != old })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	results := make(chan error, 1)
+	go func() {
+		_, err := ts.streams.SendCommand(ctx, "prod", &agentpb.Command{Action: &agentpb.Command_Resync{Resync: &agentpb.RequestResync{}}})
+		results <- err
+	}()
+	msg, err := second.Recv()
+	if err != nil || msg.GetCommand().GetResync() == nil {
+		t.Fatalf("replacing stream received %v, %v, want the command", msg, err)
+	}
+	err = second.Send(&agentpb.AgentMessage{Payload: &agentpb.AgentMessage_Result{Result: &agentpb.CommandResult{
+		CommandId: msg.GetCommand().Id,
+		Success:   true,
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-results; err != nil {
+		t.Fatalf("SendCommand: %v", err)
+	}
+
+	// The replaced stream ending leaves the new one in place
+	closeFirst()
+	first.Recv()
+	time.Sleep(50 * time.Millisecond)
+	if !ts.streams.Connected("prod") {
+		t.Fatalf("closing the replaced stream disconnected the cluster")
+	}
+}
+
+func TestStreamDisconnectCleanup(t *testing.T) {
+	ts := newTestServer(t, "")
+	stream, cancelAgent := ts.agent(t, "token-a", &fakeHandler{commands: make(chan *agentpb.Command)})
+	eventually(t, "the agent is connected", func() bool { return ts.streams.Connected("prod") && stream.Connected() })
+
+	// A command the agent never answers fails once the stream is gone
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	results := make(chan error, 1)
+	go func() {
+		_, err := ts.streams.SendCommand(ctx, "prod", &agentpb.Command{Action: &agentpb.Command_Resync{Resync: &agentpb.RequestResync{}}})
+		results <- err
+	}()
+	conn := ts.streamFor("prod")
+	eventually(t, "the command is pending", func() bool {
+		conn.mu.Lock()
+		defer conn.mu.Unlock()
+		return len(conn.pending) == 1
+	})
+
+	cancelAgent()
+	eventually(t, "the stream is removed", func() bool { return !ts.streams.Connected("prod") })
+	if err := <-results; !errors.Is(err, ErrAgentNotConnected) {
+		t.Errorf("pending command returned %v, want ErrAgentNotConnected", err)
+	}
+
+	// Another agent may connect once the cluster's stream is gone
+	other, _ := ts.rawStream(t, "token-b", "prod")
+	eventually(t, "the other agent is connected", func() bool { return ts.streams.Connected("prod") })
+	if owner := ts.streamFor("prod").owner; owner != "agent-b" {
+		t.Errorf("stream owner = %q, want agent-b", owner)
+	}
+	other.CloseSend()
+}
+
+func TestStreamClusterBinding(t *testing.T) {
+	ts := newTestServer(t, `{"rules": [{"subjects": ["agent-a"], "clusters": ["prod"], "namespaces": ["*"]}]}`)
+
+	denied, _ := ts.rawStream(t, "token-b", "prod")
+	if _, err := denied.Recv(); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("stream for an unbound cluster ended with %v, want PermissionDenied", err)
+	}
+
+	stream, _ := ts.rawStream(t, "token-a", "prod")
+	eventually(t, "the bound agent is connected", func() bool { return ts.streams.Connected("prod") })
+	err := stream.Send(&agentpb.AgentMessage{Payload: &agentpb.AgentMessage_Report{Report: &agentpb.DeltaReport{
+		Identity: &agentpb.AgentIdentity{ClusterName: "staging"},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("report for another cluster ended the stream with %v, want PermissionDenied", err)
+	}
+	select {
+	case report := <-ts.reports:
+		t.Errorf("server handled report for %s", report.Identity.ClusterName)
+	default:
+	}
+}
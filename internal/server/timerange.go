@@ -0,0 +1,104 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	agentpb "github.com/thekubefleet/kubefleet/proto"
+)
+
+// timeRange is a from/to/step window parsed from query parameters
+type timeRange struct {
+	from int64
+	to   int64
+	step int64
+}
+
+// parseTimeRange reads the from, to and step query parameters. It returns
+// false when none of them are set. Times are Unix seconds or RFC3339 and
+// step is a Go duration such as "5m" or a number of seconds.
+func parseTimeRange(query url.Values) (timeRange, bool, error) {
+	if query.Get("from") == "" && query.Get("to") == "" && query.Get("step") == "" {
+		return timeRange{}, false, nil
+	}
+
+	tr := timeRange{from: 0, to: time.Now().Unix()}
+	var err error
+	if value := query.Get("from"); value != "" {
+		if tr.from, err = parseTime(value); err != nil {
+			return tr, true, fmt.Errorf("invalid from: %w", err)
+		}
+	}
+	if value := query.Get("to"); value != "" {
+		if tr.to, err = parseTime(value); err != nil {
+			return tr, true, fmt.Errorf("invalid to: %w", err)
+		}
+	}
+	if tr.from > tr.to {
+		return tr, true, fmt.Errorf("from must not be after to")
+	}
+	if value := query.Get("step"); value != "" {
+		if tr.step, err = parseStep(value); err != nil {
+			return tr, true, fmt.Errorf("invalid step: %w", err)
+		}
+	}
+	return tr, true, nil
+}
+
+func parseTime(value string) (int64, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0, fmt.Errorf("%d is negative", seconds)
+		}
+		return seconds, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is neither Unix seconds nor RFC3339", value)
+	}
+	return t.Unix(), nil
+}
+
+func parseStep(value string) (int64, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds <= 0 {
+			return 0, fmt.Errorf("%d is not positive", seconds)
+		}
+		return seconds, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d < time.Second {
+		return 0, fmt.Errorf("%s is shorter than a second", value)
+	}
+	return int64(d / time.Second), nil
+}
+
+// downsample keeps the latest data point of each cluster in every step-sized
+// bucket of the window. data must be ordered by timestamp.
+func downsample(data []*agentpb.AgentData, from, step int64) []*agentpb.AgentData {
+	if step <= 0 {
+		return data
+	}
+
+	type bucketKey struct {
+		cluster string
+		bucket  int64
+	}
+	index := make(map[bucketKey]int)
+	result := make([]*agentpb.AgentData, 0)
+	for _, d := range data {
+		key := bucketKey{cluster: ClusterKey(d.Identity), bucket: (d.Timestamp - from) / step}
+		if i, ok := index[key]; ok {
+			result[i] = d
+			continue
+		}
+		index[key] = len(result)
+		result = append(result, d)
+	}
+	return result
+}
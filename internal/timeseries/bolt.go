@@ -0,0 +1,99 @@
+package timeseries
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/thekubefleet/kubefleet/internal/boltdb"
+)
+
+// rollupsBucket maps a tier, series key and timestamp to a closed rollup
+// point's rollupRecord. Keys end in the timestamp as 8 big-endian bytes, so
+// each series' points load in time order.
+var rollupsBucket = []byte("rollups")
+
+type rollupRecord struct {
+	Tier Tier      `json:"tier"`
+	Key  SeriesKey `json:"key"`
+	Point
+}
+
+// Open opens or creates a store whose rollup tiers are persisted in the
+// bbolt database at path, loading the points still within retention
+func Open(path string, retention Retention) (*Store, error) {
+	s := NewStore(retention)
+	db, err := boltdb.OpenBucket(path, "series store", rollupsBucket, s.load)
+	if err != nil {
+		return nil, err
+	}
+	s.db = db
+
+	boltdb.EnforceRetention("series", s)
+	return s, nil
+}
+
+// load reads a stored rollup point into memory
+func (s *Store) load(key, value []byte) error {
+	var record rollupRecord
+	if err := json.Unmarshal(value, &record); err != nil {
+		log.Printf("Skipping unreadable rollup %q", key)
+		return nil
+	}
+	ser, ok := s.series[record.Key]
+	if !ok {
+		ser = newSeries()
+		s.series[record.Key] = ser
+	}
+	ser.points[record.Tier] = append(ser.points[record.Tier], record.Point)
+	return nil
+}
+
+func (r rollupRecord) ref() rollupRef {
+	return rollupRef{r.Tier, r.Key, r.Timestamp}
+}
+
+func (r rollupRef) bytes() []byte {
+	prefix := strings.Join([]string{string(r.tier), r.key.Cluster, r.key.Namespace, r.key.Kind, r.key.Name, r.key.Metric}, "\x00")
+	return append([]byte(prefix+"\x00"), boltdb.Key(uint64(r.timestamp))...)
+}
+
+// persist writes closed rollup points and deletes evicted ones
+func (s *Store) persist(closed []rollupRecord, evicted []rollupRef) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(rollupsBucket)
+		for _, ref := range evicted {
+			if err := bucket.Delete(ref.bytes()); err != nil {
+				return err
+			}
+		}
+		for _, record := range closed {
+			value, err := json.Marshal(record)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put(record.ref().bytes(), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to persist rollups: %w", err)
+	}
+	return nil
+}
+
+// Close closes the database of a persistent store
+func (s *Store) Close() error {
+	if s.db == nil {
+		return nil
+	}
+	if err := s.db.Close(); err != nil {
+		return fmt.Errorf("failed to close series store: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,376 @@
+package timeseries
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/thekubefleet/kubefleet/internal/boltdb"
+	agentpb "github.com/thekubefleet/kubefleet/proto"
+)
+
+// Tier is the resolution a series is stored at
+type Tier string
+
+const (
+	TierRaw        Tier = "raw"
+	TierFiveMinute Tier = "5m"
+	TierHour       Tier = "1h"
+)
+
+// rollupTiers are the aggregated tiers and their bucket widths in seconds
+var rollupTiers = []struct {
+	tier  Tier
+	width int64
+}{
+	{TierFiveMinute, 5 * 60},
+	{TierHour, 60 * 60},
+}
+
+// Metric names recorded for each ResourceMetrics sample
+const (
+	MetricCPU    = "cpu"    // cores
+	MetricMemory = "memory" // MiB
+)
+
+// ParseTier validates a tier name
+func ParseTier(value string) (Tier, error) {
+	switch Tier(value) {
+	case TierRaw, TierFiveMinute, TierHour:
+		return Tier(value), nil
+	}
+	return "", fmt.Errorf("unknown tier %q, expected raw, 5m or 1h", value)
+}
+
+// SeriesKey identifies a series
+type SeriesKey struct {
+	Cluster   string `json:"cluster"`
+	Namespace string `json:"namespace"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Metric    string `json:"metric"`
+}
+
+// Point is a sample or a rollup of samples. Raw samples have Count 1 and
+// the value in every field.
+type Point struct {
+	Timestamp int64   `json:"timestamp"`
+	Min       float64 `json:"min"`
+	Max       float64 `json:"max"`
+	Avg       float64 `json:"avg"`
+	P95       float64 `json:"p95"`
+	Count     int     `json:"count"`
+}
+
+// Series is a query result
+type Series struct {
+	SeriesKey
+	Tier   Tier    `json:"tier"`
+	Points []Point `json:"points"`
+}
+
+// Retention is how long each tier is kept
+type Retention struct {
+	Raw        time.Duration
+	FiveMinute time.Duration
+	Hour       time.Duration
+}
+
+func (r Retention) forTier(tier Tier) time.Duration {
+	switch tier {
+	case TierFiveMinute:
+		return r.FiveMinute
+	case TierHour:
+		return r.Hour
+	}
+	return r.Raw
+}
+
+// bucket collects the samples of a rollup period that hasn't closed yet
+type bucket struct {
+	start  int64
+	values []float64
+}
+
+type series struct {
+	points map[Tier][]Point
+	open   map[Tier]*bucket
+}
+
+func newSeries() *series {
+	return &series{
+		points: make(map[Tier][]Point),
+		open:   make(map[Tier]*bucket),
+	}
+}
+
+// rollupRef identifies a closed rollup point
+type rollupRef struct {
+	tier      Tier
+	key       SeriesKey
+	timestamp int64
+}
+
+// Store keeps metric series at raw, 5-minute and 1-hour resolution, each
+// tier with its own retention. A persistent store also keeps the closed
+// rollup points on disk, so they outlive the data store's history; raw
+// points and still-open periods are rebuilt from the data store on startup.
+type Store struct {
+	mu        sync.RWMutex
+	series    map[SeriesKey]*series
+	retention Retention
+	db        *bolt.DB // nil for an in-memory store
+	now       func() time.Time
+}
+
+// NewStore creates an empty in-memory store
+func NewStore(retention Retention) *Store {
+	return &Store{
+		series:    make(map[SeriesKey]*series),
+		retention: retention,
+		now:       time.Now,
+	}
+}
+
+// IngestMetrics records the CPU and memory of every resource in a report
+func (s *Store) IngestMetrics(cluster string, timestamp int64, metrics []*agentpb.ResourceMetrics) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var closed []rollupRecord
+	for _, m := range metrics {
+		key := SeriesKey{Cluster: cluster, Namespace: m.Namespace, Kind: m.Kind, Name: m.Name}
+
+		key.Metric = MetricCPU
+		closed = append(closed, s.add(key, timestamp, m.Cpu)...)
+		key.Metric = MetricMemory
+		closed = append(closed, s.add(key, timestamp, m.Memory)...)
+	}
+	if s.db != nil && len(closed) > 0 {
+		return s.persist(closed, nil)
+	}
+	return nil
+}
+
+// Append adds a sample to a series. Samples at or before the series' latest
+// timestamp are ignored, so re-sent reports aren't counted twice.
+func (s *Store) Append(key SeriesKey, timestamp int64, value float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	closed := s.add(key, timestamp, value)
+	if s.db != nil && len(closed) > 0 {
+		return s.persist(closed, nil)
+	}
+	return nil
+}
+
+// add adds a sample to a series and returns the rollup points it closed.
+// Callers must hold s.mu.
+func (s *Store) add(key SeriesKey, timestamp int64, value float64) []rollupRecord {
+	ser, ok := s.series[key]
+	if !ok {
+		ser = newSeries()
+		s.series[key] = ser
+	}
+
+	raw := ser.points[TierRaw]
+	if len(raw) > 0 && timestamp <= raw[len(raw)-1].Timestamp {
+		return nil
+	}
+	ser.points[TierRaw] = append(raw, Point{
+		Timestamp: timestamp,
+		Min:       value,
+		Max:       value,
+		Avg:       value,
+		P95:       value,
+		Count:     1,
+	})
+
+	var closed []rollupRecord
+	for _, rt := range rollupTiers {
+		start := timestamp - timestamp%rt.width
+		if points := ser.points[rt.tier]; len(points) > 0 && start <= points[len(points)-1].Timestamp {
+			// The period was closed before a restart, and the data store is
+			// replaying its samples
+			continue
+		}
+		open := ser.open[rt.tier]
+		if open != nil && open.start != start {
+			// The sample starts a new period, so the previous one is complete
+			point := open.rollup()
+			ser.points[rt.tier] = append(ser.points[rt.tier], point)
+			closed = append(closed, rollupRecord{Tier: rt.tier, Key: key, Point: point})
+			open = nil
+		}
+		if open == nil {
+			open = &bucket{start: start}
+			ser.open[rt.tier] = open
+		}
+		open.values = append(open.values, value)
+	}
+	return closed
+}
+
+// rollup aggregates the bucket's samples
+func (b *bucket) rollup() Point {
+	sorted := append([]float64(nil), b.values...)
+	sort.Float64s(sorted)
+
+	sum := 0.0
+	for _, v := range sorted {
+		sum += v
+	}
+	// Nearest-rank 95th percentile
+	rank := int(math.Ceil(0.95*float64(len(sorted)))) - 1
+
+	return Point{
+		Timestamp: b.start,
+		Min:       sorted[0],
+		Max:       sorted[len(sorted)-1],
+		Avg:       sum / float64(len(sorted)),
+		P95:       sorted[rank],
+		Count:     len(sorted),
+	}
+}
+
+// Query selects series for a window. Empty key fields match any value.
+type Query struct {
+	SeriesKey
+	From int64
+	To   int64
+	Tier Tier
+}
+
+func (q Query) matches(key SeriesKey) bool {
+	return (q.Cluster == "" || q.Cluster == key.Cluster) &&
+		(q.Namespace == "" || q.Namespace == key.Namespace) &&
+		(q.Kind == "" || q.Kind == key.Kind) &&
+		(q.Name == "" || q.Name == key.Name) &&
+		(q.Metric == "" || q.Metric == key.Metric)
+}
+
+// Query returns the points of every matching series in the window, including
+// the still-open period of rollup tiers. Series are sorted by key.
+func (s *Store) Query(q Query) []Series {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]Series, 0)
+	for key, ser := range s.series {
+		if !q.matches(key) {
+			continue
+		}
+
+		points := ser.points[q.Tier]
+		if open := ser.open[q.Tier]; open != nil {
+			points = append(points[:len(points):len(points)], open.rollup())
+		}
+		start := sort.Search(len(points), func(i int) bool {
+			return points[i].Timestamp >= q.From
+		})
+		end := sort.Search(len(points), func(i int) bool {
+			return points[i].Timestamp > q.To
+		})
+		if start >= end {
+			continue
+		}
+
+		result = append(result, Series{
+			SeriesKey: key,
+			Tier:      q.Tier,
+			Points:    append([]Point(nil), points[start:end]...),
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i].SeriesKey, result[j].SeriesKey
+		if a.Cluster != b.Cluster {
+			return a.Cluster < b.Cluster
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Metric < b.Metric
+	})
+	return result
+}
+
+// TierFor picks the finest tier whose retention covers a window starting at from
+func (s *Store) TierFor(from int64, now time.Time) Tier {
+	age := now.Sub(time.Unix(from, 0))
+	switch {
+	case age <= s.retention.Raw:
+		return TierRaw
+	case age <= s.retention.FiveMinute:
+		return TierFiveMinute
+	default:
+		return TierHour
+	}
+}
+
+// EnforceRetention drops points older than their tier's retention and
+// forgets series with nothing left
+func (s *Store) EnforceRetention() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	var evicted []rollupRef
+	for key, ser := range s.series {
+		empty := true
+		for _, tier := range []Tier{TierRaw, TierFiveMinute, TierHour} {
+			points := ser.points[tier]
+			if retention := s.retention.forTier(tier); retention > 0 {
+				cutoff := now.Add(-retention).Unix()
+				expired := sort.Search(len(points), func(i int) bool {
+					return points[i].Timestamp >= cutoff
+				})
+				if tier != TierRaw {
+					for _, point := range points[:expired] {
+						evicted = append(evicted, rollupRef{tier, key, point.Timestamp})
+					}
+				}
+				points = points[expired:]
+				ser.points[tier] = points
+			}
+			if len(points) > 0 {
+				empty = false
+			}
+		}
+
+		// A series that stopped reporting goes once its history has expired,
+		// along with any period it left open
+		if empty {
+			delete(s.series, key)
+		}
+	}
+
+	if s.db != nil && len(evicted) > 0 {
+		return s.persist(nil, evicted)
+	}
+	return nil
+}
+
+// Run enforces retention every interval until ctx is done
+func (s *Store) Run(ctx context.Context, interval time.Duration) {
+	boltdb.RunRetention(ctx, interval, "series", s)
+}
+
+// SeriesCount returns the number of series in the store
+func (s *Store) SeriesCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.series)
+}
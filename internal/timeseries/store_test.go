@@ -0,0 +1,239 @@
+package timeseries
+
+import (
+	"math"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// start is on the hour, so it begins a period of every tier
+var start = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+var cpuKey = SeriesKey{Cluster: "prod", Namespace: "default", Kind: "Pod", Name: "web", Metric: MetricCPU}
+
+// newTestStore returns a store whose clock reads now
+func newTestStore(retention Retention, now *time.Time) *Store {
+	s := NewStore(retention)
+	s.now = func() time.Time { return *now }
+	return s
+}
+
+// at returns the unix time of an offset from start
+func at(offset time.Duration) int64 {
+	return start.Add(offset).Unix()
+}
+
+// points returns the points of cpuKey in a tier
+func points(s *Store, tier Tier) []Point {
+	result := s.Query(Query{SeriesKey: cpuKey, From: 0, To: math.MaxInt64, Tier: tier})
+	if len(result) == 0 {
+		return nil
+	}
+	return result[0].Points
+}
+
+func timestamps(points []Point) []int64 {
+	var ts []int64
+	for _, p := range points {
+		ts = append(ts, p.Timestamp)
+	}
+	return ts
+}
+
+func TestRollupBoundaries(t *testing.T) {
+	s := NewStore(Retention{})
+	s.Append(cpuKey, at(0), 1)
+	s.Append(cpuKey, at(time.Minute), 2)
+	s.Append(cpuKey, at(5*time.Minute-time.Second), 6)
+
+	// The open period is returned before it closes
+	want := []Point{{Timestamp: at(0), Min: 1, Max: 6, Avg: 3, P95: 6, Count: 3}}
+	if got := points(s, TierFiveMinute); !slices.Equal(got, want) {
+		t.Errorf("5m points = %+v, want %+v", got, want)
+	}
+
+	// A sample on the boundary starts the next period
+	s.Append(cpuKey, at(5*time.Minute), 10)
+	want = []Point{
+		{Timestamp: at(0), Min: 1, Max: 6, Avg: 3, P95: 6, Count: 3},
+		{Timestamp: at(5 * time.Minute), Min: 10, Max: 10, Avg: 10, P95: 10, Count: 1},
+	}
+	if got := points(s, TierFiveMinute); !slices.Equal(got, want) {
+		t.Errorf("5m points = %+v, want %+v", got, want)
+	}
+	if got := points(s, TierHour); len(got) != 1 || got[0].Timestamp != at(0) || got[0].Count != 4 {
+		t.Errorf("1h points = %+v, want one period of 4 samples", got)
+	}
+
+	s.Append(cpuKey, at(time.Hour), 3)
+	if got := timestamps(points(s, TierHour)); !slices.Equal(got, []int64{at(0), at(time.Hour)}) {
+		t.Errorf("1h periods start at %v, want at 10:00 and 11:00", got)
+	}
+	if got := timestamps(points(s, TierFiveMinute)); !slices.Equal(got, []int64{at(0), at(5 * time.Minute), at(time.Hour)}) {
+		t.Errorf("5m periods start at %v, want at 10:00, 10:05 and 11:00", got)
+	}
+	if got := len(points(s, TierRaw)); got != 5 {
+		t.Errorf("%d raw points, want 5", got)
+	}
+}
+
+func TestRollupP95(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   float64
+	}{
+		{name: "one sample", values: []float64{7}, want: 7},
+		{name: "ten samples", values: []float64{10, 9, 8, 7, 6, 5, 4, 3, 2, 1}, want: 10},
+		{name: "twenty samples", values: []float64{20, 19, 18, 17, 16, 15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1}, want: 19},
+		{name: "twenty-one samples", values: []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21}, want: 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &bucket{values: tt.values}
+			if got := b.rollup().P95; got != tt.want {
+				t.Errorf("p95 = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTierFor(t *testing.T) {
+	s := NewStore(Retention{Raw: 24 * time.Hour, FiveMinute: 7 * 24 * time.Hour, Hour: 90 * 24 * time.Hour})
+	now := start
+	tests := []struct {
+		name string
+		age  time.Duration
+		want Tier
+	}{
+		{name: "last hour", age: time.Hour, want: TierRaw},
+		{name: "raw retention", age: 24 * time.Hour, want: TierRaw},
+		{name: "beyond raw retention", age: 24*time.Hour + time.Second, want: TierFiveMinute},
+		{name: "5m retention", age: 7 * 24 * time.Hour, want: TierFiveMinute},
+		{name: "beyond 5m retention", age: 30 * 24 * time.Hour, want: TierHour},
+		{name: "beyond 1h retention", age: 365 * 24 * time.Hour, want: TierHour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.TierFor(now.Add(-tt.age).Unix(), now); got != tt.want {
+				t.Errorf("TierFor() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnforceRetentionPerTier(t *testing.T) {
+	now := start
+	s := newTestStore(Retention{Raw: 10 * time.Minute, FiveMinute: time.Hour}, &now)
+	for offset := time.Duration(0); offset <= 2*time.Hour; offset += time.Minute {
+		s.Append(cpuKey, at(offset), 1)
+	}
+
+	now = start.Add(2 * time.Hour)
+	if err := s.EnforceRetention(); err != nil {
+		t.Fatalf("EnforceRetention: %v", err)
+	}
+	if got := timestamps(points(s, TierRaw)); len(got) != 11 || got[0] != at(110*time.Minute) {
+		t.Errorf("raw points at %v, want the last 10 minutes", got)
+	}
+	// The period still open is kept along with the closed ones
+	if got := timestamps(points(s, TierFiveMinute)); len(got) != 13 || got[0] != at(time.Hour) {
+		t.Errorf("5m points at %v, want the last hour", got)
+	}
+	// No retention keeps every period
+	if got := timestamps(points(s, TierHour)); !slices.Equal(got, []int64{at(0), at(time.Hour), at(2 * time.Hour)}) {
+		t.Errorf("1h points at %v, want all of them", got)
+	}
+
+	// A series goes once every tier has expired
+	s.retention.Hour = 24 * time.Hour
+	now = start.Add(30 * time.Hour)
+	if err := s.EnforceRetention(); err != nil {
+		t.Fatalf("EnforceRetention: %v", err)
+	}
+	if s.SeriesCount() != 0 {
+		t.Errorf("%d series left, want none", s.SeriesCount())
+	}
+}
+
+func TestAppendIgnoresOldSamples(t *testing.T) {
+	s := NewStore(Retention{})
+	s.Append(cpuKey, at(2*time.Minute), 2)
+	// A re-sent report and one that arrives late
+	s.Append(cpuKey, at(2*time.Minute), 5)
+	s.Append(cpuKey, at(time.Minute), 9)
+	s.Append(cpuKey, at(3*time.Minute), 4)
+
+	want := []Point{
+		{Timestamp: at(2 * time.Minute), Min: 2, Max: 2, Avg: 2, P95: 2, Count: 1},
+		{Timestamp: at(3 * time.Minute), Min: 4, Max: 4, Avg: 4, P95: 4, Count: 1},
+	}
+	if got := points(s, TierRaw); !slices.Equal(got, want) {
+		t.Errorf("raw points = %+v, want %+v", got, want)
+	}
+	if got := points(s, TierFiveMinute); len(got) != 1 || got[0].Count != 2 || got[0].Avg != 3 {
+		t.Errorf("5m points = %+v, want one of the 2 accepted samples", got)
+	}
+}
+
+func TestOpenReloadsRollups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "series.db")
+	retention := Retention{Raw: time.Hour, FiveMinute: 24 * time.Hour}
+	// Open enforces retention on the wall clock, so the samples are recent
+	origin := time.Now().Truncate(time.Hour).Add(-time.Hour)
+	appendSamples := func(s *Store) {
+		for offset := time.Duration(0); offset <= 20*time.Minute; offset += time.Minute {
+			if err := s.Append(cpuKey, origin.Add(offset).Unix(), float64(offset/time.Minute)); err != nil {
+				t.Fatalf("Append: %v", err)
+			}
+		}
+	}
+
+	s, err := Open(path, retention)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	appendSamples(s)
+	closed := points(s, TierFiveMinute)[:4]
+	s.Close()
+
+	s, err = Open(path, retention)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	// Closed periods are reloaded, the raw tier and open periods aren't
+	if got := points(s, TierFiveMinute); !slices.Equal(got, closed) {
+		t.Errorf("5m points = %+v, want %+v", got, closed)
+	}
+	if got := points(s, TierRaw); len(got) != 0 {
+		t.Errorf("raw points = %+v, want none", got)
+	}
+
+	// Replaying the data store's samples doesn't count closed periods twice
+	appendSamples(s)
+	got := points(s, TierFiveMinute)
+	if len(got) != 5 || !slices.Equal(got[:4], closed) || got[4].Count != 1 {
+		t.Errorf("5m points after the replay = %+v", got)
+	}
+	if got := points(s, TierHour); len(got) != 1 || got[0].Count != 21 {
+		t.Errorf("1h points after the replay = %+v, want the open hour rebuilt", got)
+	}
+
+	// Expired periods are deleted from disk
+	s.now = func() time.Time { return origin.Add(24*time.Hour + 10*time.Minute) }
+	if err := s.EnforceRetention(); err != nil {
+		t.Fatalf("EnforceRetention: %v", err)
+	}
+	s.Close()
+	s, err = Open(path, retention)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+	want := []int64{origin.Add(10 * time.Minute).Unix(), origin.Add(15 * time.Minute).Unix()}
+	if got := timestamps(points(s, TierFiveMinute)); !slices.Equal(got, want) {
+		t.Errorf("5m points at %v after retention, want %v", got, want)
+	}
+}
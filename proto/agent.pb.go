@@ -21,30 +21,30 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
-// Namespace and resource info
-type ResourceInfo struct {
+// The owner of a pod, e.g. its ReplicaSet
+type OwnerReference struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Namespace     string                 `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
-	Pods          []string               `protobuf:"bytes,2,rep,name=pods,proto3" json:"pods,omitempty"`
-	Deployments   []string               `protobuf:"bytes,3,rep,name=deployments,proto3" json:"deployments,omitempty"` // Add more resource types as needed
+	Kind          string                 `protobuf:"bytes,1,opt,name=kind,proto3" json:"kind,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Controller    bool                   `protobuf:"varint,3,opt,name=controller,proto3" json:"controller,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ResourceInfo) Reset() {
-	*x = ResourceInfo{}
+func (x *OwnerReference) Reset() {
+	*x = OwnerReference{}
 	mi := &file_proto_agent_proto_msgTypes[0]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ResourceInfo) String() string {
+func (x *OwnerReference) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ResourceInfo) ProtoMessage() {}
+func (*OwnerReference) ProtoMessage() {}
 
-func (x *ResourceInfo) ProtoReflect() protoreflect.Message {
+func (x *OwnerReference) ProtoReflect() protoreflect.Message {
 	mi := &file_proto_agent_proto_msgTypes[0]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -56,59 +56,356 @@ func (x *ResourceInfo) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ResourceInfo.ProtoReflect.Descriptor instead.
-func (*ResourceInfo) Descriptor() ([]byte, []int) {
+// Deprecated: Use OwnerReference.ProtoReflect.Descriptor instead.
+func (*OwnerReference) Descriptor() ([]byte, []int) {
 	return file_proto_agent_proto_rawDescGZIP(), []int{0}
 }
 
-func (x *ResourceInfo) GetNamespace() string {
+func (x *OwnerReference) GetKind() string {
 	if x != nil {
-		return x.Namespace
+		return x.Kind
 	}
 	return ""
 }
 
-func (x *ResourceInfo) GetPods() []string {
+func (x *OwnerReference) GetName() string {
 	if x != nil {
-		return x.Pods
+		return x.Name
+	}
+	return ""
+}
+
+func (x *OwnerReference) GetController() bool {
+	if x != nil {
+		return x.Controller
+	}
+	return false
+}
+
+// A pod condition, e.g. PodScheduled or Ready
+type PodCondition struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	Type               string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	Status             string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"` // True, False or Unknown
+	Reason             string                 `protobuf:"bytes,3,opt,name=reason,proto3" json:"reason,omitempty"`
+	Message            string                 `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`
+	LastTransitionTime int64                  `protobuf:"varint,5,opt,name=last_transition_time,json=lastTransitionTime,proto3" json:"last_transition_time,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *PodCondition) Reset() {
+	*x = PodCondition{}
+	mi := &file_proto_agent_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PodCondition) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PodCondition) ProtoMessage() {}
+
+func (x *PodCondition) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_agent_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PodCondition.ProtoReflect.Descriptor instead.
+func (*PodCondition) Descriptor() ([]byte, []int) {
+	return file_proto_agent_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *PodCondition) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *PodCondition) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *PodCondition) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *PodCondition) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *PodCondition) GetLastTransitionTime() int64 {
+	if x != nil {
+		return x.LastTransitionTime
+	}
+	return 0
+}
+
+// How a container instance terminated
+type ContainerTermination struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Reason        string                 `protobuf:"bytes,1,opt,name=reason,proto3" json:"reason,omitempty"` // e.g. OOMKilled, Error or Completed
+	ExitCode      int32                  `protobuf:"varint,2,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
+	Signal        int32                  `protobuf:"varint,3,opt,name=signal,proto3" json:"signal,omitempty"`
+	Message       string                 `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`
+	StartedAt     int64                  `protobuf:"varint,5,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
+	FinishedAt    int64                  `protobuf:"varint,6,opt,name=finished_at,json=finishedAt,proto3" json:"finished_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ContainerTermination) Reset() {
+	*x = ContainerTermination{}
+	mi := &file_proto_agent_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ContainerTermination) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ContainerTermination) ProtoMessage() {}
+
+func (x *ContainerTermination) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_agent_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ContainerTermination.ProtoReflect.Descriptor instead.
+func (*ContainerTermination) Descriptor() ([]byte, []int) {
+	return file_proto_agent_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ContainerTermination) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *ContainerTermination) GetExitCode() int32 {
+	if x != nil {
+		return x.ExitCode
+	}
+	return 0
+}
+
+func (x *ContainerTermination) GetSignal() int32 {
+	if x != nil {
+		return x.Signal
+	}
+	return 0
+}
+
+func (x *ContainerTermination) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *ContainerTermination) GetStartedAt() int64 {
+	if x != nil {
+		return x.StartedAt
+	}
+	return 0
+}
+
+func (x *ContainerTermination) GetFinishedAt() int64 {
+	if x != nil {
+		return x.FinishedAt
+	}
+	return 0
+}
+
+// The status of one container of a pod
+type ContainerInfo struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Name            string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Image           string                 `protobuf:"bytes,2,opt,name=image,proto3" json:"image,omitempty"`
+	Init            bool                   `protobuf:"varint,3,opt,name=init,proto3" json:"init,omitempty"`
+	Ready           bool                   `protobuf:"varint,4,opt,name=ready,proto3" json:"ready,omitempty"`
+	RestartCount    int32                  `protobuf:"varint,5,opt,name=restart_count,json=restartCount,proto3" json:"restart_count,omitempty"`
+	State           string                 `protobuf:"bytes,6,opt,name=state,proto3" json:"state,omitempty"`   // waiting, running or terminated
+	Reason          string                 `protobuf:"bytes,7,opt,name=reason,proto3" json:"reason,omitempty"` // Why it is waiting or terminated, e.g. CrashLoopBackOff
+	Message         string                 `protobuf:"bytes,8,opt,name=message,proto3" json:"message,omitempty"`
+	StartedAt       int64                  `protobuf:"varint,9,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`                   // When the current instance started
+	Termination     *ContainerTermination  `protobuf:"bytes,10,opt,name=termination,proto3" json:"termination,omitempty"`                                // Set while terminated
+	LastTermination *ContainerTermination  `protobuf:"bytes,11,opt,name=last_termination,json=lastTermination,proto3" json:"last_termination,omitempty"` // The previous instance's, after a restart
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *ContainerInfo) Reset() {
+	*x = ContainerInfo{}
+	mi := &file_proto_agent_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ContainerInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ContainerInfo) ProtoMessage() {}
+
+func (x *ContainerInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_agent_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ContainerInfo.ProtoReflect.Descriptor instead.
+func (*ContainerInfo) Descriptor() ([]byte, []int) {
+	return file_proto_agent_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ContainerInfo) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ContainerInfo) GetImage() string {
+	if x != nil {
+		return x.Image
+	}
+	return ""
+}
+
+func (x *ContainerInfo) GetInit() bool {
+	if x != nil {
+		return x.Init
+	}
+	return false
+}
+
+func (x *ContainerInfo) GetReady() bool {
+	if x != nil {
+		return x.Ready
+	}
+	return false
+}
+
+func (x *ContainerInfo) GetRestartCount() int32 {
+	if x != nil {
+		return x.RestartCount
+	}
+	return 0
+}
+
+func (x *ContainerInfo) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *ContainerInfo) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *ContainerInfo) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *ContainerInfo) GetStartedAt() int64 {
+	if x != nil {
+		return x.StartedAt
+	}
+	return 0
+}
+
+func (x *ContainerInfo) GetTermination() *ContainerTermination {
+	if x != nil {
+		return x.Termination
 	}
 	return nil
 }
 
-func (x *ResourceInfo) GetDeployments() []string {
+func (x *ContainerInfo) GetLastTermination() *ContainerTermination {
 	if x != nil {
-		return x.Deployments
+		return x.LastTermination
 	}
 	return nil
 }
 
-// Performance metrics for a resource
-type ResourceMetrics struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Namespace     string                 `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
-	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
-	Kind          string                 `protobuf:"bytes,3,opt,name=kind,proto3" json:"kind,omitempty"` // Pod, Deployment, etc.
-	Cpu           float64                `protobuf:"fixed64,4,opt,name=cpu,proto3" json:"cpu,omitempty"`
-	Memory        float64                `protobuf:"fixed64,5,opt,name=memory,proto3" json:"memory,omitempty"`
+// A pod and its status. Timestamps are Unix seconds.
+type PodInfo struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Phase string                 `protobuf:"bytes,2,opt,name=phase,proto3" json:"phase,omitempty"` // Pending, Running, Succeeded, Failed or Unknown
+	// What kubectl get pods shows, e.g. Running, CrashLoopBackOff,
+	// Init:Error, Completed or Terminating
+	Status        string            `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
+	Reason        string            `protobuf:"bytes,4,opt,name=reason,proto3" json:"reason,omitempty"` // Set by the kubelet, e.g. Evicted
+	Message       string            `protobuf:"bytes,5,opt,name=message,proto3" json:"message,omitempty"`
+	Node          string            `protobuf:"bytes,6,opt,name=node,proto3" json:"node,omitempty"`
+	PodIp         string            `protobuf:"bytes,7,opt,name=pod_ip,json=podIp,proto3" json:"pod_ip,omitempty"`
+	Owners        []*OwnerReference `protobuf:"bytes,8,rep,name=owners,proto3" json:"owners,omitempty"`
+	Conditions    []*PodCondition   `protobuf:"bytes,9,rep,name=conditions,proto3" json:"conditions,omitempty"`
+	Containers    []*ContainerInfo  `protobuf:"bytes,10,rep,name=containers,proto3" json:"containers,omitempty"` // Init containers first
+	Restarts      int32             `protobuf:"varint,11,opt,name=restarts,proto3" json:"restarts,omitempty"`    // Summed over containers
+	Ready         bool              `protobuf:"varint,12,opt,name=ready,proto3" json:"ready,omitempty"`          // The Ready condition is True
+	StartTime     int64             `protobuf:"varint,13,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ResourceMetrics) Reset() {
-	*x = ResourceMetrics{}
-	mi := &file_proto_agent_proto_msgTypes[1]
+func (x *PodInfo) Reset() {
+	*x = PodInfo{}
+	mi := &file_proto_agent_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ResourceMetrics) String() string {
+func (x *PodInfo) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ResourceMetrics) ProtoMessage() {}
+func (*PodInfo) ProtoMessage() {}
 
-func (x *ResourceMetrics) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_agent_proto_msgTypes[1]
+func (x *PodInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_agent_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -116,77 +413,1893 @@ func (x *ResourceMetrics) ProtoReflect() protoreflect.Message {
 		}
 		return ms
 	}
-	return mi.MessageOf(x)
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PodInfo.ProtoReflect.Descriptor instead.
+func (*PodInfo) Descriptor() ([]byte, []int) {
+	return file_proto_agent_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *PodInfo) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *PodInfo) GetPhase() string {
+	if x != nil {
+		return x.Phase
+	}
+	return ""
+}
+
+func (x *PodInfo) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *PodInfo) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *PodInfo) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *PodInfo) GetNode() string {
+	if x != nil {
+		return x.Node
+	}
+	return ""
+}
+
+func (x *PodInfo) GetPodIp() string {
+	if x != nil {
+		return x.PodIp
+	}
+	return ""
+}
+
+func (x *PodInfo) GetOwners() []*OwnerReference {
+	if x != nil {
+		return x.Owners
+	}
+	return nil
+}
+
+func (x *PodInfo) GetConditions() []*PodCondition {
+	if x != nil {
+		return x.Conditions
+	}
+	return nil
+}
+
+func (x *PodInfo) GetContainers() []*ContainerInfo {
+	if x != nil {
+		return x.Containers
+	}
+	return nil
+}
+
+func (x *PodInfo) GetRestarts() int32 {
+	if x != nil {
+		return x.Restarts
+	}
+	return 0
+}
+
+func (x *PodInfo) GetReady() bool {
+	if x != nil {
+		return x.Ready
+	}
+	return false
+}
+
+func (x *PodInfo) GetStartTime() int64 {
+	if x != nil {
+		return x.StartTime
+	}
+	return 0
+}
+
+// Namespace and resource info
+type ResourceInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Namespace     string                 `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Pods          []string               `protobuf:"bytes,2,rep,name=pods,proto3" json:"pods,omitempty"` // Names of pod_infos, kept for older dashboards
+	Deployments   []string               `protobuf:"bytes,3,rep,name=deployments,proto3" json:"deployments,omitempty"`
+	PodInfos      []*PodInfo             `protobuf:"bytes,4,rep,name=pod_infos,json=podInfos,proto3" json:"pod_infos,omitempty"` // Add more resource types as needed
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResourceInfo) Reset() {
+	*x = ResourceInfo{}
+	mi := &file_proto_agent_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResourceInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResourceInfo) ProtoMessage() {}
+
+func (x *ResourceInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_agent_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResourceInfo.ProtoReflect.Descriptor instead.
+func (*ResourceInfo) Descriptor() ([]byte, []int) {
+	return file_proto_agent_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ResourceInfo) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *ResourceInfo) GetPods() []string {
+	if x != nil {
+		return x.Pods
+	}
+	return nil
+}
+
+func (x *ResourceInfo) GetDeployments() []string {
+	if x != nil {
+		return x.Deployments
+	}
+	return nil
+}
+
+func (x *ResourceInfo) GetPodInfos() []*PodInfo {
+	if x != nil {
+		return x.PodInfos
+	}
+	return nil
+}
+
+// Performance metrics for a resource
+type ResourceMetrics struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Namespace string                 `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Name      string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Kind      string                 `protobuf:"bytes,3,opt,name=kind,proto3" json:"kind,omitempty"`       // Pod, Deployment, etc.
+	Cpu       float64                `protobuf:"fixed64,4,opt,name=cpu,proto3" json:"cpu,omitempty"`       // cores
+	Memory    float64                `protobuf:"fixed64,5,opt,name=memory,proto3" json:"memory,omitempty"` // MiB
+	// Summed over containers, in cores and MiB. A limit is 0 when a container
+	// has none, since the resource is then unbounded.
+	CpuRequest    float64 `protobuf:"fixed64,6,opt,name=cpu_request,json=cpuRequest,proto3" json:"cpu_request,omitempty"`
+	CpuLimit      float64 `protobuf:"fixed64,7,opt,name=cpu_limit,json=cpuLimit,proto3" json:"cpu_limit,omitempty"`
+	MemoryRequest float64 `protobuf:"fixed64,8,opt,name=memory_request,json=memoryRequest,proto3" json:"memory_request,omitempty"`
+	MemoryLimit   float64 `protobuf:"fixed64,9,opt,name=memory_limit,json=memoryLimit,proto3" json:"memory_limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResourceMetrics) Reset() {
+	*x = ResourceMetrics{}
+	mi := &file_proto_agent_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResourceMetrics) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResourceMetrics) ProtoMessage() {}
+
+func (x *ResourceMetrics) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_agent_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResourceMetrics.ProtoReflect.Descriptor instead.
+func (*ResourceMetrics) Descriptor() ([]byte, []int) {
+	return file_proto_agent_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ResourceMetrics) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *ResourceMetrics) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ResourceMetrics) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *ResourceMetrics) GetCpu() float64 {
+	if x != nil {
+		return x.Cpu
+	}
+	return 0
+}
+
+func (x *ResourceMetrics) GetMemory() float64 {
+	if x != nil {
+		return x.Memory
+	}
+	return 0
+}
+
+func (x *ResourceMetrics) GetCpuRequest() float64 {
+	if x != nil {
+		return x.CpuRequest
+	}
+	return 0
+}
+
+func (x *ResourceMetrics) GetCpuLimit() float64 {
+	if x != nil {
+		return x.CpuLimit
+	}
+	return 0
+}
+
+func (x *ResourceMetrics) GetMemoryRequest() float64 {
+	if x != nil {
+		return x.MemoryRequest
+	}
+	return 0
+}
+
+func (x *ResourceMetrics) GetMemoryLimit() float64 {
+	if x != nil {
+		return x.MemoryLimit
+	}
+	return 0
+}
+
+// Pod log entry
+type PodLog struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Namespace     string                 `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	PodName       string                 `protobuf:"bytes,2,opt,name=pod_name,json=podName,proto3" json:"pod_name,omitempty"`
+	ContainerName string                 `protobuf:"bytes,3,opt,name=container_name,json=containerName,proto3" json:"container_name,omitempty"`
+	LogLine       string                 `protobuf:"bytes,4,opt,name=log_line,json=logLine,proto3" json:"log_line,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Level         string                 `protobuf:"bytes,6,opt,name=level,proto3" json:"level,omitempty"`        // INFO, ERROR, WARN, DEBUG
+	Previous      bool                   `protobuf:"varint,7,opt,name=previous,proto3" json:"previous,omitempty"` // From the container's previous instance, e.g. before a crash
+	// Parsed from structured lines (JSON, logfmt, klog, access and error logs):
+	// the line's own fields plus format, message, time and trace_id. Empty for
+	// plain text lines.
+	Fields        map[string]string `protobuf:"bytes,8,rep,name=fields,proto3" json:"fields,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	PodLabels     map[string]string `protobuf:"bytes,9,rep,name=pod_labels,json=podLabels,proto3" json:"pod_labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // The pod's labels when the line was collected
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PodLog) Reset() {
+	*x = PodLog{}
+	mi := &file_proto_agent_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PodLog) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PodLog) ProtoMessage() {}
+
+func (x *PodLog) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_agent_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PodLog.ProtoReflect.Descriptor instead.
+func (*PodLog) Descriptor() ([]byte, []int) {
+	return file_proto_agent_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *PodLog) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *PodLog) GetPodName() string {
+	if x != nil {
+		return x.PodName
+	}
+	return ""
+}
+
+func (x *PodLog) GetContainerName() string {
+	if x != nil {
+		return x.ContainerName
+	}
+	return ""
+}
+
+func (x *PodLog) GetLogLine() string {
+	if x != nil {
+		return x.LogLine
+	}
+	return ""
+}
+
+func (x *PodLog) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *PodLog) GetLevel() string {
+	if x != nil {
+		return x.Level
+	}
+	return ""
+}
+
+func (x *PodLog) GetPrevious() bool {
+	if x != nil {
+		return x.Previous
+	}
+	return false
+}
+
+func (x *PodLog) GetFields() map[string]string {
+	if x != nil {
+		return x.Fields
+	}
+	return nil
+}
+
+func (x *PodLog) GetPodLabels() map[string]string {
+	if x != nil {
+		return x.PodLabels
+	}
+	return nil
+}
+
+// The object a Kubernetes Event is about
+type ObjectReference struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Kind          string                 `protobuf:"bytes,1,opt,name=kind,proto3" json:"kind,omitempty"`
+	Namespace     string                 `protobuf:"bytes,2,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Name          string                 `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	Uid           string                 `protobuf:"bytes,4,opt,name=uid,proto3" json:"uid,omitempty"`
+	FieldPath     string                 `protobuf:"bytes,5,opt,name=field_path,json=fieldPath,proto3" json:"field_path,omitempty"` // e.g. spec.containers{app} for a container
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ObjectReference) Reset() {
+	*x = ObjectReference{}
+	mi := &file_proto_agent_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ObjectReference) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ObjectReference) ProtoMessage() {}
+
+func (x *ObjectReference) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_agent_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ObjectReference.ProtoReflect.Descriptor instead.
+func (*ObjectReference) Descriptor() ([]byte, []int) {
+	return file_proto_agent_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ObjectReference) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *ObjectReference) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *ObjectReference) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ObjectReference) GetUid() string {
+	if x != nil {
+		return x.Uid
+	}
+	return ""
+}
+
+func (x *ObjectReference) GetFieldPath() string {
+	if x != nil {
+		return x.FieldPath
+	}
+	return ""
+}
+
+// A Kubernetes Event, e.g. a failed scheduling or a back-off restarting a
+// container. Timestamps are Unix seconds.
+type Event struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Namespace      string                 `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Name           string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Uid            string                 `protobuf:"bytes,3,opt,name=uid,proto3" json:"uid,omitempty"`
+	Type           string                 `protobuf:"bytes,4,opt,name=type,proto3" json:"type,omitempty"` // Normal or Warning
+	Reason         string                 `protobuf:"bytes,5,opt,name=reason,proto3" json:"reason,omitempty"`
+	Message        string                 `protobuf:"bytes,6,opt,name=message,proto3" json:"message,omitempty"`
+	InvolvedObject *ObjectReference       `protobuf:"bytes,7,opt,name=involved_object,json=involvedObject,proto3" json:"involved_object,omitempty"`
+	Count          int32                  `protobuf:"varint,8,opt,name=count,proto3" json:"count,omitempty"` // How many times it occurred
+	FirstTimestamp int64                  `protobuf:"varint,9,opt,name=first_timestamp,json=firstTimestamp,proto3" json:"first_timestamp,omitempty"`
+	LastTimestamp  int64                  `protobuf:"varint,10,opt,name=last_timestamp,json=lastTimestamp,proto3" json:"last_timestamp,omitempty"`
+	Source         string                 `protobuf:"bytes,11,opt,name=source,proto3" json:"source,omitempty"` // The component that reported it, e.g. kubelet
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_proto_agent_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_agent_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_proto_agent_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Event) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *Event) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Event) GetUid() string {
+	if x != nil {
+		return x.Uid
+	}
+	return ""
+}
+
+func (x *Event) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Event) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *Event) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *Event) GetInvolvedObject() *ObjectReference {
+	if x != nil {
+		return x.InvolvedObject
+	}
+	return nil
+}
+
+func (x *Event) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *Event) GetFirstTimestamp() int64 {
+	if x != nil {
+		return x.FirstTimestamp
+	}
+	return 0
+}
+
+func (x *Event) GetLastTimestamp() int64 {
+	if x != nil {
+		return x.LastTimestamp
+	}
+	return 0
+}
+
+func (x *Event) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+// Identity of the reporting agent and the cluster it runs in
+type AgentIdentity struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ClusterName   string                 `protobuf:"bytes,1,opt,name=cluster_name,json=clusterName,proto3" json:"cluster_name,omitempty"`
+	ClusterUid    string                 `protobuf:"bytes,2,opt,name=cluster_uid,json=clusterUid,proto3" json:"cluster_uid,omitempty"` // UID of the kube-system namespace
+	AgentId       string                 `protobuf:"bytes,3,opt,name=agent_id,json=agentId,proto3" json:"agent_id,omitempty"`
+	AgentVersion  string                 `protobuf:"bytes,4,opt,name=agent_version,json=agentVersion,proto3" json:"agent_version,omitempty"`
+	Hostname      string                 `protobuf:"bytes,5,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AgentIdentity) Reset() {
+	*x = AgentIdentity{}
+	mi := &file_proto_agent_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AgentIdentity) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentIdentity) ProtoMessage() {}
+
+func (x *AgentIdentity) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_agent_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentIdentity.ProtoReflect.Descriptor instead.
+func (*AgentIdentity) Descriptor() ([]byte, []int) {
+	return file_proto_agent_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *AgentIdentity) GetClusterName() string {
+	if x != nil {
+		return x.ClusterName
+	}
+	return ""
+}
+
+func (x *AgentIdentity) GetClusterUid() string {
+	if x != nil {
+		return x.ClusterUid
+	}
+	return ""
+}
+
+func (x *AgentIdentity) GetAgentId() string {
+	if x != nil {
+		return x.AgentId
+	}
+	return ""
+}
+
+func (x *AgentIdentity) GetAgentVersion() string {
+	if x != nil {
+		return x.AgentVersion
+	}
+	return ""
+}
+
+func (x *AgentIdentity) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+// The main data payload sent by the agent
+type AgentData struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Resources     []*ResourceInfo        `protobuf:"bytes,1,rep,name=resources,proto3" json:"resources,omitempty"`
+	Metrics       []*ResourceMetrics     `protobuf:"bytes,2,rep,name=metrics,proto3" json:"metrics,omitempty"`
+	Logs          []*PodLog              `protobuf:"bytes,3,rep,name=logs,proto3" json:"logs,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Identity      *AgentIdentity         `protobuf:"bytes,5,opt,name=identity,proto3" json:"identity,omitempty"`
+	Events        []*Event               `protobuf:"bytes,6,rep,name=events,proto3" json:"events,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AgentData) Reset() {
+	*x = AgentData{}
+	mi := &file_proto_agent_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AgentData) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentData) ProtoMessage() {}
+
+func (x *AgentData) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_agent_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentData.ProtoReflect.Descriptor instead.
+func (*AgentData) Descriptor() ([]byte, []int) {
+	return file_proto_agent_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *AgentData) GetResources() []*ResourceInfo {
+	if x != nil {
+		return x.Resources
+	}
+	return nil
+}
+
+func (x *AgentData) GetMetrics() []*ResourceMetrics {
+	if x != nil {
+		return x.Metrics
+	}
+	return nil
+}
+
+func (x *AgentData) GetLogs() []*PodLog {
+	if x != nil {
+		return x.Logs
+	}
+	return nil
+}
+
+func (x *AgentData) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *AgentData) GetIdentity() *AgentIdentity {
+	if x != nil {
+		return x.Identity
+	}
+	return nil
+}
+
+func (x *AgentData) GetEvents() []*Event {
+	if x != nil {
+		return x.Events
+	}
+	return nil
+}
+
+// Request for pod logs
+type LogRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Namespace     string                 `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	PodName       string                 `protobuf:"bytes,2,opt,name=pod_name,json=podName,proto3" json:"pod_name,omitempty"`
+	ContainerName string                 `protobuf:"bytes,3,opt,name=container_name,json=containerName,proto3" json:"container_name,omitempty"` // Optional, if empty gets all containers
+	TailLines     int32                  `protobuf:"varint,4,opt,name=tail_lines,json=tailLines,proto3" json:"tail_lines,omitempty"`            // Number of lines to fetch, default 100
+	Follow        bool                   `protobuf:"varint,5,opt,name=follow,proto3" json:"follow,omitempty"`                                   // Whether to follow logs in real-time
+	Cluster       string                 `protobuf:"bytes,6,opt,name=cluster,proto3" json:"cluster,omitempty"`                                  // Cluster whose agent relays the logs; required by StreamPodLogs
+	Previous      bool                   `protobuf:"varint,7,opt,name=previous,proto3" json:"previous,omitempty"`                               // Read the previous instance of a restarted container; follow is ignored
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LogRequest) Reset() {
+	*x = LogRequest{}
+	mi := &file_proto_agent_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LogRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogRequest) ProtoMessage() {}
+
+func (x *LogRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_agent_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogRequest.ProtoReflect.Descriptor instead.
+func (*LogRequest) Descriptor() ([]byte, []int) {
+	return file_proto_agent_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *LogRequest) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *LogRequest) GetPodName() string {
+	if x != nil {
+		return x.PodName
+	}
+	return ""
+}
+
+func (x *LogRequest) GetContainerName() string {
+	if x != nil {
+		return x.ContainerName
+	}
+	return ""
+}
+
+func (x *LogRequest) GetTailLines() int32 {
+	if x != nil {
+		return x.TailLines
+	}
+	return 0
+}
+
+func (x *LogRequest) GetFollow() bool {
+	if x != nil {
+		return x.Follow
+	}
+	return false
+}
+
+func (x *LogRequest) GetCluster() string {
+	if x != nil {
+		return x.Cluster
+	}
+	return ""
+}
+
+func (x *LogRequest) GetPrevious() bool {
+	if x != nil {
+		return x.Previous
+	}
+	return false
+}
+
+// Stream of log entries
+type LogStream struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Logs          []*PodLog              `protobuf:"bytes,1,rep,name=logs,proto3" json:"logs,omitempty"`
+	IsComplete    bool                   `protobuf:"varint,2,opt,name=is_complete,json=isComplete,proto3" json:"is_complete,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LogStream) Reset() {
+	*x = LogStream{}
+	mi := &file_proto_agent_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LogStream) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogStream) ProtoMessage() {}
+
+func (x *LogStream) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_agent_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogStream.ProtoReflect.Descriptor instead.
+func (*LogStream) Descriptor() ([]byte, []int) {
+	return file_proto_agent_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *LogStream) GetLogs() []*PodLog {
+	if x != nil {
+		return x.Logs
+	}
+	return nil
+}
+
+func (x *LogStream) GetIsComplete() bool {
+	if x != nil {
+		return x.IsComplete
+	}
+	return false
+}
+
+// Agent registration, sent once when the agent starts
+type RegisterRequest struct {
+	state                 protoimpl.MessageState `protogen:"open.v1"`
+	Identity              *AgentIdentity         `protobuf:"bytes,1,opt,name=identity,proto3" json:"identity,omitempty"`
+	ReportIntervalSeconds int64                  `protobuf:"varint,2,opt,name=report_interval_seconds,json=reportIntervalSeconds,proto3" json:"report_interval_seconds,omitempty"`
+	unknownFields         protoimpl.UnknownFields
+	sizeCache             protoimpl.SizeCache
+}
+
+func (x *RegisterRequest) Reset() {
+	*x = RegisterRequest{}
+	mi := &file_proto_agent_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RegisterRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RegisterRequest) ProtoMessage() {}
+
+func (x *RegisterRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_agent_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RegisterRequest.ProtoReflect.Descriptor instead.
+func (*RegisterRequest) Descriptor() ([]byte, []int) {
+	return file_proto_agent_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *RegisterRequest) GetIdentity() *AgentIdentity {
+	if x != nil {
+		return x.Identity
+	}
+	return nil
+}
+
+func (x *RegisterRequest) GetReportIntervalSeconds() int64 {
+	if x != nil {
+		return x.ReportIntervalSeconds
+	}
+	return 0
+}
+
+type RegisterResponse struct {
+	state                    protoimpl.MessageState `protogen:"open.v1"`
+	Success                  bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Message                  string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	HeartbeatIntervalSeconds int64                  `protobuf:"varint,3,opt,name=heartbeat_interval_seconds,json=heartbeatIntervalSeconds,proto3" json:"heartbeat_interval_seconds,omitempty"` // Interval the agent should heartbeat at
+	unknownFields            protoimpl.UnknownFields
+	sizeCache                protoimpl.SizeCache
+}
+
+func (x *RegisterResponse) Reset() {
+	*x = RegisterResponse{}
+	mi := &file_proto_agent_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RegisterResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RegisterResponse) ProtoMessage() {}
+
+func (x *RegisterResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_agent_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RegisterResponse.ProtoReflect.Descriptor instead.
+func (*RegisterResponse) Descriptor() ([]byte, []int) {
+	return file_proto_agent_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *RegisterResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *RegisterResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *RegisterResponse) GetHeartbeatIntervalSeconds() int64 {
+	if x != nil {
+		return x.HeartbeatIntervalSeconds
+	}
+	return 0
+}
+
+// Periodic liveness signal from a registered agent
+type HeartbeatRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Identity      *AgentIdentity         `protobuf:"bytes,1,opt,name=identity,proto3" json:"identity,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HeartbeatRequest) Reset() {
+	*x = HeartbeatRequest{}
+	mi := &file_proto_agent_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HeartbeatRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HeartbeatRequest) ProtoMessage() {}
+
+func (x *HeartbeatRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_agent_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HeartbeatRequest.ProtoReflect.Descriptor instead.
+func (*HeartbeatRequest) Descriptor() ([]byte, []int) {
+	return file_proto_agent_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *HeartbeatRequest) GetIdentity() *AgentIdentity {
+	if x != nil {
+		return x.Identity
+	}
+	return nil
+}
+
+func (x *HeartbeatRequest) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+type HeartbeatResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HeartbeatResponse) Reset() {
+	*x = HeartbeatResponse{}
+	mi := &file_proto_agent_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HeartbeatResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HeartbeatResponse) ProtoMessage() {}
+
+func (x *HeartbeatResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_agent_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HeartbeatResponse.ProtoReflect.Descriptor instead.
+func (*HeartbeatResponse) Descriptor() ([]byte, []int) {
+	return file_proto_agent_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *HeartbeatResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *HeartbeatResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+// Identifies a ResourceMetrics entry
+type MetricKey struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Namespace     string                 `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Kind          string                 `protobuf:"bytes,3,opt,name=kind,proto3" json:"kind,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MetricKey) Reset() {
+	*x = MetricKey{}
+	mi := &file_proto_agent_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MetricKey) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MetricKey) ProtoMessage() {}
+
+func (x *MetricKey) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_agent_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MetricKey.ProtoReflect.Descriptor instead.
+func (*MetricKey) Descriptor() ([]byte, []int) {
+	return file_proto_agent_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *MetricKey) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *MetricKey) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *MetricKey) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+// Changes to one namespace since the previous report
+type ResourceDelta struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	Namespace          string                 `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Removed            bool                   `protobuf:"varint,2,opt,name=removed,proto3" json:"removed,omitempty"` // The namespace is gone; the other fields are empty
+	AddedPods          []string               `protobuf:"bytes,3,rep,name=added_pods,json=addedPods,proto3" json:"added_pods,omitempty"`
+	RemovedPods        []string               `protobuf:"bytes,4,rep,name=removed_pods,json=removedPods,proto3" json:"removed_pods,omitempty"`
+	AddedDeployments   []string               `protobuf:"bytes,5,rep,name=added_deployments,json=addedDeployments,proto3" json:"added_deployments,omitempty"`
+	RemovedDeployments []string               `protobuf:"bytes,6,rep,name=removed_deployments,json=removedDeployments,proto3" json:"removed_deployments,omitempty"`
+	ChangedPods        []*PodInfo             `protobuf:"bytes,7,rep,name=changed_pods,json=changedPods,proto3" json:"changed_pods,omitempty"` // New pods and pods whose status changed
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *ResourceDelta) Reset() {
+	*x = ResourceDelta{}
+	mi := &file_proto_agent_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResourceDelta) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResourceDelta) ProtoMessage() {}
+
+func (x *ResourceDelta) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_agent_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResourceDelta.ProtoReflect.Descriptor instead.
+func (*ResourceDelta) Descriptor() ([]byte, []int) {
+	return file_proto_agent_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *ResourceDelta) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *ResourceDelta) GetRemoved() bool {
+	if x != nil {
+		return x.Removed
+	}
+	return false
+}
+
+func (x *ResourceDelta) GetAddedPods() []string {
+	if x != nil {
+		return x.AddedPods
+	}
+	return nil
+}
+
+func (x *ResourceDelta) GetRemovedPods() []string {
+	if x != nil {
+		return x.RemovedPods
+	}
+	return nil
+}
+
+func (x *ResourceDelta) GetAddedDeployments() []string {
+	if x != nil {
+		return x.AddedDeployments
+	}
+	return nil
+}
+
+func (x *ResourceDelta) GetRemovedDeployments() []string {
+	if x != nil {
+		return x.RemovedDeployments
+	}
+	return nil
+}
+
+func (x *ResourceDelta) GetChangedPods() []*PodInfo {
+	if x != nil {
+		return x.ChangedPods
+	}
+	return nil
+}
+
+// Incremental report. Each agent session starts with a baseline holding its
+// full state, then sends only what changed, numbering reports consecutively
+// so the server can detect gaps.
+type DeltaReport struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Identity        *AgentIdentity         `protobuf:"bytes,1,opt,name=identity,proto3" json:"identity,omitempty"`
+	Timestamp       int64                  `protobuf:"varint,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	ProtocolVersion uint32                 `protobuf:"varint,3,opt,name=protocol_version,json=protocolVersion,proto3" json:"protocol_version,omitempty"`
+	SessionId       string                 `protobuf:"bytes,4,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"` // New for every agent process
+	Sequence        uint64                 `protobuf:"varint,5,opt,name=sequence,proto3" json:"sequence,omitempty"`                   // 1 for the first report of a session
+	Baseline        bool                   `protobuf:"varint,6,opt,name=baseline,proto3" json:"baseline,omitempty"`
+	// Baseline reports: the full state
+	Resources []*ResourceInfo `protobuf:"bytes,7,rep,name=resources,proto3" json:"resources,omitempty"`
+	// Delta reports: changes since the previous sequence number
+	ResourceDeltas []*ResourceDelta `protobuf:"bytes,8,rep,name=resource_deltas,json=resourceDeltas,proto3" json:"resource_deltas,omitempty"`
+	RemovedMetrics []*MetricKey     `protobuf:"bytes,9,rep,name=removed_metrics,json=removedMetrics,proto3" json:"removed_metrics,omitempty"`
+	// Both: new or changed metrics (all metrics in a baseline) and log lines
+	// not sent before
+	Metrics []*ResourceMetrics `protobuf:"bytes,10,rep,name=metrics,proto3" json:"metrics,omitempty"`
+	Logs    []*PodLog          `protobuf:"bytes,11,rep,name=logs,proto3" json:"logs,omitempty"`
+	// New or changed events (all events in a baseline). Events are never
+	// removed; the server keeps them until they age out.
+	Events        []*Event `protobuf:"bytes,12,rep,name=events,proto3" json:"events,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeltaReport) Reset() {
+	*x = DeltaReport{}
+	mi := &file_proto_agent_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeltaReport) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeltaReport) ProtoMessage() {}
+
+func (x *DeltaReport) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_agent_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeltaReport.ProtoReflect.Descriptor instead.
+func (*DeltaReport) Descriptor() ([]byte, []int) {
+	return file_proto_agent_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *DeltaReport) GetIdentity() *AgentIdentity {
+	if x != nil {
+		return x.Identity
+	}
+	return nil
+}
+
+func (x *DeltaReport) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *DeltaReport) GetProtocolVersion() uint32 {
+	if x != nil {
+		return x.ProtocolVersion
+	}
+	return 0
+}
+
+func (x *DeltaReport) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *DeltaReport) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *DeltaReport) GetBaseline() bool {
+	if x != nil {
+		return x.Baseline
+	}
+	return false
+}
+
+func (x *DeltaReport) GetResources() []*ResourceInfo {
+	if x != nil {
+		return x.Resources
+	}
+	return nil
+}
+
+func (x *DeltaReport) GetResourceDeltas() []*ResourceDelta {
+	if x != nil {
+		return x.ResourceDeltas
+	}
+	return nil
+}
+
+func (x *DeltaReport) GetRemovedMetrics() []*MetricKey {
+	if x != nil {
+		return x.RemovedMetrics
+	}
+	return nil
+}
+
+func (x *DeltaReport) GetMetrics() []*ResourceMetrics {
+	if x != nil {
+		return x.Metrics
+	}
+	return nil
+}
+
+func (x *DeltaReport) GetLogs() []*PodLog {
+	if x != nil {
+		return x.Logs
+	}
+	return nil
+}
+
+func (x *DeltaReport) GetEvents() []*Event {
+	if x != nil {
+		return x.Events
+	}
+	return nil
+}
+
+type DeltaResponse struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Success bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Message string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	// The server can't apply the report; the agent must send a baseline
+	ResyncRequired bool   `protobuf:"varint,3,opt,name=resync_required,json=resyncRequired,proto3" json:"resync_required,omitempty"`
+	Sequence       uint64 `protobuf:"varint,4,opt,name=sequence,proto3" json:"sequence,omitempty"` // Sequence number of the report this answers
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *DeltaResponse) Reset() {
+	*x = DeltaResponse{}
+	mi := &file_proto_agent_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeltaResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeltaResponse) ProtoMessage() {}
+
+func (x *DeltaResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_agent_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeltaResponse.ProtoReflect.Descriptor instead.
+func (*DeltaResponse) Descriptor() ([]byte, []int) {
+	return file_proto_agent_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *DeltaResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *DeltaResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *DeltaResponse) GetResyncRequired() bool {
+	if x != nil {
+		return x.ResyncRequired
+	}
+	return false
+}
+
+func (x *DeltaResponse) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+// First message on a Connect stream
+type StreamHello struct {
+	state                 protoimpl.MessageState `protogen:"open.v1"`
+	Identity              *AgentIdentity         `protobuf:"bytes,1,opt,name=identity,proto3" json:"identity,omitempty"`
+	ReportIntervalSeconds int64                  `protobuf:"varint,2,opt,name=report_interval_seconds,json=reportIntervalSeconds,proto3" json:"report_interval_seconds,omitempty"`
+	unknownFields         protoimpl.UnknownFields
+	sizeCache             protoimpl.SizeCache
+}
+
+func (x *StreamHello) Reset() {
+	*x = StreamHello{}
+	mi := &file_proto_agent_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamHello) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamHello) ProtoMessage() {}
+
+func (x *StreamHello) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_agent_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamHello.ProtoReflect.Descriptor instead.
+func (*StreamHello) Descriptor() ([]byte, []int) {
+	return file_proto_agent_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *StreamHello) GetIdentity() *AgentIdentity {
+	if x != nil {
+		return x.Identity
+	}
+	return nil
+}
+
+func (x *StreamHello) GetReportIntervalSeconds() int64 {
+	if x != nil {
+		return x.ReportIntervalSeconds
+	}
+	return 0
+}
+
+// Something that happened in the agent, e.g. a failed collection
+type AgentEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AgentEvent) Reset() {
+	*x = AgentEvent{}
+	mi := &file_proto_agent_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AgentEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentEvent) ProtoMessage() {}
+
+func (x *AgentEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_agent_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentEvent.ProtoReflect.Descriptor instead.
+func (*AgentEvent) Descriptor() ([]byte, []int) {
+	return file_proto_agent_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *AgentEvent) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *AgentEvent) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *AgentEvent) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+type SetReportInterval struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	IntervalSeconds int64                  `protobuf:"varint,1,opt,name=interval_seconds,json=intervalSeconds,proto3" json:"interval_seconds,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *SetReportInterval) Reset() {
+	*x = SetReportInterval{}
+	mi := &file_proto_agent_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetReportInterval) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetReportInterval) ProtoMessage() {}
+
+func (x *SetReportInterval) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_agent_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetReportInterval.ProtoReflect.Descriptor instead.
+func (*SetReportInterval) Descriptor() ([]byte, []int) {
+	return file_proto_agent_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *SetReportInterval) GetIntervalSeconds() int64 {
+	if x != nil {
+		return x.IntervalSeconds
+	}
+	return 0
+}
+
+type RequestResync struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RequestResync) Reset() {
+	*x = RequestResync{}
+	mi := &file_proto_agent_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RequestResync) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RequestResync) ProtoMessage() {}
+
+func (x *RequestResync) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_agent_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RequestResync.ProtoReflect.Descriptor instead.
+func (*RequestResync) Descriptor() ([]byte, []int) {
+	return file_proto_agent_proto_rawDescGZIP(), []int{25}
+}
+
+// Stops a running tail_logs command
+type CancelCommand struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	CommandId     string                 `protobuf:"bytes,1,opt,name=command_id,json=commandId,proto3" json:"command_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelCommand) Reset() {
+	*x = CancelCommand{}
+	mi := &file_proto_agent_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelCommand) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelCommand) ProtoMessage() {}
+
+func (x *CancelCommand) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_agent_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelCommand.ProtoReflect.Descriptor instead.
+func (*CancelCommand) Descriptor() ([]byte, []int) {
+	return file_proto_agent_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *CancelCommand) GetCommandId() string {
+	if x != nil {
+		return x.CommandId
+	}
+	return ""
+}
+
+// Instruction from the server to an agent
+type Command struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Types that are valid to be assigned to Action:
+	//
+	//	*Command_SetReportInterval
+	//	*Command_Resync
+	//	*Command_FetchLogs
+	//	*Command_TailLogs
+	//	*Command_Cancel
+	Action        isCommand_Action `protobuf_oneof:"action"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Command) Reset() {
+	*x = Command{}
+	mi := &file_proto_agent_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Command) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Command) ProtoMessage() {}
+
+func (x *Command) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_agent_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Command.ProtoReflect.Descriptor instead.
+func (*Command) Descriptor() ([]byte, []int) {
+	return file_proto_agent_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *Command) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
 }
 
-// Deprecated: Use ResourceMetrics.ProtoReflect.Descriptor instead.
-func (*ResourceMetrics) Descriptor() ([]byte, []int) {
-	return file_proto_agent_proto_rawDescGZIP(), []int{1}
+func (x *Command) GetAction() isCommand_Action {
+	if x != nil {
+		return x.Action
+	}
+	return nil
 }
 
-func (x *ResourceMetrics) GetNamespace() string {
+func (x *Command) GetSetReportInterval() *SetReportInterval {
 	if x != nil {
-		return x.Namespace
+		if x, ok := x.Action.(*Command_SetReportInterval); ok {
+			return x.SetReportInterval
+		}
 	}
-	return ""
+	return nil
 }
 
-func (x *ResourceMetrics) GetName() string {
+func (x *Command) GetResync() *RequestResync {
 	if x != nil {
-		return x.Name
+		if x, ok := x.Action.(*Command_Resync); ok {
+			return x.Resync
+		}
 	}
-	return ""
+	return nil
 }
 
-func (x *ResourceMetrics) GetKind() string {
+func (x *Command) GetFetchLogs() *LogRequest {
 	if x != nil {
-		return x.Kind
+		if x, ok := x.Action.(*Command_FetchLogs); ok {
+			return x.FetchLogs
+		}
 	}
-	return ""
+	return nil
 }
 
-func (x *ResourceMetrics) GetCpu() float64 {
+func (x *Command) GetTailLogs() *LogRequest {
 	if x != nil {
-		return x.Cpu
+		if x, ok := x.Action.(*Command_TailLogs); ok {
+			return x.TailLogs
+		}
 	}
-	return 0
+	return nil
 }
 
-func (x *ResourceMetrics) GetMemory() float64 {
+func (x *Command) GetCancel() *CancelCommand {
 	if x != nil {
-		return x.Memory
+		if x, ok := x.Action.(*Command_Cancel); ok {
+			return x.Cancel
+		}
 	}
-	return 0
+	return nil
 }
 
-// Pod log entry
-type PodLog struct {
+type isCommand_Action interface {
+	isCommand_Action()
+}
+
+type Command_SetReportInterval struct {
+	SetReportInterval *SetReportInterval `protobuf:"bytes,2,opt,name=set_report_interval,json=setReportInterval,proto3,oneof"`
+}
+
+type Command_Resync struct {
+	Resync *RequestResync `protobuf:"bytes,3,opt,name=resync,proto3,oneof"`
+}
+
+type Command_FetchLogs struct {
+	FetchLogs *LogRequest `protobuf:"bytes,4,opt,name=fetch_logs,json=fetchLogs,proto3,oneof"` // Follow is ignored
+}
+
+type Command_TailLogs struct {
+	TailLogs *LogRequest `protobuf:"bytes,5,opt,name=tail_logs,json=tailLogs,proto3,oneof"` // Answered with LogChunks until complete or cancelled
+}
+
+type Command_Cancel struct {
+	Cancel *CancelCommand `protobuf:"bytes,6,opt,name=cancel,proto3,oneof"`
+}
+
+func (*Command_SetReportInterval) isCommand_Action() {}
+
+func (*Command_Resync) isCommand_Action() {}
+
+func (*Command_FetchLogs) isCommand_Action() {}
+
+func (*Command_TailLogs) isCommand_Action() {}
+
+func (*Command_Cancel) isCommand_Action() {}
+
+type CommandResult struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Namespace     string                 `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
-	PodName       string                 `protobuf:"bytes,2,opt,name=pod_name,json=podName,proto3" json:"pod_name,omitempty"`
-	ContainerName string                 `protobuf:"bytes,3,opt,name=container_name,json=containerName,proto3" json:"container_name,omitempty"`
-	LogLine       string                 `protobuf:"bytes,4,opt,name=log_line,json=logLine,proto3" json:"log_line,omitempty"`
-	Timestamp     int64                  `protobuf:"varint,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
-	Level         string                 `protobuf:"bytes,6,opt,name=level,proto3" json:"level,omitempty"` // INFO, ERROR, WARN, DEBUG
+	CommandId     string                 `protobuf:"bytes,1,opt,name=command_id,json=commandId,proto3" json:"command_id,omitempty"`
+	Success       bool                   `protobuf:"varint,2,opt,name=success,proto3" json:"success,omitempty"`
+	Message       string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	Logs          []*PodLog              `protobuf:"bytes,4,rep,name=logs,proto3" json:"logs,omitempty"` // For fetch_logs
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *PodLog) Reset() {
-	*x = PodLog{}
-	mi := &file_proto_agent_proto_msgTypes[2]
+func (x *CommandResult) Reset() {
+	*x = CommandResult{}
+	mi := &file_proto_agent_proto_msgTypes[28]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *PodLog) String() string {
+func (x *CommandResult) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*PodLog) ProtoMessage() {}
+func (*CommandResult) ProtoMessage() {}
 
-func (x *PodLog) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_agent_proto_msgTypes[2]
+func (x *CommandResult) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_agent_proto_msgTypes[28]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -197,79 +2310,66 @@ func (x *PodLog) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use PodLog.ProtoReflect.Descriptor instead.
-func (*PodLog) Descriptor() ([]byte, []int) {
-	return file_proto_agent_proto_rawDescGZIP(), []int{2}
-}
-
-func (x *PodLog) GetNamespace() string {
-	if x != nil {
-		return x.Namespace
-	}
-	return ""
+// Deprecated: Use CommandResult.ProtoReflect.Descriptor instead.
+func (*CommandResult) Descriptor() ([]byte, []int) {
+	return file_proto_agent_proto_rawDescGZIP(), []int{28}
 }
 
-func (x *PodLog) GetPodName() string {
+func (x *CommandResult) GetCommandId() string {
 	if x != nil {
-		return x.PodName
+		return x.CommandId
 	}
 	return ""
 }
 
-func (x *PodLog) GetContainerName() string {
+func (x *CommandResult) GetSuccess() bool {
 	if x != nil {
-		return x.ContainerName
+		return x.Success
 	}
-	return ""
+	return false
 }
 
-func (x *PodLog) GetLogLine() string {
+func (x *CommandResult) GetMessage() string {
 	if x != nil {
-		return x.LogLine
+		return x.Message
 	}
 	return ""
 }
 
-func (x *PodLog) GetTimestamp() int64 {
-	if x != nil {
-		return x.Timestamp
-	}
-	return 0
-}
-
-func (x *PodLog) GetLevel() string {
+func (x *CommandResult) GetLogs() []*PodLog {
 	if x != nil {
-		return x.Level
+		return x.Logs
 	}
-	return ""
+	return nil
 }
 
-// The main data payload sent by the agent
-type AgentData struct {
+// Logs relayed for a tail_logs command. The last chunk has is_complete set,
+// and error set if tailing failed.
+type LogChunk struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Resources     []*ResourceInfo        `protobuf:"bytes,1,rep,name=resources,proto3" json:"resources,omitempty"`
-	Metrics       []*ResourceMetrics     `protobuf:"bytes,2,rep,name=metrics,proto3" json:"metrics,omitempty"`
-	Logs          []*PodLog              `protobuf:"bytes,3,rep,name=logs,proto3" json:"logs,omitempty"`
-	Timestamp     int64                  `protobuf:"varint,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	CommandId     string                 `protobuf:"bytes,1,opt,name=command_id,json=commandId,proto3" json:"command_id,omitempty"`
+	Logs          []*PodLog              `protobuf:"bytes,2,rep,name=logs,proto3" json:"logs,omitempty"`
+	IsComplete    bool                   `protobuf:"varint,3,opt,name=is_complete,json=isComplete,proto3" json:"is_complete,omitempty"`
+	Error         string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *AgentData) Reset() {
-	*x = AgentData{}
-	mi := &file_proto_agent_proto_msgTypes[3]
+func (x *LogChunk) Reset() {
+	*x = LogChunk{}
+	mi := &file_proto_agent_proto_msgTypes[29]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *AgentData) String() string {
+func (x *LogChunk) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*AgentData) ProtoMessage() {}
+func (*LogChunk) ProtoMessage() {}
 
-func (x *AgentData) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_agent_proto_msgTypes[3]
+func (x *LogChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_agent_proto_msgTypes[29]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -280,66 +2380,69 @@ func (x *AgentData) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use AgentData.ProtoReflect.Descriptor instead.
-func (*AgentData) Descriptor() ([]byte, []int) {
-	return file_proto_agent_proto_rawDescGZIP(), []int{3}
+// Deprecated: Use LogChunk.ProtoReflect.Descriptor instead.
+func (*LogChunk) Descriptor() ([]byte, []int) {
+	return file_proto_agent_proto_rawDescGZIP(), []int{29}
 }
 
-func (x *AgentData) GetResources() []*ResourceInfo {
+func (x *LogChunk) GetCommandId() string {
 	if x != nil {
-		return x.Resources
+		return x.CommandId
 	}
-	return nil
+	return ""
 }
 
-func (x *AgentData) GetMetrics() []*ResourceMetrics {
+func (x *LogChunk) GetLogs() []*PodLog {
 	if x != nil {
-		return x.Metrics
+		return x.Logs
 	}
 	return nil
 }
 
-func (x *AgentData) GetLogs() []*PodLog {
+func (x *LogChunk) GetIsComplete() bool {
 	if x != nil {
-		return x.Logs
+		return x.IsComplete
 	}
-	return nil
+	return false
 }
 
-func (x *AgentData) GetTimestamp() int64 {
+func (x *LogChunk) GetError() string {
 	if x != nil {
-		return x.Timestamp
+		return x.Error
 	}
-	return 0
+	return ""
 }
 
-// Request for pod logs
-type LogRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Namespace     string                 `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
-	PodName       string                 `protobuf:"bytes,2,opt,name=pod_name,json=podName,proto3" json:"pod_name,omitempty"`
-	ContainerName string                 `protobuf:"bytes,3,opt,name=container_name,json=containerName,proto3" json:"container_name,omitempty"` // Optional, if empty gets all containers
-	TailLines     int32                  `protobuf:"varint,4,opt,name=tail_lines,json=tailLines,proto3" json:"tail_lines,omitempty"`            // Number of lines to fetch, default 100
-	Follow        bool                   `protobuf:"varint,5,opt,name=follow,proto3" json:"follow,omitempty"`                                   // Whether to follow logs in real-time
+// Agent to server message on a Connect stream
+type AgentMessage struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Payload:
+	//
+	//	*AgentMessage_Hello
+	//	*AgentMessage_Report
+	//	*AgentMessage_Event
+	//	*AgentMessage_Result
+	//	*AgentMessage_LogChunk
+	Payload       isAgentMessage_Payload `protobuf_oneof:"payload"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *LogRequest) Reset() {
-	*x = LogRequest{}
-	mi := &file_proto_agent_proto_msgTypes[4]
+func (x *AgentMessage) Reset() {
+	*x = AgentMessage{}
+	mi := &file_proto_agent_proto_msgTypes[30]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *LogRequest) String() string {
+func (x *AgentMessage) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*LogRequest) ProtoMessage() {}
+func (*AgentMessage) ProtoMessage() {}
 
-func (x *LogRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_agent_proto_msgTypes[4]
+func (x *AgentMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_agent_proto_msgTypes[30]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -350,70 +2453,124 @@ func (x *LogRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use LogRequest.ProtoReflect.Descriptor instead.
-func (*LogRequest) Descriptor() ([]byte, []int) {
-	return file_proto_agent_proto_rawDescGZIP(), []int{4}
+// Deprecated: Use AgentMessage.ProtoReflect.Descriptor instead.
+func (*AgentMessage) Descriptor() ([]byte, []int) {
+	return file_proto_agent_proto_rawDescGZIP(), []int{30}
 }
 
-func (x *LogRequest) GetNamespace() string {
+func (x *AgentMessage) GetPayload() isAgentMessage_Payload {
 	if x != nil {
-		return x.Namespace
+		return x.Payload
 	}
-	return ""
+	return nil
 }
 
-func (x *LogRequest) GetPodName() string {
+func (x *AgentMessage) GetHello() *StreamHello {
 	if x != nil {
-		return x.PodName
+		if x, ok := x.Payload.(*AgentMessage_Hello); ok {
+			return x.Hello
+		}
 	}
-	return ""
+	return nil
 }
 
-func (x *LogRequest) GetContainerName() string {
+func (x *AgentMessage) GetReport() *DeltaReport {
 	if x != nil {
-		return x.ContainerName
+		if x, ok := x.Payload.(*AgentMessage_Report); ok {
+			return x.Report
+		}
 	}
-	return ""
+	return nil
 }
 
-func (x *LogRequest) GetTailLines() int32 {
+func (x *AgentMessage) GetEvent() *AgentEvent {
 	if x != nil {
-		return x.TailLines
+		if x, ok := x.Payload.(*AgentMessage_Event); ok {
+			return x.Event
+		}
 	}
-	return 0
+	return nil
 }
 
-func (x *LogRequest) GetFollow() bool {
+func (x *AgentMessage) GetResult() *CommandResult {
 	if x != nil {
-		return x.Follow
+		if x, ok := x.Payload.(*AgentMessage_Result); ok {
+			return x.Result
+		}
 	}
-	return false
+	return nil
 }
 
-// Stream of log entries
-type LogStream struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Logs          []*PodLog              `protobuf:"bytes,1,rep,name=logs,proto3" json:"logs,omitempty"`
-	IsComplete    bool                   `protobuf:"varint,2,opt,name=is_complete,json=isComplete,proto3" json:"is_complete,omitempty"`
+func (x *AgentMessage) GetLogChunk() *LogChunk {
+	if x != nil {
+		if x, ok := x.Payload.(*AgentMessage_LogChunk); ok {
+			return x.LogChunk
+		}
+	}
+	return nil
+}
+
+type isAgentMessage_Payload interface {
+	isAgentMessage_Payload()
+}
+
+type AgentMessage_Hello struct {
+	Hello *StreamHello `protobuf:"bytes,1,opt,name=hello,proto3,oneof"`
+}
+
+type AgentMessage_Report struct {
+	Report *DeltaReport `protobuf:"bytes,2,opt,name=report,proto3,oneof"`
+}
+
+type AgentMessage_Event struct {
+	Event *AgentEvent `protobuf:"bytes,3,opt,name=event,proto3,oneof"`
+}
+
+type AgentMessage_Result struct {
+	Result *CommandResult `protobuf:"bytes,4,opt,name=result,proto3,oneof"`
+}
+
+type AgentMessage_LogChunk struct {
+	LogChunk *LogChunk `protobuf:"bytes,5,opt,name=log_chunk,json=logChunk,proto3,oneof"`
+}
+
+func (*AgentMessage_Hello) isAgentMessage_Payload() {}
+
+func (*AgentMessage_Report) isAgentMessage_Payload() {}
+
+func (*AgentMessage_Event) isAgentMessage_Payload() {}
+
+func (*AgentMessage_Result) isAgentMessage_Payload() {}
+
+func (*AgentMessage_LogChunk) isAgentMessage_Payload() {}
+
+// Server to agent message on a Connect stream
+type ServerMessage struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Payload:
+	//
+	//	*ServerMessage_Ack
+	//	*ServerMessage_Command
+	Payload       isServerMessage_Payload `protobuf_oneof:"payload"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *LogStream) Reset() {
-	*x = LogStream{}
-	mi := &file_proto_agent_proto_msgTypes[5]
+func (x *ServerMessage) Reset() {
+	*x = ServerMessage{}
+	mi := &file_proto_agent_proto_msgTypes[31]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *LogStream) String() string {
+func (x *ServerMessage) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*LogStream) ProtoMessage() {}
+func (*ServerMessage) ProtoMessage() {}
 
-func (x *LogStream) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_agent_proto_msgTypes[5]
+func (x *ServerMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_agent_proto_msgTypes[31]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -424,25 +2581,52 @@ func (x *LogStream) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use LogStream.ProtoReflect.Descriptor instead.
-func (*LogStream) Descriptor() ([]byte, []int) {
-	return file_proto_agent_proto_rawDescGZIP(), []int{5}
+// Deprecated: Use ServerMessage.ProtoReflect.Descriptor instead.
+func (*ServerMessage) Descriptor() ([]byte, []int) {
+	return file_proto_agent_proto_rawDescGZIP(), []int{31}
 }
 
-func (x *LogStream) GetLogs() []*PodLog {
+func (x *ServerMessage) GetPayload() isServerMessage_Payload {
 	if x != nil {
-		return x.Logs
+		return x.Payload
 	}
 	return nil
 }
 
-func (x *LogStream) GetIsComplete() bool {
+func (x *ServerMessage) GetAck() *DeltaResponse {
 	if x != nil {
-		return x.IsComplete
+		if x, ok := x.Payload.(*ServerMessage_Ack); ok {
+			return x.Ack
+		}
 	}
-	return false
+	return nil
+}
+
+func (x *ServerMessage) GetCommand() *Command {
+	if x != nil {
+		if x, ok := x.Payload.(*ServerMessage_Command); ok {
+			return x.Command
+		}
+	}
+	return nil
+}
+
+type isServerMessage_Payload interface {
+	isServerMessage_Payload()
+}
+
+type ServerMessage_Ack struct {
+	Ack *DeltaResponse `protobuf:"bytes,1,opt,name=ack,proto3,oneof"`
+}
+
+type ServerMessage_Command struct {
+	Command *Command `protobuf:"bytes,2,opt,name=command,proto3,oneof"`
 }
 
+func (*ServerMessage_Ack) isServerMessage_Payload() {}
+
+func (*ServerMessage_Command) isServerMessage_Payload() {}
+
 type ReportResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
@@ -453,7 +2637,7 @@ type ReportResponse struct {
 
 func (x *ReportResponse) Reset() {
 	*x = ReportResponse{}
-	mi := &file_proto_agent_proto_msgTypes[6]
+	mi := &file_proto_agent_proto_msgTypes[32]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -465,7 +2649,7 @@ func (x *ReportResponse) String() string {
 func (*ReportResponse) ProtoMessage() {}
 
 func (x *ReportResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_agent_proto_msgTypes[6]
+	mi := &file_proto_agent_proto_msgTypes[32]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -478,7 +2662,7 @@ func (x *ReportResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ReportResponse.ProtoReflect.Descriptor instead.
 func (*ReportResponse) Descriptor() ([]byte, []int) {
-	return file_proto_agent_proto_rawDescGZIP(), []int{6}
+	return file_proto_agent_proto_rawDescGZIP(), []int{32}
 }
 
 func (x *ReportResponse) GetSuccess() bool {
@@ -499,29 +2683,129 @@ var File_proto_agent_proto protoreflect.FileDescriptor
 
 const file_proto_agent_proto_rawDesc = "" +
 	"\n" +
-	"\x11proto/agent.proto\x12\x05agent\"b\n" +
+	"\x11proto/agent.proto\x12\x05agent\"X\n" +
+	"\x0eOwnerReference\x12\x12\n" +
+	"\x04kind\x18\x01 \x01(\tR\x04kind\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x1e\n" +
+	"\n" +
+	"controller\x18\x03 \x01(\bR\n" +
+	"controller\"\x9e\x01\n" +
+	"\fPodCondition\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12\x16\n" +
+	"\x06reason\x18\x03 \x01(\tR\x06reason\x12\x18\n" +
+	"\amessage\x18\x04 \x01(\tR\amessage\x120\n" +
+	"\x14last_transition_time\x18\x05 \x01(\x03R\x12lastTransitionTime\"\xbd\x01\n" +
+	"\x14ContainerTermination\x12\x16\n" +
+	"\x06reason\x18\x01 \x01(\tR\x06reason\x12\x1b\n" +
+	"\texit_code\x18\x02 \x01(\x05R\bexitCode\x12\x16\n" +
+	"\x06signal\x18\x03 \x01(\x05R\x06signal\x12\x18\n" +
+	"\amessage\x18\x04 \x01(\tR\amessage\x12\x1d\n" +
+	"\n" +
+	"started_at\x18\x05 \x01(\x03R\tstartedAt\x12\x1f\n" +
+	"\vfinished_at\x18\x06 \x01(\x03R\n" +
+	"finishedAt\"\xf6\x02\n" +
+	"\rContainerInfo\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05image\x18\x02 \x01(\tR\x05image\x12\x12\n" +
+	"\x04init\x18\x03 \x01(\bR\x04init\x12\x14\n" +
+	"\x05ready\x18\x04 \x01(\bR\x05ready\x12#\n" +
+	"\rrestart_count\x18\x05 \x01(\x05R\frestartCount\x12\x14\n" +
+	"\x05state\x18\x06 \x01(\tR\x05state\x12\x16\n" +
+	"\x06reason\x18\a \x01(\tR\x06reason\x12\x18\n" +
+	"\amessage\x18\b \x01(\tR\amessage\x12\x1d\n" +
+	"\n" +
+	"started_at\x18\t \x01(\x03R\tstartedAt\x12=\n" +
+	"\vtermination\x18\n" +
+	" \x01(\v2\x1b.agent.ContainerTerminationR\vtermination\x12F\n" +
+	"\x10last_termination\x18\v \x01(\v2\x1b.agent.ContainerTerminationR\x0flastTermination\"\x93\x03\n" +
+	"\aPodInfo\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05phase\x18\x02 \x01(\tR\x05phase\x12\x16\n" +
+	"\x06status\x18\x03 \x01(\tR\x06status\x12\x16\n" +
+	"\x06reason\x18\x04 \x01(\tR\x06reason\x12\x18\n" +
+	"\amessage\x18\x05 \x01(\tR\amessage\x12\x12\n" +
+	"\x04node\x18\x06 \x01(\tR\x04node\x12\x15\n" +
+	"\x06pod_ip\x18\a \x01(\tR\x05podIp\x12-\n" +
+	"\x06owners\x18\b \x03(\v2\x15.agent.OwnerReferenceR\x06owners\x123\n" +
+	"\n" +
+	"conditions\x18\t \x03(\v2\x13.agent.PodConditionR\n" +
+	"conditions\x124\n" +
+	"\n" +
+	"containers\x18\n" +
+	" \x03(\v2\x14.agent.ContainerInfoR\n" +
+	"containers\x12\x1a\n" +
+	"\brestarts\x18\v \x01(\x05R\brestarts\x12\x14\n" +
+	"\x05ready\x18\f \x01(\bR\x05ready\x12\x1d\n" +
+	"\n" +
+	"start_time\x18\r \x01(\x03R\tstartTime\"\x8f\x01\n" +
 	"\fResourceInfo\x12\x1c\n" +
 	"\tnamespace\x18\x01 \x01(\tR\tnamespace\x12\x12\n" +
 	"\x04pods\x18\x02 \x03(\tR\x04pods\x12 \n" +
-	"\vdeployments\x18\x03 \x03(\tR\vdeployments\"\x81\x01\n" +
+	"\vdeployments\x18\x03 \x03(\tR\vdeployments\x12+\n" +
+	"\tpod_infos\x18\x04 \x03(\v2\x0e.agent.PodInfoR\bpodInfos\"\x89\x02\n" +
 	"\x0fResourceMetrics\x12\x1c\n" +
 	"\tnamespace\x18\x01 \x01(\tR\tnamespace\x12\x12\n" +
 	"\x04name\x18\x02 \x01(\tR\x04name\x12\x12\n" +
 	"\x04kind\x18\x03 \x01(\tR\x04kind\x12\x10\n" +
 	"\x03cpu\x18\x04 \x01(\x01R\x03cpu\x12\x16\n" +
-	"\x06memory\x18\x05 \x01(\x01R\x06memory\"\xb7\x01\n" +
+	"\x06memory\x18\x05 \x01(\x01R\x06memory\x12\x1f\n" +
+	"\vcpu_request\x18\x06 \x01(\x01R\n" +
+	"cpuRequest\x12\x1b\n" +
+	"\tcpu_limit\x18\a \x01(\x01R\bcpuLimit\x12%\n" +
+	"\x0ememory_request\x18\b \x01(\x01R\rmemoryRequest\x12!\n" +
+	"\fmemory_limit\x18\t \x01(\x01R\vmemoryLimit\"\xbc\x03\n" +
 	"\x06PodLog\x12\x1c\n" +
 	"\tnamespace\x18\x01 \x01(\tR\tnamespace\x12\x19\n" +
 	"\bpod_name\x18\x02 \x01(\tR\apodName\x12%\n" +
 	"\x0econtainer_name\x18\x03 \x01(\tR\rcontainerName\x12\x19\n" +
 	"\blog_line\x18\x04 \x01(\tR\alogLine\x12\x1c\n" +
 	"\ttimestamp\x18\x05 \x01(\x03R\ttimestamp\x12\x14\n" +
-	"\x05level\x18\x06 \x01(\tR\x05level\"\xb1\x01\n" +
+	"\x05level\x18\x06 \x01(\tR\x05level\x12\x1a\n" +
+	"\bprevious\x18\a \x01(\bR\bprevious\x121\n" +
+	"\x06fields\x18\b \x03(\v2\x19.agent.PodLog.FieldsEntryR\x06fields\x12;\n" +
+	"\n" +
+	"pod_labels\x18\t \x03(\v2\x1c.agent.PodLog.PodLabelsEntryR\tpodLabels\x1a9\n" +
+	"\vFieldsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a<\n" +
+	"\x0ePodLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x88\x01\n" +
+	"\x0fObjectReference\x12\x12\n" +
+	"\x04kind\x18\x01 \x01(\tR\x04kind\x12\x1c\n" +
+	"\tnamespace\x18\x02 \x01(\tR\tnamespace\x12\x12\n" +
+	"\x04name\x18\x03 \x01(\tR\x04name\x12\x10\n" +
+	"\x03uid\x18\x04 \x01(\tR\x03uid\x12\x1d\n" +
+	"\n" +
+	"field_path\x18\x05 \x01(\tR\tfieldPath\"\xd0\x02\n" +
+	"\x05Event\x12\x1c\n" +
+	"\tnamespace\x18\x01 \x01(\tR\tnamespace\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x10\n" +
+	"\x03uid\x18\x03 \x01(\tR\x03uid\x12\x12\n" +
+	"\x04type\x18\x04 \x01(\tR\x04type\x12\x16\n" +
+	"\x06reason\x18\x05 \x01(\tR\x06reason\x12\x18\n" +
+	"\amessage\x18\x06 \x01(\tR\amessage\x12?\n" +
+	"\x0finvolved_object\x18\a \x01(\v2\x16.agent.ObjectReferenceR\x0einvolvedObject\x12\x14\n" +
+	"\x05count\x18\b \x01(\x05R\x05count\x12'\n" +
+	"\x0ffirst_timestamp\x18\t \x01(\x03R\x0efirstTimestamp\x12%\n" +
+	"\x0elast_timestamp\x18\n" +
+	" \x01(\x03R\rlastTimestamp\x12\x16\n" +
+	"\x06source\x18\v \x01(\tR\x06source\"\xaf\x01\n" +
+	"\rAgentIdentity\x12!\n" +
+	"\fcluster_name\x18\x01 \x01(\tR\vclusterName\x12\x1f\n" +
+	"\vcluster_uid\x18\x02 \x01(\tR\n" +
+	"clusterUid\x12\x19\n" +
+	"\bagent_id\x18\x03 \x01(\tR\aagentId\x12#\n" +
+	"\ragent_version\x18\x04 \x01(\tR\fagentVersion\x12\x1a\n" +
+	"\bhostname\x18\x05 \x01(\tR\bhostname\"\x89\x02\n" +
 	"\tAgentData\x121\n" +
 	"\tresources\x18\x01 \x03(\v2\x13.agent.ResourceInfoR\tresources\x120\n" +
 	"\ametrics\x18\x02 \x03(\v2\x16.agent.ResourceMetricsR\ametrics\x12!\n" +
 	"\x04logs\x18\x03 \x03(\v2\r.agent.PodLogR\x04logs\x12\x1c\n" +
-	"\ttimestamp\x18\x04 \x01(\x03R\ttimestamp\"\xa3\x01\n" +
+	"\ttimestamp\x18\x04 \x01(\x03R\ttimestamp\x120\n" +
+	"\bidentity\x18\x05 \x01(\v2\x14.agent.AgentIdentityR\bidentity\x12$\n" +
+	"\x06events\x18\x06 \x03(\v2\f.agent.EventR\x06events\"\xd9\x01\n" +
 	"\n" +
 	"LogRequest\x12\x1c\n" +
 	"\tnamespace\x18\x01 \x01(\tR\tnamespace\x12\x19\n" +
@@ -529,18 +2813,117 @@ const file_proto_agent_proto_rawDesc = "" +
 	"\x0econtainer_name\x18\x03 \x01(\tR\rcontainerName\x12\x1d\n" +
 	"\n" +
 	"tail_lines\x18\x04 \x01(\x05R\ttailLines\x12\x16\n" +
-	"\x06follow\x18\x05 \x01(\bR\x06follow\"O\n" +
+	"\x06follow\x18\x05 \x01(\bR\x06follow\x12\x18\n" +
+	"\acluster\x18\x06 \x01(\tR\acluster\x12\x1a\n" +
+	"\bprevious\x18\a \x01(\bR\bprevious\"O\n" +
 	"\tLogStream\x12!\n" +
 	"\x04logs\x18\x01 \x03(\v2\r.agent.PodLogR\x04logs\x12\x1f\n" +
 	"\vis_complete\x18\x02 \x01(\bR\n" +
-	"isComplete\"D\n" +
+	"isComplete\"{\n" +
+	"\x0fRegisterRequest\x120\n" +
+	"\bidentity\x18\x01 \x01(\v2\x14.agent.AgentIdentityR\bidentity\x126\n" +
+	"\x17report_interval_seconds\x18\x02 \x01(\x03R\x15reportIntervalSeconds\"\x84\x01\n" +
+	"\x10RegisterResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12<\n" +
+	"\x1aheartbeat_interval_seconds\x18\x03 \x01(\x03R\x18heartbeatIntervalSeconds\"b\n" +
+	"\x10HeartbeatRequest\x120\n" +
+	"\bidentity\x18\x01 \x01(\v2\x14.agent.AgentIdentityR\bidentity\x12\x1c\n" +
+	"\ttimestamp\x18\x02 \x01(\x03R\ttimestamp\"G\n" +
+	"\x11HeartbeatResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"Q\n" +
+	"\tMetricKey\x12\x1c\n" +
+	"\tnamespace\x18\x01 \x01(\tR\tnamespace\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x12\n" +
+	"\x04kind\x18\x03 \x01(\tR\x04kind\"\x9a\x02\n" +
+	"\rResourceDelta\x12\x1c\n" +
+	"\tnamespace\x18\x01 \x01(\tR\tnamespace\x12\x18\n" +
+	"\aremoved\x18\x02 \x01(\bR\aremoved\x12\x1d\n" +
+	"\n" +
+	"added_pods\x18\x03 \x03(\tR\taddedPods\x12!\n" +
+	"\fremoved_pods\x18\x04 \x03(\tR\vremovedPods\x12+\n" +
+	"\x11added_deployments\x18\x05 \x03(\tR\x10addedDeployments\x12/\n" +
+	"\x13removed_deployments\x18\x06 \x03(\tR\x12removedDeployments\x121\n" +
+	"\fchanged_pods\x18\a \x03(\v2\x0e.agent.PodInfoR\vchangedPods\"\x87\x04\n" +
+	"\vDeltaReport\x120\n" +
+	"\bidentity\x18\x01 \x01(\v2\x14.agent.AgentIdentityR\bidentity\x12\x1c\n" +
+	"\ttimestamp\x18\x02 \x01(\x03R\ttimestamp\x12)\n" +
+	"\x10protocol_version\x18\x03 \x01(\rR\x0fprotocolVersion\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x04 \x01(\tR\tsessionId\x12\x1a\n" +
+	"\bsequence\x18\x05 \x01(\x04R\bsequence\x12\x1a\n" +
+	"\bbaseline\x18\x06 \x01(\bR\bbaseline\x121\n" +
+	"\tresources\x18\a \x03(\v2\x13.agent.ResourceInfoR\tresources\x12=\n" +
+	"\x0fresource_deltas\x18\b \x03(\v2\x14.agent.ResourceDeltaR\x0eresourceDeltas\x129\n" +
+	"\x0fremoved_metrics\x18\t \x03(\v2\x10.agent.MetricKeyR\x0eremovedMetrics\x120\n" +
+	"\ametrics\x18\n" +
+	" \x03(\v2\x16.agent.ResourceMetricsR\ametrics\x12!\n" +
+	"\x04logs\x18\v \x03(\v2\r.agent.PodLogR\x04logs\x12$\n" +
+	"\x06events\x18\f \x03(\v2\f.agent.EventR\x06events\"\x88\x01\n" +
+	"\rDeltaResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12'\n" +
+	"\x0fresync_required\x18\x03 \x01(\bR\x0eresyncRequired\x12\x1a\n" +
+	"\bsequence\x18\x04 \x01(\x04R\bsequence\"w\n" +
+	"\vStreamHello\x120\n" +
+	"\bidentity\x18\x01 \x01(\v2\x14.agent.AgentIdentityR\bidentity\x126\n" +
+	"\x17report_interval_seconds\x18\x02 \x01(\x03R\x15reportIntervalSeconds\"X\n" +
+	"\n" +
+	"AgentEvent\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x1c\n" +
+	"\ttimestamp\x18\x03 \x01(\x03R\ttimestamp\">\n" +
+	"\x11SetReportInterval\x12)\n" +
+	"\x10interval_seconds\x18\x01 \x01(\x03R\x0fintervalSeconds\"\x0f\n" +
+	"\rRequestResync\".\n" +
+	"\rCancelCommand\x12\x1d\n" +
+	"\n" +
+	"command_id\x18\x01 \x01(\tR\tcommandId\"\xb5\x02\n" +
+	"\aCommand\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12J\n" +
+	"\x13set_report_interval\x18\x02 \x01(\v2\x18.agent.SetReportIntervalH\x00R\x11setReportInterval\x12.\n" +
+	"\x06resync\x18\x03 \x01(\v2\x14.agent.RequestResyncH\x00R\x06resync\x122\n" +
+	"\n" +
+	"fetch_logs\x18\x04 \x01(\v2\x11.agent.LogRequestH\x00R\tfetchLogs\x120\n" +
+	"\ttail_logs\x18\x05 \x01(\v2\x11.agent.LogRequestH\x00R\btailLogs\x12.\n" +
+	"\x06cancel\x18\x06 \x01(\v2\x14.agent.CancelCommandH\x00R\x06cancelB\b\n" +
+	"\x06action\"\x85\x01\n" +
+	"\rCommandResult\x12\x1d\n" +
+	"\n" +
+	"command_id\x18\x01 \x01(\tR\tcommandId\x12\x18\n" +
+	"\asuccess\x18\x02 \x01(\bR\asuccess\x12\x18\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\x12!\n" +
+	"\x04logs\x18\x04 \x03(\v2\r.agent.PodLogR\x04logs\"\x83\x01\n" +
+	"\bLogChunk\x12\x1d\n" +
+	"\n" +
+	"command_id\x18\x01 \x01(\tR\tcommandId\x12!\n" +
+	"\x04logs\x18\x02 \x03(\v2\r.agent.PodLogR\x04logs\x12\x1f\n" +
+	"\vis_complete\x18\x03 \x01(\bR\n" +
+	"isComplete\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\"\xfe\x01\n" +
+	"\fAgentMessage\x12*\n" +
+	"\x05hello\x18\x01 \x01(\v2\x12.agent.StreamHelloH\x00R\x05hello\x12,\n" +
+	"\x06report\x18\x02 \x01(\v2\x12.agent.DeltaReportH\x00R\x06report\x12)\n" +
+	"\x05event\x18\x03 \x01(\v2\x11.agent.AgentEventH\x00R\x05event\x12.\n" +
+	"\x06result\x18\x04 \x01(\v2\x14.agent.CommandResultH\x00R\x06result\x12.\n" +
+	"\tlog_chunk\x18\x05 \x01(\v2\x0f.agent.LogChunkH\x00R\blogChunkB\t\n" +
+	"\apayload\"p\n" +
+	"\rServerMessage\x12(\n" +
+	"\x03ack\x18\x01 \x01(\v2\x14.agent.DeltaResponseH\x00R\x03ack\x12*\n" +
+	"\acommand\x18\x02 \x01(\v2\x0e.agent.CommandH\x00R\acommandB\t\n" +
+	"\apayload\"D\n" +
 	"\x0eReportResponse\x12\x18\n" +
 	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
-	"\amessage\x18\x02 \x01(\tR\amessage2~\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage2\xf3\x02\n" +
 	"\rAgentReporter\x125\n" +
 	"\n" +
 	"ReportData\x12\x10.agent.AgentData\x1a\x15.agent.ReportResponse\x126\n" +
-	"\rStreamPodLogs\x12\x11.agent.LogRequest\x1a\x10.agent.LogStream0\x01B\x11Z\x0f./proto;agentpbb\x06proto3"
+	"\rStreamPodLogs\x12\x11.agent.LogRequest\x1a\x10.agent.LogStream0\x01\x12@\n" +
+	"\rRegisterAgent\x12\x16.agent.RegisterRequest\x1a\x17.agent.RegisterResponse\x12>\n" +
+	"\tHeartbeat\x12\x17.agent.HeartbeatRequest\x1a\x18.agent.HeartbeatResponse\x127\n" +
+	"\vReportDelta\x12\x12.agent.DeltaReport\x1a\x14.agent.DeltaResponse\x128\n" +
+	"\aConnect\x12\x13.agent.AgentMessage\x1a\x14.agent.ServerMessage(\x010\x01B\x11Z\x0f./proto;agentpbb\x06proto3"
 
 var (
 	file_proto_agent_proto_rawDescOnce sync.Once
@@ -554,30 +2937,102 @@ func file_proto_agent_proto_rawDescGZIP() []byte {
 	return file_proto_agent_proto_rawDescData
 }
 
-var file_proto_agent_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_proto_agent_proto_msgTypes = make([]protoimpl.MessageInfo, 35)
 var file_proto_agent_proto_goTypes = []any{
-	(*ResourceInfo)(nil),    // 0: agent.ResourceInfo
-	(*ResourceMetrics)(nil), // 1: agent.ResourceMetrics
-	(*PodLog)(nil),          // 2: agent.PodLog
-	(*AgentData)(nil),       // 3: agent.AgentData
-	(*LogRequest)(nil),      // 4: agent.LogRequest
-	(*LogStream)(nil),       // 5: agent.LogStream
-	(*ReportResponse)(nil),  // 6: agent.ReportResponse
+	(*OwnerReference)(nil),       // 0: agent.OwnerReference
+	(*PodCondition)(nil),         // 1: agent.PodCondition
+	(*ContainerTermination)(nil), // 2: agent.ContainerTermination
+	(*ContainerInfo)(nil),        // 3: agent.ContainerInfo
+	(*PodInfo)(nil),              // 4: agent.PodInfo
+	(*ResourceInfo)(nil),         // 5: agent.ResourceInfo
+	(*ResourceMetrics)(nil),      // 6: agent.ResourceMetrics
+	(*PodLog)(nil),               // 7: agent.PodLog
+	(*ObjectReference)(nil),      // 8: agent.ObjectReference
+	(*Event)(nil),                // 9: agent.Event
+	(*AgentIdentity)(nil),        // 10: agent.AgentIdentity
+	(*AgentData)(nil),            // 11: agent.AgentData
+	(*LogRequest)(nil),           // 12: agent.LogRequest
+	(*LogStream)(nil),            // 13: agent.LogStream
+	(*RegisterRequest)(nil),      // 14: agent.RegisterRequest
+	(*RegisterResponse)(nil),     // 15: agent.RegisterResponse
+	(*HeartbeatRequest)(nil),     // 16: agent.HeartbeatRequest
+	(*HeartbeatResponse)(nil),    // 17: agent.HeartbeatResponse
+	(*MetricKey)(nil),            // 18: agent.MetricKey
+	(*ResourceDelta)(nil),        // 19: agent.ResourceDelta
+	(*DeltaReport)(nil),          // 20: agent.DeltaReport
+	(*DeltaResponse)(nil),        // 21: agent.DeltaResponse
+	(*StreamHello)(nil),          // 22: agent.StreamHello
+	(*AgentEvent)(nil),           // 23: agent.AgentEvent
+	(*SetReportInterval)(nil),    // 24: agent.SetReportInterval
+	(*RequestResync)(nil),        // 25: agent.RequestResync
+	(*CancelCommand)(nil),        // 26: agent.CancelCommand
+	(*Command)(nil),              // 27: agent.Command
+	(*CommandResult)(nil),        // 28: agent.CommandResult
+	(*LogChunk)(nil),             // 29: agent.LogChunk
+	(*AgentMessage)(nil),         // 30: agent.AgentMessage
+	(*ServerMessage)(nil),        // 31: agent.ServerMessage
+	(*ReportResponse)(nil),       // 32: agent.ReportResponse
+	nil,                          // 33: agent.PodLog.FieldsEntry
+	nil,                          // 34: agent.PodLog.PodLabelsEntry
 }
 var file_proto_agent_proto_depIdxs = []int32{
-	0, // 0: agent.AgentData.resources:type_name -> agent.ResourceInfo
-	1, // 1: agent.AgentData.metrics:type_name -> agent.ResourceMetrics
-	2, // 2: agent.AgentData.logs:type_name -> agent.PodLog
-	2, // 3: agent.LogStream.logs:type_name -> agent.PodLog
-	3, // 4: agent.AgentReporter.ReportData:input_type -> agent.AgentData
-	4, // 5: agent.AgentReporter.StreamPodLogs:input_type -> agent.LogRequest
-	6, // 6: agent.AgentReporter.ReportData:output_type -> agent.ReportResponse
-	5, // 7: agent.AgentReporter.StreamPodLogs:output_type -> agent.LogStream
-	6, // [6:8] is the sub-list for method output_type
-	4, // [4:6] is the sub-list for method input_type
-	4, // [4:4] is the sub-list for extension type_name
-	4, // [4:4] is the sub-list for extension extendee
-	0, // [0:4] is the sub-list for field type_name
+	2,  // 0: agent.ContainerInfo.termination:type_name -> agent.ContainerTermination
+	2,  // 1: agent.ContainerInfo.last_termination:type_name -> agent.ContainerTermination
+	0,  // 2: agent.PodInfo.owners:type_name -> agent.OwnerReference
+	1,  // 3: agent.PodInfo.conditions:type_name -> agent.PodCondition
+	3,  // 4: agent.PodInfo.containers:type_name -> agent.ContainerInfo
+	4,  // 5: agent.ResourceInfo.pod_infos:type_name -> agent.PodInfo
+	33, // 6: agent.PodLog.fields:type_name -> agent.PodLog.FieldsEntry
+	34, // 7: agent.PodLog.pod_labels:type_name -> agent.PodLog.PodLabelsEntry
+	8,  // 8: agent.Event.involved_object:type_name -> agent.ObjectReference
+	5,  // 9: agent.AgentData.resources:type_name -> agent.ResourceInfo
+	6,  // 10: agent.AgentData.metrics:type_name -> agent.ResourceMetrics
+	7,  // 11: agent.AgentData.logs:type_name -> agent.PodLog
+	10, // 12: agent.AgentData.identity:type_name -> agent.AgentIdentity
+	9,  // 13: agent.AgentData.events:type_name -> agent.Event
+	7,  // 14: agent.LogStream.logs:type_name -> agent.PodLog
+	10, // 15: agent.RegisterRequest.identity:type_name -> agent.AgentIdentity
+	10, // 16: agent.HeartbeatRequest.identity:type_name -> agent.AgentIdentity
+	4,  // 17: agent.ResourceDelta.changed_pods:type_name -> agent.PodInfo
+	10, // 18: agent.DeltaReport.identity:type_name -> agent.AgentIdentity
+	5,  // 19: agent.DeltaReport.resources:type_name -> agent.ResourceInfo
+	19, // 20: agent.DeltaReport.resource_deltas:type_name -> agent.ResourceDelta
+	18, // 21: agent.DeltaReport.removed_metrics:type_name -> agent.MetricKey
+	6,  // 22: agent.DeltaReport.metrics:type_name -> agent.ResourceMetrics
+	7,  // 23: agent.DeltaReport.logs:type_name -> agent.PodLog
+	9,  // 24: agent.DeltaReport.events:type_name -> agent.Event
+	10, // 25: agent.StreamHello.identity:type_name -> agent.AgentIdentity
+	24, // 26: agent.Command.set_report_interval:type_name -> agent.SetReportInterval
+	25, // 27: agent.Command.resync:type_name -> agent.RequestResync
+	12, // 28: agent.Command.fetch_logs:type_name -> agent.LogRequest
+	12, // 29: agent.Command.tail_logs:type_name -> agent.LogRequest
+	26, // 30: agent.Command.cancel:type_name -> agent.CancelCommand
+	7,  // 31: agent.CommandResult.logs:type_name -> agent.PodLog
+	7,  // 32: agent.LogChunk.logs:type_name -> agent.PodLog
+	22, // 33: agent.AgentMessage.hello:type_name -> agent.StreamHello
+	20, // 34: agent.AgentMessage.report:type_name -> agent.DeltaReport
+	23, // 35: agent.AgentMessage.event:type_name -> agent.AgentEvent
+	28, // 36: agent.AgentMessage.result:type_name -> agent.CommandResult
+	29, // 37: agent.AgentMessage.log_chunk:type_name -> agent.LogChunk
+	21, // 38: agent.ServerMessage.ack:type_name -> agent.DeltaResponse
+	27, // 39: agent.ServerMessage.command:type_name -> agent.Command
+	11, // 40: agent.AgentReporter.ReportData:input_type -> agent.AgentData
+	12, // 41: agent.AgentReporter.StreamPodLogs:input_type -> agent.LogRequest
+	14, // 42: agent.AgentReporter.RegisterAgent:input_type -> agent.RegisterRequest
+	16, // 43: agent.AgentReporter.Heartbeat:input_type -> agent.HeartbeatRequest
+	20, // 44: agent.AgentReporter.ReportDelta:input_type -> agent.DeltaReport
+	30, // 45: agent.AgentReporter.Connect:input_type -> agent.AgentMessage
+	32, // 46: agent.AgentReporter.ReportData:output_type -> agent.ReportResponse
+	13, // 47: agent.AgentReporter.StreamPodLogs:output_type -> agent.LogStream
+	15, // 48: agent.AgentReporter.RegisterAgent:output_type -> agent.RegisterResponse
+	17, // 49: agent.AgentReporter.Heartbeat:output_type -> agent.HeartbeatResponse
+	21, // 50: agent.AgentReporter.ReportDelta:output_type -> agent.DeltaResponse
+	31, // 51: agent.AgentReporter.Connect:output_type -> agent.ServerMessage
+	46, // [46:52] is the sub-list for method output_type
+	40, // [40:46] is the sub-list for method input_type
+	40, // [40:40] is the sub-list for extension type_name
+	40, // [40:40] is the sub-list for extension extendee
+	0,  // [0:40] is the sub-list for field type_name
 }
 
 func init() { file_proto_agent_proto_init() }
@@ -585,13 +3040,31 @@ func file_proto_agent_proto_init() {
 	if File_proto_agent_proto != nil {
 		return
 	}
+	file_proto_agent_proto_msgTypes[27].OneofWrappers = []any{
+		(*Command_SetReportInterval)(nil),
+		(*Command_Resync)(nil),
+		(*Command_FetchLogs)(nil),
+		(*Command_TailLogs)(nil),
+		(*Command_Cancel)(nil),
+	}
+	file_proto_agent_proto_msgTypes[30].OneofWrappers = []any{
+		(*AgentMessage_Hello)(nil),
+		(*AgentMessage_Report)(nil),
+		(*AgentMessage_Event)(nil),
+		(*AgentMessage_Result)(nil),
+		(*AgentMessage_LogChunk)(nil),
+	}
+	file_proto_agent_proto_msgTypes[31].OneofWrappers = []any{
+		(*ServerMessage_Ack)(nil),
+		(*ServerMessage_Command)(nil),
+	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_agent_proto_rawDesc), len(file_proto_agent_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   7,
+			NumMessages:   35,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
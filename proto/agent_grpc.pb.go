@@ -21,6 +21,10 @@ const _ = grpc.SupportPackageIsVersion9
 const (
 	AgentReporter_ReportData_FullMethodName    = "/agent.AgentReporter/ReportData"
 	AgentReporter_StreamPodLogs_FullMethodName = "/agent.AgentReporter/StreamPodLogs"
+	AgentReporter_RegisterAgent_FullMethodName = "/agent.AgentReporter/RegisterAgent"
+	AgentReporter_Heartbeat_FullMethodName     = "/agent.AgentReporter/Heartbeat"
+	AgentReporter_ReportDelta_FullMethodName   = "/agent.AgentReporter/ReportDelta"
+	AgentReporter_Connect_FullMethodName       = "/agent.AgentReporter/Connect"
 )
 
 // AgentReporterClient is the client API for AgentReporter service.
@@ -31,6 +35,12 @@ const (
 type AgentReporterClient interface {
 	ReportData(ctx context.Context, in *AgentData, opts ...grpc.CallOption) (*ReportResponse, error)
 	StreamPodLogs(ctx context.Context, in *LogRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[LogStream], error)
+	RegisterAgent(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error)
+	Heartbeat(ctx context.Context, in *HeartbeatRequest, opts ...grpc.CallOption) (*HeartbeatResponse, error)
+	ReportDelta(ctx context.Context, in *DeltaReport, opts ...grpc.CallOption) (*DeltaResponse, error)
+	// Long-lived channel: the agent streams reports and events, the server
+	// acknowledges reports and sends commands
+	Connect(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[AgentMessage, ServerMessage], error)
 }
 
 type agentReporterClient struct {
@@ -70,6 +80,49 @@ func (c *agentReporterClient) StreamPodLogs(ctx context.Context, in *LogRequest,
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type AgentReporter_StreamPodLogsClient = grpc.ServerStreamingClient[LogStream]
 
+func (c *agentReporterClient) RegisterAgent(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RegisterResponse)
+	err := c.cc.Invoke(ctx, AgentReporter_RegisterAgent_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *agentReporterClient) Heartbeat(ctx context.Context, in *HeartbeatRequest, opts ...grpc.CallOption) (*HeartbeatResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HeartbeatResponse)
+	err := c.cc.Invoke(ctx, AgentReporter_Heartbeat_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *agentReporterClient) ReportDelta(ctx context.Context, in *DeltaReport, opts ...grpc.CallOption) (*DeltaResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeltaResponse)
+	err := c.cc.Invoke(ctx, AgentReporter_ReportDelta_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *agentReporterClient) Connect(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[AgentMessage, ServerMessage], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &AgentReporter_ServiceDesc.Streams[1], AgentReporter_Connect_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[AgentMessage, ServerMessage]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type AgentReporter_ConnectClient = grpc.BidiStreamingClient[AgentMessage, ServerMessage]
+
 // AgentReporterServer is the server API for AgentReporter service.
 // All implementations must embed UnimplementedAgentReporterServer
 // for forward compatibility.
@@ -78,6 +131,12 @@ type AgentReporter_StreamPodLogsClient = grpc.ServerStreamingClient[LogStream]
 type AgentReporterServer interface {
 	ReportData(context.Context, *AgentData) (*ReportResponse, error)
 	StreamPodLogs(*LogRequest, grpc.ServerStreamingServer[LogStream]) error
+	RegisterAgent(context.Context, *RegisterRequest) (*RegisterResponse, error)
+	Heartbeat(context.Context, *HeartbeatRequest) (*HeartbeatResponse, error)
+	ReportDelta(context.Context, *DeltaReport) (*DeltaResponse, error)
+	// Long-lived channel: the agent streams reports and events, the server
+	// acknowledges reports and sends commands
+	Connect(grpc.BidiStreamingServer[AgentMessage, ServerMessage]) error
 	mustEmbedUnimplementedAgentReporterServer()
 }
 
@@ -94,6 +153,18 @@ func (UnimplementedAgentReporterServer) ReportData(context.Context, *AgentData)
 func (UnimplementedAgentReporterServer) StreamPodLogs(*LogRequest, grpc.ServerStreamingServer[LogStream]) error {
 	return status.Errorf(codes.Unimplemented, "method StreamPodLogs not implemented")
 }
+func (UnimplementedAgentReporterServer) RegisterAgent(context.Context, *RegisterRequest) (*RegisterResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterAgent not implemented")
+}
+func (UnimplementedAgentReporterServer) Heartbeat(context.Context, *HeartbeatRequest) (*HeartbeatResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Heartbeat not implemented")
+}
+func (UnimplementedAgentReporterServer) ReportDelta(context.Context, *DeltaReport) (*DeltaResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReportDelta not implemented")
+}
+func (UnimplementedAgentReporterServer) Connect(grpc.BidiStreamingServer[AgentMessage, ServerMessage]) error {
+	return status.Errorf(codes.Unimplemented, "method Connect not implemented")
+}
 func (UnimplementedAgentReporterServer) mustEmbedUnimplementedAgentReporterServer() {}
 func (UnimplementedAgentReporterServer) testEmbeddedByValue()                       {}
 
@@ -144,6 +215,67 @@ func _AgentReporter_StreamPodLogs_Handler(srv interface{}, stream grpc.ServerStr
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type AgentReporter_StreamPodLogsServer = grpc.ServerStreamingServer[LogStream]
 
+func _AgentReporter_RegisterAgent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentReporterServer).RegisterAgent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AgentReporter_RegisterAgent_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentReporterServer).RegisterAgent(ctx, req.(*RegisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AgentReporter_Heartbeat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HeartbeatRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentReporterServer).Heartbeat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AgentReporter_Heartbeat_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentReporterServer).Heartbeat(ctx, req.(*HeartbeatRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AgentReporter_ReportDelta_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeltaReport)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentReporterServer).ReportDelta(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AgentReporter_ReportDelta_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentReporterServer).ReportDelta(ctx, req.(*DeltaReport))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AgentReporter_Connect_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(AgentReporterServer).Connect(&grpc.GenericServerStream[AgentMessage, ServerMessage]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type AgentReporter_ConnectServer = grpc.BidiStreamingServer[AgentMessage, ServerMessage]
+
 // AgentReporter_ServiceDesc is the grpc.ServiceDesc for AgentReporter service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -155,6 +287,18 @@ var AgentReporter_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ReportData",
 			Handler:    _AgentReporter_ReportData_Handler,
 		},
+		{
+			MethodName: "RegisterAgent",
+			Handler:    _AgentReporter_RegisterAgent_Handler,
+		},
+		{
+			MethodName: "Heartbeat",
+			Handler:    _AgentReporter_Heartbeat_Handler,
+		},
+		{
+			MethodName: "ReportDelta",
+			Handler:    _AgentReporter_ReportDelta_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -162,6 +306,12 @@ var AgentReporter_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _AgentReporter_StreamPodLogs_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "Connect",
+			Handler:       _AgentReporter_Connect_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 	},
 	Metadata: "proto/agent.proto",
 }